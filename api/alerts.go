@@ -0,0 +1,303 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"snapp-task/db"
+	"time"
+)
+
+// maxAlertWindow caps how many recent checks a rule looks at.
+const maxAlertWindow = 100
+
+type AlertRuleRequest struct {
+	Name string `json:"name"`
+	// MonitorID limits the rule to one monitor; without it the rule applies to every monitor of the tenant.
+	MonitorID int64           `json:"monitor_id,omitempty"`
+	On        db.AlertTrigger `json:"on,omitempty"`
+	// Threshold of the last Window checks have to be bad to fire; Window defaults to Threshold.
+	Threshold     int   `json:"threshold,omitempty"`
+	Window        int   `json:"window,omitempty"`
+	ResolveAfter  int   `json:"resolve_after,omitempty"`
+	FlapThreshold int   `json:"flap_threshold,omitempty"`
+	TenantID      int64 `json:"tenant_id,omitempty"`
+}
+
+type AlertRuleResponse struct {
+	ID int64 `json:"id"`
+	AlertRuleRequest
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func toAlertRuleResponse(rule db.AlertRule) AlertRuleResponse {
+	return AlertRuleResponse{ID: rule.ID, CreatedAt: rule.CreatedAt, AlertRuleRequest: AlertRuleRequest{
+		Name:          rule.Name,
+		MonitorID:     rule.MonitorID,
+		On:            rule.On,
+		Threshold:     rule.Threshold,
+		Window:        rule.Window,
+		ResolveAfter:  rule.ResolveAfter,
+		FlapThreshold: rule.FlapThreshold,
+		TenantID:      rule.TenantID,
+	}}
+}
+
+type AlertResponse struct {
+	RuleID    int64          `json:"rule_id"`
+	RuleName  string         `json:"rule_name"`
+	MonitorID int64          `json:"monitor_id"`
+	TenantID  int64          `json:"tenant_id"`
+	Status    db.AlertStatus `json:"status"`
+	Flapping  bool           `json:"flapping,omitempty"`
+	Silenced  bool           `json:"silenced,omitempty"`
+	// History holds the outcomes of the checks in the rule's window, oldest first, as 1 for bad and 0 for good.
+	History   string    `json:"history"`
+	Since     time.Time `json:"since"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SilenceRequest struct {
+	// MonitorID limits the silence to one monitor; without it every monitor of the tenant is silenced.
+	MonitorID int64 `json:"monitor_id,omitempty"`
+	// StartsAt defaults to now; a later start schedules a maintenance window.
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   time.Time  `json:"ends_at"`
+	Comment  string     `json:"comment,omitempty"`
+	TenantID int64      `json:"tenant_id,omitempty"`
+}
+
+type SilenceResponse struct {
+	ID        int64     `json:"id"`
+	MonitorID int64     `json:"monitor_id,omitempty"`
+	TenantID  int64     `json:"tenant_id"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Comment   string    `json:"comment,omitempty"`
+	Active    bool      `json:"active"`
+}
+
+func toSilenceResponse(silence db.Silence, now time.Time) SilenceResponse {
+	return SilenceResponse{
+		ID:        silence.ID,
+		MonitorID: silence.MonitorID,
+		TenantID:  silence.TenantID,
+		StartsAt:  silence.StartsAt,
+		EndsAt:    silence.EndsAt,
+		Comment:   silence.Comment,
+		Active:    silence.Active(now),
+	}
+}
+
+// applyDefaults fills in the trigger, window and resolve_after the request leaves out.
+func (req *AlertRuleRequest) applyDefaults() {
+	if req.On == "" {
+		req.On = db.AlertOnFailure
+	}
+	if req.Threshold == 0 {
+		req.Threshold = 1
+	}
+	if req.Window == 0 {
+		req.Window = req.Threshold
+	}
+	if req.ResolveAfter == 0 {
+		req.ResolveAfter = 1
+	}
+}
+
+func validateAlertRule(req AlertRuleRequest) []FieldError {
+	var fields []FieldError
+	if req.Name == "" {
+		fields = append(fields, FieldError{Field: "name", Code: codeRequired, Message: "name is required"})
+	}
+	if req.On != db.AlertOnFailure && req.On != db.AlertOnMatch {
+		fields = append(fields, FieldError{Field: "on", Code: codeUnsupported, Message: "on must be failure or match"})
+	}
+	if req.Threshold < 1 {
+		fields = append(fields, FieldError{Field: "threshold", Code: codeOutOfRange, Message: "threshold must be at least 1"})
+	}
+	if req.Window < req.Threshold || req.Window > maxAlertWindow {
+		fields = append(fields, FieldError{Field: "window", Code: codeOutOfRange,
+			Message: fmt.Sprintf("window must be between threshold and %d", maxAlertWindow)})
+	}
+	if req.ResolveAfter < 1 || req.ResolveAfter > maxAlertWindow {
+		fields = append(fields, FieldError{Field: "resolve_after", Code: codeOutOfRange,
+			Message: fmt.Sprintf("resolve_after must be between 1 and %d", maxAlertWindow)})
+	}
+	if req.FlapThreshold != 0 && (req.FlapThreshold < 2 || req.FlapThreshold >= req.Window) {
+		fields = append(fields, FieldError{Field: "flap_threshold", Code: codeOutOfRange,
+			Message: "flap_threshold must be between 2 and window - 1, or 0 to disable flap detection"})
+	}
+	return fields
+}
+
+// scopeToMonitor checks the monitor is visible to *tenantID and moves *tenantID to the monitor's tenant.
+func (s *APIServer) scopeToMonitor(monitorID int64, tenantID *int64) *FieldError {
+	monitor, err := s.registry.Get(*tenantID, monitorID)
+	if err != nil {
+		return &FieldError{Field: "monitor_id", Code: codeUnknownMonitor, Message: "monitor_id does not name a monitor"}
+	}
+	*tenantID = monitor.TenantID
+	return nil
+}
+
+func writeAlertError(writer http.ResponseWriter, err error, notFound string) {
+	if errors.Is(err, db.ErrNotFound) {
+		writeJson(writer, http.StatusNotFound, apiError{Error: notFound})
+		return
+	}
+	writeJson(writer, http.StatusInternalServerError, apiError{Error: err.Error()})
+}
+
+func (s *APIServer) HandleCreateAlertRule(writer http.ResponseWriter, request *http.Request) {
+	var req AlertRuleRequest
+	if err := s.decodeJSON(writer, request, &req); err != nil {
+		writeDecodeError(writer, err)
+		return
+	}
+	req.applyDefaults()
+	fields := validateAlertRule(req)
+	if field := scopeToCaller(request, &req.TenantID); field != nil {
+		fields = append(fields, *field)
+	} else if req.MonitorID != 0 {
+		if field := s.scopeToMonitor(req.MonitorID, &req.TenantID); field != nil {
+			fields = append(fields, *field)
+		}
+	}
+	if len(fields) > 0 {
+		writeJson(writer, http.StatusBadRequest, newValidationError(fields))
+		return
+	}
+	rule, err := s.Alerts.CreateRule(db.AlertRule{
+		TenantID:      req.TenantID,
+		Name:          req.Name,
+		MonitorID:     req.MonitorID,
+		On:            req.On,
+		Threshold:     req.Threshold,
+		Window:        req.Window,
+		ResolveAfter:  req.ResolveAfter,
+		FlapThreshold: req.FlapThreshold,
+	})
+	if err != nil {
+		writeAlertError(writer, err, "Alert rule not found")
+		return
+	}
+	writeJson(writer, http.StatusCreated, toAlertRuleResponse(rule))
+}
+
+func (s *APIServer) HandleListAlertRules(writer http.ResponseWriter, request *http.Request) {
+	rules := s.Alerts.Rules(callerTenant(request))
+	response := make([]AlertRuleResponse, 0, len(rules))
+	for _, rule := range rules {
+		response = append(response, toAlertRuleResponse(rule))
+	}
+	writeJson(writer, http.StatusOK, response)
+}
+
+func (s *APIServer) HandleDeleteAlertRule(writer http.ResponseWriter, request *http.Request) {
+	id, err := pathID(request)
+	if err != nil {
+		writeJson(writer, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	if err = s.Alerts.DeleteRule(callerTenant(request), id); err != nil {
+		writeAlertError(writer, err, "Alert rule not found")
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListAlerts returns where every rule stands for each monitor it has seen checked. ?status=firing
+// lists only the alerts that are firing.
+func (s *APIServer) HandleListAlerts(writer http.ResponseWriter, request *http.Request) {
+	status := db.AlertStatus(request.URL.Query().Get("status"))
+	if status != "" && status != db.AlertOK && status != db.AlertFiring {
+		writeJson(writer, http.StatusBadRequest, apiError{Error: "status must be ok or firing"})
+		return
+	}
+	response := []AlertResponse{}
+	for _, alert := range s.Alerts.Alerts(callerTenant(request)) {
+		if status != "" && alert.Status != status {
+			continue
+		}
+		response = append(response, AlertResponse{
+			RuleID:    alert.RuleID,
+			RuleName:  alert.RuleName,
+			MonitorID: alert.MonitorID,
+			TenantID:  alert.TenantID,
+			Status:    alert.Status,
+			Flapping:  alert.Flapping,
+			Silenced:  alert.Silenced,
+			History:   alert.History,
+			Since:     alert.Since,
+			UpdatedAt: alert.UpdatedAt,
+		})
+	}
+	writeJson(writer, http.StatusOK, response)
+}
+
+func (s *APIServer) HandleCreateSilence(writer http.ResponseWriter, request *http.Request) {
+	var req SilenceRequest
+	if err := s.decodeJSON(writer, request, &req); err != nil {
+		writeDecodeError(writer, err)
+		return
+	}
+	now := time.Now()
+	startsAt := now
+	if req.StartsAt != nil {
+		startsAt = *req.StartsAt
+	}
+	var fields []FieldError
+	if req.EndsAt.IsZero() {
+		fields = append(fields, FieldError{Field: "ends_at", Code: codeRequired, Message: "ends_at is required"})
+	} else if !req.EndsAt.After(startsAt) || !req.EndsAt.After(now) {
+		fields = append(fields, FieldError{Field: "ends_at", Code: codeOutOfRange, Message: "ends_at must be after starts_at and in the future"})
+	}
+	if field := scopeToCaller(request, &req.TenantID); field != nil {
+		fields = append(fields, *field)
+	} else if req.MonitorID != 0 {
+		if field := s.scopeToMonitor(req.MonitorID, &req.TenantID); field != nil {
+			fields = append(fields, *field)
+		}
+	}
+	if len(fields) > 0 {
+		writeJson(writer, http.StatusBadRequest, newValidationError(fields))
+		return
+	}
+	silence, err := s.Alerts.CreateSilence(db.Silence{
+		TenantID:  req.TenantID,
+		MonitorID: req.MonitorID,
+		StartsAt:  startsAt,
+		EndsAt:    req.EndsAt,
+		Comment:   req.Comment,
+		CreatedBy: callerID(request),
+	})
+	if err != nil {
+		writeAlertError(writer, err, "Silence not found")
+		return
+	}
+	writeJson(writer, http.StatusCreated, toSilenceResponse(silence, now))
+}
+
+func (s *APIServer) HandleListSilences(writer http.ResponseWriter, request *http.Request) {
+	now := time.Now()
+	silences := s.Alerts.Silences(callerTenant(request))
+	response := make([]SilenceResponse, 0, len(silences))
+	for _, silence := range silences {
+		response = append(response, toSilenceResponse(silence, now))
+	}
+	writeJson(writer, http.StatusOK, response)
+}
+
+func (s *APIServer) HandleDeleteSilence(writer http.ResponseWriter, request *http.Request) {
+	id, err := pathID(request)
+	if err != nil {
+		writeJson(writer, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	if err = s.Alerts.DeleteSilence(callerTenant(request), id); err != nil {
+		writeAlertError(writer, err, "Silence not found")
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,142 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"snapp-task/api"
+	"snapp-task/db"
+	"snapp-task/services"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Alerts", func() {
+	var (
+		handler  http.Handler
+		registry *services.Registry
+		alerter  *services.Alerter
+		global   string
+		acme     string
+	)
+
+	do := func(method, target, secret string, body any) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, bytes.NewReader(payload))
+		req.Header.Set("X-API-Key", secret)
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		mockStore := newMonitorStore(
+			db.Monitor{ID: 1, URL: "https://a.com", Pattern: "a", Interval: time.Minute, TenantID: 2},
+			db.Monitor{ID: 2, URL: "https://b.com", Pattern: "b", Interval: time.Minute, TenantID: 1},
+		)
+		schedulerFactory := func(monitor db.Monitor, database db.DB) services.CheckScheduler {
+			return &services.CheckSchedulerMock{ScheduleCheckFunc: func(ctx context.Context) {}}
+		}
+		registry = services.NewRegistry(mockStore, &db.DBMock{}, schedulerFactory)
+		var rules []db.AlertRule
+		var silences []db.Silence
+		alerter = services.NewAlerter(&db.AlertStoreMock{
+			CreateAlertRuleFunc: func(rule db.AlertRule) (db.AlertRule, error) {
+				rule.ID = int64(len(rules) + 1)
+				rules = append(rules, rule)
+				return rule, nil
+			},
+			DeleteAlertRuleFunc: func(id int64) error { return nil },
+			SaveAlertStateFunc:  func(state db.AlertState) error { return nil },
+			CreateSilenceFunc: func(silence db.Silence) (db.Silence, error) {
+				silence.ID = int64(len(silences) + 1)
+				silences = append(silences, silence)
+				return silence, nil
+			},
+			DeleteSilenceFunc: func(id int64) error { return nil },
+		}, services.NewEventBus(10))
+		keys := services.NewAPIKeys(newKeyStore())
+		var err error
+		_, global, err = keys.Create("root", db.ScopeWrite, 0)
+		Expect(err).NotTo(HaveOccurred())
+		_, acme, err = keys.Create("acme", db.ScopeWrite, 2)
+		Expect(err).NotTo(HaveOccurred())
+		server := api.NewAPIServer(":8080", registry)
+		server.Keys = keys
+		server.Alerts = alerter
+		handler = server.Routes()
+	})
+
+	AfterEach(func() {
+		registry.StopAll()
+	})
+
+	It("should create rules with defaults in the monitor's tenant", func() {
+		recorder := do("POST", "/alert-rules", global, api.AlertRuleRequest{Name: "a down", MonitorID: 1, Threshold: 3, Window: 5})
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+		var created api.AlertRuleResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &created)).To(Succeed())
+		Expect(created.AlertRuleRequest).To(Equal(api.AlertRuleRequest{Name: "a down", MonitorID: 1, On: db.AlertOnFailure,
+			Threshold: 3, Window: 5, ResolveAfter: 1, TenantID: 2}))
+
+		recorder = do("GET", "/alert-rules", acme, nil)
+		Expect(recorder.Body.String()).To(ContainSubstring(`"name":"a down"`))
+		Expect(do("DELETE", "/alert-rules/1", acme, nil).Code).To(Equal(http.StatusNoContent))
+		Expect(do("DELETE", "/alert-rules/1", acme, nil).Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should reject invalid rules", func() {
+		recorder := do("POST", "/alert-rules", acme, api.AlertRuleRequest{On: "latency", Threshold: 3, Window: 2, FlapThreshold: 1, MonitorID: 2})
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		var response api.ValidationError
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+		var fields []string
+		for _, field := range response.Fields {
+			fields = append(fields, field.Field+":"+field.Code)
+		}
+		Expect(fields).To(ConsistOf("name:required", "on:unsupported", "window:out_of_range", "flap_threshold:out_of_range",
+			"monitor_id:unknown_monitor"))
+	})
+
+	It("should list the alerts the rules raised", func() {
+		Expect(do("POST", "/alert-rules", acme, api.AlertRuleRequest{Name: "down"}).Code).To(Equal(http.StatusCreated))
+		alerter.Observe(services.Event{Type: services.EventCheck, MonitorID: 1, TenantID: 2, Error: "down", Time: time.Now()})
+
+		recorder := do("GET", "/alerts?status=firing", acme, nil)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		var alerts []api.AlertResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &alerts)).To(Succeed())
+		Expect(alerts).To(HaveLen(1))
+		Expect(alerts[0].RuleName).To(Equal("down"))
+		Expect(alerts[0].History).To(Equal("1"))
+
+		Expect(do("GET", "/alerts?status=ok", acme, nil).Body.String()).To(MatchJSON(`[]`))
+		Expect(do("GET", "/alerts?status=pending", acme, nil).Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should create silences that start now unless scheduled", func() {
+		endsAt := time.Now().Add(time.Hour)
+		recorder := do("POST", "/silences", acme, api.SilenceRequest{EndsAt: endsAt, Comment: "deploy"})
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+		var created api.SilenceResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &created)).To(Succeed())
+		Expect(created.Active).To(BeTrue())
+		Expect(created.TenantID).To(Equal(int64(2)))
+
+		startsAt := endsAt.Add(time.Hour)
+		recorder = do("POST", "/silences", acme, api.SilenceRequest{MonitorID: 1, StartsAt: &startsAt, EndsAt: endsAt})
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"field":"ends_at"`))
+
+		Expect(do("GET", "/silences", global, nil).Body.String()).To(ContainSubstring(`"comment":"deploy"`))
+		Expect(do("DELETE", "/silences/1", global, nil).Code).To(Equal(http.StatusNoContent))
+		Expect(do("GET", "/silences", acme, nil).Body.String()).To(MatchJSON(`[]`))
+	})
+})
@@ -1,12 +1,14 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"net/url"
-	"regexp"
 	"snapp-task/db"
+	"snapp-task/notifier"
 	"snapp-task/services"
 	"time"
 )
@@ -21,78 +23,260 @@ func writeJson(w http.ResponseWriter, status int, data any) error {
 	return json.NewEncoder(w).Encode(data)
 }
 
+type duplicateError struct {
+	Error string `json:"error"`
+	ID    int64  `json:"id"`
+}
+
+type quotaError struct {
+	Error string `json:"error"`
+	Quota string `json:"quota"`
+	Limit int64  `json:"limit"`
+}
+
 type APIServer struct {
-	addr             string
-	db               db.DB
-	schedulerFactory services.SchedulerFactory
+	addr        string
+	registry    *services.Registry
+	server      *http.Server
+	idempotency *idempotencyCache
+	// MaxBodyBytes caps the size of request bodies; larger requests get 413.
+	MaxBodyBytes int64
+	// Keys authenticates requests. When nil, authentication is disabled.
+	Keys *services.APIKeys
+	// Tenants enables the tenant admin endpoints.
+	Tenants db.TenantStore
+	// Egress rejects monitors whose URL the checker would refuse to fetch.
+	Egress *services.EgressPolicy
+	// RateLimits throttles each client per route pattern; routes without an entry use DefaultRateLimit.
+	// A zero limit disables throttling.
+	RateLimits       map[string]RateLimit
+	DefaultRateLimit RateLimit
+	// AuthFailureLimit throttles requests with a missing or invalid API key per client address. Once it
+	// is spent, every request from the address gets 429 until it refills. A zero limit disables it.
+	AuthFailureLimit RateLimit
+	authFailures     *services.RateLimiter
+	// Events enables the event streams and the control channel.
+	Events *services.EventBus
+	// AllowedOrigins lists the origins, as scheme://host, whose pages may open the control channel
+	// besides the API's own.
+	AllowedOrigins []string
+	// Alerts enables the alert rule, alert and silence endpoints.
+	Alerts *services.Alerter
+	// Notifier enables the notification channel endpoints.
+	Notifier *notifier.Notifier
+	shutdown chan struct{}
 }
 
-func NewAPIServer(addr string, db db.DB, schedulerFactory services.SchedulerFactory) *APIServer {
-	return &APIServer{
-		addr:             addr,
-		db:               db,
-		schedulerFactory: schedulerFactory,
+func NewAPIServer(addr string, registry *services.Registry) *APIServer {
+	s := &APIServer{
+		addr:         addr,
+		registry:     registry,
+		server:       &http.Server{Addr: addr},
+		idempotency:  newIdempotencyCache(idempotencyTTL),
+		MaxBodyBytes: defaultMaxBodyBytes,
+		shutdown:     make(chan struct{}),
 	}
+	// Shutdown waits for active requests, so long-lived streams have to be told to end.
+	s.server.RegisterOnShutdown(func() { close(s.shutdown) })
+	return s
 }
 
-func (s *APIServer) Run() error {
+func (s *APIServer) Routes() http.Handler {
 	router := http.NewServeMux()
-	router.HandleFunc("POST /", s.HandleRequest)
-	server := &http.Server{
-		Addr:    s.addr,
-		Handler: router,
+	if s.AuthFailureLimit.PerSecond > 0 {
+		s.authFailures = services.NewRateLimiter(s.AuthFailureLimit.PerSecond, s.AuthFailureLimit.Burst)
+	}
+	s.handle(router, "POST /", db.ScopeWrite, s.HandleRequest)
+	s.handle(router, "POST /monitors:bulk", db.ScopeWrite, s.HandleBulkCreate)
+	s.handle(router, "GET /monitors:export", db.ScopeRead, s.HandleExport)
+	s.handle(router, "POST /monitors:import", db.ScopeWrite, s.HandleImport)
+	s.handle(router, "GET /monitors/{id}", db.ScopeRead, s.HandleGetMonitor)
+	s.handle(router, "PUT /monitors/{id}", db.ScopeWrite, s.HandleUpdateMonitor)
+	s.handle(router, "PATCH /monitors/{id}", db.ScopeWrite, s.HandleUpdateMonitor)
+	s.handle(router, "GET /monitors/{id}/revisions", db.ScopeRead, s.HandleListRevisions)
+	s.handle(router, "GET /monitors/{id}/values", db.ScopeRead, s.HandleListValues)
+	s.handle(router, "POST /monitors/{id}/run", db.ScopeWrite, s.HandleRunMonitor)
+	s.handle(router, "POST /check:dry-run", db.ScopeWrite, s.HandleDryRun)
+	if s.Events != nil {
+		s.handle(router, "GET /events", db.ScopeRead, s.HandleEvents)
+		s.handle(router, "GET /monitors/{id}/events", db.ScopeRead, s.HandleMonitorEvents)
+		s.handle(router, "GET /control", db.ScopeRead, s.HandleControl)
+	}
+	if s.Keys != nil {
+		s.handle(router, "POST /keys", db.ScopeAdmin, s.HandleCreateKey)
+		s.handle(router, "GET /keys", db.ScopeAdmin, s.HandleListKeys)
+		s.handle(router, "DELETE /keys/{id}", db.ScopeAdmin, s.HandleRevokeKey)
 	}
+	if s.Alerts != nil {
+		s.handle(router, "POST /alert-rules", db.ScopeWrite, s.HandleCreateAlertRule)
+		s.handle(router, "GET /alert-rules", db.ScopeRead, s.HandleListAlertRules)
+		s.handle(router, "DELETE /alert-rules/{id}", db.ScopeWrite, s.HandleDeleteAlertRule)
+		s.handle(router, "GET /alerts", db.ScopeRead, s.HandleListAlerts)
+		s.handle(router, "POST /silences", db.ScopeWrite, s.HandleCreateSilence)
+		s.handle(router, "GET /silences", db.ScopeRead, s.HandleListSilences)
+		s.handle(router, "DELETE /silences/{id}", db.ScopeWrite, s.HandleDeleteSilence)
+	}
+	if s.Notifier != nil {
+		s.handle(router, "POST /channels", db.ScopeWrite, s.HandleCreateChannel)
+		s.handle(router, "GET /channels", db.ScopeRead, s.HandleListChannels)
+		s.handle(router, "DELETE /channels/{id}", db.ScopeWrite, s.HandleDeleteChannel)
+		s.handle(router, "POST /channels/{id}/test", db.ScopeWrite, s.HandleTestChannel)
+	}
+	if s.Tenants != nil {
+		s.handle(router, "POST /tenants", db.ScopeAdmin, s.HandleCreateTenant)
+		s.handle(router, "GET /tenants", db.ScopeAdmin, s.HandleListTenants)
+		s.handle(router, "GET /tenants/{id}", db.ScopeRead, s.HandleGetTenant)
+		s.handle(router, "PUT /tenants/{id}", db.ScopeAdmin, s.HandleUpdateTenant)
+	}
+	return router
+}
+
+func (s *APIServer) Run() error {
+	s.server.Handler = s.Routes()
 	log.Println("Starting server on ", s.addr)
-	return server.ListenAndServe()
+	return s.server.ListenAndServe()
+}
+
+func (s *APIServer) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
 }
 
 type RequestMessage struct {
-	URL      string `json:"url"`
-	Interval int    `json:"interval"`
-	Pattern  string `json:"pattern"`
+	URL       string `json:"url" yaml:"url"`
+	Interval  int    `json:"interval" yaml:"interval"`
+	Pattern   string `json:"pattern" yaml:"pattern"`
+	Dedup     bool   `json:"dedup,omitempty" yaml:"dedup,omitempty"`
+	TenantID  int64  `json:"tenant_id,omitempty" yaml:"tenant_id,omitempty"`
+	Selector  string `json:"selector,omitempty" yaml:"selector,omitempty"`
+	XPath     string `json:"xpath,omitempty" yaml:"xpath,omitempty"`
+	CSVColumn string `json:"csv_column,omitempty" yaml:"csv_column,omitempty"`
+	ValuePath string `json:"value_path,omitempty" yaml:"value_path,omitempty"`
+	Condition string `json:"condition,omitempty" yaml:"condition,omitempty"`
+	Rule      string `json:"rule,omitempty" yaml:"rule,omitempty"`
+	// Steps make a multi-step monitor, whose url defaults to the first step's.
+	Steps []StepMessage `json:"steps,omitempty" yaml:"steps,omitempty"`
 }
 
-func validateURL(input string) bool {
-	parsedURL, err := url.ParseRequestURI(input)
-	if err != nil {
-		return false
+type StepMessage struct {
+	Name    string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Method  string            `json:"method,omitempty" yaml:"method,omitempty"`
+	URL     string            `json:"url" yaml:"url"`
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body    string            `json:"body,omitempty" yaml:"body,omitempty"`
+	Extract map[string]string `json:"extract,omitempty" yaml:"extract,omitempty"`
+	Status  int               `json:"status,omitempty" yaml:"status,omitempty"`
+	Pattern string            `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Rule    string            `json:"rule,omitempty" yaml:"rule,omitempty"`
+}
+
+func (req RequestMessage) toMonitor() db.Monitor {
+	var steps db.Steps
+	for _, step := range req.Steps {
+		steps = append(steps, db.Step(step))
+	}
+	url := req.URL
+	if url == "" && len(steps) > 0 {
+		url = steps[0].URL
+	}
+	return db.Monitor{
+		URL:       url,
+		Pattern:   req.Pattern,
+		Interval:  time.Duration(req.Interval) * time.Second,
+		Dedup:     req.Dedup,
+		TenantID:  req.TenantID,
+		Selector:  req.Selector,
+		XPath:     req.XPath,
+		CSVColumn: req.CSVColumn,
+		ValuePath: req.ValuePath,
+		Condition: req.Condition,
+		Rule:      req.Rule,
+		Steps:     steps,
 	}
-	return parsedURL.Scheme == "http" || parsedURL.Scheme == "https"
 }
 
-func validatePattern(pattern string) bool {
-	if pattern == "" {
-		return false
+func fromMonitor(monitor db.Monitor) RequestMessage {
+	var steps []StepMessage
+	for _, step := range monitor.Steps {
+		steps = append(steps, StepMessage(step))
 	}
-	if _, err := regexp.Compile(pattern); err != nil {
-		return false
+	return RequestMessage{
+		URL:       monitor.URL,
+		Interval:  int(monitor.Interval / time.Second),
+		Pattern:   monitor.Pattern,
+		Dedup:     monitor.Dedup,
+		TenantID:  monitor.TenantID,
+		Selector:  monitor.Selector,
+		XPath:     monitor.XPath,
+		CSVColumn: monitor.CSVColumn,
+		ValuePath: monitor.ValuePath,
+		Condition: monitor.Condition,
+		Rule:      monitor.Rule,
+		Steps:     steps,
 	}
-	return true
 }
 
-func validateInterval(input int) bool {
-	return input >= 1
+type CreateResponse struct {
+	ID int64 `json:"id"`
 }
 
 func (s *APIServer) HandleRequest(writer http.ResponseWriter, request *http.Request) {
 	var req RequestMessage
-	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
-		writeJson(writer, http.StatusBadRequest, apiError{Error: err.Error()})
+	if err := s.decodeJSON(writer, request, &req); err != nil {
+		writeDecodeError(writer, err)
+		return
+	}
+	if fields := s.validateScoped(request, &req); len(fields) > 0 {
+		writeJson(writer, http.StatusBadRequest, newValidationError(fields))
+		return
+	}
+	monitor := req.toMonitor()
+	monitor.CreatedBy = callerID(request)
+	fingerprint := services.MonitorKey(monitor)
+	idempotencyKey := request.Header.Get(idempotencyKeyHeader)
+	if idempotencyKey != "" {
+		idempotencyKey = fmt.Sprintf("%d:%s", monitor.CreatedBy, idempotencyKey)
+		if entry, ok := s.idempotency.Get(idempotencyKey); ok {
+			if entry.fingerprint != fingerprint {
+				writeJson(writer, http.StatusUnprocessableEntity, apiError{Error: "Idempotency-Key was used with a different request"})
+				return
+			}
+			writeJson(writer, http.StatusOK, CreateResponse{ID: entry.monitorID})
+			return
+		}
+	}
+	created, err := s.registry.Create(monitor)
+	if err != nil {
+		writeCreateError(writer, err)
+		return
+	}
+	if idempotencyKey != "" {
+		s.idempotency.Put(idempotencyKey, fingerprint, created.ID)
+	}
+	writeJson(writer, http.StatusOK, CreateResponse{ID: created.ID})
+}
+
+func writeCreateError(writer http.ResponseWriter, err error) {
+	var duplicate *services.DuplicateMonitorError
+	if errors.As(err, &duplicate) {
+		writeJson(writer, http.StatusConflict, duplicateError{Error: "Monitor already exists", ID: duplicate.ExistingID})
 		return
 	}
-	if !validateURL(req.URL) {
-		writeJson(writer, http.StatusBadRequest, apiError{Error: "Invalid url"})
+	var quota *services.QuotaError
+	if errors.As(err, &quota) {
+		writeJson(writer, http.StatusForbidden, quotaError{Error: "Tenant quota exceeded", Quota: quota.Quota, Limit: quota.Limit})
 		return
 	}
-	if !validateInterval(req.Interval) {
-		writeJson(writer, http.StatusBadRequest, apiError{Error: "Invalid interval"})
+	if errors.Is(err, services.ErrCapacity) {
+		writeJson(writer, http.StatusServiceUnavailable, apiError{Error: "Monitor capacity reached"})
 		return
 	}
-	if !validatePattern(req.Pattern) {
-		writeJson(writer, http.StatusBadRequest, apiError{Error: "Invalid pattern"})
+	if errors.Is(err, services.ErrUnknownTenant) {
+		writeJson(writer, http.StatusBadRequest, newValidationError([]FieldError{{
+			Field:   "tenant_id",
+			Code:    codeUnknownTenant,
+			Message: "tenant_id does not exist",
+		}}))
 		return
 	}
-	scheduler := s.schedulerFactory(req.URL, req.Pattern, time.Duration(req.Interval)*time.Second, s.db)
-	go scheduler.ScheduleCheck()
-	writeJson(writer, http.StatusOK, struct{}{})
+	writeJson(writer, http.StatusInternalServerError, apiError{Error: err.Error()})
 }
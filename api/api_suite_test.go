@@ -1,6 +1,8 @@
 package api_test
 
 import (
+	"snapp-task/db"
+	"sync"
 	"testing"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -11,3 +13,93 @@ func TestApi(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Api Suite")
 }
+
+// newMonitorStore returns a MonitorStoreMock backed by an in-memory slice.
+func newMonitorStore(monitors ...db.Monitor) *db.MonitorStoreMock {
+	var mu sync.Mutex
+	nextID := int64(len(monitors))
+	find := func(id int64) int {
+		for i, monitor := range monitors {
+			if monitor.ID == id {
+				return i
+			}
+		}
+		return -1
+	}
+	store := &db.MonitorStoreMock{
+		CreateMonitorFunc: func(monitor db.Monitor) (db.Monitor, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			nextID++
+			monitor.ID = nextID
+			monitor.Revision = 1
+			monitors = append(monitors, monitor)
+			return monitor, nil
+		},
+		UpdateMonitorFunc: func(monitor db.Monitor) (db.Monitor, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			i := find(monitor.ID)
+			if i < 0 {
+				return monitor, db.ErrNotFound
+			}
+			monitor.Revision = monitors[i].Revision + 1
+			monitors[i] = monitor
+			return monitor, nil
+		},
+		DeleteMonitorFunc: func(id int64) error {
+			mu.Lock()
+			defer mu.Unlock()
+			i := find(id)
+			if i < 0 {
+				return db.ErrNotFound
+			}
+			monitors = append(monitors[:i], monitors[i+1:]...)
+			return nil
+		},
+		GetMonitorFunc: func(id int64) (db.Monitor, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			i := find(id)
+			if i < 0 {
+				return db.Monitor{}, db.ErrNotFound
+			}
+			return monitors[i], nil
+		},
+		ListMonitorsFunc: func() ([]db.Monitor, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]db.Monitor(nil), monitors...), nil
+		},
+		ListMonitorRevisionsFunc: func(id int64) ([]db.MonitorRevision, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			i := find(id)
+			if i < 0 {
+				return nil, db.ErrNotFound
+			}
+			return []db.MonitorRevision{{Monitor: monitors[i]}}, nil
+		},
+	}
+	store.ApplyMonitorChangesFunc = func(changes db.MonitorChanges) (db.MonitorChanges, error) {
+		applied := db.MonitorChanges{Delete: changes.Delete}
+		for _, monitor := range changes.Create {
+			created, _ := store.CreateMonitorFunc(monitor)
+			applied.Create = append(applied.Create, created)
+		}
+		for _, monitor := range changes.Update {
+			updated, err := store.UpdateMonitorFunc(monitor)
+			if err != nil {
+				return applied, err
+			}
+			applied.Update = append(applied.Update, updated)
+		}
+		for _, id := range changes.Delete {
+			if err := store.DeleteMonitorFunc(id); err != nil {
+				return applied, err
+			}
+		}
+		return applied, nil
+	}
+	return store
+}
@@ -2,12 +2,14 @@ package api_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"snapp-task/api"
 	"snapp-task/db"
 	"snapp-task/services"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -20,19 +22,22 @@ var _ = Describe("APIServer", func() {
 		recorder       *httptest.ResponseRecorder
 		requestPayload api.RequestMessage
 		mockScheduler  *services.CheckSchedulerMock
+		mockStore      *db.MonitorStoreMock
 	)
 
 	BeforeEach(func() {
 		mockScheduler = &services.CheckSchedulerMock{
-			ScheduleCheckFunc: func() {},
+			ScheduleCheckFunc: func(ctx context.Context) {},
 		}
-		schedulerFactory := func(url, pattern string, interval time.Duration, db db.DB) services.CheckScheduler {
+		schedulerFactory := func(monitor db.Monitor, db db.DB) services.CheckScheduler {
 			return mockScheduler
 		}
-		mockDB := &db.DBMock{SaveDataFunc: func(url string, pattern string, data string) error {
+		mockDB := &db.DBMock{SaveDataFunc: func(match db.Match) error {
 			return nil
 		}}
-		server = api.NewAPIServer(":8080", mockDB, schedulerFactory)
+		mockStore = newMonitorStore()
+		registry := services.NewRegistry(mockStore, mockDB, schedulerFactory)
+		server = api.NewAPIServer(":8080", registry)
 		recorder = httptest.NewRecorder()
 	})
 
@@ -52,6 +57,13 @@ var _ = Describe("APIServer", func() {
 			It("should return status 200", func() {
 				Expect(recorder.Code).To(Equal(http.StatusOK))
 			})
+			It("should store the monitor and return its id", func() {
+				Expect(mockStore.CreateMonitorCalls()).To(HaveLen(1))
+				Expect(mockStore.CreateMonitorCalls()[0].Monitor.Interval).To(Equal(time.Second))
+				var response api.CreateResponse
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+				Expect(response.ID).To(Equal(int64(1)))
+			})
 			It("should call scheduler", func() {
 				Eventually(func() int {
 					return len(mockScheduler.ScheduleCheckCalls())
@@ -84,3 +96,186 @@ var _ = Describe("APIServer", func() {
 		})
 	})
 })
+
+var _ = Describe("Request validation", func() {
+	var (
+		server   *api.APIServer
+		registry *services.Registry
+	)
+
+	post := func(body string) (*httptest.ResponseRecorder, api.ValidationError) {
+		recorder := httptest.NewRecorder()
+		server.Routes().ServeHTTP(recorder, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		var response api.ValidationError
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		return recorder, response
+	}
+
+	BeforeEach(func() {
+		schedulerFactory := func(monitor db.Monitor, database db.DB) services.CheckScheduler {
+			return &services.CheckSchedulerMock{ScheduleCheckFunc: func(ctx context.Context) {}}
+		}
+		registry = services.NewRegistry(newMonitorStore(), &db.DBMock{}, schedulerFactory)
+		server = api.NewAPIServer(":8080", registry)
+	})
+
+	AfterEach(func() {
+		registry.StopAll()
+	})
+
+	It("should report every invalid field at once", func() {
+		recorder, response := post(`{"url": "ftp://a.com", "pattern": "", "interval": 0}`)
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Error).To(Equal("Validation failed"))
+		Expect(response.Fields).To(ConsistOf(
+			HaveField("Field", "url"),
+			HaveField("Field", "interval"),
+			HaveField("Field", "pattern"),
+		))
+		Expect(response.Fields[0].Code).To(Equal("invalid_scheme"))
+	})
+
+	It("should include the position of a regexp compile error", func() {
+		_, response := post(`{"url": "https://a.com", "pattern": "ab(c", "interval": 1}`)
+		Expect(response.Fields).To(HaveLen(1))
+		Expect(response.Fields[0].Code).To(Equal("invalid_regexp"))
+		Expect(response.Fields[0].Position).NotTo(BeNil())
+		Expect(*response.Fields[0].Position).To(Equal(0))
+		Expect(response.Fields[0].Message).To(ContainSubstring("missing closing )"))
+
+		_, response = post(`{"url": "https://a.com", "pattern": "abc[", "interval": 1}`)
+		Expect(*response.Fields[0].Position).To(Equal(3))
+	})
+
+	It("should leave the position out when the offending part occurs more than once", func() {
+		_, response := post(`{"url": "https://a.com", "pattern": "a**b**", "interval": 1}`)
+		Expect(response.Fields).To(ConsistOf(HaveField("Code", "invalid_regexp")))
+		Expect(response.Fields[0].Position).To(BeNil())
+		Expect(response.Fields[0].Message).To(ContainSubstring("invalid nested repetition operator"))
+	})
+
+	It("should reject invalid selectors", func() {
+		recorder, response := post(`{"url": "https://a.com", "pattern": "a", "interval": 1, "selector": "div >"}`)
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Fields).To(ConsistOf(HaveField("Code", "invalid_selector")))
+
+		recorder, _ = post(`{"url": "https://a.com", "pattern": "a", "interval": 1, "selector": "div.price > span"}`)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+	})
+
+	It("should reject invalid XPath expressions", func() {
+		recorder, response := post(`{"url": "https://a.com", "pattern": "a", "interval": 1, "xpath": "//item[contains(title, 'a')"}`)
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Fields).To(ConsistOf(HaveField("Code", "invalid_xpath")))
+
+		recorder, _ = post(`{"url": "https://a.com", "pattern": "a", "interval": 1, "xpath": "//item[contains(title, 'a') and @type='a']/title"}`)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+	})
+
+	It("should validate value paths and conditions and not require a pattern with them", func() {
+		recorder, response := post(`{"url": "https://a.com", "interval": 1, "value_path": "items[x]", "condition": "over 9000"}`)
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Fields).To(ConsistOf(HaveField("Code", "invalid_value_path"), HaveField("Code", "invalid_condition")))
+		Expect(*response.Fields[0].Position).To(Equal(6))
+
+		_, response = post(`{"url": "https://a.com", "pattern": "a", "interval": 1, "condition": "> 1"}`)
+		Expect(response.Fields).To(ConsistOf(HaveField("Field", "value_path")))
+
+		recorder, _ = post(`{"url": "https://a.com", "interval": 1, "value_path": "$.queue.depth", "condition": "between 1 and 10"}`)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+	})
+
+	It("should validate rules and not require a pattern with them", func() {
+		recorder, response := post(`{"url": "https://a.com", "interval": 1, "rule": "status == "}`)
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Fields).To(ConsistOf(HaveField("Code", "invalid_rule")))
+		Expect(response.Fields[0].Position).NotTo(BeNil())
+
+		_, response = post(`{"url": "https://a.com", "interval": 1, "rule": "status"}`)
+		Expect(response.Fields).To(ConsistOf(HaveField("Code", "invalid_rule")))
+
+		recorder, _ = post(`{"url": "https://a.com", "interval": 1, "rule": "status == 200 && duration < duration('2s')"}`)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+	})
+
+	It("should validate the steps of multi-step monitors", func() {
+		fieldError := func(field, code string) OmegaMatcher {
+			return SatisfyAll(HaveField("Field", field), HaveField("Code", code))
+		}
+		recorder, response := post(`{"interval": 1, "steps": [
+			{"method": "PO ST", "url": "https://a.com/login?u={{user}}", "extract": {"token": "auth.token", "bad-name": "x"}, "status": 999},
+			{"url": "https://a.com/{{token}}", "pattern": "ab(c", "rule": "status =="}]}`)
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Fields).To(ConsistOf(
+			fieldError("steps[0].method", "invalid_method"),
+			fieldError("steps[0].status", "out_of_range"),
+			fieldError("steps[0].variables", "unknown_variable"),
+			fieldError("steps[0].extract", "invalid_variable"),
+			fieldError("steps[1].pattern", "invalid_regexp"),
+			fieldError("steps[1].rule", "invalid_rule"),
+		))
+
+		recorder, _ = post(`{"interval": 1, "steps": [
+			{"method": "POST", "url": "https://a.com/login", "extract": {"token": "auth.token"}},
+			{"url": "https://a.com/orders", "headers": {"Authorization": "Bearer {{token}}"}, "status": 200}]}`)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+	})
+
+	It("should validate probe URLs and the options they support", func() {
+		recorder, response := post(`{"url": "tcp://db.internal", "interval": 1}`)
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Fields).To(ConsistOf(HaveField("Code", "invalid_url")))
+
+		_, response = post(`{"url": "tls://example.com?days=30", "pattern": "a", "interval": 1, "selector": "div"}`)
+		Expect(response.Fields).To(ConsistOf(
+			SatisfyAll(HaveField("Field", "pattern"), HaveField("Code", "unsupported")),
+			SatisfyAll(HaveField("Field", "selector"), HaveField("Code", "unsupported")),
+		))
+
+		recorder, _ = post(`{"url": "tcp://db.internal:5432", "interval": 1}`)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		recorder, _ = post(`{"url": "dns:///example.com?type=MX", "pattern": "mail", "interval": 1}`)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+	})
+
+	It("should reject unknown fields", func() {
+		recorder, response := post(`{"url": "https://a.com", "pattern": "a", "interval": 1, "intervall": 5}`)
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Fields).To(Equal([]api.FieldError{{Field: "intervall", Code: "unknown_field", Message: `unknown field "intervall"`}}))
+	})
+
+	It("should report fields of the wrong type", func() {
+		recorder, response := post(`{"url": "https://a.com", "pattern": "a", "interval": "5s"}`)
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Fields).To(ConsistOf(HaveField("Code", "invalid_type")))
+		Expect(response.Fields[0].Field).To(Equal("interval"))
+	})
+
+	It("should reject bodies over the size limit", func() {
+		server.MaxBodyBytes = 32
+		recorder, _ := post(`{"url": "https://a.com", "pattern": "` + strings.Repeat("a", 64) + `", "interval": 1}`)
+		Expect(recorder.Code).To(Equal(http.StatusRequestEntityTooLarge))
+	})
+})
+
+var _ = Describe("Egress policy", func() {
+	It("should reject monitors pointing at denied destinations", func() {
+		schedulerFactory := func(monitor db.Monitor, database db.DB) services.CheckScheduler {
+			return &services.CheckSchedulerMock{ScheduleCheckFunc: func(ctx context.Context) {}}
+		}
+		mockStore := newMonitorStore()
+		registry := services.NewRegistry(mockStore, &db.DBMock{}, schedulerFactory)
+		defer registry.StopAll()
+		server := api.NewAPIServer(":8080", registry)
+		server.Egress = &services.EgressPolicy{}
+
+		recorder := httptest.NewRecorder()
+		body := `{"url": "http://169.254.169.254/latest/meta-data", "pattern": "a", "interval": 1}`
+		server.Routes().ServeHTTP(recorder, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		var response api.ValidationError
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+		Expect(response.Fields).To(ConsistOf(HaveField("Code", "egress_denied")))
+		Expect(mockStore.CreateMonitorCalls()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"snapp-task/db"
+	"snapp-task/services"
+	"strings"
+	"time"
+)
+
+type contextKey int
+
+const apiKeyContextKey contextKey = iota
+
+type APIKeyRequest struct {
+	Name     string   `json:"name"`
+	Scope    db.Scope `json:"scope"`
+	TenantID int64    `json:"tenant_id,omitempty"`
+}
+
+type APIKeyResponse struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scope     db.Scope   `json:"scope"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	TenantID  int64      `json:"tenant_id,omitempty"`
+	// Key is the secret, returned only when the key is created.
+	Key string `json:"key,omitempty"`
+}
+
+func toAPIKeyResponse(key db.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scope:     key.Scope,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: key.RevokedAt,
+		TenantID:  key.TenantID,
+	}
+}
+
+// wsKeyProtocol prefixes the API key offered as a WebSocket subprotocol, for browsers, which cannot set
+// headers on a WebSocket handshake.
+const wsKeyProtocol = "apikey."
+
+func requestSecret(request *http.Request) string {
+	if secret := request.Header.Get("X-API-Key"); secret != "" {
+		return secret
+	}
+	if secret, ok := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(secret)
+	}
+	for _, header := range request.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			if secret, ok := strings.CutPrefix(strings.TrimSpace(protocol), wsKeyProtocol); ok {
+				return secret
+			}
+		}
+	}
+	return ""
+}
+
+// authenticate looks up the request's API key and stores it in the request context. Requests without a
+// valid key go on anonymously for requireScope to reject, but each one counts against its address's
+// AuthFailureLimit; once that is spent, the address gets 429 without its keys being looked up.
+func (s *APIServer) authenticate(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if s.Keys == nil {
+			handler(writer, request)
+			return
+		}
+		if s.authFailures != nil {
+			if allowed, retryAfter := s.authFailures.Peek(clientIP(request)); !allowed {
+				writeTooManyRequests(writer, retryAfter, "Too many failed authentications")
+				return
+			}
+		}
+		key, err := s.Keys.Authenticate(requestSecret(request))
+		if errors.Is(err, services.ErrInvalidAPIKey) {
+			if s.authFailures != nil {
+				s.authFailures.Allow(clientIP(request))
+			}
+			handler(writer, request)
+			return
+		}
+		if err != nil {
+			writeJson(writer, http.StatusInternalServerError, apiError{Error: err.Error()})
+			return
+		}
+		handler(writer, request.WithContext(context.WithValue(request.Context(), apiKeyContextKey, key)))
+	}
+}
+
+// requireScope checks the API key stored by authenticate grants scope before calling handler. When the
+// server has no Keys, authentication is disabled and every request is let through.
+func (s *APIServer) requireScope(scope db.Scope, handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if s.Keys == nil {
+			handler(writer, request)
+			return
+		}
+		key, ok := request.Context().Value(apiKeyContextKey).(db.APIKey)
+		if !ok {
+			writer.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			writeJson(writer, http.StatusUnauthorized, apiError{Error: "Missing or invalid API key"})
+			return
+		}
+		if !key.Scope.Allows(scope) {
+			writeJson(writer, http.StatusForbidden, apiError{Error: "API key lacks the " + string(scope) + " scope"})
+			return
+		}
+		handler(writer, request)
+	}
+}
+
+// callerID returns the id of the API key that authenticated request, or 0 when there is none.
+func callerID(request *http.Request) int64 {
+	key, _ := request.Context().Value(apiKeyContextKey).(db.APIKey)
+	return key.ID
+}
+
+// callerAllows reports whether the request's API key grants scope. It always does when authentication
+// is disabled.
+func callerAllows(request *http.Request, scope db.Scope) bool {
+	key, ok := request.Context().Value(apiKeyContextKey).(db.APIKey)
+	return !ok || key.Scope.Allows(scope)
+}
+
+// callerTenant returns the tenant the request is scoped to, or 0 for global keys and when authentication
+// is disabled.
+func callerTenant(request *http.Request) int64 {
+	key, _ := request.Context().Value(apiKeyContextKey).(db.APIKey)
+	return key.TenantID
+}
+
+// scopeToCaller moves *tenantID to the caller's tenant. Only global callers may choose a tenant.
+func scopeToCaller(request *http.Request, tenantID *int64) *FieldError {
+	callerTenantID := callerTenant(request)
+	if callerTenantID == 0 {
+		return nil
+	}
+	if *tenantID != 0 && *tenantID != callerTenantID {
+		return &FieldError{Field: "tenant_id", Code: codeForbidden, Message: "tenant_id must be the API key's own tenant"}
+	}
+	*tenantID = callerTenantID
+	return nil
+}
+
+func writeGlobalOnly(writer http.ResponseWriter) {
+	writeJson(writer, http.StatusForbidden, apiError{Error: "Only global API keys may manage tenants"})
+}
+
+func (s *APIServer) HandleCreateKey(writer http.ResponseWriter, request *http.Request) {
+	var req APIKeyRequest
+	if err := s.decodeJSON(writer, request, &req); err != nil {
+		writeDecodeError(writer, err)
+		return
+	}
+	var fields []FieldError
+	if req.Name == "" {
+		fields = append(fields, FieldError{Field: "name", Code: codeRequired, Message: "name is required"})
+	}
+	if !req.Scope.Valid() {
+		fields = append(fields, FieldError{Field: "scope", Code: codeInvalidScope, Message: "scope must be one of read, write or admin"})
+	}
+	if fieldError := scopeToCaller(request, &req.TenantID); fieldError != nil {
+		fields = append(fields, *fieldError)
+	} else if req.TenantID != 0 && s.Tenants != nil {
+		if _, err := s.Tenants.GetTenant(req.TenantID); errors.Is(err, db.ErrNotFound) {
+			fields = append(fields, FieldError{Field: "tenant_id", Code: codeUnknownTenant, Message: "tenant_id does not exist"})
+		}
+	}
+	if len(fields) > 0 {
+		writeJson(writer, http.StatusBadRequest, newValidationError(fields))
+		return
+	}
+	key, secret, err := s.Keys.Create(req.Name, req.Scope, req.TenantID)
+	if err != nil {
+		writeJson(writer, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+	response := toAPIKeyResponse(key)
+	response.Key = secret
+	writeJson(writer, http.StatusCreated, response)
+}
+
+func (s *APIServer) HandleListKeys(writer http.ResponseWriter, request *http.Request) {
+	keys, err := s.Keys.List(callerTenant(request))
+	if err != nil {
+		writeJson(writer, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+	response := make([]APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, toAPIKeyResponse(key))
+	}
+	writeJson(writer, http.StatusOK, response)
+}
+
+func (s *APIServer) HandleRevokeKey(writer http.ResponseWriter, request *http.Request) {
+	id, err := pathID(request)
+	if err != nil {
+		writeJson(writer, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	if err = s.Keys.Revoke(callerTenant(request), id); errors.Is(err, db.ErrNotFound) {
+		writeJson(writer, http.StatusNotFound, apiError{Error: "API key not found"})
+		return
+	} else if err != nil {
+		writeJson(writer, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,146 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"snapp-task/api"
+	"snapp-task/db"
+	"snapp-task/services"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// newKeyStore returns a KeyStoreMock backed by an in-memory map.
+func newKeyStore() *db.KeyStoreMock {
+	var keys []db.APIKey
+	hashes := make(map[string]int)
+	return &db.KeyStoreMock{
+		CreateAPIKeyFunc: func(key db.APIKey, hash string) (db.APIKey, error) {
+			key.ID = int64(len(keys) + 1)
+			hashes[hash] = len(keys)
+			keys = append(keys, key)
+			return key, nil
+		},
+		GetAPIKeyByHashFunc: func(hash string) (db.APIKey, error) {
+			i, ok := hashes[hash]
+			if !ok {
+				return db.APIKey{}, db.ErrNotFound
+			}
+			return keys[i], nil
+		},
+		ListAPIKeysFunc: func() ([]db.APIKey, error) {
+			return keys, nil
+		},
+		RevokeAPIKeyFunc: func(id int64) error {
+			if id < 1 || int(id) > len(keys) {
+				return db.ErrNotFound
+			}
+			now := keys[id-1].CreatedAt
+			keys[id-1].RevokedAt = &now
+			return nil
+		},
+	}
+}
+
+var _ = Describe("Authentication", func() {
+	var (
+		handler   http.Handler
+		mockStore *db.MonitorStoreMock
+		registry  *services.Registry
+		keys      *services.APIKeys
+		secrets   map[db.Scope]string
+	)
+
+	do := func(method, target, secret string, body any) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, bytes.NewReader(payload))
+		if secret != "" {
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		mockStore = newMonitorStore()
+		schedulerFactory := func(monitor db.Monitor, database db.DB) services.CheckScheduler {
+			return &services.CheckSchedulerMock{ScheduleCheckFunc: func(ctx context.Context) {}}
+		}
+		registry = services.NewRegistry(mockStore, &db.DBMock{}, schedulerFactory)
+		keys = services.NewAPIKeys(newKeyStore())
+		secrets = make(map[db.Scope]string)
+		for _, scope := range []db.Scope{db.ScopeRead, db.ScopeWrite, db.ScopeAdmin} {
+			_, secret, err := keys.Create(string(scope), scope, 0)
+			Expect(err).NotTo(HaveOccurred())
+			secrets[scope] = secret
+		}
+		server := api.NewAPIServer(":8080", registry)
+		server.Keys = keys
+		handler = server.Routes()
+	})
+
+	AfterEach(func() {
+		registry.StopAll()
+	})
+
+	It("should reject requests without a valid key", func() {
+		recorder := do("GET", "/monitors:export", "", nil)
+		Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+		Expect(recorder.Header().Get("WWW-Authenticate")).NotTo(BeEmpty())
+		Expect(do("GET", "/monitors:export", "snk_wrong", nil).Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("should enforce scopes", func() {
+		monitor := api.RequestMessage{URL: "https://a.com", Pattern: "a", Interval: 1}
+		Expect(do("GET", "/monitors:export", secrets[db.ScopeRead], nil).Code).To(Equal(http.StatusOK))
+		Expect(do("POST", "/", secrets[db.ScopeRead], monitor).Code).To(Equal(http.StatusForbidden))
+		Expect(do("POST", "/", secrets[db.ScopeWrite], monitor).Code).To(Equal(http.StatusOK))
+		Expect(do("GET", "/keys", secrets[db.ScopeWrite], nil).Code).To(Equal(http.StatusForbidden))
+		Expect(mockStore.CreateMonitorCalls()).To(HaveLen(1))
+	})
+
+	It("should record the key that created a monitor", func() {
+		recorder := do("POST", "/", secrets[db.ScopeWrite], api.RequestMessage{URL: "https://a.com", Pattern: "a", Interval: 1})
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		recorder = do("GET", "/monitors/1", secrets[db.ScopeRead], nil)
+		var response api.MonitorResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+		Expect(response.CreatedBy).To(Equal(int64(2)))
+	})
+
+	It("should let admins create and revoke keys", func() {
+		recorder := do("POST", "/keys", secrets[db.ScopeAdmin], api.APIKeyRequest{Name: "ci", Scope: db.ScopeRead})
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+		var created api.APIKeyResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &created)).To(Succeed())
+		Expect(created.Key).NotTo(BeEmpty())
+		Expect(do("GET", "/monitors:export", created.Key, nil).Code).To(Equal(http.StatusOK))
+
+		recorder = do("GET", "/keys", secrets[db.ScopeAdmin], nil)
+		var listed []api.APIKeyResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &listed)).To(Succeed())
+		Expect(listed).To(HaveLen(4))
+		Expect(listed[3].Key).To(BeEmpty())
+
+		Expect(do("DELETE", "/keys/4", secrets[db.ScopeAdmin], nil).Code).To(Equal(http.StatusNoContent))
+		Expect(do("GET", "/monitors:export", created.Key, nil).Code).To(Equal(http.StatusUnauthorized))
+		Expect(do("DELETE", "/keys/9", secrets[db.ScopeAdmin], nil).Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should reject invalid key requests", func() {
+		recorder := do("POST", "/keys", secrets[db.ScopeAdmin], api.APIKeyRequest{Scope: "root"})
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		var response api.ValidationError
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+		Expect(response.Fields).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"snapp-task/db"
+	"snapp-task/notifier"
+	"sort"
+	"time"
+)
+
+// maskedSetting replaces secret settings in responses.
+const maskedSetting = "********"
+
+type ChannelRequest struct {
+	Name     string            `json:"name"`
+	Kind     db.ChannelKind    `json:"kind"`
+	Settings map[string]string `json:"settings,omitempty"`
+	// Template replaces the kind's default message template. It is a Go text/template rendered with the
+	// alert's Rule, Status, MonitorID, URL and Time.
+	Template string `json:"template,omitempty"`
+	TenantID int64  `json:"tenant_id,omitempty"`
+}
+
+type ChannelResponse struct {
+	ID int64 `json:"id"`
+	ChannelRequest
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func toChannelResponse(channel db.Channel) ChannelResponse {
+	settings := make(map[string]string, len(channel.Settings))
+	for name, value := range channel.Settings {
+		settings[name] = value
+	}
+	for _, name := range notifier.SecretSettings[channel.Kind] {
+		if _, ok := settings[name]; ok {
+			settings[name] = maskedSetting
+		}
+	}
+	return ChannelResponse{ID: channel.ID, CreatedAt: channel.CreatedAt, ChannelRequest: ChannelRequest{
+		Name:     channel.Name,
+		Kind:     channel.Kind,
+		Settings: settings,
+		Template: channel.Template,
+		TenantID: channel.TenantID,
+	}}
+}
+
+func (s *APIServer) validateChannel(req ChannelRequest) []FieldError {
+	var fields []FieldError
+	if req.Name == "" {
+		fields = append(fields, FieldError{Field: "name", Code: codeRequired, Message: "name is required"})
+	}
+	required, ok := notifier.RequiredSettings[req.Kind]
+	if !ok {
+		kinds := make([]string, 0, len(notifier.RequiredSettings))
+		for kind := range notifier.RequiredSettings {
+			kinds = append(kinds, string(kind))
+		}
+		sort.Strings(kinds)
+		return append(fields, FieldError{Field: "kind", Code: codeUnsupported, Message: fmt.Sprintf("kind must be one of %v", kinds)})
+	}
+	for _, name := range required {
+		if req.Settings[name] == "" {
+			fields = append(fields, FieldError{Field: "settings." + name, Code: codeRequired, Message: fmt.Sprintf("%s channels need %s", req.Kind, name)})
+		}
+	}
+	for _, name := range []string{"url", "api_url"} {
+		if value := req.Settings[name]; value != "" {
+			if parsed, err := url.ParseRequestURI(value); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				fields = append(fields, FieldError{Field: "settings." + name, Code: codeInvalidURL, Message: name + " must be an absolute http or https URL"})
+			} else if s.Egress != nil {
+				if err = s.Egress.CheckURL(value); err != nil {
+					fields = append(fields, FieldError{Field: "settings." + name, Code: codeEgressDenied, Message: err.Error()})
+				}
+			}
+		}
+	}
+	if _, err := notifier.ParseTemplate(req.Kind, req.Template); err != nil {
+		fields = append(fields, FieldError{Field: "template", Code: codeInvalidTemplate, Message: fmt.Sprintf("template does not parse: %v", err)})
+	}
+	return fields
+}
+
+func writeChannelError(writer http.ResponseWriter, err error) {
+	if errors.Is(err, db.ErrNotFound) {
+		writeJson(writer, http.StatusNotFound, apiError{Error: "Channel not found"})
+		return
+	}
+	writeJson(writer, http.StatusInternalServerError, apiError{Error: err.Error()})
+}
+
+func (s *APIServer) HandleCreateChannel(writer http.ResponseWriter, request *http.Request) {
+	var req ChannelRequest
+	if err := s.decodeJSON(writer, request, &req); err != nil {
+		writeDecodeError(writer, err)
+		return
+	}
+	fields := s.validateChannel(req)
+	if field := scopeToCaller(request, &req.TenantID); field != nil {
+		fields = append(fields, *field)
+	}
+	if len(fields) > 0 {
+		writeJson(writer, http.StatusBadRequest, newValidationError(fields))
+		return
+	}
+	channel, err := s.Notifier.CreateChannel(db.Channel{
+		TenantID: req.TenantID,
+		Name:     req.Name,
+		Kind:     req.Kind,
+		Settings: req.Settings,
+		Template: req.Template,
+	})
+	if err != nil {
+		writeChannelError(writer, err)
+		return
+	}
+	writeJson(writer, http.StatusCreated, toChannelResponse(channel))
+}
+
+func (s *APIServer) HandleListChannels(writer http.ResponseWriter, request *http.Request) {
+	channels, err := s.Notifier.Channels(callerTenant(request))
+	if err != nil {
+		writeChannelError(writer, err)
+		return
+	}
+	response := make([]ChannelResponse, 0, len(channels))
+	for _, channel := range channels {
+		response = append(response, toChannelResponse(channel))
+	}
+	writeJson(writer, http.StatusOK, response)
+}
+
+func (s *APIServer) HandleDeleteChannel(writer http.ResponseWriter, request *http.Request) {
+	id, err := pathID(request)
+	if err != nil {
+		writeJson(writer, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	if err = s.Notifier.DeleteChannel(callerTenant(request), id); err != nil {
+		writeChannelError(writer, err)
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// HandleTestChannel sends a sample firing notification to the channel, so its settings and template can
+// be checked before an alert fires. A failed delivery is reported as 502 with the channel's error.
+func (s *APIServer) HandleTestChannel(writer http.ResponseWriter, request *http.Request) {
+	id, err := pathID(request)
+	if err != nil {
+		writeJson(writer, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	channel, err := s.Notifier.Channel(callerTenant(request), id)
+	if err != nil {
+		writeChannelError(writer, err)
+		return
+	}
+	notification := notifier.Notification{Rule: "Test notification", Status: db.AlertFiring, TenantID: channel.TenantID,
+		Time: time.Now().UTC(), Test: true}
+	if err = s.Notifier.Send(request.Context(), channel, notification); err != nil {
+		writeJson(writer, http.StatusBadGateway, apiError{Error: "Delivery failed: " + err.Error()})
+		return
+	}
+	writeJson(writer, http.StatusOK, map[string]bool{"delivered": true})
+}
@@ -0,0 +1,144 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"snapp-task/api"
+	"snapp-task/db"
+	"snapp-task/notifier"
+	"snapp-task/services"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Channels", func() {
+	var (
+		handler  http.Handler
+		registry *services.Registry
+		hook     *httptest.Server
+		bodies   chan string
+		status   int
+		global   string
+		acme     string
+	)
+
+	do := func(method, target, secret string, body any) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, bytes.NewReader(payload))
+		req.Header.Set("X-API-Key", secret)
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		bodies = make(chan string, 1)
+		status = http.StatusOK
+		hook = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			bodies <- string(body)
+			w.WriteHeader(status)
+		}))
+		schedulerFactory := func(monitor db.Monitor, database db.DB) services.CheckScheduler {
+			return &services.CheckSchedulerMock{ScheduleCheckFunc: func(ctx context.Context) {}}
+		}
+		registry = services.NewRegistry(newMonitorStore(), &db.DBMock{}, schedulerFactory)
+		var channels []db.Channel
+		store := &db.ChannelStoreMock{
+			CreateChannelFunc: func(channel db.Channel) (db.Channel, error) {
+				channel.ID = int64(len(channels) + 1)
+				channels = append(channels, channel)
+				return channel, nil
+			},
+			GetChannelFunc: func(id int64) (db.Channel, error) {
+				for _, channel := range channels {
+					if channel.ID == id {
+						return channel, nil
+					}
+				}
+				return db.Channel{}, db.ErrNotFound
+			},
+			ListChannelsFunc: func() ([]db.Channel, error) { return channels, nil },
+			DeleteChannelFunc: func(id int64) error {
+				channels = nil
+				return nil
+			},
+		}
+		keys := services.NewAPIKeys(newKeyStore())
+		var err error
+		_, global, err = keys.Create("root", db.ScopeWrite, 0)
+		Expect(err).NotTo(HaveOccurred())
+		_, acme, err = keys.Create("acme", db.ScopeWrite, 2)
+		Expect(err).NotTo(HaveOccurred())
+		server := api.NewAPIServer(":8080", registry)
+		server.Keys = keys
+		server.Notifier = notifier.NewNotifier(store, services.NewEventBus(10), hook.Client())
+		handler = server.Routes()
+	})
+
+	AfterEach(func() {
+		registry.StopAll()
+		hook.Close()
+	})
+
+	It("should create channels in the caller's tenant and mask their secrets", func() {
+		recorder := do("POST", "/channels", acme, api.ChannelRequest{Name: "bot", Kind: db.ChannelTelegram,
+			Settings: map[string]string{"token": "123:abc", "chat_id": "-100"}})
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+		var created api.ChannelResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &created)).To(Succeed())
+		Expect(created.TenantID).To(Equal(int64(2)))
+		Expect(created.Settings).To(Equal(map[string]string{"token": "********", "chat_id": "-100"}))
+
+		Expect(do("GET", "/channels", global, nil).Body.String()).To(ContainSubstring(`"name":"bot"`))
+		Expect(do("DELETE", "/channels/1", acme, nil).Code).To(Equal(http.StatusNoContent))
+		Expect(do("GET", "/channels", acme, nil).Body.String()).To(MatchJSON(`[]`))
+	})
+
+	It("should reject invalid channels", func() {
+		recorder := do("POST", "/channels", acme, api.ChannelRequest{Kind: db.ChannelWebhook,
+			Settings: map[string]string{"url": "ftp://hooks.example.com"}, Template: "{{.Rule"})
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		var response api.ValidationError
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+		var fields []string
+		for _, field := range response.Fields {
+			fields = append(fields, field.Field+":"+field.Code)
+		}
+		Expect(fields).To(ConsistOf("name:required", "settings.url:invalid_url", "template:invalid_template"))
+
+		recorder = do("POST", "/channels", acme, api.ChannelRequest{Name: "pager", Kind: "pager"})
+		Expect(recorder.Body.String()).To(ContainSubstring(`"code":"unsupported"`))
+	})
+
+	It("should send a test notification through the channel", func() {
+		recorder := do("POST", "/channels", acme, api.ChannelRequest{Name: "ops", Kind: db.ChannelSlack,
+			Settings: map[string]string{"url": hook.URL}, Template: "{{if .Test}}test: {{end}}{{.Rule}}"})
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"url":"********"`))
+
+		recorder = do("POST", "/channels/1/test", acme, nil)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(<-bodies).To(MatchJSON(`{"text": "test: Test notification"}`))
+
+		status = http.StatusForbidden
+		recorder = do("POST", "/channels/1/test", acme, nil)
+		Expect(recorder.Code).To(Equal(http.StatusBadGateway))
+		Expect(recorder.Body.String()).To(ContainSubstring("unexpected status 403"))
+	})
+
+	It("should hide channels of other tenants", func() {
+		Expect(do("POST", "/channels", global, api.ChannelRequest{Name: "ops", Kind: db.ChannelSlack, TenantID: 1,
+			Settings: map[string]string{"url": hook.URL}}).Code).To(Equal(http.StatusCreated))
+		Expect(do("POST", "/channels/1/test", acme, nil).Code).To(Equal(http.StatusNotFound))
+		Expect(do("DELETE", "/channels/1", acme, nil).Code).To(Equal(http.StatusNotFound))
+	})
+})
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"snapp-task/services"
+)
+
+type CheckResultResponse struct {
+	MonitorID       int64    `json:"monitor_id,omitempty"`
+	StatusCode      int      `json:"status_code,omitempty"`
+	ContentType     string   `json:"content_type,omitempty"`
+	ContentEncoding string   `json:"content_encoding,omitempty"`
+	RawBytes        int64    `json:"raw_bytes"`
+	DecodedBytes    int64    `json:"decoded_bytes"`
+	Matched         bool     `json:"matched"`
+	Data            string   `json:"data,omitempty"`
+	Value           *float64 `json:"value,omitempty"`
+	DurationMs      int64    `json:"duration_ms"`
+	Error           string   `json:"error,omitempty"`
+	// FailedStep is the 1-based step a multi-step check failed at.
+	FailedStep int                  `json:"failed_step,omitempty"`
+	Steps      []StepResultResponse `json:"steps,omitempty"`
+}
+
+type StepResultResponse struct {
+	Name       string `json:"name,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+func toCheckResultResponse(monitorID int64, result services.CheckResult) CheckResultResponse {
+	response := CheckResultResponse{
+		MonitorID:       monitorID,
+		StatusCode:      result.StatusCode,
+		ContentType:     result.ContentType,
+		ContentEncoding: result.ContentEncoding,
+		RawBytes:        result.RawBytes,
+		DecodedBytes:    result.DecodedBytes,
+		Matched:         result.Matched,
+		Data:            result.Data,
+		Value:           result.Value,
+		DurationMs:      result.Duration.Milliseconds(),
+	}
+	if result.Err != nil {
+		response.Error = result.Err.Error()
+	}
+	var stepErr *services.StepError
+	if errors.As(result.Err, &stepErr) {
+		response.FailedStep = stepErr.Step
+	}
+	for _, step := range result.Steps {
+		response.Steps = append(response.Steps, StepResultResponse{Name: step.Name, StatusCode: step.StatusCode, DurationMs: step.Duration.Milliseconds()})
+	}
+	return response
+}
+
+// HandleRunMonitor checks the monitor right away and returns the result. A failed check is still a
+// result, so it is reported with 200 and an error field.
+func (s *APIServer) HandleRunMonitor(writer http.ResponseWriter, request *http.Request) {
+	id, err := pathID(request)
+	if err != nil {
+		writeJson(writer, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	result, err := s.registry.Check(callerTenant(request), id)
+	if err != nil {
+		writeStoreError(writer, err)
+		return
+	}
+	writeJson(writer, http.StatusOK, toCheckResultResponse(id, result))
+}
+
+// HandleDryRun checks an ad-hoc monitor configuration without saving it. The interval may be omitted.
+func (s *APIServer) HandleDryRun(writer http.ResponseWriter, request *http.Request) {
+	req := RequestMessage{Interval: 1}
+	if err := s.decodeJSON(writer, request, &req); err != nil {
+		writeDecodeError(writer, err)
+		return
+	}
+	if fields := s.validateScoped(request, &req); len(fields) > 0 {
+		writeJson(writer, http.StatusBadRequest, newValidationError(fields))
+		return
+	}
+	writeJson(writer, http.StatusOK, toCheckResultResponse(0, s.registry.DryRun(req.toMonitor())))
+}
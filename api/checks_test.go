@@ -0,0 +1,98 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"snapp-task/api"
+	"snapp-task/db"
+	"snapp-task/services"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Manual checks", func() {
+	var (
+		handler  http.Handler
+		target   *httptest.Server
+		database *db.DBMock
+		registry *services.Registry
+	)
+
+	BeforeEach(func() {
+		target = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("status: all good"))
+		}))
+		database = &db.DBMock{
+			SaveDataFunc:     func(match db.Match) error { return nil },
+			SaveCheckRunFunc: func(run db.CheckRun) error { return nil },
+		}
+		schedulerFactory := func(monitor db.Monitor, database db.DB) services.CheckScheduler {
+			return &services.CheckSchedulerMock{ScheduleCheckFunc: func(ctx context.Context) {}}
+		}
+		registry = services.NewRegistry(newMonitorStore(db.Monitor{ID: 1, URL: target.URL, Pattern: "all good"}), database, schedulerFactory)
+		handler = api.NewAPIServer(":8080", registry).Routes()
+	})
+
+	AfterEach(func() {
+		target.Close()
+		registry.StopAll()
+	})
+
+	do := func(path, body string) (*httptest.ResponseRecorder, api.CheckResultResponse) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		var result api.CheckResultResponse
+		json.Unmarshal(recorder.Body.Bytes(), &result)
+		return recorder, result
+	}
+
+	It("should run a monitor synchronously and save what it matched", func() {
+		recorder, result := do("/monitors/1/run", "")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(result.MonitorID).To(Equal(int64(1)))
+		Expect(result.StatusCode).To(Equal(http.StatusOK))
+		Expect(result.Matched).To(BeTrue())
+		Expect(result.Data).To(Equal("status: all good"))
+		Expect(database.SaveDataCalls()).To(HaveLen(1))
+		Expect(database.SaveCheckRunCalls()).To(HaveLen(1))
+	})
+
+	It("should return 404 for unknown monitors", func() {
+		recorder, _ := do("/monitors/9/run", "")
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should dry-run an ad-hoc monitor without persisting anything", func() {
+		recorder, result := do("/check:dry-run", `{"url": "`+target.URL+`", "pattern": "good"}`)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(result.Matched).To(BeTrue())
+		Expect(database.SaveDataCalls()).To(BeEmpty())
+		Expect(database.SaveCheckRunCalls()).To(BeEmpty())
+	})
+
+	It("should report the step a multi-step dry-run failed at", func() {
+		_, result := do("/check:dry-run", `{"steps": [{"url": "`+target.URL+`", "pattern": "good"}, {"name": "create", "url": "`+target.URL+`", "status": 201}]}`)
+		Expect(result.Matched).To(BeFalse())
+		Expect(result.FailedStep).To(Equal(2))
+		Expect(result.Error).To(Equal("step 2 (create) failed: expected status 201, got 200"))
+		Expect(result.Steps).To(HaveLen(2))
+		Expect(result.Steps[1].Name).To(Equal("create"))
+		Expect(database.SaveDataCalls()).To(BeEmpty())
+	})
+
+	It("should report a dry-run that matches nothing", func() {
+		_, result := do("/check:dry-run", `{"url": "`+target.URL+`", "pattern": "down"}`)
+		Expect(result.Matched).To(BeFalse())
+		Expect(result.Error).To(BeEmpty())
+	})
+
+	It("should validate the dry-run payload", func() {
+		recorder, _ := do("/check:dry-run", `{"url": "ftp://a.com", "pattern": "a"}`)
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(recorder.Body.String()).To(ContainSubstring("invalid_scheme"))
+	})
+})
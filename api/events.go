@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"snapp-task/db"
+	"snapp-task/services"
+	"strconv"
+	"time"
+)
+
+const eventHeartbeatInterval = 15 * time.Second
+
+type EventResponse struct {
+	Type       services.EventType    `json:"type"`
+	MonitorID  int64                 `json:"monitor_id"`
+	TenantID   int64                 `json:"tenant_id,omitempty"`
+	URL        string                `json:"url,omitempty"`
+	Time       time.Time             `json:"time"`
+	Matched    bool                  `json:"matched,omitempty"`
+	Error      string                `json:"error,omitempty"`
+	DurationMs int64                 `json:"duration_ms,omitempty"`
+	Data       string                `json:"data,omitempty"`
+	State      services.MonitorState `json:"state,omitempty"`
+	RuleID     int64                 `json:"rule_id,omitempty"`
+	Alert      db.AlertStatus        `json:"alert,omitempty"`
+}
+
+func toEventResponse(event services.Event) EventResponse {
+	return EventResponse{
+		Type:       event.Type,
+		MonitorID:  event.MonitorID,
+		TenantID:   event.TenantID,
+		URL:        event.URL,
+		Time:       event.Time,
+		Matched:    event.Matched,
+		Error:      event.Error,
+		DurationMs: event.Duration.Milliseconds(),
+		Data:       event.Data,
+		State:      event.State,
+		RuleID:     event.RuleID,
+		Alert:      event.Alert,
+	}
+}
+
+func writeEvent(writer http.ResponseWriter, event services.Event) error {
+	data, err := json.Marshal(toEventResponse(event))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// HandleEvents streams the events of every monitor visible to the caller.
+func (s *APIServer) HandleEvents(writer http.ResponseWriter, request *http.Request) {
+	tenantID := callerTenant(request)
+	s.streamEvents(writer, request, func(event services.Event) bool {
+		return tenantID == 0 || event.TenantID == tenantID
+	})
+}
+
+// HandleMonitorEvents streams the events of a single monitor.
+func (s *APIServer) HandleMonitorEvents(writer http.ResponseWriter, request *http.Request) {
+	id, err := pathID(request)
+	if err != nil {
+		writeJson(writer, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	if _, err = s.registry.Get(callerTenant(request), id); err != nil {
+		writeStoreError(writer, err)
+		return
+	}
+	s.streamEvents(writer, request, func(event services.Event) bool {
+		return event.MonitorID == id
+	})
+}
+
+// streamEvents writes the events accepted by filter as Server-Sent Events until the client goes away or
+// the server shuts down. A Last-Event-ID header replays the retained events the client missed.
+func (s *APIServer) streamEvents(writer http.ResponseWriter, request *http.Request, filter func(services.Event) bool) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		writeJson(writer, http.StatusInternalServerError, apiError{Error: "Streaming is not supported"})
+		return
+	}
+	var lastID uint64
+	if header := request.Header.Get("Last-Event-ID"); header != "" {
+		var err error
+		if lastID, err = strconv.ParseUint(header, 10, 64); err != nil {
+			writeJson(writer, http.StatusBadRequest, apiError{Error: "Invalid Last-Event-ID"})
+			return
+		}
+	}
+	sub, replay := s.Events.Subscribe(lastID, filter)
+	defer s.Events.Unsubscribe(sub)
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.WriteHeader(http.StatusOK)
+	for _, event := range replay {
+		if writeEvent(writer, event) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, open := <-sub.C:
+			if !open {
+				return
+			}
+			if writeEvent(writer, event) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-request.Context().Done():
+			return
+		case <-s.shutdown:
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,96 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"snapp-task/api"
+	"snapp-task/db"
+	"snapp-task/services"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Event streams", func() {
+	var (
+		testServer *httptest.Server
+		registry   *services.Registry
+		events     *services.EventBus
+	)
+
+	BeforeEach(func() {
+		schedulerFactory := func(monitor db.Monitor, database db.DB) services.CheckScheduler {
+			return &services.CheckSchedulerMock{ScheduleCheckFunc: func(ctx context.Context) {}}
+		}
+		registry = services.NewRegistry(newMonitorStore(db.Monitor{ID: 1, URL: "https://a.com", Pattern: "a"}), &db.DBMock{}, schedulerFactory)
+		events = services.NewEventBus(10)
+		server := api.NewAPIServer(":8080", registry)
+		server.Events = events
+		testServer = httptest.NewServer(server.Routes())
+	})
+
+	AfterEach(func() {
+		testServer.CloseClientConnections()
+		testServer.Close()
+		registry.StopAll()
+	})
+
+	// readEvent reads one event from the stream, returning its id, type and data lines.
+	readEvent := func(reader *bufio.Reader) []string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			Expect(err).NotTo(HaveOccurred())
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				return lines
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	open := func(path, lastEventID string) *http.Response {
+		request, err := http.NewRequest(http.MethodGet, testServer.URL+path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		if lastEventID != "" {
+			request.Header.Set("Last-Event-ID", lastEventID)
+		}
+		response, err := http.DefaultClient.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(response.Body.Close)
+		return response
+	}
+
+	It("should stream a monitor's events as they are published", func() {
+		response := open("/monitors/1/events", "")
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+		Expect(response.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+		events.Publish(services.Event{Type: services.EventMatch, MonitorID: 2, Data: "other"})
+		events.Publish(services.Event{Type: services.EventMatch, MonitorID: 1, Data: "found"})
+		lines := readEvent(bufio.NewReader(response.Body))
+		Expect(lines).To(HaveLen(3))
+		Expect(lines[0]).To(Equal("id: 2"))
+		Expect(lines[1]).To(Equal("event: match"))
+		Expect(lines[2]).To(ContainSubstring(`"data":"found"`))
+	})
+
+	It("should replay missed events after Last-Event-ID", func() {
+		events.Publish(services.Event{Type: services.EventCheck, MonitorID: 1})
+		events.Publish(services.Event{Type: services.EventState, MonitorID: 1, State: services.StateDown})
+		reader := bufio.NewReader(open("/events", "1").Body)
+		lines := readEvent(reader)
+		Expect(lines[0]).To(Equal("id: 2"))
+		Expect(lines[2]).To(ContainSubstring(`"state":"down"`))
+	})
+
+	It("should return 404 for unknown monitors", func() {
+		Expect(open("/monitors/9/events", "").StatusCode).To(Equal(http.StatusNotFound))
+	})
+
+	It("should reject a malformed Last-Event-ID", func() {
+		Expect(open("/events", "abc").StatusCode).To(Equal(http.StatusBadRequest))
+	})
+})
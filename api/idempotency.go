@@ -0,0 +1,48 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+const idempotencyTTL = 24 * time.Hour
+
+type idempotencyEntry struct {
+	fingerprint string
+	monitorID   int64
+	expiresAt   time.Time
+}
+
+// idempotencyCache remembers which monitor a client's Idempotency-Key created so retries get the same answer.
+type idempotencyCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]idempotencyEntry
+}
+
+func newIdempotencyCache(ttl time.Duration) *idempotencyCache {
+	return &idempotencyCache{ttl: ttl, entries: make(map[string]idempotencyEntry)}
+}
+
+func (c *idempotencyCache) Get(key string) (idempotencyEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return idempotencyEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *idempotencyCache) Put(key, fingerprint string, monitorID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = idempotencyEntry{fingerprint: fingerprint, monitorID: monitorID, expiresAt: now.Add(c.ttl)}
+}
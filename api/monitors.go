@@ -0,0 +1,278 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/http"
+	"snapp-task/db"
+	"snapp-task/services"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type MonitorResponse struct {
+	ID        int64 `json:"id"`
+	Revision  int   `json:"revision"`
+	CreatedBy int64 `json:"created_by,omitempty"`
+	RequestMessage
+}
+
+func toMonitorResponse(monitor db.Monitor) MonitorResponse {
+	return MonitorResponse{ID: monitor.ID, Revision: monitor.Revision, CreatedBy: monitor.CreatedBy, RequestMessage: fromMonitor(monitor)}
+}
+
+type RevisionResponse struct {
+	MonitorResponse
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type MonitorSet struct {
+	Monitors []SetMonitor `json:"monitors" yaml:"monitors"`
+}
+
+// SetMonitor is a monitor of an exported set. On import the ID ties it to the stored monitor, and
+// entries without one are matched by their configuration.
+type SetMonitor struct {
+	ID             int64 `json:"id,omitempty" yaml:"id,omitempty"`
+	RequestMessage `yaml:",inline"`
+}
+
+type BulkResult struct {
+	Index  int          `json:"index"`
+	ID     int64        `json:"id,omitempty"`
+	Error  string       `json:"error,omitempty"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+type MonitorUpdate struct {
+	ID     int64          `json:"id"`
+	Before RequestMessage `json:"before"`
+	After  RequestMessage `json:"after"`
+}
+
+type ImportResponse struct {
+	DryRun    bool             `json:"dry_run"`
+	Create    []RequestMessage `json:"create"`
+	Update    []MonitorUpdate  `json:"update"`
+	Delete    []RequestMessage `json:"delete"`
+	Unchanged int              `json:"unchanged"`
+}
+
+func (s *APIServer) HandleBulkCreate(writer http.ResponseWriter, request *http.Request) {
+	var reqs []RequestMessage
+	if err := s.decodeJSON(writer, request, &reqs); err != nil {
+		writeDecodeError(writer, err)
+		return
+	}
+	results := make([]BulkResult, len(reqs))
+	for i, req := range reqs {
+		results[i].Index = i
+		if fields := s.validateScoped(request, &req); len(fields) > 0 {
+			results[i].Error = validationFailed
+			results[i].Fields = fields
+			continue
+		}
+		monitor := req.toMonitor()
+		monitor.CreatedBy = callerID(request)
+		monitor, err := s.registry.Create(monitor)
+		var duplicate *services.DuplicateMonitorError
+		if errors.As(err, &duplicate) {
+			results[i].ID = duplicate.ExistingID
+			results[i].Error = "Monitor already exists"
+			continue
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].ID = monitor.ID
+	}
+	writeJson(writer, http.StatusOK, results)
+}
+
+func isYAML(request *http.Request) bool {
+	if format := request.URL.Query().Get("format"); format != "" {
+		return format == "yaml"
+	}
+	return strings.Contains(request.Header.Get("Content-Type"), "yaml")
+}
+
+func (s *APIServer) HandleExport(writer http.ResponseWriter, request *http.Request) {
+	monitors, err := s.registry.List(callerTenant(request))
+	if err != nil {
+		writeJson(writer, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+	set := MonitorSet{Monitors: make([]SetMonitor, 0, len(monitors))}
+	for _, monitor := range monitors {
+		set.Monitors = append(set.Monitors, SetMonitor{ID: monitor.ID, RequestMessage: fromMonitor(monitor)})
+	}
+	if !isYAML(request) {
+		writeJson(writer, http.StatusOK, set)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/yaml")
+	writer.WriteHeader(http.StatusOK)
+	yaml.NewEncoder(writer).Encode(set)
+}
+
+func (s *APIServer) decodeMonitorSet(writer http.ResponseWriter, request *http.Request) (MonitorSet, error) {
+	var set MonitorSet
+	if !isYAML(request) {
+		err := s.decodeJSON(writer, request, &set)
+		return set, err
+	}
+	body, err := s.readBody(writer, request)
+	if err != nil {
+		return set, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&set); err != nil && err != io.EOF {
+		return set, err
+	}
+	return set, nil
+}
+
+// HandleImport syncs the stored monitors to the posted set. With dry_run=true it only reports the diff,
+// and monitors missing from the set are deleted only with prune=true.
+func (s *APIServer) HandleImport(writer http.ResponseWriter, request *http.Request) {
+	set, err := s.decodeMonitorSet(writer, request)
+	if err != nil {
+		writeDecodeError(writer, err)
+		return
+	}
+	var invalid []BulkResult
+	desired := make([]db.Monitor, 0, len(set.Monitors))
+	for i, entry := range set.Monitors {
+		if fields := s.validateScoped(request, &entry.RequestMessage); len(fields) > 0 {
+			invalid = append(invalid, BulkResult{Index: i, Error: validationFailed, Fields: fields})
+			continue
+		}
+		monitor := entry.toMonitor()
+		monitor.ID = entry.ID
+		monitor.CreatedBy = callerID(request)
+		desired = append(desired, monitor)
+	}
+	if len(invalid) > 0 {
+		writeJson(writer, http.StatusBadRequest, invalid)
+		return
+	}
+	query := request.URL.Query()
+	dryRun := query.Get("dry_run") == "true"
+	plan, err := s.registry.Plan(callerTenant(request), desired, query.Get("prune") == "true")
+	if err != nil {
+		writeJson(writer, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+	if !dryRun {
+		if err = s.registry.Apply(plan); err != nil {
+			writeCreateError(writer, err)
+			return
+		}
+	}
+	response := ImportResponse{
+		DryRun:    dryRun,
+		Create:    make([]RequestMessage, 0, len(plan.Create)),
+		Update:    make([]MonitorUpdate, 0, len(plan.Update)),
+		Delete:    make([]RequestMessage, 0, len(plan.Delete)),
+		Unchanged: plan.Unchanged,
+	}
+	for _, monitor := range plan.Create {
+		response.Create = append(response.Create, fromMonitor(monitor))
+	}
+	for _, change := range plan.Update {
+		response.Update = append(response.Update, MonitorUpdate{ID: change.Before.ID, Before: fromMonitor(change.Before), After: fromMonitor(change.After)})
+	}
+	for _, monitor := range plan.Delete {
+		response.Delete = append(response.Delete, fromMonitor(monitor))
+	}
+	writeJson(writer, http.StatusOK, response)
+}
+
+func pathID(request *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(request.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("Invalid id")
+	}
+	return id, nil
+}
+
+func writeStoreError(writer http.ResponseWriter, err error) {
+	if errors.Is(err, db.ErrNotFound) {
+		writeJson(writer, http.StatusNotFound, apiError{Error: "Monitor not found"})
+		return
+	}
+	writeCreateError(writer, err)
+}
+
+func (s *APIServer) HandleGetMonitor(writer http.ResponseWriter, request *http.Request) {
+	id, err := pathID(request)
+	if err != nil {
+		writeJson(writer, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	monitor, err := s.registry.Get(callerTenant(request), id)
+	if err != nil {
+		writeStoreError(writer, err)
+		return
+	}
+	writeJson(writer, http.StatusOK, toMonitorResponse(monitor))
+}
+
+// HandleUpdateMonitor replaces the monitor on PUT. On PATCH the body is applied on top of the current
+// configuration, so omitted fields keep their values.
+func (s *APIServer) HandleUpdateMonitor(writer http.ResponseWriter, request *http.Request) {
+	id, err := pathID(request)
+	if err != nil {
+		writeJson(writer, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	var req RequestMessage
+	if request.Method == http.MethodPatch {
+		current, getErr := s.registry.Get(callerTenant(request), id)
+		if getErr != nil {
+			writeStoreError(writer, getErr)
+			return
+		}
+		req = fromMonitor(current)
+	}
+	if err = s.decodeJSON(writer, request, &req); err != nil {
+		writeDecodeError(writer, err)
+		return
+	}
+	if fields := s.validateScoped(request, &req); len(fields) > 0 {
+		writeJson(writer, http.StatusBadRequest, newValidationError(fields))
+		return
+	}
+	monitor := req.toMonitor()
+	monitor.ID = id
+	updated, err := s.registry.Update(callerTenant(request), monitor)
+	if err != nil {
+		writeStoreError(writer, err)
+		return
+	}
+	writeJson(writer, http.StatusOK, toMonitorResponse(updated))
+}
+
+func (s *APIServer) HandleListRevisions(writer http.ResponseWriter, request *http.Request) {
+	id, err := pathID(request)
+	if err != nil {
+		writeJson(writer, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	revisions, err := s.registry.Revisions(callerTenant(request), id)
+	if err != nil {
+		writeStoreError(writer, err)
+		return
+	}
+	response := make([]RevisionResponse, 0, len(revisions))
+	for _, revision := range revisions {
+		response = append(response, RevisionResponse{MonitorResponse: toMonitorResponse(revision.Monitor), ChangedAt: revision.ChangedAt})
+	}
+	writeJson(writer, http.StatusOK, response)
+}
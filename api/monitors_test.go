@@ -0,0 +1,371 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"gopkg.in/yaml.v3"
+	"net/http"
+	"net/http/httptest"
+	"snapp-task/api"
+	"snapp-task/db"
+	"snapp-task/services"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Monitor import and export", func() {
+	var (
+		handler   http.Handler
+		mockStore *db.MonitorStoreMock
+		registry  *services.Registry
+	)
+
+	do := func(method, target, contentType string, body []byte) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, bytes.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		mockStore = newMonitorStore(
+			db.Monitor{ID: 1, URL: "https://a.com", Pattern: "a", Interval: time.Second},
+			db.Monitor{ID: 2, URL: "https://b.com", Pattern: "b", Interval: 2 * time.Second, Dedup: true},
+		)
+		schedulerFactory := func(monitor db.Monitor, database db.DB) services.CheckScheduler {
+			return &services.CheckSchedulerMock{ScheduleCheckFunc: func(ctx context.Context) {}, UpdateMonitorFunc: func(monitor db.Monitor) {}}
+		}
+		registry = services.NewRegistry(mockStore, &db.DBMock{}, schedulerFactory)
+		handler = api.NewAPIServer(":8080", registry).Routes()
+	})
+
+	AfterEach(func() {
+		registry.StopAll()
+	})
+
+	Describe("POST /monitors:bulk", func() {
+		It("should create valid items and report invalid ones", func() {
+			payload, _ := json.Marshal([]api.RequestMessage{
+				{URL: "https://c.com", Pattern: "c", Interval: 1},
+				{URL: "ftp://d.com", Pattern: "d", Interval: 1},
+				{URL: "https://e.com", Pattern: "e", Interval: 0},
+			})
+			recorder := do("POST", "/monitors:bulk", "application/json", payload)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var results []api.BulkResult
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &results)).To(Succeed())
+			Expect(results).To(HaveLen(3))
+			Expect(results[0]).To(Equal(api.BulkResult{Index: 0, ID: 3}))
+			Expect(results[1].Error).To(Equal("Validation failed"))
+			Expect(results[1].Fields).To(ConsistOf(HaveField("Code", "invalid_scheme")))
+			Expect(results[2].Fields).To(ConsistOf(HaveField("Code", "out_of_range")))
+			Expect(mockStore.CreateMonitorCalls()).To(HaveLen(1))
+		})
+
+		It("should reject a body that is not an array", func() {
+			recorder := do("POST", "/monitors:bulk", "application/json", []byte(`{"url": "https://c.com"}`))
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Describe("GET /monitors:export", func() {
+		It("should export all monitors as JSON", func() {
+			recorder := do("GET", "/monitors:export", "", nil)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var set api.MonitorSet
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &set)).To(Succeed())
+			Expect(set.Monitors).To(Equal([]api.SetMonitor{
+				{ID: 1, RequestMessage: api.RequestMessage{URL: "https://a.com", Pattern: "a", Interval: 1}},
+				{ID: 2, RequestMessage: api.RequestMessage{URL: "https://b.com", Pattern: "b", Interval: 2, Dedup: true}},
+			}))
+		})
+
+		It("should export all monitors as YAML", func() {
+			recorder := do("GET", "/monitors:export?format=yaml", "", nil)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/yaml"))
+
+			var set api.MonitorSet
+			Expect(yaml.Unmarshal(recorder.Body.Bytes(), &set)).To(Succeed())
+			Expect(set.Monitors).To(HaveLen(2))
+			Expect(set.Monitors[1].Dedup).To(BeTrue())
+		})
+	})
+
+	Describe("POST /monitors:import", func() {
+		var set api.MonitorSet
+
+		BeforeEach(func() {
+			set = api.MonitorSet{Monitors: []api.SetMonitor{
+				{ID: 1, RequestMessage: api.RequestMessage{URL: "https://a.com", Pattern: "a", Interval: 1}},
+				{ID: 2, RequestMessage: api.RequestMessage{URL: "https://b.com", Pattern: "b", Interval: 10}},
+				{RequestMessage: api.RequestMessage{URL: "https://c.com", Pattern: "c", Interval: 1}},
+			}}
+		})
+
+		It("should round-trip an export without changes", func() {
+			exported := do("GET", "/monitors:export?format=yaml", "", nil)
+			recorder := do("POST", "/monitors:import?format=yaml", "", exported.Body.Bytes())
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var response api.ImportResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Unchanged).To(Equal(2))
+			Expect(response.Create).To(BeEmpty())
+			Expect(response.Update).To(BeEmpty())
+			Expect(response.Delete).To(BeEmpty())
+		})
+
+		It("should keep monitors apart that differ only by selector", func() {
+			for _, selector := range []string{"#price", "#stock"} {
+				_, err := registry.Create(db.Monitor{URL: "https://shop.com", Pattern: "1", Interval: time.Second, Selector: selector})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			exported := do("GET", "/monitors:export", "", nil)
+			recorder := do("POST", "/monitors:import?prune=true", "application/json", exported.Body.Bytes())
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var response api.ImportResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Unchanged).To(Equal(4))
+			Expect(response.Delete).To(BeEmpty())
+
+			var edited api.MonitorSet
+			Expect(json.Unmarshal(exported.Body.Bytes(), &edited)).To(Succeed())
+			edited.Monitors[3].Selector = "#stock .count"
+			payload, _ := json.Marshal(edited)
+			recorder = do("POST", "/monitors:import?prune=true", "application/json", payload)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Update).To(ConsistOf(HaveField("ID", int64(4))))
+			Expect(response.Create).To(BeEmpty())
+			Expect(response.Delete).To(BeEmpty())
+			monitors, err := registry.List(0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(monitors).To(HaveLen(4))
+			Expect(monitors[2].Selector).To(Equal("#price"))
+		})
+
+		It("should report the diff without applying it in dry-run mode", func() {
+			payload, _ := json.Marshal(set)
+			recorder := do("POST", "/monitors:import?dry_run=true&prune=true", "application/json", payload)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var response api.ImportResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.DryRun).To(BeTrue())
+			Expect(response.Create).To(Equal([]api.RequestMessage{set.Monitors[2].RequestMessage}))
+			Expect(response.Update).To(HaveLen(1))
+			Expect(response.Update[0].ID).To(Equal(int64(2)))
+			Expect(response.Update[0].After.Interval).To(Equal(10))
+			Expect(response.Delete).To(BeEmpty())
+			Expect(mockStore.CreateMonitorCalls()).To(BeEmpty())
+			Expect(mockStore.UpdateMonitorCalls()).To(BeEmpty())
+		})
+
+		It("should apply the diff and prune missing monitors", func() {
+			set.Monitors = set.Monitors[1:]
+			payload, _ := yaml.Marshal(set)
+			recorder := do("POST", "/monitors:import?prune=true", "application/yaml", payload)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			monitors, err := registry.List(0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(monitors).To(HaveLen(2))
+			Expect(monitors[0].URL).To(Equal("https://b.com"))
+			Expect(monitors[0].Interval).To(Equal(10 * time.Second))
+			Expect(monitors[1].URL).To(Equal("https://c.com"))
+		})
+
+		It("should reject the import when any monitor is invalid", func() {
+			set.Monitors[1].Pattern = ""
+			payload, _ := json.Marshal(set)
+			recorder := do("POST", "/monitors:import", "application/json", payload)
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+			var results []api.BulkResult
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &results)).To(Succeed())
+			Expect(results).To(Equal([]api.BulkResult{{
+				Index:  1,
+				Error:  "Validation failed",
+				Fields: []api.FieldError{{Field: "pattern", Code: "required", Message: "pattern is required"}},
+			}}))
+			Expect(mockStore.CreateMonitorCalls()).To(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("Monitor updates", func() {
+	var (
+		handler   http.Handler
+		mockStore *db.MonitorStoreMock
+		registry  *services.Registry
+		schedule  *services.CheckSchedulerMock
+	)
+
+	do := func(method, target string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, target, bytes.NewReader(payload)))
+		return recorder
+	}
+
+	BeforeEach(func() {
+		mockStore = newMonitorStore(db.Monitor{ID: 1, URL: "https://a.com", Pattern: "a", Interval: time.Second, Revision: 1})
+		schedule = &services.CheckSchedulerMock{
+			ScheduleCheckFunc: func(ctx context.Context) {},
+			UpdateMonitorFunc: func(monitor db.Monitor) {},
+		}
+		schedulerFactory := func(monitor db.Monitor, db db.DB) services.CheckScheduler {
+			return schedule
+		}
+		registry = services.NewRegistry(mockStore, &db.DBMock{}, schedulerFactory)
+		Expect(registry.Start()).To(Succeed())
+		handler = api.NewAPIServer(":8080", registry).Routes()
+	})
+
+	AfterEach(func() {
+		registry.StopAll()
+	})
+
+	It("should replace the monitor on PUT and hot-swap the scheduler", func() {
+		recorder := do("PUT", "/monitors/1", api.RequestMessage{URL: "https://a.com", Pattern: "b", Interval: 5})
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		var response api.MonitorResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+		Expect(response.ID).To(Equal(int64(1)))
+		Expect(response.Revision).To(Equal(2))
+		Expect(response.Pattern).To(Equal("b"))
+		Expect(response.Interval).To(Equal(5))
+
+		Expect(schedule.UpdateMonitorCalls()).To(HaveLen(1))
+		Expect(schedule.UpdateMonitorCalls()[0].Monitor.Interval).To(Equal(5 * time.Second))
+	})
+
+	It("should keep omitted fields on PATCH", func() {
+		recorder := do("PATCH", "/monitors/1", map[string]any{"interval": 7})
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		monitor, err := registry.Get(0, 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitor.URL).To(Equal("https://a.com"))
+		Expect(monitor.Pattern).To(Equal("a"))
+		Expect(monitor.Interval).To(Equal(7 * time.Second))
+	})
+
+	It("should validate the change with the create rules", func() {
+		recorder := do("PATCH", "/monitors/1", map[string]any{"url": "gopher://a.com"})
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(mockStore.UpdateMonitorCalls()).To(BeEmpty())
+		Expect(schedule.UpdateMonitorCalls()).To(BeEmpty())
+	})
+
+	It("should return 404 for unknown monitors", func() {
+		Expect(do("PUT", "/monitors/9", api.RequestMessage{URL: "https://a.com", Pattern: "b", Interval: 5}).Code).To(Equal(http.StatusNotFound))
+		Expect(do("PATCH", "/monitors/9", map[string]any{"interval": 7}).Code).To(Equal(http.StatusNotFound))
+		Expect(do("GET", "/monitors/9", nil).Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should return 400 for a malformed id", func() {
+		Expect(do("GET", "/monitors/abc", nil).Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should list the monitor's revisions", func() {
+		recorder := do("GET", "/monitors/1/revisions", nil)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		var revisions []api.RevisionResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &revisions)).To(Succeed())
+		Expect(revisions).To(HaveLen(1))
+		Expect(revisions[0].Revision).To(Equal(1))
+	})
+})
+
+var _ = Describe("Duplicate monitors", func() {
+	var (
+		handler   http.Handler
+		mockStore *db.MonitorStoreMock
+		registry  *services.Registry
+	)
+
+	post := func(target, idempotencyKey string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", target, bytes.NewReader(payload))
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		mockStore = newMonitorStore(db.Monitor{ID: 1, URL: "https://a.com", Pattern: "a", Interval: time.Second, Revision: 1})
+		schedulerFactory := func(monitor db.Monitor, database db.DB) services.CheckScheduler {
+			return &services.CheckSchedulerMock{
+				ScheduleCheckFunc: func(ctx context.Context) {},
+				UpdateMonitorFunc: func(updated db.Monitor) {},
+			}
+		}
+		registry = services.NewRegistry(mockStore, &db.DBMock{}, schedulerFactory)
+		handler = api.NewAPIServer(":8080", registry).Routes()
+	})
+
+	AfterEach(func() {
+		registry.StopAll()
+	})
+
+	It("should return 409 with the existing id for an equivalent monitor", func() {
+		recorder := post("/", "", api.RequestMessage{URL: "HTTPS://A.COM/", Pattern: "a", Interval: 1})
+		Expect(recorder.Code).To(Equal(http.StatusConflict))
+		Expect(recorder.Body.String()).To(MatchJSON(`{"error": "Monitor already exists", "id": 1}`))
+		Expect(mockStore.CreateMonitorCalls()).To(BeEmpty())
+	})
+
+	It("should report duplicates in bulk results", func() {
+		recorder := post("/monitors:bulk", "", []api.RequestMessage{
+			{URL: "https://a.com", Pattern: "a", Interval: 1},
+			{URL: "https://b.com", Pattern: "b", Interval: 1},
+		})
+		var results []api.BulkResult
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &results)).To(Succeed())
+		Expect(results).To(Equal([]api.BulkResult{
+			{Index: 0, ID: 1, Error: "Monitor already exists"},
+			{Index: 1, ID: 2},
+		}))
+	})
+
+	It("should return 409 when an update collides with another monitor", func() {
+		Expect(post("/", "", api.RequestMessage{URL: "https://b.com", Pattern: "a", Interval: 1}).Code).To(Equal(http.StatusOK))
+		payload, _ := json.Marshal(map[string]any{"url": "https://a.com"})
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("PATCH", "/monitors/2", bytes.NewReader(payload)))
+		Expect(recorder.Code).To(Equal(http.StatusConflict))
+		Expect(mockStore.UpdateMonitorCalls()).To(BeEmpty())
+	})
+
+	It("should replay the response for a repeated idempotency key", func() {
+		body := api.RequestMessage{URL: "https://b.com", Pattern: "b", Interval: 1}
+		first := post("/", "retry-1", body)
+		Expect(first.Code).To(Equal(http.StatusOK))
+		second := post("/", "retry-1", body)
+		Expect(second.Code).To(Equal(http.StatusOK))
+		Expect(second.Body.String()).To(MatchJSON(first.Body.String()))
+		Expect(mockStore.CreateMonitorCalls()).To(HaveLen(1))
+	})
+
+	It("should reject a reused idempotency key with a different request", func() {
+		Expect(post("/", "retry-1", api.RequestMessage{URL: "https://b.com", Pattern: "b", Interval: 1}).Code).To(Equal(http.StatusOK))
+		recorder := post("/", "retry-1", api.RequestMessage{URL: "https://c.com", Pattern: "c", Interval: 1})
+		Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(mockStore.CreateMonitorCalls()).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"snapp-task/db"
+	"snapp-task/services"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit is a token bucket refilled at PerSecond requests per second and holding up to Burst requests.
+type RateLimit struct {
+	PerSecond float64
+	Burst     int
+}
+
+// ParseRateLimits parses "ROUTE=RATE:BURST" entries separated by commas, where ROUTE is a route pattern
+// such as "POST /monitors:bulk".
+func ParseRateLimits(spec string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, entry := range strings.Split(spec, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		separator := strings.LastIndex(entry, "=")
+		if separator < 0 {
+			return nil, fmt.Errorf("invalid rate limit %q, want ROUTE=RATE:BURST", entry)
+		}
+		rate, burst, ok := strings.Cut(entry[separator+1:], ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit %q, want ROUTE=RATE:BURST", entry)
+		}
+		var limit RateLimit
+		var err error
+		if limit.PerSecond, err = strconv.ParseFloat(rate, 64); err != nil || limit.PerSecond <= 0 {
+			return nil, fmt.Errorf("invalid rate in %q", entry)
+		}
+		if limit.Burst, err = strconv.Atoi(burst); err != nil || limit.Burst < 1 {
+			return nil, fmt.Errorf("invalid burst in %q", entry)
+		}
+		limits[strings.TrimSpace(entry[:separator])] = limit
+	}
+	return limits, nil
+}
+
+// clientIP returns the address the request came from.
+func clientIP(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		host = request.RemoteAddr
+	}
+	return host
+}
+
+// clientKey identifies the client for rate limiting: its API key when authenticated, its address otherwise.
+func clientKey(request *http.Request) string {
+	if id := callerID(request); id != 0 {
+		return "key:" + strconv.FormatInt(id, 10)
+	}
+	return "ip:" + clientIP(request)
+}
+
+func writeTooManyRequests(writer http.ResponseWriter, retryAfter time.Duration, message string) {
+	writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	writeJson(writer, http.StatusTooManyRequests, apiError{Error: message})
+}
+
+// rateLimit throttles handler per client using the limit configured for pattern, falling back to
+// DefaultRateLimit. Throttled requests get 429 with Retry-After.
+func (s *APIServer) rateLimit(pattern string, handler http.HandlerFunc) http.HandlerFunc {
+	limit, ok := s.RateLimits[pattern]
+	if !ok {
+		limit = s.DefaultRateLimit
+	}
+	if limit.PerSecond <= 0 {
+		return handler
+	}
+	limiter := services.NewRateLimiter(limit.PerSecond, limit.Burst)
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if allowed, retryAfter := limiter.Allow(clientKey(request)); !allowed {
+			writeTooManyRequests(writer, retryAfter, "Rate limit exceeded")
+			return
+		}
+		handler(writer, request)
+	}
+}
+
+// handle registers handler for pattern. Requests are throttled before their scope is checked, so
+// unauthenticated requests are limited by address like any other.
+func (s *APIServer) handle(router *http.ServeMux, pattern string, scope db.Scope, handler http.HandlerFunc) {
+	router.HandleFunc(pattern, s.authenticate(s.rateLimit(pattern, s.requireScope(scope, handler))))
+}
@@ -0,0 +1,100 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"snapp-task/api"
+	"snapp-task/db"
+	"snapp-task/services"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rate limiting", func() {
+	var (
+		handler  http.Handler
+		registry *services.Registry
+	)
+
+	do := func(method, target, remoteAddr string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(`{"url": "https://a.com", "pattern": "a", "interval": 1}`))
+		req.RemoteAddr = remoteAddr
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		schedulerFactory := func(monitor db.Monitor, database db.DB) services.CheckScheduler {
+			return &services.CheckSchedulerMock{ScheduleCheckFunc: func(ctx context.Context) {}}
+		}
+		registry = services.NewRegistry(newMonitorStore(), &db.DBMock{}, schedulerFactory)
+		server := api.NewAPIServer(":8080", registry)
+		server.DefaultRateLimit = api.RateLimit{PerSecond: 100, Burst: 3}
+		server.RateLimits = map[string]api.RateLimit{"POST /": {PerSecond: 0.5, Burst: 1}}
+		handler = server.Routes()
+	})
+
+	AfterEach(func() {
+		registry.StopAll()
+	})
+
+	It("should return 429 with Retry-After once a client exceeds a route's limit", func() {
+		Expect(do("POST", "/", "10.0.0.1:1234").Code).To(Equal(http.StatusOK))
+		recorder := do("POST", "/", "10.0.0.1:1234")
+		Expect(recorder.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(recorder.Header().Get("Retry-After")).To(Equal("2"))
+	})
+
+	It("should limit each client separately", func() {
+		Expect(do("POST", "/", "10.0.0.1:1234").Code).To(Equal(http.StatusOK))
+		Expect(do("POST", "/", "10.0.0.2:1234").Code).To(Equal(http.StatusConflict))
+	})
+
+	It("should use the default limit for other routes", func() {
+		for i := 0; i < 3; i++ {
+			Expect(do("GET", "/monitors:export", "10.0.0.1:1234").Code).To(Equal(http.StatusOK))
+		}
+		Expect(do("GET", "/monitors:export", "10.0.0.1:1234").Code).To(Equal(http.StatusTooManyRequests))
+	})
+
+	It("should answer repeated bad keys from an address with 429", func() {
+		keys := services.NewAPIKeys(newKeyStore())
+		_, secret, err := keys.Create("root", db.ScopeRead, 0)
+		Expect(err).NotTo(HaveOccurred())
+		server := api.NewAPIServer(":8080", registry)
+		server.Keys = keys
+		server.AuthFailureLimit = api.RateLimit{PerSecond: 0.01, Burst: 2}
+		handler = server.Routes()
+		get := func(secret, remoteAddr string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/monitors:export", nil)
+			req.RemoteAddr = remoteAddr
+			req.Header.Set("X-API-Key", secret)
+			handler.ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		Expect(get("bad", "10.0.0.1:1234").Code).To(Equal(http.StatusUnauthorized))
+		Expect(get("", "10.0.0.1:1234").Code).To(Equal(http.StatusUnauthorized))
+		recorder := get("bad", "10.0.0.1:1234")
+		Expect(recorder.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(recorder.Header().Get("Retry-After")).NotTo(BeEmpty())
+		Expect(get(secret, "10.0.0.1:1234").Code).To(Equal(http.StatusTooManyRequests))
+		Expect(get(secret, "10.0.0.2:1234").Code).To(Equal(http.StatusOK))
+	})
+
+	It("should parse per-route limits", func() {
+		limits, err := api.ParseRateLimits("POST /=1:10, POST /monitors:bulk=0.2:2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(limits).To(Equal(map[string]api.RateLimit{
+			"POST /":              {PerSecond: 1, Burst: 10},
+			"POST /monitors:bulk": {PerSecond: 0.2, Burst: 2},
+		}))
+		_, err = api.ParseRateLimits("POST /=fast")
+		Expect(err).To(HaveOccurred())
+	})
+})
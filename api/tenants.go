@@ -0,0 +1,162 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"snapp-task/db"
+	"time"
+)
+
+type TenantRequest struct {
+	Name            string `json:"name"`
+	MaxMonitors     int    `json:"max_monitors,omitempty"`
+	MinInterval     int    `json:"min_interval,omitempty"`
+	MaxStorageBytes int64  `json:"max_storage_bytes,omitempty"`
+}
+
+func (req TenantRequest) toTenant() db.Tenant {
+	return db.Tenant{
+		Name:            req.Name,
+		MaxMonitors:     req.MaxMonitors,
+		MinInterval:     time.Duration(req.MinInterval) * time.Second,
+		MaxStorageBytes: req.MaxStorageBytes,
+	}
+}
+
+type UsageResponse struct {
+	Monitors     int   `json:"monitors"`
+	StorageBytes int64 `json:"storage_bytes"`
+}
+
+type TenantResponse struct {
+	ID int64 `json:"id"`
+	TenantRequest
+	Usage *UsageResponse `json:"usage,omitempty"`
+}
+
+func toTenantResponse(tenant db.Tenant) TenantResponse {
+	return TenantResponse{ID: tenant.ID, TenantRequest: TenantRequest{
+		Name:            tenant.Name,
+		MaxMonitors:     tenant.MaxMonitors,
+		MinInterval:     int(tenant.MinInterval / time.Second),
+		MaxStorageBytes: tenant.MaxStorageBytes,
+	}}
+}
+
+func validateTenant(req TenantRequest) []FieldError {
+	var fields []FieldError
+	if req.Name == "" {
+		fields = append(fields, FieldError{Field: "name", Code: codeRequired, Message: "name is required"})
+	}
+	if req.MaxMonitors < 0 {
+		fields = append(fields, FieldError{Field: "max_monitors", Code: codeOutOfRange, Message: "max_monitors must not be negative"})
+	}
+	if req.MinInterval < 0 {
+		fields = append(fields, FieldError{Field: "min_interval", Code: codeOutOfRange, Message: "min_interval must not be negative"})
+	}
+	if req.MaxStorageBytes < 0 {
+		fields = append(fields, FieldError{Field: "max_storage_bytes", Code: codeOutOfRange, Message: "max_storage_bytes must not be negative"})
+	}
+	return fields
+}
+
+func writeTenantError(writer http.ResponseWriter, err error) {
+	if errors.Is(err, db.ErrNotFound) {
+		writeJson(writer, http.StatusNotFound, apiError{Error: "Tenant not found"})
+		return
+	}
+	writeJson(writer, http.StatusInternalServerError, apiError{Error: err.Error()})
+}
+
+func (s *APIServer) HandleCreateTenant(writer http.ResponseWriter, request *http.Request) {
+	if callerTenant(request) != 0 {
+		writeGlobalOnly(writer)
+		return
+	}
+	var req TenantRequest
+	if err := s.decodeJSON(writer, request, &req); err != nil {
+		writeDecodeError(writer, err)
+		return
+	}
+	if fields := validateTenant(req); len(fields) > 0 {
+		writeJson(writer, http.StatusBadRequest, newValidationError(fields))
+		return
+	}
+	tenant, err := s.Tenants.CreateTenant(req.toTenant())
+	if err != nil {
+		writeTenantError(writer, err)
+		return
+	}
+	writeJson(writer, http.StatusCreated, toTenantResponse(tenant))
+}
+
+func (s *APIServer) HandleListTenants(writer http.ResponseWriter, request *http.Request) {
+	if callerTenant(request) != 0 {
+		writeGlobalOnly(writer)
+		return
+	}
+	tenants, err := s.Tenants.ListTenants()
+	if err != nil {
+		writeTenantError(writer, err)
+		return
+	}
+	response := make([]TenantResponse, 0, len(tenants))
+	for _, tenant := range tenants {
+		response = append(response, toTenantResponse(tenant))
+	}
+	writeJson(writer, http.StatusOK, response)
+}
+
+// HandleGetTenant returns the tenant with its current usage. Tenant keys may only read their own tenant.
+func (s *APIServer) HandleGetTenant(writer http.ResponseWriter, request *http.Request) {
+	id, err := pathID(request)
+	if err != nil {
+		writeJson(writer, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	if tenantID := callerTenant(request); tenantID != 0 && tenantID != id {
+		writeTenantError(writer, db.ErrNotFound)
+		return
+	}
+	tenant, err := s.Tenants.GetTenant(id)
+	if err != nil {
+		writeTenantError(writer, err)
+		return
+	}
+	usage, err := s.Tenants.GetTenantUsage(id)
+	if err != nil {
+		writeTenantError(writer, err)
+		return
+	}
+	response := toTenantResponse(tenant)
+	response.Usage = &UsageResponse{Monitors: usage.Monitors, StorageBytes: usage.StorageBytes}
+	writeJson(writer, http.StatusOK, response)
+}
+
+func (s *APIServer) HandleUpdateTenant(writer http.ResponseWriter, request *http.Request) {
+	if callerTenant(request) != 0 {
+		writeGlobalOnly(writer)
+		return
+	}
+	id, err := pathID(request)
+	if err != nil {
+		writeJson(writer, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	var req TenantRequest
+	if err = s.decodeJSON(writer, request, &req); err != nil {
+		writeDecodeError(writer, err)
+		return
+	}
+	if fields := validateTenant(req); len(fields) > 0 {
+		writeJson(writer, http.StatusBadRequest, newValidationError(fields))
+		return
+	}
+	tenant := req.toTenant()
+	tenant.ID = id
+	if tenant, err = s.Tenants.UpdateTenant(tenant); err != nil {
+		writeTenantError(writer, err)
+		return
+	}
+	writeJson(writer, http.StatusOK, toTenantResponse(tenant))
+}
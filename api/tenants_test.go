@@ -0,0 +1,149 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"snapp-task/api"
+	"snapp-task/db"
+	"snapp-task/services"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tenants", func() {
+	var (
+		handler  http.Handler
+		registry *services.Registry
+		tenants  map[int64]db.Tenant
+		global   string
+		acme     string
+	)
+
+	do := func(method, target, secret string, body any) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, bytes.NewReader(payload))
+		req.Header.Set("X-API-Key", secret)
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		mockStore := newMonitorStore(
+			db.Monitor{ID: 1, URL: "https://a.com", Pattern: "a", Interval: time.Minute, TenantID: 2},
+			db.Monitor{ID: 2, URL: "https://b.com", Pattern: "b", Interval: time.Minute, TenantID: 1},
+		)
+		schedulerFactory := func(monitor db.Monitor, database db.DB) services.CheckScheduler {
+			return &services.CheckSchedulerMock{ScheduleCheckFunc: func(ctx context.Context) {}}
+		}
+		tenants = map[int64]db.Tenant{
+			1: {ID: 1, Name: "default"},
+			2: {ID: 2, Name: "acme", MinInterval: 30 * time.Second},
+		}
+		tenantStore := &db.TenantStoreMock{
+			GetTenantFunc: func(id int64) (db.Tenant, error) {
+				tenant, ok := tenants[id]
+				if !ok {
+					return tenant, db.ErrNotFound
+				}
+				return tenant, nil
+			},
+			GetTenantUsageFunc: func(id int64) (db.TenantUsage, error) {
+				return db.TenantUsage{Monitors: 1, StorageBytes: 42}, nil
+			},
+			CreateTenantFunc: func(tenant db.Tenant) (db.Tenant, error) {
+				tenant.ID = int64(len(tenants) + 1)
+				tenants[tenant.ID] = tenant
+				return tenant, nil
+			},
+		}
+		registry = services.NewRegistry(mockStore, &db.DBMock{}, schedulerFactory)
+		registry.Tenants = tenantStore
+		keys := services.NewAPIKeys(newKeyStore())
+		var err error
+		_, global, err = keys.Create("root", db.ScopeAdmin, 0)
+		Expect(err).NotTo(HaveOccurred())
+		_, acme, err = keys.Create("acme", db.ScopeAdmin, 2)
+		Expect(err).NotTo(HaveOccurred())
+		server := api.NewAPIServer(":8080", registry)
+		server.Keys = keys
+		server.Tenants = tenantStore
+		handler = server.Routes()
+	})
+
+	AfterEach(func() {
+		registry.StopAll()
+	})
+
+	It("should scope monitor queries to the caller's tenant", func() {
+		recorder := do("GET", "/monitors:export", acme, nil)
+		var set api.MonitorSet
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &set)).To(Succeed())
+		Expect(set.Monitors).To(HaveLen(1))
+		Expect(set.Monitors[0].URL).To(Equal("https://a.com"))
+
+		Expect(do("GET", "/monitors/2", acme, nil).Code).To(Equal(http.StatusNotFound))
+		Expect(do("PATCH", "/monitors/2", acme, map[string]any{"interval": 60}).Code).To(Equal(http.StatusNotFound))
+		Expect(do("GET", "/monitors/2", global, nil).Code).To(Equal(http.StatusOK))
+	})
+
+	It("should create monitors in the caller's tenant", func() {
+		recorder := do("POST", "/", acme, api.RequestMessage{URL: "https://c.com", Pattern: "c", Interval: 60})
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		monitor, err := registry.Get(2, 3)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitor.TenantID).To(Equal(int64(2)))
+
+		recorder = do("POST", "/", acme, api.RequestMessage{URL: "https://d.com", Pattern: "d", Interval: 60, TenantID: 1})
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should reject monitors that exceed the tenant's quotas", func() {
+		recorder := do("POST", "/", acme, api.RequestMessage{URL: "https://c.com", Pattern: "c", Interval: 5})
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		Expect(recorder.Body.String()).To(MatchJSON(`{"error": "Tenant quota exceeded", "quota": "min_interval", "limit": 30}`))
+	})
+
+	It("should let global callers place monitors in any existing tenant", func() {
+		Expect(do("POST", "/", global, api.RequestMessage{URL: "https://c.com", Pattern: "c", Interval: 60, TenantID: 1}).Code).To(Equal(http.StatusOK))
+		Expect(do("POST", "/", global, api.RequestMessage{URL: "https://d.com", Pattern: "d", Interval: 60, TenantID: 9}).Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should restrict tenant management to global admins", func() {
+		Expect(do("POST", "/tenants", acme, api.TenantRequest{Name: "other"}).Code).To(Equal(http.StatusForbidden))
+		Expect(do("GET", "/tenants/1", acme, nil).Code).To(Equal(http.StatusNotFound))
+
+		recorder := do("GET", "/tenants/2", acme, nil)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		var response api.TenantResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+		Expect(response.Usage).To(Equal(&api.UsageResponse{Monitors: 1, StorageBytes: 42}))
+
+		recorder = do("POST", "/tenants", global, api.TenantRequest{Name: "other", MaxMonitors: 5})
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+		Expect(tenants[3].MaxMonitors).To(Equal(5))
+	})
+
+	It("should only let tenant admins issue keys for their own tenant", func() {
+		recorder := do("POST", "/keys", acme, api.APIKeyRequest{Name: "ci", Scope: db.ScopeRead})
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+		var created api.APIKeyResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &created)).To(Succeed())
+		Expect(created.TenantID).To(Equal(int64(2)))
+
+		Expect(do("POST", "/keys", acme, api.APIKeyRequest{Name: "ci", Scope: db.ScopeRead, TenantID: 1}).Code).To(Equal(http.StatusBadRequest))
+		Expect(do("DELETE", "/keys/1", acme, nil).Code).To(Equal(http.StatusNotFound))
+
+		var listed []api.APIKeyResponse
+		Expect(json.Unmarshal(do("GET", "/keys", acme, nil).Body.Bytes(), &listed)).To(Succeed())
+		Expect(listed).To(HaveLen(2))
+	})
+})
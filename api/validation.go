@@ -0,0 +1,366 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"regexp/syntax"
+	"snapp-task/services"
+	"sort"
+	"strings"
+)
+
+const defaultMaxBodyBytes = 1 << 20
+
+const maxSteps = 10
+
+const (
+	codeRequired         = "required"
+	codeInvalidURL       = "invalid_url"
+	codeInvalidScheme    = "invalid_scheme"
+	codeOutOfRange       = "out_of_range"
+	codeInvalidRegexp    = "invalid_regexp"
+	codeUnknownField     = "unknown_field"
+	codeInvalidType      = "invalid_type"
+	codeInvalidScope     = "invalid_scope"
+	codeForbidden        = "forbidden"
+	codeUnknownTenant    = "unknown_tenant"
+	codeEgressDenied     = "egress_denied"
+	codeInvalidSelector  = "invalid_selector"
+	codeInvalidXPath     = "invalid_xpath"
+	codeInvalidValuePath = "invalid_value_path"
+	codeInvalidCondition = "invalid_condition"
+	codeInvalidRule      = "invalid_rule"
+	codeInvalidMethod    = "invalid_method"
+	codeInvalidVariable  = "invalid_variable"
+	codeUnknownVariable  = "unknown_variable"
+	codeUnsupported      = "unsupported"
+	codeUnknownMonitor   = "unknown_monitor"
+	codeInvalidTemplate  = "invalid_template"
+	validationFailed     = "Validation failed"
+	requestTooLarge      = "Request body too large"
+	malformedBodyError   = "Malformed request body"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Position is the byte offset of the offending part of the value, when known.
+	Position *int `json:"position,omitempty"`
+}
+
+type ValidationError struct {
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields"`
+}
+
+func newValidationError(fields []FieldError) ValidationError {
+	return ValidationError{Error: validationFailed, Fields: fields}
+}
+
+func validateURL(input string) *FieldError {
+	if input == "" {
+		return &FieldError{Field: "url", Code: codeRequired, Message: "url is required"}
+	}
+	if services.IsProbeURL(input) {
+		if _, err := services.ParseProbe(input); err != nil {
+			return &FieldError{Field: "url", Code: codeInvalidURL, Message: "url is not a valid probe: " + err.Error()}
+		}
+		return nil
+	}
+	parsedURL, err := url.ParseRequestURI(input)
+	if err != nil {
+		return &FieldError{Field: "url", Code: codeInvalidURL, Message: fmt.Sprintf("url is not a valid absolute URL: %v", errors.Unwrap(err))}
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return &FieldError{Field: "url", Code: codeInvalidScheme, Message: fmt.Sprintf("url scheme must be http, https, tcp, dns or tls, got %q", parsedURL.Scheme)}
+	}
+	if parsedURL.Host == "" {
+		return &FieldError{Field: "url", Code: codeInvalidURL, Message: "url has no host"}
+	}
+	return nil
+}
+
+func validatePattern(pattern string) *FieldError {
+	if pattern == "" {
+		return &FieldError{Field: "pattern", Code: codeRequired, Message: "pattern is required"}
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		fieldError := &FieldError{Field: "pattern", Code: codeInvalidRegexp, Message: fmt.Sprintf("pattern does not compile: %v", err)}
+		var syntaxErr *syntax.Error
+		// The parser only reports the offending fragment, so its position is only known when the fragment
+		// occurs once in the pattern.
+		if errors.As(err, &syntaxErr) && syntaxErr.Expr != "" {
+			if position := strings.Index(pattern, syntaxErr.Expr); position >= 0 && position == strings.LastIndex(pattern, syntaxErr.Expr) {
+				fieldError.Position = &position
+				fieldError.Message = fmt.Sprintf("pattern does not compile at offset %d: %s: `%s`", position, syntaxErr.Code, syntaxErr.Expr)
+			}
+		}
+		return fieldError
+	}
+	return nil
+}
+
+func validateInterval(input int) *FieldError {
+	if input < 1 {
+		return &FieldError{Field: "interval", Code: codeOutOfRange, Message: fmt.Sprintf("interval must be at least 1 second, got %d", input)}
+	}
+	return nil
+}
+
+func validateSelector(selector string) *FieldError {
+	if selector == "" {
+		return nil
+	}
+	if _, err := services.ParseSelector(selector); err != nil {
+		return &FieldError{Field: "selector", Code: codeInvalidSelector, Message: "selector is not a valid CSS selector: " + err.Error()}
+	}
+	return nil
+}
+
+func validateXPath(xpath string) *FieldError {
+	if xpath == "" {
+		return nil
+	}
+	if _, err := services.ParseXPath(xpath); err != nil {
+		return &FieldError{Field: "xpath", Code: codeInvalidXPath, Message: "xpath is not a valid XPath 1.0 expression: " + err.Error()}
+	}
+	return nil
+}
+
+func validateValuePath(valuePath string) *FieldError {
+	if valuePath == "" {
+		return nil
+	}
+	_, err := services.ParseValuePath(valuePath)
+	var parseErr *services.ParseError
+	if errors.As(err, &parseErr) {
+		position := parseErr.Offset
+		return &FieldError{Field: "value_path", Code: codeInvalidValuePath, Message: "value_path is not a valid path: " + parseErr.Error(), Position: &position}
+	}
+	return nil
+}
+
+func validateCondition(condition string, valuePath string) *FieldError {
+	if condition == "" {
+		return nil
+	}
+	if valuePath == "" {
+		return &FieldError{Field: "value_path", Code: codeRequired, Message: "value_path is required with a condition"}
+	}
+	if _, err := services.ParseCondition(condition); err != nil {
+		return &FieldError{Field: "condition", Code: codeInvalidCondition, Message: "condition is not valid: " + err.Error()}
+	}
+	return nil
+}
+
+func validateRule(rule string) *FieldError {
+	if rule == "" {
+		return nil
+	}
+	_, err := services.CompileRule(rule)
+	var parseErr *services.ParseError
+	if errors.As(err, &parseErr) {
+		position := parseErr.Offset
+		return &FieldError{Field: "rule", Code: codeInvalidRule, Message: "rule does not compile: " + parseErr.Error(), Position: &position}
+	}
+	if err != nil {
+		return &FieldError{Field: "rule", Code: codeInvalidRule, Message: "rule does not compile: " + err.Error()}
+	}
+	return nil
+}
+
+// stepURL replaces the variable references in a step's URL, so it can be validated before they are known.
+func stepURL(rawURL string) string {
+	variables := make(map[string]string)
+	for _, name := range services.TemplateVariables(rawURL) {
+		variables[name] = name
+	}
+	expanded, _ := services.ExpandVariables(rawURL, variables)
+	return expanded
+}
+
+// validateSteps checks the steps of a multi-step monitor. Fields are reported as steps[N].field, and
+// every variable has to be extracted by an earlier step than the one referring to it.
+func validateSteps(steps []StepMessage) []FieldError {
+	if len(steps) > maxSteps {
+		return []FieldError{{Field: "steps", Code: codeOutOfRange, Message: fmt.Sprintf("at most %d steps are allowed, got %d", maxSteps, len(steps))}}
+	}
+	var fields []FieldError
+	add := func(index int, field string, fieldError *FieldError) {
+		if fieldError != nil {
+			fieldError.Field = fmt.Sprintf("steps[%d].%s", index, field)
+			fields = append(fields, *fieldError)
+		}
+	}
+	extracted := make(map[string]bool)
+	for i, step := range steps {
+		add(i, "url", validateURL(stepURL(step.URL)))
+		if step.Method != "" {
+			if _, err := http.NewRequest(step.Method, "http://localhost", nil); err != nil {
+				add(i, "method", &FieldError{Code: codeInvalidMethod, Message: fmt.Sprintf("method %q is not valid", step.Method)})
+			}
+		}
+		if step.Status != 0 && (step.Status < 100 || step.Status > 599) {
+			add(i, "status", &FieldError{Code: codeOutOfRange, Message: fmt.Sprintf("status must be between 100 and 599, got %d", step.Status)})
+		}
+		if step.Pattern != "" {
+			add(i, "pattern", validatePattern(step.Pattern))
+		}
+		add(i, "rule", validateRule(step.Rule))
+		templates := []string{step.URL, step.Body}
+		for _, value := range step.Headers {
+			templates = append(templates, value)
+		}
+		for _, template := range templates {
+			for _, name := range services.TemplateVariables(template) {
+				if !extracted[name] {
+					add(i, "variables", &FieldError{Code: codeUnknownVariable, Message: fmt.Sprintf("variable %q is not extracted by an earlier step", name)})
+				}
+			}
+		}
+		names := make([]string, 0, len(step.Extract))
+		for name := range step.Extract {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if !services.IsVariableName(name) {
+				add(i, "extract", &FieldError{Code: codeInvalidVariable, Message: fmt.Sprintf("%q is not a valid variable name", name)})
+			}
+			add(i, "extract."+name, validateValuePath(step.Extract[name]))
+		}
+		for _, name := range names {
+			extracted[name] = true
+		}
+	}
+	return fields
+}
+
+// validateProbe rejects the options tcp, dns and tls monitors have no use for. Their pattern is
+// optional, except for tls monitors, which match on certificate expiry alone.
+func validateProbe(req RequestMessage) []FieldError {
+	kind, _, _ := strings.Cut(strings.ToLower(req.URL), "://")
+	var fields []FieldError
+	for _, option := range []struct {
+		field string
+		set   bool
+	}{
+		{"pattern", kind == "tls" && req.Pattern != ""},
+		{"selector", req.Selector != ""},
+		{"xpath", req.XPath != ""},
+		{"csv_column", req.CSVColumn != ""},
+		{"value_path", req.ValuePath != ""},
+		{"condition", req.Condition != ""},
+		{"rule", req.Rule != ""},
+		{"steps", len(req.Steps) > 0},
+	} {
+		if option.set {
+			fields = append(fields, FieldError{Field: option.field, Code: codeUnsupported, Message: fmt.Sprintf("%s is not supported by %s monitors", option.field, kind)})
+		}
+	}
+	return fields
+}
+
+// validateRequest checks every field of req and returns one FieldError per offending field.
+func validateRequest(req RequestMessage) []FieldError {
+	var fields []FieldError
+	if services.IsProbeURL(req.URL) {
+		fields = validateProbe(req)
+	}
+	// Monitors extracting a value match on their condition, and rules, multi-step monitors and probes
+	// can match on their own, so none of them needs a pattern.
+	var patternError *FieldError
+	if (req.ValuePath == "" && req.Rule == "" && len(req.Steps) == 0 && !services.IsProbeURL(req.URL)) || req.Pattern != "" {
+		patternError = validatePattern(req.Pattern)
+	}
+	// The url of a multi-step monitor defaults to the first step's.
+	var urlError *FieldError
+	if len(req.Steps) == 0 || req.URL != "" {
+		urlError = validateURL(req.URL)
+	}
+	for _, fieldError := range []*FieldError{urlError, validateInterval(req.Interval), patternError,
+		validateSelector(req.Selector), validateXPath(req.XPath), validateValuePath(req.ValuePath), validateCondition(req.Condition, req.ValuePath),
+		validateRule(req.Rule)} {
+		if fieldError != nil {
+			fields = append(fields, *fieldError)
+		}
+	}
+	return append(fields, validateSteps(req.Steps)...)
+}
+
+// validateScoped validates req against the field rules and the egress policy, and assigns it to the
+// caller's tenant.
+func (s *APIServer) validateScoped(request *http.Request, req *RequestMessage) []FieldError {
+	fields := validateRequest(*req)
+	if s.Egress != nil && validateURL(req.URL) == nil {
+		if err := s.Egress.CheckURL(req.URL); err != nil {
+			fields = append(fields, FieldError{Field: "url", Code: codeEgressDenied, Message: err.Error()})
+		}
+	}
+	for i, step := range req.Steps {
+		// Hosts filled in from variables are only checked when the step connects.
+		if s.Egress != nil && validateURL(stepURL(step.URL)) == nil {
+			if err := s.Egress.CheckURL(stepURL(step.URL)); err != nil {
+				fields = append(fields, FieldError{Field: fmt.Sprintf("steps[%d].url", i), Code: codeEgressDenied, Message: err.Error()})
+			}
+		}
+	}
+	if fieldError := scopeToCaller(request, &req.TenantID); fieldError != nil {
+		fields = append(fields, *fieldError)
+	}
+	return fields
+}
+
+// decodeJSON decodes the request body into v, enforcing MaxBodyBytes and rejecting unknown fields
+// and trailing data.
+func (s *APIServer) decodeJSON(writer http.ResponseWriter, request *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(writer, request.Body, s.MaxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("unexpected data after the JSON value")
+	}
+	return nil
+}
+
+func (s *APIServer) readBody(writer http.ResponseWriter, request *http.Request) ([]byte, error) {
+	var body bytes.Buffer
+	_, err := body.ReadFrom(http.MaxBytesReader(writer, request.Body, s.MaxBodyBytes))
+	return body.Bytes(), err
+}
+
+func writeDecodeError(writer http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJson(writer, http.StatusRequestEntityTooLarge, apiError{Error: fmt.Sprintf("%s, the limit is %d bytes", requestTooLarge, tooLarge.Limit)})
+		return
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		writeJson(writer, http.StatusBadRequest, newValidationError([]FieldError{{
+			Field:   typeErr.Field,
+			Code:    codeInvalidType,
+			Message: fmt.Sprintf("%s must be %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value),
+		}}))
+		return
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field = strings.Trim(field, `"`)
+		writeJson(writer, http.StatusBadRequest, newValidationError([]FieldError{{
+			Field:   field,
+			Code:    codeUnknownField,
+			Message: fmt.Sprintf("unknown field %q", field),
+		}}))
+		return
+	}
+	writeJson(writer, http.StatusBadRequest, apiError{Error: fmt.Sprintf("%s: %v", malformedBodyError, err)})
+}
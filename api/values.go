@@ -0,0 +1,94 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"snapp-task/services"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultValuesLimit = 1000
+	maxValuesLimit     = 10000
+	defaultValuesRange = 24 * time.Hour
+)
+
+// ValuePoint is one recorded value, or with a step the summary of a bucket: Value is then the mean.
+type ValuePoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+	Min   *float64  `json:"min,omitempty"`
+	Max   *float64  `json:"max,omitempty"`
+	Count int       `json:"count,omitempty"`
+}
+
+type valuesQuery struct {
+	from  time.Time
+	to    time.Time
+	limit int
+	step  time.Duration
+}
+
+func parseValuesQuery(query url.Values, now time.Time) (valuesQuery, error) {
+	parsed := valuesQuery{to: now, limit: defaultValuesLimit}
+	var err error
+	if to := query.Get("to"); to != "" {
+		if parsed.to, err = time.Parse(time.RFC3339, to); err != nil {
+			return parsed, fmt.Errorf("to must be an RFC 3339 time")
+		}
+	}
+	parsed.from = parsed.to.Add(-defaultValuesRange)
+	if from := query.Get("from"); from != "" {
+		if parsed.from, err = time.Parse(time.RFC3339, from); err != nil {
+			return parsed, fmt.Errorf("from must be an RFC 3339 time")
+		}
+	}
+	if !parsed.from.Before(parsed.to) {
+		return parsed, fmt.Errorf("from must be before to")
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if parsed.limit, err = strconv.Atoi(limit); err != nil || parsed.limit < 1 || parsed.limit > maxValuesLimit {
+			return parsed, fmt.Errorf("limit must be between 1 and %d", maxValuesLimit)
+		}
+	}
+	if step := query.Get("step"); step != "" {
+		if parsed.step, err = time.ParseDuration(step); err != nil || parsed.step < time.Second {
+			return parsed, fmt.Errorf("step must be a duration of at least 1s, like 5m or 1h")
+		}
+	}
+	return parsed, nil
+}
+
+// HandleListValues returns the values a monitor extracted between from and to (RFC 3339, the last 24
+// hours by default), oldest first. limit caps the values read, keeping the most recent ones; with step,
+// like 1h, they are summarized per bucket for charting.
+func (s *APIServer) HandleListValues(writer http.ResponseWriter, request *http.Request) {
+	id, err := pathID(request)
+	if err != nil {
+		writeJson(writer, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	query, err := parseValuesQuery(request.URL.Query(), time.Now())
+	if err != nil {
+		writeJson(writer, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	values, err := s.registry.Values(callerTenant(request), id, query.from, query.to, query.limit)
+	if err != nil {
+		writeStoreError(writer, err)
+		return
+	}
+	points := make([]ValuePoint, 0, len(values))
+	if query.step == 0 {
+		for _, value := range values {
+			points = append(points, ValuePoint{Time: value.RecordedAt, Value: value.Value})
+		}
+	} else {
+		for _, bucket := range services.BucketValues(values, query.step) {
+			points = append(points, ValuePoint{Time: bucket.Start, Value: bucket.Mean, Min: &bucket.Min, Max: &bucket.Max, Count: bucket.Count})
+		}
+	}
+	writeJson(writer, http.StatusOK, points)
+}
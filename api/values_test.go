@@ -0,0 +1,98 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"snapp-task/api"
+	"snapp-task/db"
+	"snapp-task/services"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Monitor values", func() {
+	var (
+		handler  http.Handler
+		store    *db.MonitorStoreMock
+		registry *services.Registry
+		start    time.Time
+	)
+
+	BeforeEach(func() {
+		start = time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+		store = newMonitorStore(db.Monitor{ID: 1, URL: "https://a.com", ValuePath: "queue.depth", TenantID: 1})
+		store.ListMonitorValuesFunc = func(id int64, from, to time.Time, limit int) ([]db.MonitorValue, error) {
+			var values []db.MonitorValue
+			for i, value := range []float64{1, 3, 5, 10} {
+				values = append(values, db.MonitorValue{MonitorID: id, Value: value, RecordedAt: start.Add(time.Duration(i*25) * time.Minute)})
+			}
+			return values, nil
+		}
+		schedulerFactory := func(monitor db.Monitor, database db.DB) services.CheckScheduler {
+			return &services.CheckSchedulerMock{ScheduleCheckFunc: func(ctx context.Context) {}}
+		}
+		registry = services.NewRegistry(store, &db.DBMock{}, schedulerFactory)
+		handler = api.NewAPIServer(":8080", registry).Routes()
+	})
+
+	AfterEach(func() {
+		registry.StopAll()
+	})
+
+	get := func(path string) (*httptest.ResponseRecorder, []api.ValuePoint) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		var points []api.ValuePoint
+		json.Unmarshal(recorder.Body.Bytes(), &points)
+		return recorder, points
+	}
+
+	It("should list the values in the requested range", func() {
+		recorder, points := get("/monitors/1/values?from=2024-09-01T12:00:00Z&to=2024-09-01T14:00:00Z&limit=50")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(points).To(HaveLen(4))
+		Expect(points[3]).To(Equal(api.ValuePoint{Time: start.Add(75 * time.Minute), Value: 10}))
+		call := store.ListMonitorValuesCalls()[0]
+		Expect(call.From).To(Equal(start))
+		Expect(call.To).To(Equal(start.Add(2 * time.Hour)))
+		Expect(call.Limit).To(Equal(50))
+	})
+
+	It("should default to the last day", func() {
+		get("/monitors/1/values")
+		call := store.ListMonitorValuesCalls()[0]
+		Expect(call.To.Sub(call.From)).To(Equal(24 * time.Hour))
+		Expect(call.Limit).To(Equal(1000))
+	})
+
+	It("should summarize values per step", func() {
+		recorder, points := get("/monitors/1/values?step=1h")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(points).To(HaveLen(2))
+		Expect(points[0].Value).To(Equal(3.0))
+		Expect(*points[0].Min).To(Equal(1.0))
+		Expect(*points[0].Max).To(Equal(5.0))
+		Expect(points[0].Count).To(Equal(3))
+	})
+
+	DescribeTable("should reject invalid queries",
+		func(query string) {
+			recorder, _ := get("/monitors/1/values?" + query)
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(store.ListMonitorValuesCalls()).To(BeEmpty())
+		},
+		Entry("a malformed time", "from=yesterday"),
+		Entry("a reversed range", "from=2024-09-02T00:00:00Z&to=2024-09-01T00:00:00Z"),
+		Entry("a limit over the maximum", "limit=100000"),
+		Entry("a step under a second", "step=10ms"),
+	)
+
+	It("should return 404 for unknown monitors", func() {
+		recorder, _ := get("/monitors/2/values")
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+})
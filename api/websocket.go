@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"slices"
+	"snapp-task/db"
+	"snapp-task/services"
+	"sync"
+)
+
+// wsProtocol is the subprotocol of the control channel.
+const wsProtocol = "control"
+
+const (
+	wsSubscribe   = "subscribe"
+	wsUnsubscribe = "unsubscribe"
+	wsRun         = "run"
+	wsPause       = "pause"
+	wsResume      = "resume"
+	wsAck         = "ack"
+	wsError       = "error"
+	wsEvent       = "event"
+)
+
+// ControlMessage is sent by clients over the control channel. Subscribe and unsubscribe take Monitors,
+// where an empty list means every monitor visible to the caller; the other commands take Monitor.
+// ID is echoed in the reply so clients can match replies to commands.
+type ControlMessage struct {
+	ID       string  `json:"id,omitempty"`
+	Type     string  `json:"type"`
+	Monitor  int64   `json:"monitor,omitempty"`
+	Monitors []int64 `json:"monitors,omitempty"`
+}
+
+// ControlReply is sent by the server: an ack or error for each command, and an event for each
+// published event the client is subscribed to.
+type ControlReply struct {
+	ID      string         `json:"id,omitempty"`
+	Type    string         `json:"type"`
+	Error   string         `json:"error,omitempty"`
+	EventID uint64         `json:"event_id,omitempty"`
+	Event   *EventResponse `json:"event,omitempty"`
+}
+
+// controlSession is the state of one control channel connection.
+type controlSession struct {
+	server   *APIServer
+	conn     *websocket.Conn
+	request  *http.Request
+	tenantID int64
+	sendMu   sync.Mutex
+	mu       sync.Mutex
+	all      bool
+	monitors map[int64]bool
+}
+
+func (session *controlSession) send(reply ControlReply) error {
+	session.sendMu.Lock()
+	defer session.sendMu.Unlock()
+	return websocket.JSON.Send(session.conn, reply)
+}
+
+func (session *controlSession) wants(event services.Event) bool {
+	if session.tenantID != 0 && event.TenantID != session.tenantID {
+		return false
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.all || session.monitors[event.MonitorID]
+}
+
+func (session *controlSession) subscribe(monitors []int64, subscribed bool) error {
+	registry := session.server.registry
+	for _, id := range monitors {
+		if _, err := registry.Get(session.tenantID, id); err != nil {
+			return err
+		}
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if len(monitors) == 0 {
+		session.all = subscribed
+		if !subscribed {
+			session.monitors = make(map[int64]bool)
+		}
+		return nil
+	}
+	for _, id := range monitors {
+		if subscribed {
+			session.monitors[id] = true
+		} else {
+			delete(session.monitors, id)
+		}
+	}
+	return nil
+}
+
+var errWriteScope = errors.New("API key lacks the write scope")
+
+func (session *controlSession) handle(message ControlMessage) error {
+	registry := session.server.registry
+	switch message.Type {
+	case wsSubscribe, wsUnsubscribe:
+		return session.subscribe(message.Monitors, message.Type == wsSubscribe)
+	case wsRun, wsPause, wsResume:
+		if !callerAllows(session.request, db.ScopeWrite) {
+			return errWriteScope
+		}
+		if message.Type == wsRun {
+			return registry.RunNow(session.tenantID, message.Monitor)
+		}
+		return registry.SetPaused(session.tenantID, message.Monitor, message.Type == wsPause)
+	default:
+		return errors.New("unknown message type " + message.Type)
+	}
+}
+
+func (session *controlSession) serve() {
+	sub, _ := session.server.Events.Subscribe(0, session.wants)
+	defer session.server.Events.Unsubscribe(sub)
+	go func() {
+		defer session.conn.Close()
+		for {
+			select {
+			case event, open := <-sub.C:
+				if !open {
+					return
+				}
+				response := toEventResponse(event)
+				if session.send(ControlReply{Type: wsEvent, EventID: event.ID, Event: &response}) != nil {
+					return
+				}
+			case <-session.request.Context().Done():
+				return
+			case <-session.server.shutdown:
+				return
+			}
+		}
+	}()
+	for {
+		var message ControlMessage
+		if err := websocket.JSON.Receive(session.conn, &message); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr) {
+				return
+			}
+			if session.send(ControlReply{Type: wsError, Error: fmt.Sprintf("%s: %v", malformedBodyError, err)}) != nil {
+				return
+			}
+			continue
+		}
+		reply := ControlReply{ID: message.ID, Type: wsAck}
+		if err := session.handle(message); errors.Is(err, db.ErrNotFound) {
+			reply = ControlReply{ID: message.ID, Type: wsError, Error: "Monitor not found"}
+		} else if err != nil {
+			reply = ControlReply{ID: message.ID, Type: wsError, Error: err.Error()}
+		}
+		if session.send(reply) != nil {
+			return
+		}
+	}
+}
+
+// checkControlHandshake rejects WebSocket handshakes from pages the server does not trust, since a
+// browser sends the page's cookies and subprotocols along to any origin. Origins are allowed when they
+// match the request's host or are listed in AllowedOrigins; clients that send no Origin are not
+// browsers and are let through. Of the offered subprotocols only wsProtocol is selected, so the API key
+// is never echoed back.
+func (s *APIServer) checkControlHandshake(config *websocket.Config, request *http.Request) error {
+	origin, err := websocket.Origin(config, request)
+	if err != nil {
+		return err
+	}
+	if origin != nil && origin.Host != request.Host && !slices.Contains(s.AllowedOrigins, origin.Scheme+"://"+origin.Host) {
+		return fmt.Errorf("origin %s is not allowed", origin)
+	}
+	config.Origin = origin
+	if slices.Contains(config.Protocol, wsProtocol) {
+		config.Protocol = []string{wsProtocol}
+	} else {
+		config.Protocol = nil
+	}
+	return nil
+}
+
+// HandleControl upgrades the request to a WebSocket control channel. Clients subscribe to monitors to
+// receive their events and may run, pause and resume them if their key has the write scope. Browsers
+// pass the API key as the "apikey.<key>" subprotocol, alongside wsProtocol.
+func (s *APIServer) HandleControl(writer http.ResponseWriter, request *http.Request) {
+	websocket.Server{Handshake: s.checkControlHandshake, Handler: func(conn *websocket.Conn) {
+		conn.MaxPayloadBytes = int(s.MaxBodyBytes)
+		session := &controlSession{
+			server:   s,
+			conn:     conn,
+			request:  request,
+			tenantID: callerTenant(request),
+			monitors: make(map[int64]bool),
+		}
+		session.serve()
+	}}.ServeHTTP(writer, request)
+}
@@ -0,0 +1,141 @@
+package api_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"snapp-task/api"
+	"snapp-task/db"
+	"snapp-task/services"
+	"strings"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/websocket"
+)
+
+var _ = Describe("Control channel", func() {
+	var (
+		testServer *httptest.Server
+		registry   *services.Registry
+		events     *services.EventBus
+		keys       *services.APIKeys
+		runs       atomic.Int32
+	)
+
+	BeforeEach(func() {
+		runs.Store(0)
+		schedulerFactory := func(monitor db.Monitor, database db.DB) services.CheckScheduler {
+			return &services.CheckSchedulerMock{
+				ScheduleCheckFunc: func(ctx context.Context) {},
+				RunNowFunc:        func() { runs.Add(1) },
+				SetPausedFunc:     func(paused bool) {},
+			}
+		}
+		registry = services.NewRegistry(newMonitorStore(db.Monitor{ID: 1, URL: "https://a.com", Pattern: "a", TenantID: 1}), &db.DBMock{}, schedulerFactory)
+		Expect(registry.Start()).To(Succeed())
+		events = services.NewEventBus(10)
+		keys = services.NewAPIKeys(newKeyStore())
+		server := api.NewAPIServer(":8080", registry)
+		server.Events = events
+		server.Keys = keys
+		server.AllowedOrigins = []string{"https://dashboard.example.com"}
+		testServer = httptest.NewServer(server.Routes())
+	})
+
+	AfterEach(func() {
+		testServer.CloseClientConnections()
+		testServer.Close()
+		registry.StopAll()
+	})
+
+	dial := func(scope db.Scope) *websocket.Conn {
+		_, secret, err := keys.Create("dashboard", scope, 0)
+		Expect(err).NotTo(HaveOccurred())
+		config, err := websocket.NewConfig(strings.Replace(testServer.URL, "http", "ws", 1)+"/control", testServer.URL)
+		Expect(err).NotTo(HaveOccurred())
+		config.Header.Set("X-API-Key", secret)
+		conn, err := websocket.DialConfig(config)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(conn.Close)
+		return conn
+	}
+
+	roundTrip := func(conn *websocket.Conn, message api.ControlMessage) api.ControlReply {
+		Expect(websocket.JSON.Send(conn, message)).To(Succeed())
+		var reply api.ControlReply
+		Expect(websocket.JSON.Receive(conn, &reply)).To(Succeed())
+		return reply
+	}
+
+	It("should push the events of subscribed monitors", func() {
+		conn := dial(db.ScopeRead)
+		Expect(roundTrip(conn, api.ControlMessage{ID: "1", Type: "subscribe", Monitors: []int64{1}})).To(Equal(api.ControlReply{ID: "1", Type: "ack"}))
+		events.Publish(services.Event{Type: services.EventMatch, MonitorID: 2, TenantID: 1})
+		events.Publish(services.Event{Type: services.EventMatch, MonitorID: 1, TenantID: 1, Data: "found"})
+		var reply api.ControlReply
+		Expect(websocket.JSON.Receive(conn, &reply)).To(Succeed())
+		Expect(reply.Type).To(Equal("event"))
+		Expect(reply.EventID).To(Equal(uint64(2)))
+		Expect(reply.Event.Data).To(Equal("found"))
+	})
+
+	It("should run monitors on demand", func() {
+		conn := dial(db.ScopeWrite)
+		Expect(roundTrip(conn, api.ControlMessage{ID: "1", Type: "run", Monitor: 1}).Type).To(Equal("ack"))
+		Expect(runs.Load()).To(Equal(int32(1)))
+		Expect(roundTrip(conn, api.ControlMessage{ID: "2", Type: "pause", Monitor: 1}).Type).To(Equal("ack"))
+	})
+
+	It("should reject commands a read-only key may not issue", func() {
+		conn := dial(db.ScopeRead)
+		reply := roundTrip(conn, api.ControlMessage{ID: "1", Type: "run", Monitor: 1})
+		Expect(reply).To(Equal(api.ControlReply{ID: "1", Type: "error", Error: "API key lacks the write scope"}))
+		Expect(runs.Load()).To(BeZero())
+	})
+
+	It("should report unknown monitors and message types", func() {
+		conn := dial(db.ScopeWrite)
+		Expect(roundTrip(conn, api.ControlMessage{ID: "1", Type: "subscribe", Monitors: []int64{9}}).Error).To(Equal("Monitor not found"))
+		Expect(roundTrip(conn, api.ControlMessage{ID: "2", Type: "explode"}).Error).To(Equal("unknown message type explode"))
+	})
+
+	It("should keep the connection open after a malformed message", func() {
+		conn := dial(db.ScopeRead)
+		Expect(websocket.Message.Send(conn, "{")).To(Succeed())
+		var reply api.ControlReply
+		Expect(websocket.JSON.Receive(conn, &reply)).To(Succeed())
+		Expect(reply.Type).To(Equal("error"))
+		Expect(roundTrip(conn, api.ControlMessage{ID: "1", Type: "subscribe"}).Type).To(Equal("ack"))
+	})
+
+	Context("from a browser", func() {
+		var secret string
+
+		BeforeEach(func() {
+			var err error
+			_, secret, err = keys.Create("dashboard", db.ScopeRead, 0)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		browserConfig := func(origin string) *websocket.Config {
+			config, err := websocket.NewConfig(strings.Replace(testServer.URL, "http", "ws", 1)+"/control", origin)
+			Expect(err).NotTo(HaveOccurred())
+			config.Protocol = []string{"control", "apikey." + secret}
+			return config
+		}
+
+		It("should take the API key from the subprotocols without echoing it", func() {
+			conn, err := websocket.DialConfig(browserConfig("https://dashboard.example.com"))
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(conn.Close)
+			Expect(conn.Config().Protocol).To(Equal([]string{"control"}))
+			Expect(roundTrip(conn, api.ControlMessage{ID: "1", Type: "subscribe"}).Type).To(Equal("ack"))
+		})
+
+		It("should refuse pages of origins that are not allowed", func() {
+			_, err := websocket.DialConfig(browserConfig("https://evil.example.com"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const alertRuleColumns = "id, tenant_id, name, monitor_id, trigger_on, threshold, window_size, resolve_after, flap_threshold, created_at"
+
+func scanAlertRule(row rowScanner) (AlertRule, error) {
+	var rule AlertRule
+	var monitorID sql.NullInt64
+	err := row.Scan(&rule.ID, &rule.TenantID, &rule.Name, &monitorID, &rule.On, &rule.Threshold, &rule.Window, &rule.ResolveAfter,
+		&rule.FlapThreshold, &rule.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rule, ErrNotFound
+	}
+	rule.MonitorID = monitorID.Int64
+	return rule, err
+}
+
+func (db *SQLiteDB) CreateAlertRule(rule AlertRule) (AlertRule, error) {
+	rule.CreatedAt = time.Now().UTC()
+	query := `
+    INSERT INTO alert_rules (tenant_id, name, monitor_id, trigger_on, threshold, window_size, resolve_after, flap_threshold, created_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := db.Conn.Exec(query, rule.TenantID, rule.Name, nullableID(rule.MonitorID), rule.On, rule.Threshold, rule.Window,
+		rule.ResolveAfter, rule.FlapThreshold, rule.CreatedAt)
+	if err != nil {
+		return rule, err
+	}
+	rule.ID, err = res.LastInsertId()
+	return rule, err
+}
+
+func (db *SQLiteDB) ListAlertRules() ([]AlertRule, error) {
+	rows, err := db.Conn.Query("SELECT " + alertRuleColumns + " FROM alert_rules ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rules []AlertRule
+	for rows.Next() {
+		rule, scanErr := scanAlertRule(rows)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (db *SQLiteDB) DeleteAlertRule(id int64) error {
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec("DELETE FROM alert_rules WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if deleted, _ := res.RowsAffected(); deleted == 0 {
+		return ErrNotFound
+	}
+	if _, err = tx.Exec("DELETE FROM alert_states WHERE rule_id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *SQLiteDB) SaveAlertState(state AlertState) error {
+	query := `
+    INSERT OR REPLACE INTO alert_states (rule_id, monitor_id, tenant_id, status, history, good_streak, flapping, since, updated_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.Conn.Exec(query, state.RuleID, state.MonitorID, state.TenantID, state.Status, state.History, state.GoodStreak,
+		state.Flapping, state.Since.UTC(), state.UpdatedAt.UTC())
+	return err
+}
+
+func (db *SQLiteDB) DeleteAlertStates(monitorID int64) error {
+	_, err := db.Conn.Exec("DELETE FROM alert_states WHERE monitor_id = ?", monitorID)
+	return err
+}
+
+func (db *SQLiteDB) ListAlertStates() ([]AlertState, error) {
+	query := `
+    SELECT rule_id, monitor_id, tenant_id, status, history, good_streak, flapping, since, updated_at
+    FROM alert_states ORDER BY rule_id, monitor_id`
+	rows, err := db.Conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var states []AlertState
+	for rows.Next() {
+		var state AlertState
+		err = rows.Scan(&state.RuleID, &state.MonitorID, &state.TenantID, &state.Status, &state.History, &state.GoodStreak,
+			&state.Flapping, &state.Since, &state.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, rows.Err()
+}
+
+func (db *SQLiteDB) CreateSilence(silence Silence) (Silence, error) {
+	query := `
+    INSERT INTO silences (tenant_id, monitor_id, starts_at, ends_at, comment, created_by_key_id, created_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?)`
+	res, err := db.Conn.Exec(query, silence.TenantID, nullableID(silence.MonitorID), silence.StartsAt.UTC(), silence.EndsAt.UTC(),
+		silence.Comment, nullableID(silence.CreatedBy), time.Now().UTC())
+	if err != nil {
+		return silence, err
+	}
+	silence.ID, err = res.LastInsertId()
+	return silence, err
+}
+
+func (db *SQLiteDB) ListSilences() ([]Silence, error) {
+	rows, err := db.Conn.Query("SELECT id, tenant_id, monitor_id, starts_at, ends_at, comment, created_by_key_id FROM silences ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var silences []Silence
+	for rows.Next() {
+		var silence Silence
+		var monitorID, createdBy sql.NullInt64
+		err = rows.Scan(&silence.ID, &silence.TenantID, &monitorID, &silence.StartsAt, &silence.EndsAt, &silence.Comment, &createdBy)
+		if err != nil {
+			return nil, err
+		}
+		silence.MonitorID = monitorID.Int64
+		silence.CreatedBy = createdBy.Int64
+		silences = append(silences, silence)
+	}
+	return silences, rows.Err()
+}
+
+func (db *SQLiteDB) DeleteSilence(id int64) error {
+	res, err := db.Conn.Exec("DELETE FROM silences WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if deleted, _ := res.RowsAffected(); deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
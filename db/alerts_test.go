@@ -0,0 +1,88 @@
+package db_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"os"
+	. "snapp-task/db"
+	"time"
+)
+
+var _ = Describe("Alerts", func() {
+	var (
+		db             *SQLiteDB
+		dataSourceName string
+		now            time.Time
+	)
+
+	BeforeEach(func() {
+		file, err := os.CreateTemp("", "testdb_*.db")
+		Expect(err).NotTo(HaveOccurred())
+		dataSourceName = file.Name()
+
+		db, err = NewSQLiteDB(dataSourceName)
+		Expect(err).NotTo(HaveOccurred())
+		now = time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	})
+
+	AfterEach(func() {
+		Expect(db.Close()).To(Succeed())
+		Expect(os.Remove(dataSourceName)).To(Succeed())
+	})
+
+	It("should store rules and delete them with their states", func() {
+		rule, err := db.CreateAlertRule(AlertRule{TenantID: 1, Name: "down", MonitorID: 3, On: AlertOnFailure, Threshold: 3, Window: 5, ResolveAfter: 2, FlapThreshold: 4})
+		Expect(err).NotTo(HaveOccurred())
+		global, err := db.CreateAlertRule(AlertRule{TenantID: 1, Name: "any", On: AlertOnMatch, Threshold: 1, Window: 1, ResolveAfter: 1})
+		Expect(err).NotTo(HaveOccurred())
+
+		rules, err := db.ListAlertRules()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rules).To(HaveLen(2))
+		Expect(rules[0].CreatedAt).To(BeTemporally("~", rule.CreatedAt, time.Second))
+		rules[0].CreatedAt = rule.CreatedAt
+		Expect(rules[0]).To(Equal(rule))
+		Expect(rules[1].MonitorID).To(BeZero())
+
+		state := AlertState{RuleID: rule.ID, MonitorID: 3, TenantID: 1, Status: AlertFiring, History: "0111", Flapping: true, Since: now, UpdatedAt: now}
+		Expect(db.SaveAlertState(state)).To(Succeed())
+		state.GoodStreak = 1
+		state.History = "1110"
+		Expect(db.SaveAlertState(state)).To(Succeed())
+		Expect(db.SaveAlertState(AlertState{RuleID: global.ID, MonitorID: 3, TenantID: 1, Status: AlertOK, Since: now, UpdatedAt: now})).To(Succeed())
+		states, err := db.ListAlertStates()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(states).To(HaveLen(2))
+		Expect(states[0]).To(Equal(state))
+
+		Expect(db.DeleteAlertRule(rule.ID)).To(Succeed())
+		Expect(db.DeleteAlertRule(rule.ID)).To(MatchError(ErrNotFound))
+		states, err = db.ListAlertStates()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(states).To(HaveLen(1))
+		Expect(states[0].RuleID).To(Equal(global.ID))
+	})
+
+	It("should delete the states of a monitor", func() {
+		for _, monitorID := range []int64{3, 4} {
+			Expect(db.SaveAlertState(AlertState{RuleID: 1, MonitorID: monitorID, TenantID: 1, Status: AlertOK, Since: now, UpdatedAt: now})).To(Succeed())
+		}
+		Expect(db.DeleteAlertStates(3)).To(Succeed())
+		states, err := db.ListAlertStates()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(states).To(ConsistOf(HaveField("MonitorID", int64(4))))
+	})
+
+	It("should store and delete silences", func() {
+		silence, err := db.CreateSilence(Silence{TenantID: 1, MonitorID: 3, StartsAt: now, EndsAt: now.Add(time.Hour), Comment: "deploy", CreatedBy: 7})
+		Expect(err).NotTo(HaveOccurred())
+		silences, err := db.ListSilences()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(silences).To(Equal([]Silence{silence}))
+		Expect(silence.Active(now.Add(time.Minute))).To(BeTrue())
+		Expect(silence.Active(now.Add(time.Hour))).To(BeFalse())
+
+		Expect(db.DeleteSilence(silence.ID)).To(Succeed())
+		Expect(db.DeleteSilence(silence.ID)).To(MatchError(ErrNotFound))
+	})
+})
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const apiKeyColumns = "id, name, prefix, scope, created_at, revoked_at, tenant_id"
+
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	var key APIKey
+	var revokedAt sql.NullTime
+	var tenantID sql.NullInt64
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Scope, &key.CreatedAt, &revokedAt, &tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return key, ErrNotFound
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	key.TenantID = tenantID.Int64
+	return key, err
+}
+
+// CreateAPIKey stores key under the hash of its secret. The secret itself is never stored.
+func (db *SQLiteDB) CreateAPIKey(key APIKey, hash string) (APIKey, error) {
+	key.CreatedAt = time.Now().UTC()
+	query := "INSERT INTO api_keys (name, prefix, key_hash, scope, created_at, tenant_id) VALUES (?, ?, ?, ?, ?, ?)"
+	res, err := db.Conn.Exec(query, key.Name, key.Prefix, hash, key.Scope, key.CreatedAt, nullableID(key.TenantID))
+	if err != nil {
+		return key, err
+	}
+	key.ID, err = res.LastInsertId()
+	return key, err
+}
+
+func (db *SQLiteDB) GetAPIKeyByHash(hash string) (APIKey, error) {
+	return scanAPIKey(db.Conn.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = ?", hash))
+}
+
+func (db *SQLiteDB) ListAPIKeys() ([]APIKey, error) {
+	rows, err := db.Conn.Query("SELECT " + apiKeyColumns + " FROM api_keys ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []APIKey
+	for rows.Next() {
+		key, scanErr := scanAPIKey(rows)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (db *SQLiteDB) RevokeAPIKey(id int64) error {
+	res, err := db.Conn.Exec("UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	if revoked, _ := res.RowsAffected(); revoked == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
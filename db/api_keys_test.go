@@ -0,0 +1,71 @@
+package db_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"os"
+	. "snapp-task/db"
+)
+
+var _ = Describe("API keys", func() {
+	var (
+		db             *SQLiteDB
+		dataSourceName string
+	)
+
+	BeforeEach(func() {
+		file, err := os.CreateTemp("", "testdb_*.db")
+		Expect(err).NotTo(HaveOccurred())
+		dataSourceName = file.Name()
+
+		db, err = NewSQLiteDB(dataSourceName)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(db.Close()).To(Succeed())
+		Expect(os.Remove(dataSourceName)).To(Succeed())
+	})
+
+	It("should look up a key by the hash of its secret", func() {
+		created, err := db.CreateAPIKey(APIKey{Name: "ci", Prefix: "snk_1234", Scope: ScopeWrite}, "hash")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created.ID).NotTo(BeZero())
+
+		fetched, err := db.GetAPIKeyByHash("hash")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched.Name).To(Equal("ci"))
+		Expect(fetched.Scope).To(Equal(ScopeWrite))
+		Expect(fetched.RevokedAt).To(BeNil())
+
+		_, err = db.GetAPIKeyByHash("other")
+		Expect(err).To(MatchError(ErrNotFound))
+	})
+
+	It("should revoke a key once", func() {
+		created, err := db.CreateAPIKey(APIKey{Name: "ci", Prefix: "snk_1234", Scope: ScopeRead}, "hash")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db.RevokeAPIKey(created.ID)).To(Succeed())
+		Expect(db.RevokeAPIKey(created.ID)).To(MatchError(ErrNotFound))
+
+		keys, err := db.ListAPIKeys()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys).To(HaveLen(1))
+		Expect(keys[0].RevokedAt).NotTo(BeNil())
+	})
+
+	It("should record which key created a monitor", func() {
+		key, err := db.CreateAPIKey(APIKey{Name: "ci", Prefix: "snk_1234", Scope: ScopeWrite}, "hash")
+		Expect(err).NotTo(HaveOccurred())
+		monitor, err := db.CreateMonitor(Monitor{URL: "http://example.com", Pattern: "a", Interval: 1, CreatedBy: key.ID})
+		Expect(err).NotTo(HaveOccurred())
+
+		fetched, err := db.GetMonitor(monitor.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched.CreatedBy).To(Equal(key.ID))
+		fetched.Pattern = "b"
+		updated, err := db.UpdateMonitor(fetched)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.CreatedBy).To(Equal(key.ID))
+	})
+})
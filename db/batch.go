@@ -0,0 +1,212 @@
+package db
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var ErrBufferFull = errors.New("write buffer is full")
+var ErrWriterClosed = errors.New("write buffer is closed")
+
+type BatchOptions struct {
+	MaxBatchSize  int
+	FlushInterval time.Duration
+	BufferSize    int
+	// EnqueueTimeout bounds how long a write waits for buffer space; zero waits until there is room.
+	EnqueueTimeout time.Duration
+	OnError        func(err error, batchSize int)
+}
+
+type pendingWrite func(e execer) error
+
+// BatchWriter is a write-behind DB that groups inserts into transactions by size or time.
+type BatchWriter struct {
+	db      *SQLiteDB
+	options BatchOptions
+	queue   chan pendingWrite
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	// lastValues holds the latest value saved per monitor, including ones not flushed yet. Deleted
+	// monitors are kept in forgotten so that a check still running does not cache a value again.
+	valuesMu   sync.Mutex
+	lastValues map[int64]MonitorValue
+	forgotten  map[int64]bool
+}
+
+func NewBatchWriter(db *SQLiteDB, options BatchOptions) *BatchWriter {
+	if options.MaxBatchSize < 1 {
+		options.MaxBatchSize = 1
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = time.Second
+	}
+	if options.BufferSize < options.MaxBatchSize {
+		options.BufferSize = options.MaxBatchSize
+	}
+	if options.OnError == nil {
+		options.OnError = func(err error, batchSize int) {
+			log.Printf("Failed to flush %d writes: %v\n", batchSize, err)
+		}
+	}
+	w := &BatchWriter{
+		db:         db,
+		options:    options,
+		queue:      make(chan pendingWrite, options.BufferSize),
+		done:       make(chan struct{}),
+		lastValues: make(map[int64]MonitorValue),
+		forgotten:  make(map[int64]bool),
+	}
+	go w.run()
+	return w
+}
+
+func (w *BatchWriter) SaveData(match Match) error {
+	return w.enqueue(func(e execer) error {
+		return saveMatch(e, match)
+	})
+}
+
+func (w *BatchWriter) SaveCheckRun(run CheckRun) error {
+	return w.enqueue(func(e execer) error {
+		return saveCheckRun(e, run)
+	})
+}
+
+// SaveValue buffers the value like the other writes, and remembers it so LastValue does not have to
+// wait for the flush.
+func (w *BatchWriter) SaveValue(value MonitorValue) error {
+	if err := w.enqueue(func(e execer) error {
+		return saveValue(e, value)
+	}); err != nil {
+		return err
+	}
+	w.valuesMu.Lock()
+	defer w.valuesMu.Unlock()
+	if !w.forgotten[value.MonitorID] {
+		w.lastValues[value.MonitorID] = value
+	}
+	return nil
+}
+
+func (w *BatchWriter) LastValue(monitorID int64) (MonitorValue, error) {
+	w.valuesMu.Lock()
+	value, ok := w.lastValues[monitorID]
+	w.valuesMu.Unlock()
+	if ok {
+		return value, nil
+	}
+	return w.db.LastValue(monitorID)
+}
+
+// ClearLastValue drops the remembered latest value of the monitor, e.g. when a new revision changes what
+// its values mean, so LastValue reads it from the database again.
+func (w *BatchWriter) ClearLastValue(monitorID int64) {
+	w.valuesMu.Lock()
+	defer w.valuesMu.Unlock()
+	delete(w.lastValues, monitorID)
+}
+
+// Forget drops the remembered latest value of a deleted monitor and stops remembering its values.
+func (w *BatchWriter) Forget(monitorID int64) {
+	w.valuesMu.Lock()
+	defer w.valuesMu.Unlock()
+	delete(w.lastValues, monitorID)
+	w.forgotten[monitorID] = true
+}
+
+func (w *BatchWriter) enqueue(write pendingWrite) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrWriterClosed
+	}
+	if w.options.EnqueueTimeout <= 0 {
+		w.queue <- write
+		return nil
+	}
+	timer := time.NewTimer(w.options.EnqueueTimeout)
+	defer timer.Stop()
+	select {
+	case w.queue <- write:
+		return nil
+	case <-timer.C:
+		return ErrBufferFull
+	}
+}
+
+// Close stops accepting writes and blocks until everything buffered has been flushed.
+func (w *BatchWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+	<-w.done
+	return nil
+}
+
+func (w *BatchWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.options.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]pendingWrite, 0, w.options.MaxBatchSize)
+	for {
+		select {
+		case write, ok := <-w.queue:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, write)
+			if len(batch) >= w.options.MaxBatchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush writes batch in one transaction. If that fails, the batch was rolled back, so its writes are
+// retried one per transaction and only the ones that fail again are lost.
+func (w *BatchWriter) flush(batch []pendingWrite) {
+	if len(batch) == 0 {
+		return
+	}
+	err := w.writeBatch(batch)
+	if err == nil {
+		return
+	}
+	if len(batch) == 1 {
+		w.options.OnError(err, 1)
+		return
+	}
+	for _, write := range batch {
+		if err = w.writeBatch([]pendingWrite{write}); err != nil {
+			w.options.OnError(err, 1)
+		}
+	}
+}
+
+func (w *BatchWriter) writeBatch(batch []pendingWrite) error {
+	tx, err := w.db.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	for _, write := range batch {
+		if err = write(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,148 @@
+package db_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"os"
+	. "snapp-task/db"
+	"sync"
+	"time"
+)
+
+var _ = Describe("BatchWriter", func() {
+	var (
+		db             *SQLiteDB
+		writer         *BatchWriter
+		options        BatchOptions
+		dataSourceName string
+		flushErrors    []error
+		errorsLock     sync.Mutex
+	)
+
+	count := func(query string) int {
+		var n int
+		Expect(db.Conn.QueryRow(query).Scan(&n)).To(Succeed())
+		return n
+	}
+
+	BeforeEach(func() {
+		file, err := os.CreateTemp("", "testdb_*.db")
+		Expect(err).NotTo(HaveOccurred())
+		dataSourceName = file.Name()
+
+		db, err = NewSQLiteDB(dataSourceName)
+		Expect(err).NotTo(HaveOccurred())
+		flushErrors = nil
+		options = BatchOptions{
+			MaxBatchSize:  3,
+			FlushInterval: time.Hour,
+			BufferSize:    10,
+			OnError: func(err error, batchSize int) {
+				errorsLock.Lock()
+				defer errorsLock.Unlock()
+				flushErrors = append(flushErrors, err)
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		writer = NewBatchWriter(db, options)
+	})
+
+	AfterEach(func() {
+		Expect(writer.Close()).To(Succeed())
+		Expect(db.Close()).To(Succeed())
+		Expect(os.Remove(dataSourceName)).To(Succeed())
+	})
+
+	It("should run SQLite in WAL mode", func() {
+		var mode string
+		Expect(db.Conn.QueryRow("PRAGMA journal_mode").Scan(&mode)).To(Succeed())
+		Expect(mode).To(Equal("wal"))
+	})
+
+	It("should flush once a batch is full", func() {
+		for i := 0; i < 3; i++ {
+			Expect(writer.SaveData(Match{URL: "http://example.com", Pattern: "p", Data: "d"})).To(Succeed())
+		}
+		Eventually(func() int {
+			return count("SELECT count(*) FROM matches")
+		}).Should(Equal(3))
+	})
+
+	It("should hold a partial batch until the flush interval", func() {
+		Expect(writer.SaveData(Match{URL: "http://example.com", Pattern: "p", Data: "d"})).To(Succeed())
+		Consistently(func() int {
+			return count("SELECT count(*) FROM matches")
+		}, 100*time.Millisecond).Should(BeZero())
+	})
+
+	Context("with a short flush interval", func() {
+		BeforeEach(func() {
+			options.FlushInterval = 20 * time.Millisecond
+		})
+
+		It("should flush partial batches on the interval", func() {
+			Expect(writer.SaveCheckRun(CheckRun{URL: "u", Pattern: "p", CheckedAt: time.Now()})).To(Succeed())
+			Eventually(func() int {
+				return count("SELECT count(*) FROM check_runs")
+			}).Should(Equal(1))
+		})
+	})
+
+	It("should flush everything buffered on Close", func() {
+		Expect(writer.SaveData(Match{URL: "http://example.com", Pattern: "p", Data: "d"})).To(Succeed())
+		Expect(writer.SaveCheckRun(CheckRun{URL: "u", Pattern: "p", CheckedAt: time.Now()})).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+		Expect(count("SELECT count(*) FROM matches")).To(Equal(1))
+		Expect(count("SELECT count(*) FROM check_runs")).To(Equal(1))
+	})
+
+	It("should reject writes after Close", func() {
+		Expect(writer.Close()).To(Succeed())
+		Expect(writer.SaveData(Match{URL: "http://example.com", Pattern: "p", Data: "d"})).To(MatchError(ErrWriterClosed))
+	})
+
+	It("should report flush errors", func() {
+		_, err := db.Conn.Exec("DROP TABLE check_runs")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.SaveCheckRun(CheckRun{URL: "u", Pattern: "p", CheckedAt: time.Now()})).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+		Expect(flushErrors).To(HaveLen(1))
+	})
+
+	It("should still commit the other writes of a batch when one fails", func() {
+		_, err := db.Conn.Exec("DROP TABLE check_runs")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.SaveData(Match{URL: "http://example.com", Pattern: "p", Data: "1"})).To(Succeed())
+		Expect(writer.SaveCheckRun(CheckRun{URL: "u", Pattern: "p", CheckedAt: time.Now()})).To(Succeed())
+		Expect(writer.SaveData(Match{URL: "http://example.com", Pattern: "p", Data: "2"})).To(Succeed())
+		Eventually(func() int {
+			return count("SELECT count(*) FROM matches")
+		}).Should(Equal(2))
+		Expect(writer.Close()).To(Succeed())
+		Expect(flushErrors).To(HaveLen(1))
+	})
+
+	Context("when the buffer is full", func() {
+		BeforeEach(func() {
+			options.MaxBatchSize = 1
+			options.BufferSize = 1
+			options.EnqueueTimeout = 20 * time.Millisecond
+		})
+
+		It("should apply backpressure and fail after the enqueue timeout", func() {
+			tx, err := db.Conn.Begin()
+			Expect(err).NotTo(HaveOccurred())
+			_, err = tx.Exec("INSERT INTO matches (url) VALUES ('lock')")
+			Expect(err).NotTo(HaveOccurred())
+
+			var lastErr error
+			for i := 0; i < 5 && lastErr == nil; i++ {
+				lastErr = writer.SaveData(Match{URL: "http://example.com", Pattern: "p", Data: "d"})
+			}
+			Expect(lastErr).To(MatchError(ErrBufferFull))
+			Expect(tx.Rollback()).To(Succeed())
+		})
+	})
+})
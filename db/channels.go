@@ -0,0 +1,137 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const channelColumns = "id, tenant_id, name, kind, settings, template, created_at"
+
+// sealedPrefix marks settings encrypted with the database's SecretKey.
+const sealedPrefix = "sealed:"
+
+func (db *SQLiteDB) scanChannel(row rowScanner) (Channel, error) {
+	var channel Channel
+	var settings string
+	err := row.Scan(&channel.ID, &channel.TenantID, &channel.Name, &channel.Kind, &settings, &channel.Template, &channel.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return channel, ErrNotFound
+	}
+	if err != nil {
+		return channel, err
+	}
+	channel.Settings, err = db.openSettings(settings)
+	return channel, err
+}
+
+func (db *SQLiteDB) secretCipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(db.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealSettings encodes settings for storage, encrypted when the database has a SecretKey.
+func (db *SQLiteDB) sealSettings(settings ChannelSettings) (string, error) {
+	value, err := settings.Value()
+	if err != nil {
+		return "", err
+	}
+	encoded := value.(string)
+	if len(db.SecretKey) == 0 || encoded == "" {
+		return encoded, nil
+	}
+	aead, err := db.secretCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(encoded), nil)
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openSettings decodes stored settings. Settings saved before a SecretKey was set are read as they are.
+func (db *SQLiteDB) openSettings(stored string) (ChannelSettings, error) {
+	var settings ChannelSettings
+	encoded, ok := strings.CutPrefix(stored, sealedPrefix)
+	if !ok {
+		return settings, settings.Scan(stored)
+	}
+	if len(db.SecretKey) == 0 {
+		return nil, errors.New("channel settings are encrypted but no secret key is set")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := db.secretCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("channel settings are truncated")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt channel settings: %v", err)
+	}
+	return settings, settings.Scan(plain)
+}
+
+func (db *SQLiteDB) CreateChannel(channel Channel) (Channel, error) {
+	channel.CreatedAt = time.Now().UTC()
+	settings, err := db.sealSettings(channel.Settings)
+	if err != nil {
+		return channel, err
+	}
+	query := "INSERT INTO channels (tenant_id, name, kind, settings, template, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+	res, err := db.Conn.Exec(query, channel.TenantID, channel.Name, channel.Kind, settings, channel.Template, channel.CreatedAt)
+	if err != nil {
+		return channel, err
+	}
+	channel.ID, err = res.LastInsertId()
+	return channel, err
+}
+
+func (db *SQLiteDB) GetChannel(id int64) (Channel, error) {
+	return db.scanChannel(db.Conn.QueryRow("SELECT "+channelColumns+" FROM channels WHERE id = ?", id))
+}
+
+func (db *SQLiteDB) ListChannels() ([]Channel, error) {
+	rows, err := db.Conn.Query("SELECT " + channelColumns + " FROM channels ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var channels []Channel
+	for rows.Next() {
+		channel, scanErr := db.scanChannel(rows)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		channels = append(channels, channel)
+	}
+	return channels, rows.Err()
+}
+
+func (db *SQLiteDB) DeleteChannel(id int64) error {
+	res, err := db.Conn.Exec("DELETE FROM channels WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if deleted, _ := res.RowsAffected(); deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package db_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"os"
+	. "snapp-task/db"
+)
+
+var _ = Describe("Channels", func() {
+	var (
+		db             *SQLiteDB
+		dataSourceName string
+	)
+
+	BeforeEach(func() {
+		file, err := os.CreateTemp("", "testdb_*.db")
+		Expect(err).NotTo(HaveOccurred())
+		dataSourceName = file.Name()
+
+		db, err = NewSQLiteDB(dataSourceName)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(db.Close()).To(Succeed())
+		Expect(os.Remove(dataSourceName)).To(Succeed())
+	})
+
+	It("should store channels with their settings", func() {
+		channel, err := db.CreateChannel(Channel{TenantID: 1, Name: "ops", Kind: ChannelSlack,
+			Settings: ChannelSettings{"url": "https://hooks.example.com/x"}, Template: "{{.Rule}}"})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = db.CreateChannel(Channel{TenantID: 2, Name: "hook", Kind: ChannelWebhook})
+		Expect(err).NotTo(HaveOccurred())
+
+		stored, err := db.GetChannel(channel.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stored.Settings).To(Equal(ChannelSettings{"url": "https://hooks.example.com/x"}))
+		Expect(stored.Template).To(Equal("{{.Rule}}"))
+
+		channels, err := db.ListChannels()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(channels).To(HaveLen(2))
+		Expect(channels[1].Settings).To(BeNil())
+
+		Expect(db.DeleteChannel(channel.ID)).To(Succeed())
+		Expect(db.DeleteChannel(channel.ID)).To(MatchError(ErrNotFound))
+		_, err = db.GetChannel(channel.ID)
+		Expect(err).To(MatchError(ErrNotFound))
+	})
+
+	It("should encrypt settings at rest with the secret key", func() {
+		plain, err := db.CreateChannel(Channel{TenantID: 1, Name: "old", Kind: ChannelTelegram,
+			Settings: ChannelSettings{"token": "123:abc", "chat_id": "-100"}})
+		Expect(err).NotTo(HaveOccurred())
+		db.SecretKey = []byte("0123456789abcdef0123456789abcdef")
+		sealed, err := db.CreateChannel(Channel{TenantID: 1, Name: "bot", Kind: ChannelTelegram,
+			Settings: ChannelSettings{"token": "456:def", "chat_id": "-200"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		var stored string
+		Expect(db.Conn.QueryRow("SELECT settings FROM channels WHERE id = ?", sealed.ID).Scan(&stored)).To(Succeed())
+		Expect(stored).NotTo(ContainSubstring("456:def"))
+		channel, err := db.GetChannel(sealed.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(channel.Settings).To(Equal(ChannelSettings{"token": "456:def", "chat_id": "-200"}))
+		channel, err = db.GetChannel(plain.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(channel.Settings["token"]).To(Equal("123:abc"))
+
+		db.SecretKey = []byte("fedcba9876543210fedcba9876543210")
+		_, err = db.GetChannel(sealed.ID)
+		Expect(err).To(MatchError(ContainSubstring("failed to decrypt channel settings")))
+		db.SecretKey = nil
+		_, err = db.GetChannel(sealed.ID)
+		Expect(err).To(MatchError(ContainSubstring("no secret key is set")))
+	})
+})
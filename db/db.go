@@ -1,6 +1,357 @@
 package db
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// DefaultTenantID owns monitors and keys created before tenants existed, and monitors created without one.
+const DefaultTenantID int64 = 1
+
+type Monitor struct {
+	ID       int64
+	URL      string
+	Pattern  string
+	Interval time.Duration
+	Dedup    bool
+	Revision int
+	// Selector is a CSS selector; when set, HTML responses are matched against the selected elements' text.
+	Selector string
+	// XPath picks the nodes of XML and HTML responses whose text is matched. The selector wins on HTML
+	// responses when both are set.
+	XPath string
+	// CSVColumn limits matching of CSV responses to one column, given by header name or 1-based index.
+	CSVColumn string
+	// ValuePath extracts a number from JSON or YAML responses, like "queue.depth" or "items[0].price".
+	// Every extracted value is recorded, and the monitor matches when Condition holds.
+	ValuePath string
+	// Condition compares the extracted value, like "> 100", "between 10 and 20" or "change > 5%".
+	Condition string
+	// Rule is a CEL expression over the response's status, headers, body and timing. When set it has to
+	// hold for the monitor to match, alongside the pattern or condition, or on its own without them.
+	Rule string
+	// Steps makes the monitor a multi-step transaction: the steps run in order and the last response is
+	// matched like the URL's would be. URL is the first step's URL.
+	Steps Steps
+	// CreatedBy is the id of the API key that created the monitor, or 0 when unknown.
+	CreatedBy int64
+	TenantID  int64
+}
+
+// Step is one request of a multi-step monitor. The URL, header values and body may refer to variables
+// extracted by earlier steps as {{name}}.
+type Step struct {
+	Name    string            `json:"name,omitempty"`
+	Method  string            `json:"method,omitempty"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	// Extract maps variable names to value paths into the step's JSON or YAML response.
+	Extract map[string]string `json:"extract,omitempty"`
+	// Status is the status code the step expects; any 2xx passes when 0.
+	Status  int    `json:"status,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+}
+
+// Steps is stored as a JSON array, or an empty string for monitors without steps.
+type Steps []Step
+
+func (s Steps) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(s)
+	return string(encoded), err
+}
+
+func (s *Steps) Scan(src any) error {
+	var encoded []byte
+	switch src := src.(type) {
+	case string:
+		encoded = []byte(src)
+	case []byte:
+		encoded = src
+	case nil:
+	default:
+		return fmt.Errorf("cannot scan %T into steps", src)
+	}
+	*s = nil
+	if len(encoded) == 0 {
+		return nil
+	}
+	return json.Unmarshal(encoded, s)
+}
+
+type MonitorRevision struct {
+	Monitor
+	ChangedAt time.Time
+}
+
+type Match struct {
+	MonitorID int64
+	TenantID  int64
+	URL       string
+	Pattern   string
+	Data      string
+	Dedup     bool
+}
+
+type CheckRun struct {
+	MonitorID int64
+	URL       string
+	Pattern   string
+	CheckedAt time.Time
+	Duration  time.Duration
+	Error     string
+}
+
+// MonitorValue is one number extracted from a monitor's response.
+type MonitorValue struct {
+	MonitorID  int64
+	TenantID   int64
+	Value      float64
+	RecordedAt time.Time
+}
+
 //go:generate moq -out=mocked_db.go . DB
 type DB interface {
-	SaveData(url, pattern, data string) error
+	SaveData(match Match) error
+	SaveCheckRun(run CheckRun) error
+	SaveValue(value MonitorValue) error
+	// LastValue returns the monitor's most recent value, or ErrNotFound if it has none.
+	LastValue(monitorID int64) (MonitorValue, error)
+}
+
+// MonitorChanges is a set of monitor writes stored together.
+type MonitorChanges struct {
+	Create []Monitor
+	Update []Monitor
+	Delete []int64
+}
+
+//go:generate moq -out=mocked_monitor_store.go . MonitorStore
+type MonitorStore interface {
+	CreateMonitor(monitor Monitor) (Monitor, error)
+	UpdateMonitor(monitor Monitor) (Monitor, error)
+	DeleteMonitor(id int64) error
+	// ApplyMonitorChanges stores the creates, updates and deletes in one transaction and returns the
+	// created and updated monitors as stored.
+	ApplyMonitorChanges(changes MonitorChanges) (MonitorChanges, error)
+	GetMonitor(id int64) (Monitor, error)
+	ListMonitors() ([]Monitor, error)
+	ListMonitorRevisions(id int64) ([]MonitorRevision, error)
+	// ListMonitorValues returns up to limit of the monitor's values recorded in [from, to), the most
+	// recent ones when there are more, in chronological order.
+	ListMonitorValues(id int64, from, to time.Time, limit int) ([]MonitorValue, error)
+}
+
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	ScopeAdmin Scope = "admin"
+)
+
+var scopeLevels = map[Scope]int{ScopeRead: 1, ScopeWrite: 2, ScopeAdmin: 3}
+
+func (s Scope) Valid() bool {
+	return scopeLevels[s] > 0
+}
+
+// Allows reports whether s includes required; each scope includes the ones below it.
+func (s Scope) Allows(required Scope) bool {
+	return s.Valid() && scopeLevels[s] >= scopeLevels[required]
+}
+
+type APIKey struct {
+	ID        int64
+	Name      string
+	Prefix    string
+	Scope     Scope
+	CreatedAt time.Time
+	RevokedAt *time.Time
+	// TenantID is the tenant the key acts for; 0 means the key is global and sees every tenant.
+	TenantID int64
+}
+
+//go:generate moq -out=mocked_key_store.go . KeyStore
+type KeyStore interface {
+	CreateAPIKey(key APIKey, hash string) (APIKey, error)
+	GetAPIKeyByHash(hash string) (APIKey, error)
+	ListAPIKeys() ([]APIKey, error)
+	RevokeAPIKey(id int64) error
+}
+
+// Tenant quotas are disabled when zero.
+type Tenant struct {
+	ID              int64
+	Name            string
+	MaxMonitors     int
+	MinInterval     time.Duration
+	MaxStorageBytes int64
+}
+
+type TenantUsage struct {
+	Monitors     int
+	StorageBytes int64
+}
+
+//go:generate moq -out=mocked_tenant_store.go . TenantStore
+type TenantStore interface {
+	CreateTenant(tenant Tenant) (Tenant, error)
+	UpdateTenant(tenant Tenant) (Tenant, error)
+	GetTenant(id int64) (Tenant, error)
+	ListTenants() ([]Tenant, error)
+	GetTenantUsage(id int64) (TenantUsage, error)
+}
+
+type AlertTrigger string
+
+const (
+	AlertOnFailure AlertTrigger = "failure"
+	AlertOnMatch   AlertTrigger = "match"
+)
+
+// AlertRule fires for a monitor when Threshold of its last Window checks were bad, i.e. failed or matched
+// depending on On, and resolves after ResolveAfter good checks in a row.
+type AlertRule struct {
+	ID       int64
+	TenantID int64
+	Name     string
+	// MonitorID limits the rule to one monitor; 0 applies it to every monitor of the tenant.
+	MonitorID    int64
+	On           AlertTrigger
+	Threshold    int
+	Window       int
+	ResolveAfter int
+	// FlapThreshold is how many changes between good and bad checks within the window mark the monitor
+	// as flapping, which holds the alert in its current status. 0 disables flap detection.
+	FlapThreshold int
+	CreatedAt     time.Time
+}
+
+type AlertStatus string
+
+const (
+	AlertOK     AlertStatus = "ok"
+	AlertFiring AlertStatus = "firing"
+)
+
+// AlertState is where a rule stands for one monitor.
+type AlertState struct {
+	RuleID    int64
+	MonitorID int64
+	TenantID  int64
+	Status    AlertStatus
+	// History holds the outcomes of the last Window checks, oldest first, as '1' for bad and '0' for good.
+	History    string
+	GoodStreak int
+	Flapping   bool
+	// Since is when Status last changed.
+	Since     time.Time
+	UpdatedAt time.Time
+}
+
+// Silence mutes the alerts of a monitor, or of every monitor of the tenant when MonitorID is 0, from
+// StartsAt until EndsAt. A silence starting in the future is a maintenance window.
+type Silence struct {
+	ID        int64
+	TenantID  int64
+	MonitorID int64
+	StartsAt  time.Time
+	EndsAt    time.Time
+	Comment   string
+	CreatedBy int64
+}
+
+// Active reports whether the silence mutes alerts at now.
+func (s Silence) Active(now time.Time) bool {
+	return !now.Before(s.StartsAt) && now.Before(s.EndsAt)
+}
+
+//go:generate moq -out=mocked_alert_store.go . AlertStore
+type AlertStore interface {
+	CreateAlertRule(rule AlertRule) (AlertRule, error)
+	ListAlertRules() ([]AlertRule, error)
+	// DeleteAlertRule deletes the rule along with its states.
+	DeleteAlertRule(id int64) error
+	// SaveAlertState inserts or replaces the state of the rule for the monitor.
+	SaveAlertState(state AlertState) error
+	// DeleteAlertStates deletes the states of every rule for the monitor.
+	DeleteAlertStates(monitorID int64) error
+	ListAlertStates() ([]AlertState, error)
+	CreateSilence(silence Silence) (Silence, error)
+	ListSilences() ([]Silence, error)
+	DeleteSilence(id int64) error
+}
+
+type ChannelKind string
+
+const (
+	ChannelEmail    ChannelKind = "email"
+	ChannelSlack    ChannelKind = "slack"
+	ChannelTelegram ChannelKind = "telegram"
+	ChannelWebhook  ChannelKind = "webhook"
+)
+
+// Channel is where alert notifications are delivered. Settings holds the kind's options, like the
+// webhook URL or the SMTP server, and Template replaces the kind's default message template.
+type Channel struct {
+	ID        int64
+	TenantID  int64
+	Name      string
+	Kind      ChannelKind
+	Settings  ChannelSettings
+	Template  string
+	CreatedAt time.Time
+}
+
+// ChannelSettings is stored as a JSON object.
+type ChannelSettings map[string]string
+
+func (s ChannelSettings) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(s)
+	return string(encoded), err
+}
+
+func (s *ChannelSettings) Scan(src any) error {
+	var encoded []byte
+	switch src := src.(type) {
+	case string:
+		encoded = []byte(src)
+	case []byte:
+		encoded = src
+	case nil:
+	default:
+		return fmt.Errorf("cannot scan %T into channel settings", src)
+	}
+	*s = nil
+	if len(encoded) == 0 {
+		return nil
+	}
+	return json.Unmarshal(encoded, s)
+}
+
+//go:generate moq -out=mocked_channel_store.go . ChannelStore
+type ChannelStore interface {
+	CreateChannel(channel Channel) (Channel, error)
+	GetChannel(id int64) (Channel, error)
+	ListChannels() ([]Channel, error)
+	DeleteChannel(id int64) error
+}
+
+//go:generate moq -out=mocked_pruner.go . Pruner
+type Pruner interface {
+	Prune(policy RetentionPolicy, now time.Time) (PruneResult, error)
+	Vacuum() error
 }
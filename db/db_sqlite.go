@@ -1,42 +1,118 @@
 package db
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	_ "github.com/mattn/go-sqlite3"
+	"strings"
+	"time"
 )
 
 type SQLiteDB struct {
 	Conn *sql.DB
+	// SecretKey, when set, encrypts channel settings at rest with AES-GCM. It must be 16, 24 or 32 bytes.
+	SecretKey []byte
 }
 
-func NewSQLiteDB(dataSourceName string) (*SQLiteDB, error) {
-	db, err := sql.Open("sqlite3", dataSourceName)
+// auto_vacuum only takes effect when the database file is created; WAL lets checkers read while a batch is written.
+const defaultParams = "_auto_vacuum=incremental&_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000"
+
+func OpenSQLite(dataSourceName string) (*sql.DB, error) {
+	separator := "?"
+	if strings.Contains(dataSourceName, "?") {
+		separator = "&"
+	}
+	db, err := sql.Open("sqlite3", dataSourceName+separator+defaultParams)
 	if err != nil {
 		return nil, err
 	}
 	if err = db.Ping(); err != nil {
+		db.Close()
 		return nil, err
 	}
-	createTableQuery := `
-    CREATE TABLE IF NOT EXISTS matches (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        url TEXT,
-        pattern TEXT,
-        data TEXT
-    );`
-	_, err = db.Exec(createTableQuery)
+	return db, nil
+}
+
+func NewSQLiteDB(dataSourceName string) (*SQLiteDB, error) {
+	db, err := OpenSQLite(dataSourceName)
 	if err != nil {
 		return nil, err
 	}
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err = migrator.Up(); err != nil {
+		db.Close()
+		return nil, err
+	}
 	return &SQLiteDB{Conn: db}, nil
 }
 
-func (db *SQLiteDB) SaveData(url, pattern, data string) error {
-	query := "INSERT INTO matches (url, pattern, data) VALUES (?, ?, ?)"
-	_, err := db.Conn.Exec(query, url, pattern, data)
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func nullableID(id int64) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func contentHash(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// saveMatch inserts a match, or with Dedup set bumps the monitor's latest row when its content is unchanged.
+func saveMatch(e execer, match Match) error {
+	now := time.Now().UTC()
+	hash := contentHash(match.Data)
+	if match.Dedup {
+		query := `
+        UPDATE matches SET last_seen_at = ?, seen_count = seen_count + 1
+        WHERE id = (SELECT id FROM matches WHERE monitor_id IS ? ORDER BY id DESC LIMIT 1)
+          AND content_hash = ?`
+		res, err := e.Exec(query, now, nullableID(match.MonitorID), hash)
+		if err != nil {
+			return err
+		}
+		if updated, _ := res.RowsAffected(); updated > 0 {
+			return nil
+		}
+	}
+	query := `
+    INSERT INTO matches (monitor_id, tenant_id, url, pattern, data, content_hash, created_at, last_seen_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := e.Exec(query, nullableID(match.MonitorID), nullableID(match.TenantID), match.URL, match.Pattern, match.Data, hash, now, now)
+	return err
+}
+
+func saveCheckRun(e execer, run CheckRun) error {
+	query := "INSERT INTO check_runs (monitor_id, url, pattern, checked_at, duration_ms, error) VALUES (?, ?, ?, ?, ?, ?)"
+	_, err := e.Exec(query, nullableID(run.MonitorID), run.URL, run.Pattern, run.CheckedAt.UTC(), run.Duration.Milliseconds(), run.Error)
 	return err
 }
 
+func (db *SQLiteDB) SaveData(match Match) error {
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	if err = saveMatch(tx, match); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *SQLiteDB) SaveCheckRun(run CheckRun) error {
+	return saveCheckRun(db.Conn, run)
+}
+
 func (db *SQLiteDB) Close() error {
 	return db.Conn.Close()
 }
@@ -7,6 +7,7 @@ import (
 	. "github.com/onsi/gomega"
 	"os"
 	. "snapp-task/db"
+	"time"
 )
 
 var _ = Describe("SQLiteDB", func() {
@@ -40,7 +41,7 @@ var _ = Describe("SQLiteDB", func() {
 
 	Describe("SaveData", func() {
 		It("should insert data into the matches table", func() {
-			err := db.SaveData("http://example.com", "testpattern", "testdata")
+			err := db.SaveData(Match{URL: "http://example.com", Pattern: "testpattern", Data: "testdata"})
 			Expect(err).NotTo(HaveOccurred())
 
 			var count int
@@ -49,6 +50,62 @@ var _ = Describe("SQLiteDB", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(count).To(Equal(1))
 		})
+
+		Context("with dedup enabled", func() {
+			var match Match
+
+			BeforeEach(func() {
+				match = Match{MonitorID: 1, URL: "http://example.com", Pattern: "testpattern", Data: "testdata", Dedup: true}
+			})
+
+			countRows := func() int {
+				var count int
+				err := db.Conn.QueryRow("SELECT count(*) FROM matches").Scan(&count)
+				Expect(err).NotTo(HaveOccurred())
+				return count
+			}
+
+			It("should bump the existing row when the content is unchanged", func() {
+				Expect(db.SaveData(match)).To(Succeed())
+				Expect(db.SaveData(match)).To(Succeed())
+				Expect(db.SaveData(match)).To(Succeed())
+				Expect(countRows()).To(Equal(1))
+
+				var seenCount int
+				var createdAt, lastSeenAt time.Time
+				err := db.Conn.QueryRow("SELECT seen_count, created_at, last_seen_at FROM matches").Scan(&seenCount, &createdAt, &lastSeenAt)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(seenCount).To(Equal(3))
+				Expect(lastSeenAt).To(BeTemporally(">=", createdAt))
+			})
+
+			It("should store a new row when the content changes", func() {
+				Expect(db.SaveData(match)).To(Succeed())
+				match.Data = "otherdata"
+				Expect(db.SaveData(match)).To(Succeed())
+				match.Data = "testdata"
+				Expect(db.SaveData(match)).To(Succeed())
+				Expect(countRows()).To(Equal(3))
+			})
+
+			It("should not merge rows of different monitors", func() {
+				Expect(db.SaveData(match)).To(Succeed())
+				match.MonitorID = 2
+				Expect(db.SaveData(match)).To(Succeed())
+				Expect(countRows()).To(Equal(2))
+			})
+		})
+
+		It("should store every match when dedup is disabled", func() {
+			match := Match{URL: "http://example.com", Pattern: "testpattern", Data: "testdata"}
+			Expect(db.SaveData(match)).To(Succeed())
+			Expect(db.SaveData(match)).To(Succeed())
+
+			var count int
+			err := db.Conn.QueryRow("SELECT count(*) FROM matches").Scan(&count)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(2))
+		})
 	})
 
 	Describe("Close", func() {
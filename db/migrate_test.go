@@ -0,0 +1,117 @@
+package db_test
+
+import (
+	"database/sql"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"os"
+	. "snapp-task/db"
+)
+
+var _ = Describe("Migrator", func() {
+	var (
+		conn           *sql.DB
+		migrator       *Migrator
+		dataSourceName string
+	)
+
+	tableExists := func(name string) bool {
+		var count int
+		err := conn.QueryRow("SELECT count(*) FROM sqlite_master WHERE type='table' AND name=?", name).Scan(&count)
+		Expect(err).NotTo(HaveOccurred())
+		return count == 1
+	}
+
+	BeforeEach(func() {
+		file, err := os.CreateTemp("", "testdb_*.db")
+		Expect(err).NotTo(HaveOccurred())
+		dataSourceName = file.Name()
+
+		conn, err = OpenSQLite(dataSourceName)
+		Expect(err).NotTo(HaveOccurred())
+		migrator, err = NewMigrator(conn)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(conn.Close()).To(Succeed())
+		Expect(os.Remove(dataSourceName)).To(Succeed())
+	})
+
+	Describe("LoadMigrations", func() {
+		It("should load embedded migrations in version order with both scripts", func() {
+			migrations, err := LoadMigrations()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(migrations).NotTo(BeEmpty())
+			for i, migration := range migrations {
+				Expect(migration.Up).NotTo(BeEmpty())
+				Expect(migration.Down).NotTo(BeEmpty())
+				if i > 0 {
+					Expect(migration.Version).To(BeNumerically(">", migrations[i-1].Version))
+				}
+			}
+		})
+	})
+
+	Describe("Up", func() {
+		It("should apply all pending migrations and record them", func() {
+			count, err := migrator.Up()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(len(migrator.Migrations)))
+			Expect(tableExists("matches")).To(BeTrue())
+
+			statuses, err := migrator.Status()
+			Expect(err).NotTo(HaveOccurred())
+			for _, status := range statuses {
+				Expect(status.Applied).To(BeTrue())
+				Expect(status.AppliedAt).NotTo(BeZero())
+			}
+		})
+
+		It("should be a no-op when everything is applied", func() {
+			_, err := migrator.Up()
+			Expect(err).NotTo(HaveOccurred())
+			count, err := migrator.Up()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(BeZero())
+		})
+
+		It("should adopt a database created before migrations existed", func() {
+			_, err := conn.Exec("CREATE TABLE matches (id INTEGER PRIMARY KEY AUTOINCREMENT, url TEXT, pattern TEXT, data TEXT)")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = conn.Exec("INSERT INTO matches (url, pattern, data) VALUES ('u', 'p', 'd')")
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = migrator.Up()
+			Expect(err).NotTo(HaveOccurred())
+
+			var count int
+			Expect(conn.QueryRow("SELECT count(*) FROM matches").Scan(&count)).To(Succeed())
+			Expect(count).To(Equal(1))
+		})
+	})
+
+	Describe("Down", func() {
+		It("should roll back the latest migrations", func() {
+			_, err := migrator.Up()
+			Expect(err).NotTo(HaveOccurred())
+
+			count, err := migrator.Down(len(migrator.Migrations))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(len(migrator.Migrations)))
+			Expect(tableExists("matches")).To(BeFalse())
+
+			statuses, err := migrator.Status()
+			Expect(err).NotTo(HaveOccurred())
+			for _, status := range statuses {
+				Expect(status.Applied).To(BeFalse())
+			}
+		})
+
+		It("should do nothing when no migrations are applied", func() {
+			count, err := migrator.Down(1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(BeZero())
+		})
+	})
+})
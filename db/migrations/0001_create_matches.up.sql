@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS matches (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url TEXT,
+    pattern TEXT,
+    data TEXT
+);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS check_runs_hourly;
+DROP TABLE IF EXISTS check_runs;
+DROP INDEX IF EXISTS idx_matches_url_pattern;
+DROP INDEX IF EXISTS idx_matches_created_at;
+ALTER TABLE matches DROP COLUMN created_at;
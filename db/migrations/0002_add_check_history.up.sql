@@ -0,0 +1,25 @@
+ALTER TABLE matches ADD COLUMN created_at DATETIME;
+UPDATE matches SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+CREATE INDEX idx_matches_created_at ON matches (created_at);
+CREATE INDEX idx_matches_url_pattern ON matches (url, pattern);
+
+CREATE TABLE check_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    checked_at DATETIME NOT NULL,
+    duration_ms INTEGER NOT NULL,
+    error TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX idx_check_runs_checked_at ON check_runs (checked_at);
+
+CREATE TABLE check_runs_hourly (
+    url TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    hour DATETIME NOT NULL,
+    total INTEGER NOT NULL,
+    failures INTEGER NOT NULL,
+    total_duration_ms INTEGER NOT NULL,
+    max_duration_ms INTEGER NOT NULL,
+    PRIMARY KEY (url, pattern, hour)
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_matches_content_hash;
+ALTER TABLE matches DROP COLUMN seen_count;
+ALTER TABLE matches DROP COLUMN last_seen_at;
+ALTER TABLE matches DROP COLUMN content_hash;
@@ -0,0 +1,5 @@
+ALTER TABLE matches ADD COLUMN content_hash TEXT;
+ALTER TABLE matches ADD COLUMN last_seen_at DATETIME;
+ALTER TABLE matches ADD COLUMN seen_count INTEGER NOT NULL DEFAULT 1;
+UPDATE matches SET last_seen_at = created_at;
+CREATE INDEX idx_matches_content_hash ON matches (url, pattern, content_hash);
@@ -0,0 +1,8 @@
+CREATE TABLE monitors (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    interval_seconds INTEGER NOT NULL,
+    dedup INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_check_runs_monitor_id;
+DROP INDEX IF EXISTS idx_matches_monitor_id;
+ALTER TABLE check_runs DROP COLUMN monitor_id;
+ALTER TABLE matches DROP COLUMN monitor_id;
+DROP TABLE IF EXISTS monitor_revisions;
+ALTER TABLE monitors DROP COLUMN revision;
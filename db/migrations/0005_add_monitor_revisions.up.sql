@@ -0,0 +1,19 @@
+ALTER TABLE monitors ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE monitor_revisions (
+    monitor_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    interval_seconds INTEGER NOT NULL,
+    dedup INTEGER NOT NULL,
+    changed_at DATETIME NOT NULL,
+    PRIMARY KEY (monitor_id, revision)
+);
+INSERT INTO monitor_revisions (monitor_id, revision, url, pattern, interval_seconds, dedup, changed_at)
+SELECT id, revision, url, pattern, interval_seconds, dedup, created_at FROM monitors;
+
+ALTER TABLE matches ADD COLUMN monitor_id INTEGER;
+ALTER TABLE check_runs ADD COLUMN monitor_id INTEGER;
+CREATE INDEX idx_matches_monitor_id ON matches (monitor_id);
+CREATE INDEX idx_check_runs_monitor_id ON check_runs (monitor_id);
@@ -0,0 +1,2 @@
+ALTER TABLE monitors DROP COLUMN created_by_key_id;
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,11 @@
+CREATE TABLE api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    scope TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    revoked_at DATETIME
+);
+
+ALTER TABLE monitors ADD COLUMN created_by_key_id INTEGER;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_matches_tenant_id;
+DROP INDEX IF EXISTS idx_monitors_tenant_id;
+ALTER TABLE api_keys DROP COLUMN tenant_id;
+ALTER TABLE matches DROP COLUMN tenant_id;
+ALTER TABLE monitors DROP COLUMN tenant_id;
+DROP TABLE IF EXISTS tenants;
@@ -0,0 +1,17 @@
+CREATE TABLE tenants (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    max_monitors INTEGER NOT NULL DEFAULT 0,
+    min_interval_seconds INTEGER NOT NULL DEFAULT 0,
+    max_storage_bytes INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL
+);
+INSERT INTO tenants (id, name, created_at) VALUES (1, 'default', CURRENT_TIMESTAMP);
+
+ALTER TABLE monitors ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE matches ADD COLUMN tenant_id INTEGER;
+UPDATE matches SET tenant_id = coalesce((SELECT tenant_id FROM monitors WHERE monitors.id = matches.monitor_id), 1);
+ALTER TABLE api_keys ADD COLUMN tenant_id INTEGER;
+UPDATE api_keys SET tenant_id = 1;
+CREATE INDEX idx_monitors_tenant_id ON monitors (tenant_id);
+CREATE INDEX idx_matches_tenant_id ON matches (tenant_id);
@@ -0,0 +1,2 @@
+ALTER TABLE monitor_revisions DROP COLUMN selector;
+ALTER TABLE monitors DROP COLUMN selector;
@@ -0,0 +1,4 @@
+ALTER TABLE monitor_revisions DROP COLUMN csv_column;
+ALTER TABLE monitor_revisions DROP COLUMN xpath;
+ALTER TABLE monitors DROP COLUMN csv_column;
+ALTER TABLE monitors DROP COLUMN xpath;
@@ -0,0 +1,4 @@
+ALTER TABLE monitors ADD COLUMN xpath TEXT NOT NULL DEFAULT '';
+ALTER TABLE monitors ADD COLUMN csv_column TEXT NOT NULL DEFAULT '';
+ALTER TABLE monitor_revisions ADD COLUMN xpath TEXT NOT NULL DEFAULT '';
+ALTER TABLE monitor_revisions ADD COLUMN csv_column TEXT NOT NULL DEFAULT '';
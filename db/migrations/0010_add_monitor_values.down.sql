@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_monitor_values_recorded_at;
+DROP INDEX IF EXISTS idx_monitor_values_monitor_id_recorded_at;
+DROP TABLE IF EXISTS monitor_values;
+ALTER TABLE monitor_revisions DROP COLUMN condition;
+ALTER TABLE monitor_revisions DROP COLUMN value_path;
+ALTER TABLE monitors DROP COLUMN condition;
+ALTER TABLE monitors DROP COLUMN value_path;
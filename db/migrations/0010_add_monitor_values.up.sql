@@ -0,0 +1,14 @@
+ALTER TABLE monitors ADD COLUMN value_path TEXT NOT NULL DEFAULT '';
+ALTER TABLE monitors ADD COLUMN condition TEXT NOT NULL DEFAULT '';
+ALTER TABLE monitor_revisions ADD COLUMN value_path TEXT NOT NULL DEFAULT '';
+ALTER TABLE monitor_revisions ADD COLUMN condition TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE monitor_values (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    monitor_id INTEGER NOT NULL,
+    tenant_id INTEGER,
+    value REAL NOT NULL,
+    recorded_at DATETIME NOT NULL
+);
+CREATE INDEX idx_monitor_values_monitor_id_recorded_at ON monitor_values (monitor_id, recorded_at);
+CREATE INDEX idx_monitor_values_recorded_at ON monitor_values (recorded_at);
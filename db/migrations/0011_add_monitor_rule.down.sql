@@ -0,0 +1,2 @@
+ALTER TABLE monitor_revisions DROP COLUMN rule;
+ALTER TABLE monitors DROP COLUMN rule;
@@ -0,0 +1,2 @@
+ALTER TABLE monitor_revisions DROP COLUMN steps;
+ALTER TABLE monitors DROP COLUMN steps;
@@ -0,0 +1,2 @@
+ALTER TABLE monitors ADD COLUMN steps TEXT NOT NULL DEFAULT '';
+ALTER TABLE monitor_revisions ADD COLUMN steps TEXT NOT NULL DEFAULT '';
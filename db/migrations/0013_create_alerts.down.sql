@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_silences_ends_at;
+DROP TABLE IF EXISTS silences;
+DROP TABLE IF EXISTS alert_states;
+DROP TABLE IF EXISTS alert_rules;
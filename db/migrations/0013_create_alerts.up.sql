@@ -0,0 +1,35 @@
+CREATE TABLE alert_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    monitor_id INTEGER,
+    trigger_on TEXT NOT NULL,
+    threshold INTEGER NOT NULL,
+    window_size INTEGER NOT NULL,
+    resolve_after INTEGER NOT NULL,
+    flap_threshold INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL
+);
+CREATE TABLE alert_states (
+    rule_id INTEGER NOT NULL,
+    monitor_id INTEGER NOT NULL,
+    tenant_id INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    history TEXT NOT NULL DEFAULT '',
+    good_streak INTEGER NOT NULL DEFAULT 0,
+    flapping INTEGER NOT NULL DEFAULT 0,
+    since DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (rule_id, monitor_id)
+);
+CREATE TABLE silences (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id INTEGER NOT NULL,
+    monitor_id INTEGER,
+    starts_at DATETIME NOT NULL,
+    ends_at DATETIME NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    created_by_key_id INTEGER,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX idx_silences_ends_at ON silences (ends_at);
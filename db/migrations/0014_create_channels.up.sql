@@ -0,0 +1,9 @@
+CREATE TABLE channels (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    settings TEXT NOT NULL DEFAULT '',
+    template TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
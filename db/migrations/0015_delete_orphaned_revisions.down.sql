@@ -0,0 +1,2 @@
+-- The deleted revisions cannot be restored.
+SELECT 1;
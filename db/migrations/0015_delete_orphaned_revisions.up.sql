@@ -0,0 +1,2 @@
+-- Revisions of deleted monitors were left behind before DeleteMonitor removed them.
+DELETE FROM monitor_revisions WHERE monitor_id NOT IN (SELECT id FROM monitors);
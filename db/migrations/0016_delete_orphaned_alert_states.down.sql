@@ -0,0 +1,2 @@
+-- The deleted alert states cannot be restored.
+SELECT 1;
@@ -0,0 +1,2 @@
+-- Alert states of deleted monitors were left behind before the alerter dropped them.
+DELETE FROM alert_states WHERE monitor_id NOT IN (SELECT id FROM monitors);
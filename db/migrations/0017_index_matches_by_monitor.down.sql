@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_matches_monitor_id_id;
+CREATE INDEX idx_matches_monitor_id ON matches (monitor_id);
@@ -0,0 +1,3 @@
+-- Dedup looks up a monitor's latest match, which the monitor_id index alone leaves to a sort.
+DROP INDEX IF EXISTS idx_matches_monitor_id;
+CREATE INDEX idx_matches_monitor_id_id ON matches (monitor_id, id);
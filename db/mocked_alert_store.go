@@ -0,0 +1,405 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package db
+
+import (
+	"sync"
+)
+
+// Ensure, that AlertStoreMock does implement AlertStore.
+// If this is not the case, regenerate this file with moq.
+var _ AlertStore = &AlertStoreMock{}
+
+// AlertStoreMock is a mock implementation of AlertStore.
+//
+//	func TestSomethingThatUsesAlertStore(t *testing.T) {
+//
+//		// make and configure a mocked AlertStore
+//		mockedAlertStore := &AlertStoreMock{
+//			CreateAlertRuleFunc: func(rule AlertRule) (AlertRule, error) {
+//				panic("mock out the CreateAlertRule method")
+//			},
+//			CreateSilenceFunc: func(silence Silence) (Silence, error) {
+//				panic("mock out the CreateSilence method")
+//			},
+//			DeleteAlertRuleFunc: func(id int64) error {
+//				panic("mock out the DeleteAlertRule method")
+//			},
+//			DeleteAlertStatesFunc: func(monitorID int64) error {
+//				panic("mock out the DeleteAlertStates method")
+//			},
+//			DeleteSilenceFunc: func(id int64) error {
+//				panic("mock out the DeleteSilence method")
+//			},
+//			ListAlertRulesFunc: func() ([]AlertRule, error) {
+//				panic("mock out the ListAlertRules method")
+//			},
+//			ListAlertStatesFunc: func() ([]AlertState, error) {
+//				panic("mock out the ListAlertStates method")
+//			},
+//			ListSilencesFunc: func() ([]Silence, error) {
+//				panic("mock out the ListSilences method")
+//			},
+//			SaveAlertStateFunc: func(state AlertState) error {
+//				panic("mock out the SaveAlertState method")
+//			},
+//		}
+//
+//		// use mockedAlertStore in code that requires AlertStore
+//		// and then make assertions.
+//
+//	}
+type AlertStoreMock struct {
+	// CreateAlertRuleFunc mocks the CreateAlertRule method.
+	CreateAlertRuleFunc func(rule AlertRule) (AlertRule, error)
+
+	// CreateSilenceFunc mocks the CreateSilence method.
+	CreateSilenceFunc func(silence Silence) (Silence, error)
+
+	// DeleteAlertRuleFunc mocks the DeleteAlertRule method.
+	DeleteAlertRuleFunc func(id int64) error
+
+	// DeleteAlertStatesFunc mocks the DeleteAlertStates method.
+	DeleteAlertStatesFunc func(monitorID int64) error
+
+	// DeleteSilenceFunc mocks the DeleteSilence method.
+	DeleteSilenceFunc func(id int64) error
+
+	// ListAlertRulesFunc mocks the ListAlertRules method.
+	ListAlertRulesFunc func() ([]AlertRule, error)
+
+	// ListAlertStatesFunc mocks the ListAlertStates method.
+	ListAlertStatesFunc func() ([]AlertState, error)
+
+	// ListSilencesFunc mocks the ListSilences method.
+	ListSilencesFunc func() ([]Silence, error)
+
+	// SaveAlertStateFunc mocks the SaveAlertState method.
+	SaveAlertStateFunc func(state AlertState) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// CreateAlertRule holds details about calls to the CreateAlertRule method.
+		CreateAlertRule []struct {
+			// Rule is the rule argument value.
+			Rule AlertRule
+		}
+		// CreateSilence holds details about calls to the CreateSilence method.
+		CreateSilence []struct {
+			// Silence is the silence argument value.
+			Silence Silence
+		}
+		// DeleteAlertRule holds details about calls to the DeleteAlertRule method.
+		DeleteAlertRule []struct {
+			// ID is the id argument value.
+			ID int64
+		}
+		// DeleteAlertStates holds details about calls to the DeleteAlertStates method.
+		DeleteAlertStates []struct {
+			// MonitorID is the monitorID argument value.
+			MonitorID int64
+		}
+		// DeleteSilence holds details about calls to the DeleteSilence method.
+		DeleteSilence []struct {
+			// ID is the id argument value.
+			ID int64
+		}
+		// ListAlertRules holds details about calls to the ListAlertRules method.
+		ListAlertRules []struct {
+		}
+		// ListAlertStates holds details about calls to the ListAlertStates method.
+		ListAlertStates []struct {
+		}
+		// ListSilences holds details about calls to the ListSilences method.
+		ListSilences []struct {
+		}
+		// SaveAlertState holds details about calls to the SaveAlertState method.
+		SaveAlertState []struct {
+			// State is the state argument value.
+			State AlertState
+		}
+	}
+	lockCreateAlertRule   sync.RWMutex
+	lockCreateSilence     sync.RWMutex
+	lockDeleteAlertRule   sync.RWMutex
+	lockDeleteAlertStates sync.RWMutex
+	lockDeleteSilence     sync.RWMutex
+	lockListAlertRules    sync.RWMutex
+	lockListAlertStates   sync.RWMutex
+	lockListSilences      sync.RWMutex
+	lockSaveAlertState    sync.RWMutex
+}
+
+// CreateAlertRule calls CreateAlertRuleFunc.
+func (mock *AlertStoreMock) CreateAlertRule(rule AlertRule) (AlertRule, error) {
+	if mock.CreateAlertRuleFunc == nil {
+		panic("AlertStoreMock.CreateAlertRuleFunc: method is nil but AlertStore.CreateAlertRule was just called")
+	}
+	callInfo := struct {
+		Rule AlertRule
+	}{
+		Rule: rule,
+	}
+	mock.lockCreateAlertRule.Lock()
+	mock.calls.CreateAlertRule = append(mock.calls.CreateAlertRule, callInfo)
+	mock.lockCreateAlertRule.Unlock()
+	return mock.CreateAlertRuleFunc(rule)
+}
+
+// CreateAlertRuleCalls gets all the calls that were made to CreateAlertRule.
+// Check the length with:
+//
+//	len(mockedAlertStore.CreateAlertRuleCalls())
+func (mock *AlertStoreMock) CreateAlertRuleCalls() []struct {
+	Rule AlertRule
+} {
+	var calls []struct {
+		Rule AlertRule
+	}
+	mock.lockCreateAlertRule.RLock()
+	calls = mock.calls.CreateAlertRule
+	mock.lockCreateAlertRule.RUnlock()
+	return calls
+}
+
+// CreateSilence calls CreateSilenceFunc.
+func (mock *AlertStoreMock) CreateSilence(silence Silence) (Silence, error) {
+	if mock.CreateSilenceFunc == nil {
+		panic("AlertStoreMock.CreateSilenceFunc: method is nil but AlertStore.CreateSilence was just called")
+	}
+	callInfo := struct {
+		Silence Silence
+	}{
+		Silence: silence,
+	}
+	mock.lockCreateSilence.Lock()
+	mock.calls.CreateSilence = append(mock.calls.CreateSilence, callInfo)
+	mock.lockCreateSilence.Unlock()
+	return mock.CreateSilenceFunc(silence)
+}
+
+// CreateSilenceCalls gets all the calls that were made to CreateSilence.
+// Check the length with:
+//
+//	len(mockedAlertStore.CreateSilenceCalls())
+func (mock *AlertStoreMock) CreateSilenceCalls() []struct {
+	Silence Silence
+} {
+	var calls []struct {
+		Silence Silence
+	}
+	mock.lockCreateSilence.RLock()
+	calls = mock.calls.CreateSilence
+	mock.lockCreateSilence.RUnlock()
+	return calls
+}
+
+// DeleteAlertRule calls DeleteAlertRuleFunc.
+func (mock *AlertStoreMock) DeleteAlertRule(id int64) error {
+	if mock.DeleteAlertRuleFunc == nil {
+		panic("AlertStoreMock.DeleteAlertRuleFunc: method is nil but AlertStore.DeleteAlertRule was just called")
+	}
+	callInfo := struct {
+		ID int64
+	}{
+		ID: id,
+	}
+	mock.lockDeleteAlertRule.Lock()
+	mock.calls.DeleteAlertRule = append(mock.calls.DeleteAlertRule, callInfo)
+	mock.lockDeleteAlertRule.Unlock()
+	return mock.DeleteAlertRuleFunc(id)
+}
+
+// DeleteAlertRuleCalls gets all the calls that were made to DeleteAlertRule.
+// Check the length with:
+//
+//	len(mockedAlertStore.DeleteAlertRuleCalls())
+func (mock *AlertStoreMock) DeleteAlertRuleCalls() []struct {
+	ID int64
+} {
+	var calls []struct {
+		ID int64
+	}
+	mock.lockDeleteAlertRule.RLock()
+	calls = mock.calls.DeleteAlertRule
+	mock.lockDeleteAlertRule.RUnlock()
+	return calls
+}
+
+// DeleteAlertStates calls DeleteAlertStatesFunc.
+func (mock *AlertStoreMock) DeleteAlertStates(monitorID int64) error {
+	if mock.DeleteAlertStatesFunc == nil {
+		panic("AlertStoreMock.DeleteAlertStatesFunc: method is nil but AlertStore.DeleteAlertStates was just called")
+	}
+	callInfo := struct {
+		MonitorID int64
+	}{
+		MonitorID: monitorID,
+	}
+	mock.lockDeleteAlertStates.Lock()
+	mock.calls.DeleteAlertStates = append(mock.calls.DeleteAlertStates, callInfo)
+	mock.lockDeleteAlertStates.Unlock()
+	return mock.DeleteAlertStatesFunc(monitorID)
+}
+
+// DeleteAlertStatesCalls gets all the calls that were made to DeleteAlertStates.
+// Check the length with:
+//
+//	len(mockedAlertStore.DeleteAlertStatesCalls())
+func (mock *AlertStoreMock) DeleteAlertStatesCalls() []struct {
+	MonitorID int64
+} {
+	var calls []struct {
+		MonitorID int64
+	}
+	mock.lockDeleteAlertStates.RLock()
+	calls = mock.calls.DeleteAlertStates
+	mock.lockDeleteAlertStates.RUnlock()
+	return calls
+}
+
+// DeleteSilence calls DeleteSilenceFunc.
+func (mock *AlertStoreMock) DeleteSilence(id int64) error {
+	if mock.DeleteSilenceFunc == nil {
+		panic("AlertStoreMock.DeleteSilenceFunc: method is nil but AlertStore.DeleteSilence was just called")
+	}
+	callInfo := struct {
+		ID int64
+	}{
+		ID: id,
+	}
+	mock.lockDeleteSilence.Lock()
+	mock.calls.DeleteSilence = append(mock.calls.DeleteSilence, callInfo)
+	mock.lockDeleteSilence.Unlock()
+	return mock.DeleteSilenceFunc(id)
+}
+
+// DeleteSilenceCalls gets all the calls that were made to DeleteSilence.
+// Check the length with:
+//
+//	len(mockedAlertStore.DeleteSilenceCalls())
+func (mock *AlertStoreMock) DeleteSilenceCalls() []struct {
+	ID int64
+} {
+	var calls []struct {
+		ID int64
+	}
+	mock.lockDeleteSilence.RLock()
+	calls = mock.calls.DeleteSilence
+	mock.lockDeleteSilence.RUnlock()
+	return calls
+}
+
+// ListAlertRules calls ListAlertRulesFunc.
+func (mock *AlertStoreMock) ListAlertRules() ([]AlertRule, error) {
+	if mock.ListAlertRulesFunc == nil {
+		panic("AlertStoreMock.ListAlertRulesFunc: method is nil but AlertStore.ListAlertRules was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockListAlertRules.Lock()
+	mock.calls.ListAlertRules = append(mock.calls.ListAlertRules, callInfo)
+	mock.lockListAlertRules.Unlock()
+	return mock.ListAlertRulesFunc()
+}
+
+// ListAlertRulesCalls gets all the calls that were made to ListAlertRules.
+// Check the length with:
+//
+//	len(mockedAlertStore.ListAlertRulesCalls())
+func (mock *AlertStoreMock) ListAlertRulesCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockListAlertRules.RLock()
+	calls = mock.calls.ListAlertRules
+	mock.lockListAlertRules.RUnlock()
+	return calls
+}
+
+// ListAlertStates calls ListAlertStatesFunc.
+func (mock *AlertStoreMock) ListAlertStates() ([]AlertState, error) {
+	if mock.ListAlertStatesFunc == nil {
+		panic("AlertStoreMock.ListAlertStatesFunc: method is nil but AlertStore.ListAlertStates was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockListAlertStates.Lock()
+	mock.calls.ListAlertStates = append(mock.calls.ListAlertStates, callInfo)
+	mock.lockListAlertStates.Unlock()
+	return mock.ListAlertStatesFunc()
+}
+
+// ListAlertStatesCalls gets all the calls that were made to ListAlertStates.
+// Check the length with:
+//
+//	len(mockedAlertStore.ListAlertStatesCalls())
+func (mock *AlertStoreMock) ListAlertStatesCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockListAlertStates.RLock()
+	calls = mock.calls.ListAlertStates
+	mock.lockListAlertStates.RUnlock()
+	return calls
+}
+
+// ListSilences calls ListSilencesFunc.
+func (mock *AlertStoreMock) ListSilences() ([]Silence, error) {
+	if mock.ListSilencesFunc == nil {
+		panic("AlertStoreMock.ListSilencesFunc: method is nil but AlertStore.ListSilences was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockListSilences.Lock()
+	mock.calls.ListSilences = append(mock.calls.ListSilences, callInfo)
+	mock.lockListSilences.Unlock()
+	return mock.ListSilencesFunc()
+}
+
+// ListSilencesCalls gets all the calls that were made to ListSilences.
+// Check the length with:
+//
+//	len(mockedAlertStore.ListSilencesCalls())
+func (mock *AlertStoreMock) ListSilencesCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockListSilences.RLock()
+	calls = mock.calls.ListSilences
+	mock.lockListSilences.RUnlock()
+	return calls
+}
+
+// SaveAlertState calls SaveAlertStateFunc.
+func (mock *AlertStoreMock) SaveAlertState(state AlertState) error {
+	if mock.SaveAlertStateFunc == nil {
+		panic("AlertStoreMock.SaveAlertStateFunc: method is nil but AlertStore.SaveAlertState was just called")
+	}
+	callInfo := struct {
+		State AlertState
+	}{
+		State: state,
+	}
+	mock.lockSaveAlertState.Lock()
+	mock.calls.SaveAlertState = append(mock.calls.SaveAlertState, callInfo)
+	mock.lockSaveAlertState.Unlock()
+	return mock.SaveAlertStateFunc(state)
+}
+
+// SaveAlertStateCalls gets all the calls that were made to SaveAlertState.
+// Check the length with:
+//
+//	len(mockedAlertStore.SaveAlertStateCalls())
+func (mock *AlertStoreMock) SaveAlertStateCalls() []struct {
+	State AlertState
+} {
+	var calls []struct {
+		State AlertState
+	}
+	mock.lockSaveAlertState.RLock()
+	calls = mock.calls.SaveAlertState
+	mock.lockSaveAlertState.RUnlock()
+	return calls
+}
@@ -0,0 +1,199 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package db
+
+import (
+	"sync"
+)
+
+// Ensure, that ChannelStoreMock does implement ChannelStore.
+// If this is not the case, regenerate this file with moq.
+var _ ChannelStore = &ChannelStoreMock{}
+
+// ChannelStoreMock is a mock implementation of ChannelStore.
+//
+//	func TestSomethingThatUsesChannelStore(t *testing.T) {
+//
+//		// make and configure a mocked ChannelStore
+//		mockedChannelStore := &ChannelStoreMock{
+//			CreateChannelFunc: func(channel Channel) (Channel, error) {
+//				panic("mock out the CreateChannel method")
+//			},
+//			DeleteChannelFunc: func(id int64) error {
+//				panic("mock out the DeleteChannel method")
+//			},
+//			GetChannelFunc: func(id int64) (Channel, error) {
+//				panic("mock out the GetChannel method")
+//			},
+//			ListChannelsFunc: func() ([]Channel, error) {
+//				panic("mock out the ListChannels method")
+//			},
+//		}
+//
+//		// use mockedChannelStore in code that requires ChannelStore
+//		// and then make assertions.
+//
+//	}
+type ChannelStoreMock struct {
+	// CreateChannelFunc mocks the CreateChannel method.
+	CreateChannelFunc func(channel Channel) (Channel, error)
+
+	// DeleteChannelFunc mocks the DeleteChannel method.
+	DeleteChannelFunc func(id int64) error
+
+	// GetChannelFunc mocks the GetChannel method.
+	GetChannelFunc func(id int64) (Channel, error)
+
+	// ListChannelsFunc mocks the ListChannels method.
+	ListChannelsFunc func() ([]Channel, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// CreateChannel holds details about calls to the CreateChannel method.
+		CreateChannel []struct {
+			// Channel is the channel argument value.
+			Channel Channel
+		}
+		// DeleteChannel holds details about calls to the DeleteChannel method.
+		DeleteChannel []struct {
+			// ID is the id argument value.
+			ID int64
+		}
+		// GetChannel holds details about calls to the GetChannel method.
+		GetChannel []struct {
+			// ID is the id argument value.
+			ID int64
+		}
+		// ListChannels holds details about calls to the ListChannels method.
+		ListChannels []struct {
+		}
+	}
+	lockCreateChannel sync.RWMutex
+	lockDeleteChannel sync.RWMutex
+	lockGetChannel    sync.RWMutex
+	lockListChannels  sync.RWMutex
+}
+
+// CreateChannel calls CreateChannelFunc.
+func (mock *ChannelStoreMock) CreateChannel(channel Channel) (Channel, error) {
+	if mock.CreateChannelFunc == nil {
+		panic("ChannelStoreMock.CreateChannelFunc: method is nil but ChannelStore.CreateChannel was just called")
+	}
+	callInfo := struct {
+		Channel Channel
+	}{
+		Channel: channel,
+	}
+	mock.lockCreateChannel.Lock()
+	mock.calls.CreateChannel = append(mock.calls.CreateChannel, callInfo)
+	mock.lockCreateChannel.Unlock()
+	return mock.CreateChannelFunc(channel)
+}
+
+// CreateChannelCalls gets all the calls that were made to CreateChannel.
+// Check the length with:
+//
+//	len(mockedChannelStore.CreateChannelCalls())
+func (mock *ChannelStoreMock) CreateChannelCalls() []struct {
+	Channel Channel
+} {
+	var calls []struct {
+		Channel Channel
+	}
+	mock.lockCreateChannel.RLock()
+	calls = mock.calls.CreateChannel
+	mock.lockCreateChannel.RUnlock()
+	return calls
+}
+
+// DeleteChannel calls DeleteChannelFunc.
+func (mock *ChannelStoreMock) DeleteChannel(id int64) error {
+	if mock.DeleteChannelFunc == nil {
+		panic("ChannelStoreMock.DeleteChannelFunc: method is nil but ChannelStore.DeleteChannel was just called")
+	}
+	callInfo := struct {
+		ID int64
+	}{
+		ID: id,
+	}
+	mock.lockDeleteChannel.Lock()
+	mock.calls.DeleteChannel = append(mock.calls.DeleteChannel, callInfo)
+	mock.lockDeleteChannel.Unlock()
+	return mock.DeleteChannelFunc(id)
+}
+
+// DeleteChannelCalls gets all the calls that were made to DeleteChannel.
+// Check the length with:
+//
+//	len(mockedChannelStore.DeleteChannelCalls())
+func (mock *ChannelStoreMock) DeleteChannelCalls() []struct {
+	ID int64
+} {
+	var calls []struct {
+		ID int64
+	}
+	mock.lockDeleteChannel.RLock()
+	calls = mock.calls.DeleteChannel
+	mock.lockDeleteChannel.RUnlock()
+	return calls
+}
+
+// GetChannel calls GetChannelFunc.
+func (mock *ChannelStoreMock) GetChannel(id int64) (Channel, error) {
+	if mock.GetChannelFunc == nil {
+		panic("ChannelStoreMock.GetChannelFunc: method is nil but ChannelStore.GetChannel was just called")
+	}
+	callInfo := struct {
+		ID int64
+	}{
+		ID: id,
+	}
+	mock.lockGetChannel.Lock()
+	mock.calls.GetChannel = append(mock.calls.GetChannel, callInfo)
+	mock.lockGetChannel.Unlock()
+	return mock.GetChannelFunc(id)
+}
+
+// GetChannelCalls gets all the calls that were made to GetChannel.
+// Check the length with:
+//
+//	len(mockedChannelStore.GetChannelCalls())
+func (mock *ChannelStoreMock) GetChannelCalls() []struct {
+	ID int64
+} {
+	var calls []struct {
+		ID int64
+	}
+	mock.lockGetChannel.RLock()
+	calls = mock.calls.GetChannel
+	mock.lockGetChannel.RUnlock()
+	return calls
+}
+
+// ListChannels calls ListChannelsFunc.
+func (mock *ChannelStoreMock) ListChannels() ([]Channel, error) {
+	if mock.ListChannelsFunc == nil {
+		panic("ChannelStoreMock.ListChannelsFunc: method is nil but ChannelStore.ListChannels was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockListChannels.Lock()
+	mock.calls.ListChannels = append(mock.calls.ListChannels, callInfo)
+	mock.lockListChannels.Unlock()
+	return mock.ListChannelsFunc()
+}
+
+// ListChannelsCalls gets all the calls that were made to ListChannels.
+// Check the length with:
+//
+//	len(mockedChannelStore.ListChannelsCalls())
+func (mock *ChannelStoreMock) ListChannelsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockListChannels.RLock()
+	calls = mock.calls.ListChannels
+	mock.lockListChannels.RUnlock()
+	return calls
+}
@@ -17,9 +17,18 @@ var _ DB = &DBMock{}
 //
 //		// make and configure a mocked DB
 //		mockedDB := &DBMock{
-//			SaveDataFunc: func(url string, pattern string, data string) error {
+//			LastValueFunc: func(monitorID int64) (MonitorValue, error) {
+//				panic("mock out the LastValue method")
+//			},
+//			SaveCheckRunFunc: func(run CheckRun) error {
+//				panic("mock out the SaveCheckRun method")
+//			},
+//			SaveDataFunc: func(match Match) error {
 //				panic("mock out the SaveData method")
 //			},
+//			SaveValueFunc: func(value MonitorValue) error {
+//				panic("mock out the SaveValue method")
+//			},
 //		}
 //
 //		// use mockedDB in code that requires DB
@@ -27,42 +36,125 @@ var _ DB = &DBMock{}
 //
 //	}
 type DBMock struct {
+	// LastValueFunc mocks the LastValue method.
+	LastValueFunc func(monitorID int64) (MonitorValue, error)
+
+	// SaveCheckRunFunc mocks the SaveCheckRun method.
+	SaveCheckRunFunc func(run CheckRun) error
+
 	// SaveDataFunc mocks the SaveData method.
-	SaveDataFunc func(url string, pattern string, data string) error
+	SaveDataFunc func(match Match) error
+
+	// SaveValueFunc mocks the SaveValue method.
+	SaveValueFunc func(value MonitorValue) error
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// LastValue holds details about calls to the LastValue method.
+		LastValue []struct {
+			// MonitorID is the monitorID argument value.
+			MonitorID int64
+		}
+		// SaveCheckRun holds details about calls to the SaveCheckRun method.
+		SaveCheckRun []struct {
+			// Run is the run argument value.
+			Run CheckRun
+		}
 		// SaveData holds details about calls to the SaveData method.
 		SaveData []struct {
-			// URL is the url argument value.
-			URL string
-			// Pattern is the pattern argument value.
-			Pattern string
-			// Data is the data argument value.
-			Data string
+			// Match is the match argument value.
+			Match Match
+		}
+		// SaveValue holds details about calls to the SaveValue method.
+		SaveValue []struct {
+			// Value is the value argument value.
+			Value MonitorValue
 		}
 	}
-	lockSaveData sync.RWMutex
+	lockLastValue    sync.RWMutex
+	lockSaveCheckRun sync.RWMutex
+	lockSaveData     sync.RWMutex
+	lockSaveValue    sync.RWMutex
+}
+
+// LastValue calls LastValueFunc.
+func (mock *DBMock) LastValue(monitorID int64) (MonitorValue, error) {
+	if mock.LastValueFunc == nil {
+		panic("DBMock.LastValueFunc: method is nil but DB.LastValue was just called")
+	}
+	callInfo := struct {
+		MonitorID int64
+	}{
+		MonitorID: monitorID,
+	}
+	mock.lockLastValue.Lock()
+	mock.calls.LastValue = append(mock.calls.LastValue, callInfo)
+	mock.lockLastValue.Unlock()
+	return mock.LastValueFunc(monitorID)
+}
+
+// LastValueCalls gets all the calls that were made to LastValue.
+// Check the length with:
+//
+//	len(mockedDB.LastValueCalls())
+func (mock *DBMock) LastValueCalls() []struct {
+	MonitorID int64
+} {
+	var calls []struct {
+		MonitorID int64
+	}
+	mock.lockLastValue.RLock()
+	calls = mock.calls.LastValue
+	mock.lockLastValue.RUnlock()
+	return calls
+}
+
+// SaveCheckRun calls SaveCheckRunFunc.
+func (mock *DBMock) SaveCheckRun(run CheckRun) error {
+	if mock.SaveCheckRunFunc == nil {
+		panic("DBMock.SaveCheckRunFunc: method is nil but DB.SaveCheckRun was just called")
+	}
+	callInfo := struct {
+		Run CheckRun
+	}{
+		Run: run,
+	}
+	mock.lockSaveCheckRun.Lock()
+	mock.calls.SaveCheckRun = append(mock.calls.SaveCheckRun, callInfo)
+	mock.lockSaveCheckRun.Unlock()
+	return mock.SaveCheckRunFunc(run)
+}
+
+// SaveCheckRunCalls gets all the calls that were made to SaveCheckRun.
+// Check the length with:
+//
+//	len(mockedDB.SaveCheckRunCalls())
+func (mock *DBMock) SaveCheckRunCalls() []struct {
+	Run CheckRun
+} {
+	var calls []struct {
+		Run CheckRun
+	}
+	mock.lockSaveCheckRun.RLock()
+	calls = mock.calls.SaveCheckRun
+	mock.lockSaveCheckRun.RUnlock()
+	return calls
 }
 
 // SaveData calls SaveDataFunc.
-func (mock *DBMock) SaveData(url string, pattern string, data string) error {
+func (mock *DBMock) SaveData(match Match) error {
 	if mock.SaveDataFunc == nil {
 		panic("DBMock.SaveDataFunc: method is nil but DB.SaveData was just called")
 	}
 	callInfo := struct {
-		URL     string
-		Pattern string
-		Data    string
+		Match Match
 	}{
-		URL:     url,
-		Pattern: pattern,
-		Data:    data,
+		Match: match,
 	}
 	mock.lockSaveData.Lock()
 	mock.calls.SaveData = append(mock.calls.SaveData, callInfo)
 	mock.lockSaveData.Unlock()
-	return mock.SaveDataFunc(url, pattern, data)
+	return mock.SaveDataFunc(match)
 }
 
 // SaveDataCalls gets all the calls that were made to SaveData.
@@ -70,17 +162,45 @@ func (mock *DBMock) SaveData(url string, pattern string, data string) error {
 //
 //	len(mockedDB.SaveDataCalls())
 func (mock *DBMock) SaveDataCalls() []struct {
-	URL     string
-	Pattern string
-	Data    string
+	Match Match
 } {
 	var calls []struct {
-		URL     string
-		Pattern string
-		Data    string
+		Match Match
 	}
 	mock.lockSaveData.RLock()
 	calls = mock.calls.SaveData
 	mock.lockSaveData.RUnlock()
 	return calls
 }
+
+// SaveValue calls SaveValueFunc.
+func (mock *DBMock) SaveValue(value MonitorValue) error {
+	if mock.SaveValueFunc == nil {
+		panic("DBMock.SaveValueFunc: method is nil but DB.SaveValue was just called")
+	}
+	callInfo := struct {
+		Value MonitorValue
+	}{
+		Value: value,
+	}
+	mock.lockSaveValue.Lock()
+	mock.calls.SaveValue = append(mock.calls.SaveValue, callInfo)
+	mock.lockSaveValue.Unlock()
+	return mock.SaveValueFunc(value)
+}
+
+// SaveValueCalls gets all the calls that were made to SaveValue.
+// Check the length with:
+//
+//	len(mockedDB.SaveValueCalls())
+func (mock *DBMock) SaveValueCalls() []struct {
+	Value MonitorValue
+} {
+	var calls []struct {
+		Value MonitorValue
+	}
+	mock.lockSaveValue.RLock()
+	calls = mock.calls.SaveValue
+	mock.lockSaveValue.RUnlock()
+	return calls
+}
@@ -0,0 +1,205 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package db
+
+import (
+	"sync"
+)
+
+// Ensure, that KeyStoreMock does implement KeyStore.
+// If this is not the case, regenerate this file with moq.
+var _ KeyStore = &KeyStoreMock{}
+
+// KeyStoreMock is a mock implementation of KeyStore.
+//
+//	func TestSomethingThatUsesKeyStore(t *testing.T) {
+//
+//		// make and configure a mocked KeyStore
+//		mockedKeyStore := &KeyStoreMock{
+//			CreateAPIKeyFunc: func(key APIKey, hash string) (APIKey, error) {
+//				panic("mock out the CreateAPIKey method")
+//			},
+//			GetAPIKeyByHashFunc: func(hash string) (APIKey, error) {
+//				panic("mock out the GetAPIKeyByHash method")
+//			},
+//			ListAPIKeysFunc: func() ([]APIKey, error) {
+//				panic("mock out the ListAPIKeys method")
+//			},
+//			RevokeAPIKeyFunc: func(id int64) error {
+//				panic("mock out the RevokeAPIKey method")
+//			},
+//		}
+//
+//		// use mockedKeyStore in code that requires KeyStore
+//		// and then make assertions.
+//
+//	}
+type KeyStoreMock struct {
+	// CreateAPIKeyFunc mocks the CreateAPIKey method.
+	CreateAPIKeyFunc func(key APIKey, hash string) (APIKey, error)
+
+	// GetAPIKeyByHashFunc mocks the GetAPIKeyByHash method.
+	GetAPIKeyByHashFunc func(hash string) (APIKey, error)
+
+	// ListAPIKeysFunc mocks the ListAPIKeys method.
+	ListAPIKeysFunc func() ([]APIKey, error)
+
+	// RevokeAPIKeyFunc mocks the RevokeAPIKey method.
+	RevokeAPIKeyFunc func(id int64) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// CreateAPIKey holds details about calls to the CreateAPIKey method.
+		CreateAPIKey []struct {
+			// Key is the key argument value.
+			Key APIKey
+			// Hash is the hash argument value.
+			Hash string
+		}
+		// GetAPIKeyByHash holds details about calls to the GetAPIKeyByHash method.
+		GetAPIKeyByHash []struct {
+			// Hash is the hash argument value.
+			Hash string
+		}
+		// ListAPIKeys holds details about calls to the ListAPIKeys method.
+		ListAPIKeys []struct {
+		}
+		// RevokeAPIKey holds details about calls to the RevokeAPIKey method.
+		RevokeAPIKey []struct {
+			// ID is the id argument value.
+			ID int64
+		}
+	}
+	lockCreateAPIKey    sync.RWMutex
+	lockGetAPIKeyByHash sync.RWMutex
+	lockListAPIKeys     sync.RWMutex
+	lockRevokeAPIKey    sync.RWMutex
+}
+
+// CreateAPIKey calls CreateAPIKeyFunc.
+func (mock *KeyStoreMock) CreateAPIKey(key APIKey, hash string) (APIKey, error) {
+	if mock.CreateAPIKeyFunc == nil {
+		panic("KeyStoreMock.CreateAPIKeyFunc: method is nil but KeyStore.CreateAPIKey was just called")
+	}
+	callInfo := struct {
+		Key  APIKey
+		Hash string
+	}{
+		Key:  key,
+		Hash: hash,
+	}
+	mock.lockCreateAPIKey.Lock()
+	mock.calls.CreateAPIKey = append(mock.calls.CreateAPIKey, callInfo)
+	mock.lockCreateAPIKey.Unlock()
+	return mock.CreateAPIKeyFunc(key, hash)
+}
+
+// CreateAPIKeyCalls gets all the calls that were made to CreateAPIKey.
+// Check the length with:
+//
+//	len(mockedKeyStore.CreateAPIKeyCalls())
+func (mock *KeyStoreMock) CreateAPIKeyCalls() []struct {
+	Key  APIKey
+	Hash string
+} {
+	var calls []struct {
+		Key  APIKey
+		Hash string
+	}
+	mock.lockCreateAPIKey.RLock()
+	calls = mock.calls.CreateAPIKey
+	mock.lockCreateAPIKey.RUnlock()
+	return calls
+}
+
+// GetAPIKeyByHash calls GetAPIKeyByHashFunc.
+func (mock *KeyStoreMock) GetAPIKeyByHash(hash string) (APIKey, error) {
+	if mock.GetAPIKeyByHashFunc == nil {
+		panic("KeyStoreMock.GetAPIKeyByHashFunc: method is nil but KeyStore.GetAPIKeyByHash was just called")
+	}
+	callInfo := struct {
+		Hash string
+	}{
+		Hash: hash,
+	}
+	mock.lockGetAPIKeyByHash.Lock()
+	mock.calls.GetAPIKeyByHash = append(mock.calls.GetAPIKeyByHash, callInfo)
+	mock.lockGetAPIKeyByHash.Unlock()
+	return mock.GetAPIKeyByHashFunc(hash)
+}
+
+// GetAPIKeyByHashCalls gets all the calls that were made to GetAPIKeyByHash.
+// Check the length with:
+//
+//	len(mockedKeyStore.GetAPIKeyByHashCalls())
+func (mock *KeyStoreMock) GetAPIKeyByHashCalls() []struct {
+	Hash string
+} {
+	var calls []struct {
+		Hash string
+	}
+	mock.lockGetAPIKeyByHash.RLock()
+	calls = mock.calls.GetAPIKeyByHash
+	mock.lockGetAPIKeyByHash.RUnlock()
+	return calls
+}
+
+// ListAPIKeys calls ListAPIKeysFunc.
+func (mock *KeyStoreMock) ListAPIKeys() ([]APIKey, error) {
+	if mock.ListAPIKeysFunc == nil {
+		panic("KeyStoreMock.ListAPIKeysFunc: method is nil but KeyStore.ListAPIKeys was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockListAPIKeys.Lock()
+	mock.calls.ListAPIKeys = append(mock.calls.ListAPIKeys, callInfo)
+	mock.lockListAPIKeys.Unlock()
+	return mock.ListAPIKeysFunc()
+}
+
+// ListAPIKeysCalls gets all the calls that were made to ListAPIKeys.
+// Check the length with:
+//
+//	len(mockedKeyStore.ListAPIKeysCalls())
+func (mock *KeyStoreMock) ListAPIKeysCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockListAPIKeys.RLock()
+	calls = mock.calls.ListAPIKeys
+	mock.lockListAPIKeys.RUnlock()
+	return calls
+}
+
+// RevokeAPIKey calls RevokeAPIKeyFunc.
+func (mock *KeyStoreMock) RevokeAPIKey(id int64) error {
+	if mock.RevokeAPIKeyFunc == nil {
+		panic("KeyStoreMock.RevokeAPIKeyFunc: method is nil but KeyStore.RevokeAPIKey was just called")
+	}
+	callInfo := struct {
+		ID int64
+	}{
+		ID: id,
+	}
+	mock.lockRevokeAPIKey.Lock()
+	mock.calls.RevokeAPIKey = append(mock.calls.RevokeAPIKey, callInfo)
+	mock.lockRevokeAPIKey.Unlock()
+	return mock.RevokeAPIKeyFunc(id)
+}
+
+// RevokeAPIKeyCalls gets all the calls that were made to RevokeAPIKey.
+// Check the length with:
+//
+//	len(mockedKeyStore.RevokeAPIKeyCalls())
+func (mock *KeyStoreMock) RevokeAPIKeyCalls() []struct {
+	ID int64
+} {
+	var calls []struct {
+		ID int64
+	}
+	mock.lockRevokeAPIKey.RLock()
+	calls = mock.calls.RevokeAPIKey
+	mock.lockRevokeAPIKey.RUnlock()
+	return calls
+}
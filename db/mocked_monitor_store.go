@@ -0,0 +1,394 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package db
+
+import (
+	"sync"
+	"time"
+)
+
+// Ensure, that MonitorStoreMock does implement MonitorStore.
+// If this is not the case, regenerate this file with moq.
+var _ MonitorStore = &MonitorStoreMock{}
+
+// MonitorStoreMock is a mock implementation of MonitorStore.
+//
+//	func TestSomethingThatUsesMonitorStore(t *testing.T) {
+//
+//		// make and configure a mocked MonitorStore
+//		mockedMonitorStore := &MonitorStoreMock{
+//			ApplyMonitorChangesFunc: func(changes MonitorChanges) (MonitorChanges, error) {
+//				panic("mock out the ApplyMonitorChanges method")
+//			},
+//			CreateMonitorFunc: func(monitor Monitor) (Monitor, error) {
+//				panic("mock out the CreateMonitor method")
+//			},
+//			DeleteMonitorFunc: func(id int64) error {
+//				panic("mock out the DeleteMonitor method")
+//			},
+//			GetMonitorFunc: func(id int64) (Monitor, error) {
+//				panic("mock out the GetMonitor method")
+//			},
+//			ListMonitorRevisionsFunc: func(id int64) ([]MonitorRevision, error) {
+//				panic("mock out the ListMonitorRevisions method")
+//			},
+//			ListMonitorValuesFunc: func(id int64, from time.Time, to time.Time, limit int) ([]MonitorValue, error) {
+//				panic("mock out the ListMonitorValues method")
+//			},
+//			ListMonitorsFunc: func() ([]Monitor, error) {
+//				panic("mock out the ListMonitors method")
+//			},
+//			UpdateMonitorFunc: func(monitor Monitor) (Monitor, error) {
+//				panic("mock out the UpdateMonitor method")
+//			},
+//		}
+//
+//		// use mockedMonitorStore in code that requires MonitorStore
+//		// and then make assertions.
+//
+//	}
+type MonitorStoreMock struct {
+	// ApplyMonitorChangesFunc mocks the ApplyMonitorChanges method.
+	ApplyMonitorChangesFunc func(changes MonitorChanges) (MonitorChanges, error)
+
+	// CreateMonitorFunc mocks the CreateMonitor method.
+	CreateMonitorFunc func(monitor Monitor) (Monitor, error)
+
+	// DeleteMonitorFunc mocks the DeleteMonitor method.
+	DeleteMonitorFunc func(id int64) error
+
+	// GetMonitorFunc mocks the GetMonitor method.
+	GetMonitorFunc func(id int64) (Monitor, error)
+
+	// ListMonitorRevisionsFunc mocks the ListMonitorRevisions method.
+	ListMonitorRevisionsFunc func(id int64) ([]MonitorRevision, error)
+
+	// ListMonitorValuesFunc mocks the ListMonitorValues method.
+	ListMonitorValuesFunc func(id int64, from time.Time, to time.Time, limit int) ([]MonitorValue, error)
+
+	// ListMonitorsFunc mocks the ListMonitors method.
+	ListMonitorsFunc func() ([]Monitor, error)
+
+	// UpdateMonitorFunc mocks the UpdateMonitor method.
+	UpdateMonitorFunc func(monitor Monitor) (Monitor, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// ApplyMonitorChanges holds details about calls to the ApplyMonitorChanges method.
+		ApplyMonitorChanges []struct {
+			// Changes is the changes argument value.
+			Changes MonitorChanges
+		}
+		// CreateMonitor holds details about calls to the CreateMonitor method.
+		CreateMonitor []struct {
+			// Monitor is the monitor argument value.
+			Monitor Monitor
+		}
+		// DeleteMonitor holds details about calls to the DeleteMonitor method.
+		DeleteMonitor []struct {
+			// ID is the id argument value.
+			ID int64
+		}
+		// GetMonitor holds details about calls to the GetMonitor method.
+		GetMonitor []struct {
+			// ID is the id argument value.
+			ID int64
+		}
+		// ListMonitorRevisions holds details about calls to the ListMonitorRevisions method.
+		ListMonitorRevisions []struct {
+			// ID is the id argument value.
+			ID int64
+		}
+		// ListMonitorValues holds details about calls to the ListMonitorValues method.
+		ListMonitorValues []struct {
+			// ID is the id argument value.
+			ID int64
+			// From is the from argument value.
+			From time.Time
+			// To is the to argument value.
+			To time.Time
+			// Limit is the limit argument value.
+			Limit int
+		}
+		// ListMonitors holds details about calls to the ListMonitors method.
+		ListMonitors []struct {
+		}
+		// UpdateMonitor holds details about calls to the UpdateMonitor method.
+		UpdateMonitor []struct {
+			// Monitor is the monitor argument value.
+			Monitor Monitor
+		}
+	}
+	lockApplyMonitorChanges  sync.RWMutex
+	lockCreateMonitor        sync.RWMutex
+	lockDeleteMonitor        sync.RWMutex
+	lockGetMonitor           sync.RWMutex
+	lockListMonitorRevisions sync.RWMutex
+	lockListMonitorValues    sync.RWMutex
+	lockListMonitors         sync.RWMutex
+	lockUpdateMonitor        sync.RWMutex
+}
+
+// ApplyMonitorChanges calls ApplyMonitorChangesFunc.
+func (mock *MonitorStoreMock) ApplyMonitorChanges(changes MonitorChanges) (MonitorChanges, error) {
+	if mock.ApplyMonitorChangesFunc == nil {
+		panic("MonitorStoreMock.ApplyMonitorChangesFunc: method is nil but MonitorStore.ApplyMonitorChanges was just called")
+	}
+	callInfo := struct {
+		Changes MonitorChanges
+	}{
+		Changes: changes,
+	}
+	mock.lockApplyMonitorChanges.Lock()
+	mock.calls.ApplyMonitorChanges = append(mock.calls.ApplyMonitorChanges, callInfo)
+	mock.lockApplyMonitorChanges.Unlock()
+	return mock.ApplyMonitorChangesFunc(changes)
+}
+
+// ApplyMonitorChangesCalls gets all the calls that were made to ApplyMonitorChanges.
+// Check the length with:
+//
+//	len(mockedMonitorStore.ApplyMonitorChangesCalls())
+func (mock *MonitorStoreMock) ApplyMonitorChangesCalls() []struct {
+	Changes MonitorChanges
+} {
+	var calls []struct {
+		Changes MonitorChanges
+	}
+	mock.lockApplyMonitorChanges.RLock()
+	calls = mock.calls.ApplyMonitorChanges
+	mock.lockApplyMonitorChanges.RUnlock()
+	return calls
+}
+
+// CreateMonitor calls CreateMonitorFunc.
+func (mock *MonitorStoreMock) CreateMonitor(monitor Monitor) (Monitor, error) {
+	if mock.CreateMonitorFunc == nil {
+		panic("MonitorStoreMock.CreateMonitorFunc: method is nil but MonitorStore.CreateMonitor was just called")
+	}
+	callInfo := struct {
+		Monitor Monitor
+	}{
+		Monitor: monitor,
+	}
+	mock.lockCreateMonitor.Lock()
+	mock.calls.CreateMonitor = append(mock.calls.CreateMonitor, callInfo)
+	mock.lockCreateMonitor.Unlock()
+	return mock.CreateMonitorFunc(monitor)
+}
+
+// CreateMonitorCalls gets all the calls that were made to CreateMonitor.
+// Check the length with:
+//
+//	len(mockedMonitorStore.CreateMonitorCalls())
+func (mock *MonitorStoreMock) CreateMonitorCalls() []struct {
+	Monitor Monitor
+} {
+	var calls []struct {
+		Monitor Monitor
+	}
+	mock.lockCreateMonitor.RLock()
+	calls = mock.calls.CreateMonitor
+	mock.lockCreateMonitor.RUnlock()
+	return calls
+}
+
+// DeleteMonitor calls DeleteMonitorFunc.
+func (mock *MonitorStoreMock) DeleteMonitor(id int64) error {
+	if mock.DeleteMonitorFunc == nil {
+		panic("MonitorStoreMock.DeleteMonitorFunc: method is nil but MonitorStore.DeleteMonitor was just called")
+	}
+	callInfo := struct {
+		ID int64
+	}{
+		ID: id,
+	}
+	mock.lockDeleteMonitor.Lock()
+	mock.calls.DeleteMonitor = append(mock.calls.DeleteMonitor, callInfo)
+	mock.lockDeleteMonitor.Unlock()
+	return mock.DeleteMonitorFunc(id)
+}
+
+// DeleteMonitorCalls gets all the calls that were made to DeleteMonitor.
+// Check the length with:
+//
+//	len(mockedMonitorStore.DeleteMonitorCalls())
+func (mock *MonitorStoreMock) DeleteMonitorCalls() []struct {
+	ID int64
+} {
+	var calls []struct {
+		ID int64
+	}
+	mock.lockDeleteMonitor.RLock()
+	calls = mock.calls.DeleteMonitor
+	mock.lockDeleteMonitor.RUnlock()
+	return calls
+}
+
+// GetMonitor calls GetMonitorFunc.
+func (mock *MonitorStoreMock) GetMonitor(id int64) (Monitor, error) {
+	if mock.GetMonitorFunc == nil {
+		panic("MonitorStoreMock.GetMonitorFunc: method is nil but MonitorStore.GetMonitor was just called")
+	}
+	callInfo := struct {
+		ID int64
+	}{
+		ID: id,
+	}
+	mock.lockGetMonitor.Lock()
+	mock.calls.GetMonitor = append(mock.calls.GetMonitor, callInfo)
+	mock.lockGetMonitor.Unlock()
+	return mock.GetMonitorFunc(id)
+}
+
+// GetMonitorCalls gets all the calls that were made to GetMonitor.
+// Check the length with:
+//
+//	len(mockedMonitorStore.GetMonitorCalls())
+func (mock *MonitorStoreMock) GetMonitorCalls() []struct {
+	ID int64
+} {
+	var calls []struct {
+		ID int64
+	}
+	mock.lockGetMonitor.RLock()
+	calls = mock.calls.GetMonitor
+	mock.lockGetMonitor.RUnlock()
+	return calls
+}
+
+// ListMonitorRevisions calls ListMonitorRevisionsFunc.
+func (mock *MonitorStoreMock) ListMonitorRevisions(id int64) ([]MonitorRevision, error) {
+	if mock.ListMonitorRevisionsFunc == nil {
+		panic("MonitorStoreMock.ListMonitorRevisionsFunc: method is nil but MonitorStore.ListMonitorRevisions was just called")
+	}
+	callInfo := struct {
+		ID int64
+	}{
+		ID: id,
+	}
+	mock.lockListMonitorRevisions.Lock()
+	mock.calls.ListMonitorRevisions = append(mock.calls.ListMonitorRevisions, callInfo)
+	mock.lockListMonitorRevisions.Unlock()
+	return mock.ListMonitorRevisionsFunc(id)
+}
+
+// ListMonitorRevisionsCalls gets all the calls that were made to ListMonitorRevisions.
+// Check the length with:
+//
+//	len(mockedMonitorStore.ListMonitorRevisionsCalls())
+func (mock *MonitorStoreMock) ListMonitorRevisionsCalls() []struct {
+	ID int64
+} {
+	var calls []struct {
+		ID int64
+	}
+	mock.lockListMonitorRevisions.RLock()
+	calls = mock.calls.ListMonitorRevisions
+	mock.lockListMonitorRevisions.RUnlock()
+	return calls
+}
+
+// ListMonitorValues calls ListMonitorValuesFunc.
+func (mock *MonitorStoreMock) ListMonitorValues(id int64, from time.Time, to time.Time, limit int) ([]MonitorValue, error) {
+	if mock.ListMonitorValuesFunc == nil {
+		panic("MonitorStoreMock.ListMonitorValuesFunc: method is nil but MonitorStore.ListMonitorValues was just called")
+	}
+	callInfo := struct {
+		ID    int64
+		From  time.Time
+		To    time.Time
+		Limit int
+	}{
+		ID:    id,
+		From:  from,
+		To:    to,
+		Limit: limit,
+	}
+	mock.lockListMonitorValues.Lock()
+	mock.calls.ListMonitorValues = append(mock.calls.ListMonitorValues, callInfo)
+	mock.lockListMonitorValues.Unlock()
+	return mock.ListMonitorValuesFunc(id, from, to, limit)
+}
+
+// ListMonitorValuesCalls gets all the calls that were made to ListMonitorValues.
+// Check the length with:
+//
+//	len(mockedMonitorStore.ListMonitorValuesCalls())
+func (mock *MonitorStoreMock) ListMonitorValuesCalls() []struct {
+	ID    int64
+	From  time.Time
+	To    time.Time
+	Limit int
+} {
+	var calls []struct {
+		ID    int64
+		From  time.Time
+		To    time.Time
+		Limit int
+	}
+	mock.lockListMonitorValues.RLock()
+	calls = mock.calls.ListMonitorValues
+	mock.lockListMonitorValues.RUnlock()
+	return calls
+}
+
+// ListMonitors calls ListMonitorsFunc.
+func (mock *MonitorStoreMock) ListMonitors() ([]Monitor, error) {
+	if mock.ListMonitorsFunc == nil {
+		panic("MonitorStoreMock.ListMonitorsFunc: method is nil but MonitorStore.ListMonitors was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockListMonitors.Lock()
+	mock.calls.ListMonitors = append(mock.calls.ListMonitors, callInfo)
+	mock.lockListMonitors.Unlock()
+	return mock.ListMonitorsFunc()
+}
+
+// ListMonitorsCalls gets all the calls that were made to ListMonitors.
+// Check the length with:
+//
+//	len(mockedMonitorStore.ListMonitorsCalls())
+func (mock *MonitorStoreMock) ListMonitorsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockListMonitors.RLock()
+	calls = mock.calls.ListMonitors
+	mock.lockListMonitors.RUnlock()
+	return calls
+}
+
+// UpdateMonitor calls UpdateMonitorFunc.
+func (mock *MonitorStoreMock) UpdateMonitor(monitor Monitor) (Monitor, error) {
+	if mock.UpdateMonitorFunc == nil {
+		panic("MonitorStoreMock.UpdateMonitorFunc: method is nil but MonitorStore.UpdateMonitor was just called")
+	}
+	callInfo := struct {
+		Monitor Monitor
+	}{
+		Monitor: monitor,
+	}
+	mock.lockUpdateMonitor.Lock()
+	mock.calls.UpdateMonitor = append(mock.calls.UpdateMonitor, callInfo)
+	mock.lockUpdateMonitor.Unlock()
+	return mock.UpdateMonitorFunc(monitor)
+}
+
+// UpdateMonitorCalls gets all the calls that were made to UpdateMonitor.
+// Check the length with:
+//
+//	len(mockedMonitorStore.UpdateMonitorCalls())
+func (mock *MonitorStoreMock) UpdateMonitorCalls() []struct {
+	Monitor Monitor
+} {
+	var calls []struct {
+		Monitor Monitor
+	}
+	mock.lockUpdateMonitor.RLock()
+	calls = mock.calls.UpdateMonitor
+	mock.lockUpdateMonitor.RUnlock()
+	return calls
+}
@@ -0,0 +1,118 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package db
+
+import (
+	"sync"
+	"time"
+)
+
+// Ensure, that PrunerMock does implement Pruner.
+// If this is not the case, regenerate this file with moq.
+var _ Pruner = &PrunerMock{}
+
+// PrunerMock is a mock implementation of Pruner.
+//
+//	func TestSomethingThatUsesPruner(t *testing.T) {
+//
+//		// make and configure a mocked Pruner
+//		mockedPruner := &PrunerMock{
+//			PruneFunc: func(policy RetentionPolicy, now time.Time) (PruneResult, error) {
+//				panic("mock out the Prune method")
+//			},
+//			VacuumFunc: func() error {
+//				panic("mock out the Vacuum method")
+//			},
+//		}
+//
+//		// use mockedPruner in code that requires Pruner
+//		// and then make assertions.
+//
+//	}
+type PrunerMock struct {
+	// PruneFunc mocks the Prune method.
+	PruneFunc func(policy RetentionPolicy, now time.Time) (PruneResult, error)
+
+	// VacuumFunc mocks the Vacuum method.
+	VacuumFunc func() error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Prune holds details about calls to the Prune method.
+		Prune []struct {
+			// Policy is the policy argument value.
+			Policy RetentionPolicy
+			// Now is the now argument value.
+			Now time.Time
+		}
+		// Vacuum holds details about calls to the Vacuum method.
+		Vacuum []struct {
+		}
+	}
+	lockPrune  sync.RWMutex
+	lockVacuum sync.RWMutex
+}
+
+// Prune calls PruneFunc.
+func (mock *PrunerMock) Prune(policy RetentionPolicy, now time.Time) (PruneResult, error) {
+	if mock.PruneFunc == nil {
+		panic("PrunerMock.PruneFunc: method is nil but Pruner.Prune was just called")
+	}
+	callInfo := struct {
+		Policy RetentionPolicy
+		Now    time.Time
+	}{
+		Policy: policy,
+		Now:    now,
+	}
+	mock.lockPrune.Lock()
+	mock.calls.Prune = append(mock.calls.Prune, callInfo)
+	mock.lockPrune.Unlock()
+	return mock.PruneFunc(policy, now)
+}
+
+// PruneCalls gets all the calls that were made to Prune.
+// Check the length with:
+//
+//	len(mockedPruner.PruneCalls())
+func (mock *PrunerMock) PruneCalls() []struct {
+	Policy RetentionPolicy
+	Now    time.Time
+} {
+	var calls []struct {
+		Policy RetentionPolicy
+		Now    time.Time
+	}
+	mock.lockPrune.RLock()
+	calls = mock.calls.Prune
+	mock.lockPrune.RUnlock()
+	return calls
+}
+
+// Vacuum calls VacuumFunc.
+func (mock *PrunerMock) Vacuum() error {
+	if mock.VacuumFunc == nil {
+		panic("PrunerMock.VacuumFunc: method is nil but Pruner.Vacuum was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockVacuum.Lock()
+	mock.calls.Vacuum = append(mock.calls.Vacuum, callInfo)
+	mock.lockVacuum.Unlock()
+	return mock.VacuumFunc()
+}
+
+// VacuumCalls gets all the calls that were made to Vacuum.
+// Check the length with:
+//
+//	len(mockedPruner.VacuumCalls())
+func (mock *PrunerMock) VacuumCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockVacuum.RLock()
+	calls = mock.calls.Vacuum
+	mock.lockVacuum.RUnlock()
+	return calls
+}
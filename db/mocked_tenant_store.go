@@ -0,0 +1,243 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package db
+
+import (
+	"sync"
+)
+
+// Ensure, that TenantStoreMock does implement TenantStore.
+// If this is not the case, regenerate this file with moq.
+var _ TenantStore = &TenantStoreMock{}
+
+// TenantStoreMock is a mock implementation of TenantStore.
+//
+//	func TestSomethingThatUsesTenantStore(t *testing.T) {
+//
+//		// make and configure a mocked TenantStore
+//		mockedTenantStore := &TenantStoreMock{
+//			CreateTenantFunc: func(tenant Tenant) (Tenant, error) {
+//				panic("mock out the CreateTenant method")
+//			},
+//			GetTenantFunc: func(id int64) (Tenant, error) {
+//				panic("mock out the GetTenant method")
+//			},
+//			GetTenantUsageFunc: func(id int64) (TenantUsage, error) {
+//				panic("mock out the GetTenantUsage method")
+//			},
+//			ListTenantsFunc: func() ([]Tenant, error) {
+//				panic("mock out the ListTenants method")
+//			},
+//			UpdateTenantFunc: func(tenant Tenant) (Tenant, error) {
+//				panic("mock out the UpdateTenant method")
+//			},
+//		}
+//
+//		// use mockedTenantStore in code that requires TenantStore
+//		// and then make assertions.
+//
+//	}
+type TenantStoreMock struct {
+	// CreateTenantFunc mocks the CreateTenant method.
+	CreateTenantFunc func(tenant Tenant) (Tenant, error)
+
+	// GetTenantFunc mocks the GetTenant method.
+	GetTenantFunc func(id int64) (Tenant, error)
+
+	// GetTenantUsageFunc mocks the GetTenantUsage method.
+	GetTenantUsageFunc func(id int64) (TenantUsage, error)
+
+	// ListTenantsFunc mocks the ListTenants method.
+	ListTenantsFunc func() ([]Tenant, error)
+
+	// UpdateTenantFunc mocks the UpdateTenant method.
+	UpdateTenantFunc func(tenant Tenant) (Tenant, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// CreateTenant holds details about calls to the CreateTenant method.
+		CreateTenant []struct {
+			// Tenant is the tenant argument value.
+			Tenant Tenant
+		}
+		// GetTenant holds details about calls to the GetTenant method.
+		GetTenant []struct {
+			// ID is the id argument value.
+			ID int64
+		}
+		// GetTenantUsage holds details about calls to the GetTenantUsage method.
+		GetTenantUsage []struct {
+			// ID is the id argument value.
+			ID int64
+		}
+		// ListTenants holds details about calls to the ListTenants method.
+		ListTenants []struct {
+		}
+		// UpdateTenant holds details about calls to the UpdateTenant method.
+		UpdateTenant []struct {
+			// Tenant is the tenant argument value.
+			Tenant Tenant
+		}
+	}
+	lockCreateTenant   sync.RWMutex
+	lockGetTenant      sync.RWMutex
+	lockGetTenantUsage sync.RWMutex
+	lockListTenants    sync.RWMutex
+	lockUpdateTenant   sync.RWMutex
+}
+
+// CreateTenant calls CreateTenantFunc.
+func (mock *TenantStoreMock) CreateTenant(tenant Tenant) (Tenant, error) {
+	if mock.CreateTenantFunc == nil {
+		panic("TenantStoreMock.CreateTenantFunc: method is nil but TenantStore.CreateTenant was just called")
+	}
+	callInfo := struct {
+		Tenant Tenant
+	}{
+		Tenant: tenant,
+	}
+	mock.lockCreateTenant.Lock()
+	mock.calls.CreateTenant = append(mock.calls.CreateTenant, callInfo)
+	mock.lockCreateTenant.Unlock()
+	return mock.CreateTenantFunc(tenant)
+}
+
+// CreateTenantCalls gets all the calls that were made to CreateTenant.
+// Check the length with:
+//
+//	len(mockedTenantStore.CreateTenantCalls())
+func (mock *TenantStoreMock) CreateTenantCalls() []struct {
+	Tenant Tenant
+} {
+	var calls []struct {
+		Tenant Tenant
+	}
+	mock.lockCreateTenant.RLock()
+	calls = mock.calls.CreateTenant
+	mock.lockCreateTenant.RUnlock()
+	return calls
+}
+
+// GetTenant calls GetTenantFunc.
+func (mock *TenantStoreMock) GetTenant(id int64) (Tenant, error) {
+	if mock.GetTenantFunc == nil {
+		panic("TenantStoreMock.GetTenantFunc: method is nil but TenantStore.GetTenant was just called")
+	}
+	callInfo := struct {
+		ID int64
+	}{
+		ID: id,
+	}
+	mock.lockGetTenant.Lock()
+	mock.calls.GetTenant = append(mock.calls.GetTenant, callInfo)
+	mock.lockGetTenant.Unlock()
+	return mock.GetTenantFunc(id)
+}
+
+// GetTenantCalls gets all the calls that were made to GetTenant.
+// Check the length with:
+//
+//	len(mockedTenantStore.GetTenantCalls())
+func (mock *TenantStoreMock) GetTenantCalls() []struct {
+	ID int64
+} {
+	var calls []struct {
+		ID int64
+	}
+	mock.lockGetTenant.RLock()
+	calls = mock.calls.GetTenant
+	mock.lockGetTenant.RUnlock()
+	return calls
+}
+
+// GetTenantUsage calls GetTenantUsageFunc.
+func (mock *TenantStoreMock) GetTenantUsage(id int64) (TenantUsage, error) {
+	if mock.GetTenantUsageFunc == nil {
+		panic("TenantStoreMock.GetTenantUsageFunc: method is nil but TenantStore.GetTenantUsage was just called")
+	}
+	callInfo := struct {
+		ID int64
+	}{
+		ID: id,
+	}
+	mock.lockGetTenantUsage.Lock()
+	mock.calls.GetTenantUsage = append(mock.calls.GetTenantUsage, callInfo)
+	mock.lockGetTenantUsage.Unlock()
+	return mock.GetTenantUsageFunc(id)
+}
+
+// GetTenantUsageCalls gets all the calls that were made to GetTenantUsage.
+// Check the length with:
+//
+//	len(mockedTenantStore.GetTenantUsageCalls())
+func (mock *TenantStoreMock) GetTenantUsageCalls() []struct {
+	ID int64
+} {
+	var calls []struct {
+		ID int64
+	}
+	mock.lockGetTenantUsage.RLock()
+	calls = mock.calls.GetTenantUsage
+	mock.lockGetTenantUsage.RUnlock()
+	return calls
+}
+
+// ListTenants calls ListTenantsFunc.
+func (mock *TenantStoreMock) ListTenants() ([]Tenant, error) {
+	if mock.ListTenantsFunc == nil {
+		panic("TenantStoreMock.ListTenantsFunc: method is nil but TenantStore.ListTenants was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockListTenants.Lock()
+	mock.calls.ListTenants = append(mock.calls.ListTenants, callInfo)
+	mock.lockListTenants.Unlock()
+	return mock.ListTenantsFunc()
+}
+
+// ListTenantsCalls gets all the calls that were made to ListTenants.
+// Check the length with:
+//
+//	len(mockedTenantStore.ListTenantsCalls())
+func (mock *TenantStoreMock) ListTenantsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockListTenants.RLock()
+	calls = mock.calls.ListTenants
+	mock.lockListTenants.RUnlock()
+	return calls
+}
+
+// UpdateTenant calls UpdateTenantFunc.
+func (mock *TenantStoreMock) UpdateTenant(tenant Tenant) (Tenant, error) {
+	if mock.UpdateTenantFunc == nil {
+		panic("TenantStoreMock.UpdateTenantFunc: method is nil but TenantStore.UpdateTenant was just called")
+	}
+	callInfo := struct {
+		Tenant Tenant
+	}{
+		Tenant: tenant,
+	}
+	mock.lockUpdateTenant.Lock()
+	mock.calls.UpdateTenant = append(mock.calls.UpdateTenant, callInfo)
+	mock.lockUpdateTenant.Unlock()
+	return mock.UpdateTenantFunc(tenant)
+}
+
+// UpdateTenantCalls gets all the calls that were made to UpdateTenant.
+// Check the length with:
+//
+//	len(mockedTenantStore.UpdateTenantCalls())
+func (mock *TenantStoreMock) UpdateTenantCalls() []struct {
+	Tenant Tenant
+} {
+	var calls []struct {
+		Tenant Tenant
+	}
+	mock.lockUpdateTenant.RLock()
+	calls = mock.calls.UpdateTenant
+	mock.lockUpdateTenant.RUnlock()
+	return calls
+}
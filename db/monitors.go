@@ -0,0 +1,207 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const monitorColumns = "id, url, pattern, interval_seconds, dedup, revision, created_by_key_id, tenant_id, selector, xpath, csv_column, value_path, condition, rule, steps"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMonitor(row rowScanner, extra ...any) (Monitor, error) {
+	var monitor Monitor
+	var intervalSeconds int64
+	var createdBy sql.NullInt64
+	dest := append([]any{&monitor.ID, &monitor.URL, &monitor.Pattern, &intervalSeconds, &monitor.Dedup, &monitor.Revision, &createdBy, &monitor.TenantID, &monitor.Selector, &monitor.XPath, &monitor.CSVColumn, &monitor.ValuePath, &monitor.Condition, &monitor.Rule, &monitor.Steps}, extra...)
+	err := row.Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return monitor, ErrNotFound
+	}
+	monitor.Interval = time.Duration(intervalSeconds) * time.Second
+	monitor.CreatedBy = createdBy.Int64
+	return monitor, err
+}
+
+func saveRevision(e execer, monitor Monitor, changedAt time.Time) error {
+	query := `
+    INSERT INTO monitor_revisions (monitor_id, revision, url, pattern, interval_seconds, dedup, selector, xpath, csv_column, value_path, condition, rule, steps, changed_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := e.Exec(query, monitor.ID, monitor.Revision, monitor.URL, monitor.Pattern,
+		int64(monitor.Interval/time.Second), monitor.Dedup, monitor.Selector, monitor.XPath, monitor.CSVColumn, monitor.ValuePath,
+		monitor.Condition, monitor.Rule, monitor.Steps, changedAt)
+	return err
+}
+
+func (db *SQLiteDB) CreateMonitor(monitor Monitor) (Monitor, error) {
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return monitor, err
+	}
+	defer tx.Rollback()
+	if monitor, err = createMonitor(tx, monitor, time.Now().UTC()); err != nil {
+		return monitor, err
+	}
+	return monitor, tx.Commit()
+}
+
+func createMonitor(tx *sql.Tx, monitor Monitor, now time.Time) (Monitor, error) {
+	if monitor.TenantID == 0 {
+		monitor.TenantID = DefaultTenantID
+	}
+	query := `
+    INSERT INTO monitors (url, pattern, interval_seconds, dedup, revision, created_at, created_by_key_id, tenant_id, selector, xpath, csv_column, value_path, condition, rule, steps)
+    VALUES (?, ?, ?, ?, 1, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := tx.Exec(query, monitor.URL, monitor.Pattern, int64(monitor.Interval/time.Second), monitor.Dedup, now,
+		nullableID(monitor.CreatedBy), monitor.TenantID, monitor.Selector, monitor.XPath, monitor.CSVColumn, monitor.ValuePath, monitor.Condition,
+		monitor.Rule, monitor.Steps)
+	if err != nil {
+		return monitor, err
+	}
+	if monitor.ID, err = res.LastInsertId(); err != nil {
+		return monitor, err
+	}
+	monitor.Revision = 1
+	return monitor, saveRevision(tx, monitor, now)
+}
+
+// UpdateMonitor replaces the monitor's configuration and records it as a new revision.
+func (db *SQLiteDB) UpdateMonitor(monitor Monitor) (Monitor, error) {
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return monitor, err
+	}
+	defer tx.Rollback()
+	if monitor, err = updateMonitor(tx, monitor, time.Now().UTC()); err != nil {
+		return monitor, err
+	}
+	return monitor, tx.Commit()
+}
+
+func updateMonitor(tx *sql.Tx, monitor Monitor, now time.Time) (Monitor, error) {
+	query := `
+    UPDATE monitors SET url = ?, pattern = ?, interval_seconds = ?, dedup = ?, selector = ?, xpath = ?, csv_column = ?, value_path = ?, condition = ?,
+        rule = ?, steps = ?, revision = revision + 1
+    WHERE id = ? RETURNING revision, created_by_key_id, tenant_id`
+	var createdBy sql.NullInt64
+	err := tx.QueryRow(query, monitor.URL, monitor.Pattern, int64(monitor.Interval/time.Second), monitor.Dedup, monitor.Selector,
+		monitor.XPath, monitor.CSVColumn, monitor.ValuePath, monitor.Condition, monitor.Rule, monitor.Steps, monitor.ID).
+		Scan(&monitor.Revision, &createdBy, &monitor.TenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return monitor, ErrNotFound
+	}
+	if err != nil {
+		return monitor, err
+	}
+	monitor.CreatedBy = createdBy.Int64
+	return monitor, saveRevision(tx, monitor, now)
+}
+
+// DeleteMonitor deletes the monitor along with its revisions.
+func (db *SQLiteDB) DeleteMonitor(id int64) error {
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err = deleteMonitor(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func deleteMonitor(tx *sql.Tx, id int64) error {
+	res, err := tx.Exec("DELETE FROM monitors WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if deleted, _ := res.RowsAffected(); deleted == 0 {
+		return ErrNotFound
+	}
+	_, err = tx.Exec("DELETE FROM monitor_revisions WHERE monitor_id = ?", id)
+	return err
+}
+
+// ApplyMonitorChanges stores the creates, updates and deletes in one transaction, so either all of them
+// take effect or none does. It returns the created and updated monitors as stored.
+func (db *SQLiteDB) ApplyMonitorChanges(changes MonitorChanges) (MonitorChanges, error) {
+	applied := MonitorChanges{Delete: changes.Delete}
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return applied, err
+	}
+	defer tx.Rollback()
+	now := time.Now().UTC()
+	for _, monitor := range changes.Create {
+		if monitor, err = createMonitor(tx, monitor, now); err != nil {
+			return applied, err
+		}
+		applied.Create = append(applied.Create, monitor)
+	}
+	for _, monitor := range changes.Update {
+		if monitor, err = updateMonitor(tx, monitor, now); err != nil {
+			return applied, err
+		}
+		applied.Update = append(applied.Update, monitor)
+	}
+	for _, id := range changes.Delete {
+		if err = deleteMonitor(tx, id); err != nil {
+			return applied, err
+		}
+	}
+	return applied, tx.Commit()
+}
+
+func (db *SQLiteDB) GetMonitor(id int64) (Monitor, error) {
+	return scanMonitor(db.Conn.QueryRow("SELECT "+monitorColumns+" FROM monitors WHERE id = ?", id))
+}
+
+func (db *SQLiteDB) ListMonitors() ([]Monitor, error) {
+	rows, err := db.Conn.Query("SELECT " + monitorColumns + " FROM monitors ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var monitors []Monitor
+	for rows.Next() {
+		monitor, scanErr := scanMonitor(rows)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		monitors = append(monitors, monitor)
+	}
+	return monitors, rows.Err()
+}
+
+func (db *SQLiteDB) ListMonitorRevisions(id int64) ([]MonitorRevision, error) {
+	query := `
+    SELECT r.monitor_id, r.url, r.pattern, r.interval_seconds, r.dedup, r.revision, m.created_by_key_id, m.tenant_id, r.selector, r.xpath, r.csv_column,
+           r.value_path, r.condition, r.rule, r.steps, r.changed_at
+    FROM monitor_revisions r JOIN monitors m ON m.id = r.monitor_id
+    WHERE r.monitor_id = ? ORDER BY r.revision`
+	rows, err := db.Conn.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var revisions []MonitorRevision
+	for rows.Next() {
+		var revision MonitorRevision
+		monitor, scanErr := scanMonitor(rows, &revision.ChangedAt)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		revision.Monitor = monitor
+		revisions = append(revisions, revision)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, ErrNotFound
+	}
+	return revisions, nil
+}
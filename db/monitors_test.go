@@ -0,0 +1,152 @@
+package db_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"os"
+	. "snapp-task/db"
+	"time"
+)
+
+var _ = Describe("Monitors", func() {
+	var (
+		db             *SQLiteDB
+		dataSourceName string
+		monitor        Monitor
+	)
+
+	BeforeEach(func() {
+		file, err := os.CreateTemp("", "testdb_*.db")
+		Expect(err).NotTo(HaveOccurred())
+		dataSourceName = file.Name()
+
+		db, err = NewSQLiteDB(dataSourceName)
+		Expect(err).NotTo(HaveOccurred())
+		monitor = Monitor{URL: "http://example.com", Pattern: "test", Interval: 5 * time.Second, Dedup: true, Selector: "div.price", XPath: "//item/title", CSVColumn: "status",
+			ValuePath: "queue.depth", Condition: "> 100", Rule: "status == 200", Steps: Steps{
+				{Name: "login", Method: "POST", URL: "http://example.com/login", Body: `{"user": "a"}`, Extract: map[string]string{"token": "token"}},
+				{URL: "http://example.com/data", Headers: map[string]string{"Authorization": "Bearer {{token}}"}, Status: 200},
+			}}
+	})
+
+	AfterEach(func() {
+		Expect(db.Close()).To(Succeed())
+		Expect(os.Remove(dataSourceName)).To(Succeed())
+	})
+
+	It("should create and read back a monitor", func() {
+		created, err := db.CreateMonitor(monitor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created.ID).NotTo(BeZero())
+
+		fetched, err := db.GetMonitor(created.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched).To(Equal(created))
+	})
+
+	It("should list monitors in creation order", func() {
+		first, err := db.CreateMonitor(monitor)
+		Expect(err).NotTo(HaveOccurred())
+		monitor.URL = "http://example.org"
+		second, err := db.CreateMonitor(monitor)
+		Expect(err).NotTo(HaveOccurred())
+
+		monitors, err := db.ListMonitors()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitors).To(Equal([]Monitor{first, second}))
+	})
+
+	It("should update a monitor", func() {
+		created, err := db.CreateMonitor(monitor)
+		Expect(err).NotTo(HaveOccurred())
+		created.Interval = time.Minute
+		created.Dedup = false
+		created.Selector = "span#total"
+		created.Condition = "change > 5%"
+		created.Steps = nil
+		updated, err := db.UpdateMonitor(created)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Revision).To(Equal(2))
+
+		fetched, err := db.GetMonitor(created.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched).To(Equal(updated))
+	})
+
+	It("should record every revision of a monitor", func() {
+		created, err := db.CreateMonitor(monitor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created.Revision).To(Equal(1))
+		created.Pattern = "changed"
+		updated, err := db.UpdateMonitor(created)
+		Expect(err).NotTo(HaveOccurred())
+
+		revisions, err := db.ListMonitorRevisions(created.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(revisions).To(HaveLen(2))
+		Expect(revisions[0].Monitor.Pattern).To(Equal("test"))
+		Expect(revisions[0].Revision).To(Equal(1))
+		Expect(revisions[1].Monitor).To(Equal(updated))
+		Expect(revisions[1].ChangedAt).NotTo(BeZero())
+	})
+
+	It("should keep match history under the monitor id across updates", func() {
+		created, err := db.CreateMonitor(monitor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db.SaveData(Match{MonitorID: created.ID, URL: created.URL, Pattern: created.Pattern, Data: "a"})).To(Succeed())
+		created.Pattern = "changed"
+		_, err = db.UpdateMonitor(created)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db.SaveData(Match{MonitorID: created.ID, URL: created.URL, Pattern: created.Pattern, Data: "b"})).To(Succeed())
+
+		var count int
+		Expect(db.Conn.QueryRow("SELECT count(*) FROM matches WHERE monitor_id = ?", created.ID).Scan(&count)).To(Succeed())
+		Expect(count).To(Equal(2))
+	})
+
+	It("should delete a monitor", func() {
+		created, err := db.CreateMonitor(monitor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db.DeleteMonitor(created.ID)).To(Succeed())
+
+		_, err = db.GetMonitor(created.ID)
+		Expect(err).To(MatchError(ErrNotFound))
+		_, err = db.ListMonitorRevisions(created.ID)
+		Expect(err).To(MatchError(ErrNotFound))
+		var count int
+		Expect(db.Conn.QueryRow("SELECT count(*) FROM monitor_revisions WHERE monitor_id = ?", created.ID).Scan(&count)).To(Succeed())
+		Expect(count).To(BeZero())
+	})
+
+	It("should apply monitor changes together or not at all", func() {
+		first, err := db.CreateMonitor(monitor)
+		Expect(err).NotTo(HaveOccurred())
+		second, err := db.CreateMonitor(monitor)
+		Expect(err).NotTo(HaveOccurred())
+
+		first.Interval = time.Minute
+		_, err = db.ApplyMonitorChanges(MonitorChanges{Create: []Monitor{monitor}, Update: []Monitor{first}, Delete: []int64{second.ID, 42}})
+		Expect(err).To(MatchError(ErrNotFound))
+		monitors, err := db.ListMonitors()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitors).To(HaveLen(2))
+		Expect(monitors[0].Interval).To(Equal(5 * time.Second))
+
+		applied, err := db.ApplyMonitorChanges(MonitorChanges{Create: []Monitor{monitor}, Update: []Monitor{first}, Delete: []int64{second.ID}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied.Create[0].ID).NotTo(BeZero())
+		Expect(applied.Update[0].Revision).To(Equal(2))
+		monitors, err = db.ListMonitors()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitors).To(HaveLen(2))
+		Expect(monitors[0].Interval).To(Equal(time.Minute))
+		Expect(monitors[1].ID).To(Equal(applied.Create[0].ID))
+	})
+
+	It("should return ErrNotFound for unknown monitors", func() {
+		monitor.ID = 42
+		_, err := db.UpdateMonitor(monitor)
+		Expect(err).To(MatchError(ErrNotFound))
+		Expect(db.DeleteMonitor(42)).To(MatchError(ErrNotFound))
+	})
+})
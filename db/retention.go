@@ -0,0 +1,134 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RetentionPolicy describes how much history is kept. Zero values disable the corresponding rule.
+type RetentionPolicy struct {
+	MaxAge            time.Duration
+	MaxRowsPerMonitor int
+	DownsampleAfter   time.Duration
+	AggregateMaxAge   time.Duration
+}
+
+type PruneResult struct {
+	MatchesDeleted    int64
+	CheckRunsDeleted  int64
+	CheckRunsRolledUp int64
+	AggregatesDeleted int64
+	ValuesDeleted     int64
+}
+
+func (db *SQLiteDB) Prune(policy RetentionPolicy, now time.Time) (PruneResult, error) {
+	var result PruneResult
+	now = now.UTC()
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	if policy.DownsampleAfter > 0 {
+		if result.CheckRunsRolledUp, err = downsampleCheckRuns(tx, now.Add(-policy.DownsampleAfter)); err != nil {
+			return result, err
+		}
+	}
+	if policy.MaxAge > 0 {
+		cutoff := now.Add(-policy.MaxAge)
+		if result.MatchesDeleted, err = execCount(tx, "DELETE FROM matches WHERE last_seen_at < ?", cutoff); err != nil {
+			return result, err
+		}
+		if result.CheckRunsDeleted, err = execCount(tx, "DELETE FROM check_runs WHERE checked_at < ?", cutoff); err != nil {
+			return result, err
+		}
+		if result.ValuesDeleted, err = execCount(tx, "DELETE FROM monitor_values WHERE recorded_at < ?", cutoff); err != nil {
+			return result, err
+		}
+	}
+	if policy.MaxRowsPerMonitor > 0 {
+		// Matches saved before they recorded their monitor are grouped by URL and pattern instead.
+		query := `
+        DELETE FROM matches WHERE id IN (
+            SELECT id FROM (
+                SELECT id, ROW_NUMBER() OVER (
+                    PARTITION BY monitor_id,
+                        CASE WHEN monitor_id IS NULL THEN url END,
+                        CASE WHEN monitor_id IS NULL THEN pattern END
+                    ORDER BY id DESC
+                ) AS rank FROM matches
+            ) WHERE rank > ?
+        )`
+		deleted, countErr := execCount(tx, query, policy.MaxRowsPerMonitor)
+		if countErr != nil {
+			return result, countErr
+		}
+		result.MatchesDeleted += deleted
+	}
+	// Tenant storage quotas always apply: a tenant over its quota loses its oldest matches first.
+	quotaQuery := `
+    DELETE FROM matches WHERE id IN (
+        SELECT id FROM (
+            SELECT m.id, t.max_storage_bytes AS quota,
+                   sum(length(CAST(m.data AS BLOB))) OVER (PARTITION BY m.tenant_id ORDER BY m.id DESC) AS used
+            FROM matches m JOIN tenants t ON t.id = m.tenant_id
+            WHERE t.max_storage_bytes > 0
+        ) WHERE used > quota
+    )`
+	deleted, err := execCount(tx, quotaQuery)
+	if err != nil {
+		return result, err
+	}
+	result.MatchesDeleted += deleted
+	if policy.AggregateMaxAge > 0 {
+		cutoff := now.Add(-policy.AggregateMaxAge).Format(hourFormat)
+		if result.AggregatesDeleted, err = execCount(tx, "DELETE FROM check_runs_hourly WHERE hour < ?", cutoff); err != nil {
+			return result, err
+		}
+	}
+	return result, tx.Commit()
+}
+
+const hourFormat = "2006-01-02 15:00:00"
+
+// downsampleCheckRuns folds raw check runs older than cutoff into hourly aggregates and removes them.
+func downsampleCheckRuns(tx *sql.Tx, cutoff time.Time) (int64, error) {
+	rollupQuery := `
+    INSERT INTO check_runs_hourly (url, pattern, hour, total, failures, total_duration_ms, max_duration_ms)
+    SELECT url, pattern, strftime('%Y-%m-%d %H:00:00', checked_at), count(*),
+           sum(CASE WHEN error != '' THEN 1 ELSE 0 END), sum(duration_ms), max(duration_ms)
+    FROM check_runs WHERE checked_at < ?
+    GROUP BY 1, 2, 3
+    ON CONFLICT (url, pattern, hour) DO UPDATE SET
+        total = total + excluded.total,
+        failures = failures + excluded.failures,
+        total_duration_ms = total_duration_ms + excluded.total_duration_ms,
+        max_duration_ms = max(max_duration_ms, excluded.max_duration_ms)`
+	if _, err := tx.Exec(rollupQuery, cutoff); err != nil {
+		return 0, err
+	}
+	return execCount(tx, "DELETE FROM check_runs WHERE checked_at < ?", cutoff)
+}
+
+func execCount(tx *sql.Tx, query string, args ...any) (int64, error) {
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Vacuum reclaims free pages, incrementally when the database was created with auto_vacuum=incremental.
+func (db *SQLiteDB) Vacuum() error {
+	var mode int
+	if err := db.Conn.QueryRow("PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return err
+	}
+	if mode == 2 {
+		_, err := db.Conn.Exec("PRAGMA incremental_vacuum")
+		return err
+	}
+	_, err := db.Conn.Exec("VACUUM")
+	return err
+}
@@ -0,0 +1,147 @@
+package db_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"os"
+	. "snapp-task/db"
+	"time"
+)
+
+var _ = Describe("Retention", func() {
+	var (
+		db             *SQLiteDB
+		dataSourceName string
+		now            time.Time
+	)
+
+	insertMatch := func(url string, createdAt time.Time) {
+		_, err := db.Conn.Exec("INSERT INTO matches (url, pattern, data, created_at, last_seen_at) VALUES (?, 'p', 'd', ?, ?)",
+			url, createdAt.UTC(), createdAt.UTC())
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	insertMonitorMatch := func(monitorID int64, url string, createdAt time.Time) {
+		_, err := db.Conn.Exec("INSERT INTO matches (monitor_id, url, pattern, data, created_at, last_seen_at) VALUES (?, ?, 'p', 'd', ?, ?)",
+			monitorID, url, createdAt.UTC(), createdAt.UTC())
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	count := func(query string) int {
+		var n int
+		Expect(db.Conn.QueryRow(query).Scan(&n)).To(Succeed())
+		return n
+	}
+
+	BeforeEach(func() {
+		file, err := os.CreateTemp("", "testdb_*.db")
+		Expect(err).NotTo(HaveOccurred())
+		dataSourceName = file.Name()
+
+		db, err = NewSQLiteDB(dataSourceName)
+		Expect(err).NotTo(HaveOccurred())
+		now = time.Date(2024, 9, 1, 12, 30, 0, 0, time.UTC)
+	})
+
+	AfterEach(func() {
+		Expect(db.Close()).To(Succeed())
+		Expect(os.Remove(dataSourceName)).To(Succeed())
+	})
+
+	Describe("Prune", func() {
+		It("should delete matches and check runs older than MaxAge", func() {
+			insertMatch("http://old.com", now.Add(-48*time.Hour))
+			insertMatch("http://new.com", now.Add(-time.Hour))
+			Expect(db.SaveCheckRun(CheckRun{URL: "u", Pattern: "p", CheckedAt: now.Add(-48 * time.Hour)})).To(Succeed())
+			Expect(db.SaveCheckRun(CheckRun{URL: "u", Pattern: "p", CheckedAt: now.Add(-time.Hour)})).To(Succeed())
+
+			result, err := db.Prune(RetentionPolicy{MaxAge: 24 * time.Hour}, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.MatchesDeleted).To(Equal(int64(1)))
+			Expect(result.CheckRunsDeleted).To(Equal(int64(1)))
+			Expect(count("SELECT count(*) FROM matches WHERE url = 'http://new.com'")).To(Equal(1))
+			Expect(count("SELECT count(*) FROM matches")).To(Equal(1))
+		})
+
+		It("should keep only the newest rows per monitor", func() {
+			for i := 0; i < 5; i++ {
+				insertMatch("http://a.com", now)
+			}
+			insertMatch("http://b.com", now)
+
+			result, err := db.Prune(RetentionPolicy{MaxRowsPerMonitor: 2}, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.MatchesDeleted).To(Equal(int64(3)))
+			Expect(count("SELECT count(*) FROM matches WHERE url = 'http://a.com'")).To(Equal(2))
+			Expect(count("SELECT count(*) FROM matches WHERE url = 'http://b.com'")).To(Equal(1))
+		})
+
+		It("should count the rows of monitors on the same URL and pattern apart", func() {
+			for i := 0; i < 3; i++ {
+				insertMonitorMatch(1, "http://a.com", now)
+				insertMonitorMatch(2, "http://a.com", now)
+			}
+
+			result, err := db.Prune(RetentionPolicy{MaxRowsPerMonitor: 2}, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.MatchesDeleted).To(Equal(int64(2)))
+			Expect(count("SELECT count(*) FROM matches WHERE monitor_id = 1")).To(Equal(2))
+			Expect(count("SELECT count(*) FROM matches WHERE monitor_id = 2")).To(Equal(2))
+		})
+
+		It("should roll old check runs up into hourly aggregates", func() {
+			hour := time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+			Expect(db.SaveCheckRun(CheckRun{URL: "u", Pattern: "p", CheckedAt: hour.Add(time.Minute), Duration: 100 * time.Millisecond})).To(Succeed())
+			Expect(db.SaveCheckRun(CheckRun{URL: "u", Pattern: "p", CheckedAt: hour.Add(2 * time.Minute), Duration: 300 * time.Millisecond, Error: "boom"})).To(Succeed())
+			Expect(db.SaveCheckRun(CheckRun{URL: "u", Pattern: "p", CheckedAt: now})).To(Succeed())
+
+			result, err := db.Prune(RetentionPolicy{DownsampleAfter: time.Hour}, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.CheckRunsRolledUp).To(Equal(int64(2)))
+			Expect(count("SELECT count(*) FROM check_runs")).To(Equal(1))
+
+			var total, failures, totalDuration, maxDuration int
+			var bucket time.Time
+			err = db.Conn.QueryRow("SELECT hour, total, failures, total_duration_ms, max_duration_ms FROM check_runs_hourly").
+				Scan(&bucket, &total, &failures, &totalDuration, &maxDuration)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bucket).To(BeTemporally("==", hour))
+			Expect(total).To(Equal(2))
+			Expect(failures).To(Equal(1))
+			Expect(totalDuration).To(Equal(400))
+			Expect(maxDuration).To(Equal(300))
+		})
+
+		It("should merge into an existing hourly aggregate", func() {
+			hour := time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+			Expect(db.SaveCheckRun(CheckRun{URL: "u", Pattern: "p", CheckedAt: hour.Add(time.Minute)})).To(Succeed())
+			_, err := db.Prune(RetentionPolicy{DownsampleAfter: time.Hour}, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(db.SaveCheckRun(CheckRun{URL: "u", Pattern: "p", CheckedAt: hour.Add(5 * time.Minute)})).To(Succeed())
+			_, err = db.Prune(RetentionPolicy{DownsampleAfter: time.Hour}, now)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(count("SELECT count(*) FROM check_runs_hourly")).To(Equal(1))
+			Expect(count("SELECT total FROM check_runs_hourly")).To(Equal(2))
+		})
+
+		It("should drop aggregates older than AggregateMaxAge", func() {
+			Expect(db.SaveCheckRun(CheckRun{URL: "u", Pattern: "p", CheckedAt: now.Add(-72 * time.Hour)})).To(Succeed())
+			_, err := db.Prune(RetentionPolicy{DownsampleAfter: time.Hour}, now)
+			Expect(err).NotTo(HaveOccurred())
+
+			result, err := db.Prune(RetentionPolicy{AggregateMaxAge: 48 * time.Hour}, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.AggregatesDeleted).To(Equal(int64(1)))
+		})
+	})
+
+	Describe("Vacuum", func() {
+		It("should run an incremental vacuum on new databases", func() {
+			var mode int
+			Expect(db.Conn.QueryRow("PRAGMA auto_vacuum").Scan(&mode)).To(Succeed())
+			Expect(mode).To(Equal(2))
+			Expect(db.Vacuum()).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const tenantColumns = "id, name, max_monitors, min_interval_seconds, max_storage_bytes"
+
+func scanTenant(row rowScanner) (Tenant, error) {
+	var tenant Tenant
+	var minIntervalSeconds int64
+	err := row.Scan(&tenant.ID, &tenant.Name, &tenant.MaxMonitors, &minIntervalSeconds, &tenant.MaxStorageBytes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return tenant, ErrNotFound
+	}
+	tenant.MinInterval = time.Duration(minIntervalSeconds) * time.Second
+	return tenant, err
+}
+
+func (db *SQLiteDB) CreateTenant(tenant Tenant) (Tenant, error) {
+	query := "INSERT INTO tenants (name, max_monitors, min_interval_seconds, max_storage_bytes, created_at) VALUES (?, ?, ?, ?, ?)"
+	res, err := db.Conn.Exec(query, tenant.Name, tenant.MaxMonitors, int64(tenant.MinInterval/time.Second), tenant.MaxStorageBytes, time.Now().UTC())
+	if err != nil {
+		return tenant, err
+	}
+	tenant.ID, err = res.LastInsertId()
+	return tenant, err
+}
+
+func (db *SQLiteDB) UpdateTenant(tenant Tenant) (Tenant, error) {
+	query := "UPDATE tenants SET name = ?, max_monitors = ?, min_interval_seconds = ?, max_storage_bytes = ? WHERE id = ?"
+	res, err := db.Conn.Exec(query, tenant.Name, tenant.MaxMonitors, int64(tenant.MinInterval/time.Second), tenant.MaxStorageBytes, tenant.ID)
+	if err != nil {
+		return tenant, err
+	}
+	if updated, _ := res.RowsAffected(); updated == 0 {
+		return tenant, ErrNotFound
+	}
+	return tenant, nil
+}
+
+func (db *SQLiteDB) GetTenant(id int64) (Tenant, error) {
+	return scanTenant(db.Conn.QueryRow("SELECT "+tenantColumns+" FROM tenants WHERE id = ?", id))
+}
+
+func (db *SQLiteDB) ListTenants() ([]Tenant, error) {
+	rows, err := db.Conn.Query("SELECT " + tenantColumns + " FROM tenants ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tenants []Tenant
+	for rows.Next() {
+		tenant, scanErr := scanTenant(rows)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, rows.Err()
+}
+
+// GetTenantUsage counts the tenant's monitors and the bytes of match data stored for it.
+func (db *SQLiteDB) GetTenantUsage(id int64) (TenantUsage, error) {
+	var usage TenantUsage
+	query := `
+    SELECT (SELECT count(*) FROM monitors WHERE tenant_id = ?),
+           (SELECT coalesce(sum(length(CAST(data AS BLOB))), 0) FROM matches WHERE tenant_id = ?)`
+	err := db.Conn.QueryRow(query, id, id).Scan(&usage.Monitors, &usage.StorageBytes)
+	return usage, err
+}
@@ -0,0 +1,89 @@
+package db_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"os"
+	. "snapp-task/db"
+	"strings"
+	"time"
+)
+
+var _ = Describe("Tenants", func() {
+	var (
+		db             *SQLiteDB
+		dataSourceName string
+	)
+
+	BeforeEach(func() {
+		file, err := os.CreateTemp("", "testdb_*.db")
+		Expect(err).NotTo(HaveOccurred())
+		dataSourceName = file.Name()
+
+		db, err = NewSQLiteDB(dataSourceName)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(db.Close()).To(Succeed())
+		Expect(os.Remove(dataSourceName)).To(Succeed())
+	})
+
+	It("should start with the default tenant", func() {
+		tenant, err := db.GetTenant(DefaultTenantID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tenant).To(Equal(Tenant{ID: DefaultTenantID, Name: "default"}))
+	})
+
+	It("should create, update and list tenants", func() {
+		created, err := db.CreateTenant(Tenant{Name: "acme", MaxMonitors: 3, MinInterval: time.Minute})
+		Expect(err).NotTo(HaveOccurred())
+		created.MaxStorageBytes = 1024
+		_, err = db.UpdateTenant(created)
+		Expect(err).NotTo(HaveOccurred())
+
+		tenants, err := db.ListTenants()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tenants).To(HaveLen(2))
+		Expect(tenants[1]).To(Equal(created))
+
+		_, err = db.UpdateTenant(Tenant{ID: 42, Name: "missing"})
+		Expect(err).To(MatchError(ErrNotFound))
+	})
+
+	It("should put monitors without a tenant in the default tenant", func() {
+		monitor, err := db.CreateMonitor(Monitor{URL: "http://example.com", Pattern: "a", Interval: time.Second})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitor.TenantID).To(Equal(DefaultTenantID))
+	})
+
+	It("should report the tenant's monitors and stored bytes", func() {
+		tenant, err := db.CreateTenant(Tenant{Name: "acme"})
+		Expect(err).NotTo(HaveOccurred())
+		monitor, err := db.CreateMonitor(Monitor{URL: "http://example.com", Pattern: "a", Interval: time.Second, TenantID: tenant.ID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db.SaveData(Match{MonitorID: monitor.ID, TenantID: tenant.ID, URL: monitor.URL, Pattern: "a", Data: "hello"})).To(Succeed())
+		Expect(db.SaveData(Match{URL: "http://other.com", Pattern: "a", Data: "ignored", TenantID: DefaultTenantID})).To(Succeed())
+
+		usage, err := db.GetTenantUsage(tenant.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(usage).To(Equal(TenantUsage{Monitors: 1, StorageBytes: 5}))
+	})
+
+	It("should drop the oldest matches of a tenant over its storage quota on Prune", func() {
+		tenant, err := db.CreateTenant(Tenant{Name: "acme", MaxStorageBytes: 25})
+		Expect(err).NotTo(HaveOccurred())
+		for _, data := range []string{"first", "second", "third"} {
+			Expect(db.SaveData(Match{TenantID: tenant.ID, URL: "http://example.com", Pattern: "a", Data: strings.Repeat(data, 2)})).To(Succeed())
+		}
+		Expect(db.SaveData(Match{TenantID: DefaultTenantID, URL: "http://example.com", Pattern: "a", Data: strings.Repeat("x", 100)})).To(Succeed())
+
+		result, err := db.Prune(RetentionPolicy{}, time.Now())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.MatchesDeleted).To(Equal(int64(1)))
+
+		var oldest string
+		Expect(db.Conn.QueryRow("SELECT data FROM matches WHERE tenant_id = ? ORDER BY id LIMIT 1", tenant.ID).Scan(&oldest)).To(Succeed())
+		Expect(oldest).To(Equal("secondsecond"))
+	})
+})
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+func saveValue(e execer, value MonitorValue) error {
+	query := "INSERT INTO monitor_values (monitor_id, tenant_id, value, recorded_at) VALUES (?, ?, ?, ?)"
+	_, err := e.Exec(query, value.MonitorID, nullableID(value.TenantID), value.Value, value.RecordedAt.UTC())
+	return err
+}
+
+func scanValue(row rowScanner) (MonitorValue, error) {
+	var value MonitorValue
+	var tenantID sql.NullInt64
+	err := row.Scan(&value.MonitorID, &tenantID, &value.Value, &value.RecordedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return value, ErrNotFound
+	}
+	value.TenantID = tenantID.Int64
+	return value, err
+}
+
+func (db *SQLiteDB) SaveValue(value MonitorValue) error {
+	return saveValue(db.Conn, value)
+}
+
+func (db *SQLiteDB) LastValue(monitorID int64) (MonitorValue, error) {
+	query := "SELECT monitor_id, tenant_id, value, recorded_at FROM monitor_values WHERE monitor_id = ? ORDER BY recorded_at DESC, id DESC LIMIT 1"
+	return scanValue(db.Conn.QueryRow(query, monitorID))
+}
+
+func (db *SQLiteDB) ListMonitorValues(id int64, from, to time.Time, limit int) ([]MonitorValue, error) {
+	query := `
+    SELECT monitor_id, tenant_id, value, recorded_at FROM monitor_values
+    WHERE monitor_id = ? AND recorded_at >= ? AND recorded_at < ?
+    ORDER BY recorded_at DESC, id DESC LIMIT ?`
+	rows, err := db.Conn.Query(query, id, from.UTC(), to.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var values []MonitorValue
+	for rows.Next() {
+		value, scanErr := scanValue(rows)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		values = append(values, value)
+	}
+	for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+		values[i], values[j] = values[j], values[i]
+	}
+	return values, rows.Err()
+}
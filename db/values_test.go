@@ -0,0 +1,104 @@
+package db_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"os"
+	. "snapp-task/db"
+	"time"
+)
+
+var _ = Describe("Values", func() {
+	var (
+		db             *SQLiteDB
+		dataSourceName string
+		now            time.Time
+	)
+
+	BeforeEach(func() {
+		file, err := os.CreateTemp("", "testdb_*.db")
+		Expect(err).NotTo(HaveOccurred())
+		dataSourceName = file.Name()
+
+		db, err = NewSQLiteDB(dataSourceName)
+		Expect(err).NotTo(HaveOccurred())
+		now = time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+		for i, value := range []float64{10, 20, 30, 40} {
+			Expect(db.SaveValue(MonitorValue{MonitorID: 1, TenantID: 1, Value: value, RecordedAt: now.Add(time.Duration(i) * time.Minute)})).To(Succeed())
+		}
+		Expect(db.SaveValue(MonitorValue{MonitorID: 2, Value: 99, RecordedAt: now})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(db.Close()).To(Succeed())
+		Expect(os.Remove(dataSourceName)).To(Succeed())
+	})
+
+	It("should return the latest value of a monitor", func() {
+		last, err := db.LastValue(1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(last).To(Equal(MonitorValue{MonitorID: 1, TenantID: 1, Value: 40, RecordedAt: now.Add(3 * time.Minute)}))
+
+		_, err = db.LastValue(3)
+		Expect(err).To(MatchError(ErrNotFound))
+	})
+
+	It("should list values in a time range in chronological order", func() {
+		values, err := db.ListMonitorValues(1, now.Add(time.Minute), now.Add(3*time.Minute), 10)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(values).To(HaveLen(2))
+		Expect(values[0].Value).To(Equal(20.0))
+		Expect(values[1].Value).To(Equal(30.0))
+	})
+
+	It("should keep the most recent values when over the limit", func() {
+		values, err := db.ListMonitorValues(1, now, now.Add(time.Hour), 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(values).To(HaveLen(2))
+		Expect(values[0].Value).To(Equal(30.0))
+		Expect(values[1].Value).To(Equal(40.0))
+	})
+
+	It("should serve the latest value from the batch writer before it is flushed", func() {
+		writer := NewBatchWriter(db, BatchOptions{MaxBatchSize: 10, FlushInterval: time.Hour})
+		Expect(writer.SaveValue(MonitorValue{MonitorID: 1, Value: 50, RecordedAt: now.Add(time.Hour)})).To(Succeed())
+
+		last, err := writer.LastValue(1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(last.Value).To(Equal(50.0))
+		last, err = writer.LastValue(2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(last.Value).To(Equal(99.0))
+
+		Expect(writer.Close()).To(Succeed())
+		last, err = db.LastValue(1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(last.Value).To(Equal(50.0))
+	})
+
+	It("should stop serving the remembered value once it is cleared or the monitor is forgotten", func() {
+		writer := NewBatchWriter(db, BatchOptions{MaxBatchSize: 10, FlushInterval: time.Hour})
+		defer writer.Close()
+		Expect(writer.SaveValue(MonitorValue{MonitorID: 1, Value: 50, RecordedAt: now.Add(time.Hour)})).To(Succeed())
+		writer.ClearLastValue(1)
+		last, err := writer.LastValue(1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(last.Value).To(Equal(40.0))
+
+		Expect(writer.SaveValue(MonitorValue{MonitorID: 2, Value: 60, RecordedAt: now.Add(time.Hour)})).To(Succeed())
+		writer.Forget(2)
+		Expect(writer.SaveValue(MonitorValue{MonitorID: 2, Value: 70, RecordedAt: now.Add(2 * time.Hour)})).To(Succeed())
+		last, err = writer.LastValue(2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(last.Value).To(Equal(99.0))
+	})
+
+	It("should prune values older than the retention MaxAge", func() {
+		result, err := db.Prune(RetentionPolicy{MaxAge: time.Hour}, now.Add(time.Hour+90*time.Second))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.ValuesDeleted).To(Equal(int64(3)))
+		values, err := db.ListMonitorValues(1, now, now.Add(time.Hour), 10)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(values).To(HaveLen(2))
+	})
+})
@@ -1,29 +1,331 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
 	"snapp-task/api"
 	"snapp-task/db"
+	"snapp-task/notifier"
 	"snapp-task/services"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
 const serverAddress = ":8080"
 const dbPath = "data.db"
 
+var (
+	retentionMaxAge      = flag.Duration("retention-max-age", 0, "delete matches and check runs older than this (0 keeps everything)")
+	retentionMaxRows     = flag.Int("retention-max-rows", 0, "keep at most this many matches per monitor (0 is unlimited)")
+	retentionDownsample  = flag.Duration("retention-downsample-after", 0, "roll check runs older than this into hourly aggregates (0 disables)")
+	retentionAggregates  = flag.Duration("retention-aggregate-max-age", 0, "delete hourly aggregates older than this (0 keeps everything)")
+	retentionInterval    = flag.Duration("retention-interval", time.Hour, "how often the retention policy is enforced")
+	retentionVacuumEvery = flag.Int("retention-vacuum-every", 24, "vacuum the database every N retention runs (0 disables)")
+	writeBatchSize       = flag.Int("write-batch-size", 100, "maximum number of inserts per write transaction")
+	writeFlushInterval   = flag.Duration("write-flush-interval", 500*time.Millisecond, "how often buffered writes are flushed")
+	writeBufferSize      = flag.Int("write-buffer-size", 10000, "number of writes buffered before writers block")
+	writeEnqueueTimeout  = flag.Duration("write-enqueue-timeout", 5*time.Second, "how long a write waits for buffer space before failing")
+	maxBodyBytes         = flag.Int64("max-body-bytes", 1<<20, "largest request body the API accepts")
+	egressAllowPrivate   = flag.Bool("egress-allow-private", false, "let checks connect to private, loopback and link-local addresses")
+	egressAllowCIDRs     = flag.String("egress-allow-cidrs", "", "comma-separated CIDRs checks may connect to even if private")
+	egressDenyCIDRs      = flag.String("egress-deny-cidrs", "", "comma-separated CIDRs checks may never connect to")
+	egressAllowHosts     = flag.String("egress-allow-hosts", "", "comma-separated hostnames (*.example.com for subdomains) exempt from the private range check")
+	egressDenyHosts      = flag.String("egress-deny-hosts", "", "comma-separated hostnames (*.example.com for subdomains) checks may never fetch")
+	rateLimit            = flag.Float64("rate-limit", 10, "API requests per second allowed per client (0 disables throttling)")
+	rateBurst            = flag.Int("rate-burst", 20, "largest burst of API requests a client may send at once")
+	authFailureRate      = flag.Float64("auth-failure-rate", 0.1, "requests with a missing or invalid API key allowed per second per client address (0 disables)")
+	authFailureBurst     = flag.Int("auth-failure-burst", 10, "largest burst of requests with a missing or invalid API key a client address may send")
+	routeRateLimits      = flag.String("route-rate-limits", "POST /=1:10,POST /monitors:bulk=0.2:2,POST /monitors:import=0.2:2,POST /monitors/{id}/run=0.5:5,POST /check:dry-run=0.5:5", "per-route limits as ROUTE=RATE:BURST, comma separated")
+	maxMonitors          = flag.Int("max-monitors", 0, "maximum number of monitors across all tenants (0 is unlimited)")
+	wsAllowedOrigins     = flag.String("ws-allowed-origins", "", "comma-separated origins (https://host) whose pages may open the control channel besides the API's own")
+	eventBufferSize      = flag.Int("event-buffer-size", 1000, "number of recent events kept for clients resuming a stream")
+	maxResponseBytes     = flag.Int64("max-response-bytes", services.DefaultMaxBodyBytes, "largest response body a check reads, before and after decompression")
+	maxChecksPerSecond   = flag.Float64("max-checks-per-second", 0, "maximum checks started per second across all monitors (0 is unlimited)")
+)
+
 func main() {
+	flag.Parse()
+	commands := map[string]func([]string) error{"migrate": runMigrate, "keys": runKeys, "tenants": runTenants}
+	if run, ok := commands[flag.Arg(0)]; ok {
+		if err := run(flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	sqliteDB, err := db.NewSQLiteDB(dbPath)
 	if err != nil {
 		panic(err)
 	}
 	defer sqliteDB.Close()
-	checkerFactory := func(url, pattern string, db db.DB) services.UrlChecker {
-		return services.NewUrlCheckerImpl(url, pattern, db)
+	// The key comes from the environment rather than a flag so it does not show up in the process list.
+	if secret := os.Getenv("CHANNEL_SECRET_KEY"); secret != "" {
+		key := sha256.Sum256([]byte(secret))
+		sqliteDB.SecretKey = key[:]
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	batchWriter := db.NewBatchWriter(sqliteDB, db.BatchOptions{
+		MaxBatchSize:   *writeBatchSize,
+		FlushInterval:  *writeFlushInterval,
+		BufferSize:     *writeBufferSize,
+		EnqueueTimeout: *writeEnqueueTimeout,
+	})
+	defer batchWriter.Close()
+	policy := db.RetentionPolicy{
+		MaxAge:            *retentionMaxAge,
+		MaxRowsPerMonitor: *retentionMaxRows,
+		DownsampleAfter:   *retentionDownsample,
+		AggregateMaxAge:   *retentionAggregates,
+	}
+	egress, err := egressPolicy()
+	if err != nil {
+		panic(err)
+	}
+	client := egress.HTTPClient()
+	events := services.NewEventBus(*eventBufferSize)
+	alerter := services.NewAlerter(sqliteDB, events)
+	if err = alerter.Start(); err != nil {
+		panic(err)
+	}
+	go alerter.Run(ctx)
+	janitor := services.NewJanitor(sqliteDB, policy, *retentionInterval, *retentionVacuumEvery)
+	janitor.Silences = alerter
+	go janitor.Run(ctx)
+	notifications := notifier.NewNotifier(sqliteDB, events, client)
+	go notifications.Run(ctx)
+	checkerFactory := func(monitor db.Monitor, db db.DB) services.UrlChecker {
+		return services.NewChecker(services.UrlCheckerImpl{Monitor: monitor, Db: db, Client: client, Events: events, MaxBodyBytes: *maxResponseBytes})
+	}
+	var checkLimiter *services.TokenBucket
+	if *maxChecksPerSecond > 0 {
+		checkLimiter = services.NewTokenBucket(*maxChecksPerSecond, int(math.Ceil(*maxChecksPerSecond)))
+	}
+	schedulerFactory := func(monitor db.Monitor, db db.DB) services.CheckScheduler {
+		return &services.CheckSchedulerImpl{Monitor: monitor, Db: db, UrlCheckerFactory: checkerFactory, CheckLimiter: checkLimiter}
+	}
+	registry := services.NewRegistry(sqliteDB, batchWriter, schedulerFactory)
+	registry.Tenants = sqliteDB
+	registry.MaxMonitors = *maxMonitors
+	registry.CheckerFactory = checkerFactory
+	registry.OnUpdate = func(monitor db.Monitor) {
+		batchWriter.ClearLastValue(monitor.ID)
 	}
-	schedulerFactory := func(url, pattern string, interval time.Duration, db db.DB) services.CheckScheduler {
-		return services.NewCheckSchedulerImpl(url, pattern, interval, db, checkerFactory)
+	registry.OnDelete = func(monitorID int64) {
+		events.Forget(monitorID)
+		batchWriter.Forget(monitorID)
+		if err := alerter.DropMonitor(monitorID); err != nil {
+			log.Printf("Failed to delete the alerts of monitor %d: %v\n", monitorID, err)
+		}
 	}
-	server := api.NewAPIServer(serverAddress, sqliteDB, schedulerFactory)
-	if runErr := server.Run(); runErr != nil {
+	if err = registry.Start(); err != nil {
+		panic(err)
+	}
+	defer registry.StopAll()
+	server := api.NewAPIServer(serverAddress, registry)
+	server.MaxBodyBytes = *maxBodyBytes
+	server.Keys = services.NewAPIKeys(sqliteDB)
+	server.Tenants = sqliteDB
+	server.Egress = &egress
+	server.Events = events
+	for _, origin := range strings.Split(*wsAllowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			server.AllowedOrigins = append(server.AllowedOrigins, origin)
+		}
+	}
+	server.Alerts = alerter
+	server.Notifier = notifications
+	server.DefaultRateLimit = api.RateLimit{PerSecond: *rateLimit, Burst: *rateBurst}
+	server.AuthFailureLimit = api.RateLimit{PerSecond: *authFailureRate, Burst: *authFailureBurst}
+	if server.RateLimits, err = api.ParseRateLimits(*routeRateLimits); err != nil {
+		panic(err)
+	}
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	if runErr := server.Run(); runErr != nil && !errors.Is(runErr, http.ErrServerClosed) {
 		panic(runErr)
 	}
 }
+
+func egressPolicy() (services.EgressPolicy, error) {
+	policy := services.EgressPolicy{
+		AllowPrivate: *egressAllowPrivate,
+		AllowHosts:   services.ParseHosts(*egressAllowHosts),
+		DenyHosts:    services.ParseHosts(*egressDenyHosts),
+	}
+	var err error
+	if policy.AllowCIDRs, err = services.ParseCIDRs(*egressAllowCIDRs); err != nil {
+		return policy, fmt.Errorf("egress-allow-cidrs: %w", err)
+	}
+	if policy.DenyCIDRs, err = services.ParseCIDRs(*egressDenyCIDRs); err != nil {
+		return policy, fmt.Errorf("egress-deny-cidrs: %w", err)
+	}
+	return policy, nil
+}
+
+const migrateUsage = "usage: migrate status | up | down [steps]"
+
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(migrateUsage)
+	}
+	conn, err := db.OpenSQLite(dbPath)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	migrator, err := db.NewMigrator(conn)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "status":
+		statuses, statusErr := migrator.Status()
+		if statusErr != nil {
+			return statusErr
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, state)
+		}
+	case "up":
+		count, upErr := migrator.Up()
+		fmt.Printf("applied %d migration(s)\n", count)
+		return upErr
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid steps: %s", args[1])
+			}
+		}
+		count, downErr := migrator.Down(steps)
+		fmt.Printf("rolled back %d migration(s)\n", count)
+		return downErr
+	default:
+		return fmt.Errorf(migrateUsage)
+	}
+	return nil
+}
+
+const keysUsage = "usage: keys create <name> <read|write|admin> [tenant-id] | list | revoke <id>"
+
+func runKeys(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(keysUsage)
+	}
+	sqliteDB, err := db.NewSQLiteDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer sqliteDB.Close()
+	keys := services.NewAPIKeys(sqliteDB)
+	switch {
+	case args[0] == "create" && (len(args) == 3 || len(args) == 4):
+		var tenantID int64
+		if len(args) == 4 {
+			if tenantID, err = strconv.ParseInt(args[3], 10, 64); err != nil {
+				return fmt.Errorf("invalid tenant id: %s", args[3])
+			}
+			if _, err = sqliteDB.GetTenant(tenantID); err != nil {
+				return fmt.Errorf("tenant %d: %w", tenantID, err)
+			}
+		}
+		key, secret, createErr := keys.Create(args[1], db.Scope(args[2]), tenantID)
+		if createErr != nil {
+			return createErr
+		}
+		fmt.Printf("created key %d (%s, %s)\n%s\n", key.ID, key.Name, key.Scope, secret)
+	case args[0] == "list":
+		list, listErr := keys.List(0)
+		if listErr != nil {
+			return listErr
+		}
+		for _, key := range list {
+			state := "active"
+			if key.RevokedAt != nil {
+				state = "revoked " + key.RevokedAt.Format(time.RFC3339)
+			}
+			tenant := "global"
+			if key.TenantID != 0 {
+				tenant = "tenant " + strconv.FormatInt(key.TenantID, 10)
+			}
+			fmt.Printf("%d\t%s\t%s...\t%s\t%s\t%s\n", key.ID, key.Name, key.Prefix, key.Scope, tenant, state)
+		}
+	case args[0] == "revoke" && len(args) == 2:
+		id, parseErr := strconv.ParseInt(args[1], 10, 64)
+		if parseErr != nil {
+			return fmt.Errorf("invalid id: %s", args[1])
+		}
+		return keys.Revoke(0, id)
+	default:
+		return fmt.Errorf(keysUsage)
+	}
+	return nil
+}
+
+const tenantsUsage = "usage: tenants create <name> [max-monitors min-interval max-storage-bytes] | list"
+
+func runTenants(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(tenantsUsage)
+	}
+	sqliteDB, err := db.NewSQLiteDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer sqliteDB.Close()
+	switch {
+	case args[0] == "create" && (len(args) == 2 || len(args) == 5):
+		tenant := db.Tenant{Name: args[1]}
+		if len(args) == 5 {
+			if tenant.MaxMonitors, err = strconv.Atoi(args[2]); err != nil {
+				return fmt.Errorf("invalid max-monitors: %s", args[2])
+			}
+			if tenant.MinInterval, err = time.ParseDuration(args[3]); err != nil {
+				return fmt.Errorf("invalid min-interval: %s", args[3])
+			}
+			if tenant.MaxStorageBytes, err = strconv.ParseInt(args[4], 10, 64); err != nil {
+				return fmt.Errorf("invalid max-storage-bytes: %s", args[4])
+			}
+		}
+		if tenant, err = sqliteDB.CreateTenant(tenant); err != nil {
+			return err
+		}
+		fmt.Printf("created tenant %d (%s)\n", tenant.ID, tenant.Name)
+	case args[0] == "list":
+		tenants, listErr := sqliteDB.ListTenants()
+		if listErr != nil {
+			return listErr
+		}
+		for _, tenant := range tenants {
+			usage, usageErr := sqliteDB.GetTenantUsage(tenant.ID)
+			if usageErr != nil {
+				return usageErr
+			}
+			fmt.Printf("%d\t%s\tmonitors %d/%d\tmin-interval %s\tstorage %d/%d\n", tenant.ID, tenant.Name,
+				usage.Monitors, tenant.MaxMonitors, tenant.MinInterval, usage.StorageBytes, tenant.MaxStorageBytes)
+		}
+	default:
+		return fmt.Errorf(tenantsUsage)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultTelegramAPI is where Telegram messages are sent unless the channel sets api_url.
+const defaultTelegramAPI = "https://api.telegram.org"
+
+// maxErrorBody caps how much of a failed response is quoted in the error.
+const maxErrorBody = 512
+
+// post sends body to url and fails on any status other than 2xx.
+func post(ctx context.Context, client *http.Client, method, url, contentType string, body []byte) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", contentType)
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	responseBody, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBody))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return responseBody, fmt.Errorf("unexpected status %d: %s", response.StatusCode, strings.TrimSpace(string(responseBody)))
+	}
+	return responseBody, nil
+}
+
+// slackSender posts to a Slack incoming webhook. Mattermost and Rocket.Chat accept the same payload.
+type slackSender struct {
+	url      string
+	template *template.Template
+	client   *http.Client
+}
+
+func (s *slackSender) Send(ctx context.Context, notification Notification) error {
+	message, err := render(s.template, notification)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(map[string]string{"text": message})
+	if err != nil {
+		return err
+	}
+	if _, err = post(ctx, s.client, http.MethodPost, s.url, "application/json", payload); err != nil {
+		// Transport errors quote the webhook URL, which is the channel's secret.
+		return errors.New(strings.ReplaceAll(err.Error(), s.url, "<url>"))
+	}
+	return nil
+}
+
+// telegramSender sends messages through the Telegram Bot API.
+type telegramSender struct {
+	settings map[string]string
+	template *template.Template
+	client   *http.Client
+}
+
+func (s *telegramSender) Send(ctx context.Context, notification Notification) error {
+	message, err := render(s.template, notification)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(map[string]string{"chat_id": s.settings["chat_id"], "text": message})
+	if err != nil {
+		return err
+	}
+	api := s.settings["api_url"]
+	if api == "" {
+		api = defaultTelegramAPI
+	}
+	body, err := post(ctx, s.client, http.MethodPost, strings.TrimSuffix(api, "/")+"/bot"+s.settings["token"]+"/sendMessage",
+		"application/json", payload)
+	var response struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if json.Unmarshal(body, &response) == nil && !response.OK && response.Description != "" {
+		// The bot API explains failures better than the status does.
+		return fmt.Errorf("telegram: %s", response.Description)
+	}
+	if err != nil {
+		// Transport errors quote the URL, which holds the bot's token.
+		return errors.New(strings.ReplaceAll(err.Error(), s.settings["token"], "<token>"))
+	}
+	return nil
+}
+
+// webhookSender sends the rendered template as the body of a request to any URL. The method defaults
+// to POST and the content type to application/json.
+type webhookSender struct {
+	settings map[string]string
+	template *template.Template
+	client   *http.Client
+}
+
+func (s *webhookSender) Send(ctx context.Context, notification Notification) error {
+	body, err := render(s.template, notification)
+	if err != nil {
+		return err
+	}
+	method := s.settings["method"]
+	if method == "" {
+		method = http.MethodPost
+	}
+	contentType := s.settings["content_type"]
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	_, err = post(ctx, s.client, method, s.settings["url"], contentType, []byte(body))
+	return err
+}
+
+// emailSender sends mail through an SMTP server, upgrading to TLS when the server offers STARTTLS. With
+// the tls setting "implicit", or on port 465 unless tls says otherwise, the connection is TLS from the
+// start. The first line of the rendered message is the subject and the whole message the body.
+type emailSender struct {
+	settings map[string]string
+	template *template.Template
+	client   *http.Client
+}
+
+func (s *emailSender) Send(ctx context.Context, notification Notification) error {
+	message, err := render(s.template, notification)
+	if err != nil {
+		return err
+	}
+	message = strings.ReplaceAll(message, "\r\n", "\n")
+	subject, _, _ := strings.Cut(message, "\n")
+	from := s.settings["from"]
+	recipients := strings.Split(s.settings["to"], ",")
+	for i := range recipients {
+		recipients[i] = strings.TrimSpace(recipients[i])
+	}
+	// A line break in a header would let the rule name or a setting add headers of its own.
+	for _, header := range [][2]string{{"from", from}, {"to", s.settings["to"]}, {"subject", subject}} {
+		if strings.ContainsAny(header[1], "\r\n") {
+			return fmt.Errorf("%s must not contain line breaks", header[0])
+		}
+	}
+	host := s.settings["host"]
+	port := s.settings["port"]
+	implicitTLS := s.settings["tls"] == "implicit" || (s.settings["tls"] == "" && port == "465")
+	if port == "" {
+		port = "25"
+		if implicitTLS {
+			port = "465"
+		}
+	}
+	conn, err := dialContext(s.client)(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: host, RootCAs: rootCAs(s.client)}
+	if implicitTLS {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return fmt.Errorf("TLS handshake failed: %v", err)
+		}
+		conn = tlsConn
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok && !implicitTLS {
+		if err = client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if username := s.settings["username"]; username != "" {
+		if err = client.Auth(smtp.PlainAuth("", username, s.settings["password"], host)); err != nil {
+			return err
+		}
+	}
+	if err = client.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		if err = client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	body := strings.ReplaceAll(strings.ReplaceAll(message, "\r", "\n"), "\n", "\r\n")
+	fmt.Fprintf(writer, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMessage-ID: %s\r\nMIME-Version: 1.0\r\n"+
+		"Content-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n", from, strings.Join(recipients, ", "),
+		mime.QEncoding.Encode("utf-8", subject), time.Now().Format(time.RFC1123Z), messageID(from, host), body)
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// messageID returns a new Message-ID in the domain of the sender's address, or of host when the
+// address has none.
+func messageID(from, host string) string {
+	domain := host
+	if address, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndexByte(address.Address, '@'); at >= 0 {
+			domain = address.Address[at+1:]
+		}
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain)
+}
+
+func dialContext(client *http.Client) func(ctx context.Context, network, address string) (net.Conn, error) {
+	if transport, ok := client.Transport.(*http.Transport); ok && transport.DialContext != nil {
+		return transport.DialContext
+	}
+	return (&net.Dialer{}).DialContext
+}
+
+// rootCAs returns the roots the HTTP client trusts, or nil for the system's.
+func rootCAs(client *http.Client) *x509.CertPool {
+	if transport, ok := client.Transport.(*http.Transport); ok && transport.TLSClientConfig != nil {
+		return transport.TLSClientConfig.RootCAs
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"snapp-task/db"
+	"snapp-task/services"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// sendTimeout bounds a single delivery attempt.
+const sendTimeout = 10 * time.Second
+
+const (
+	defaultAttempts  = 3
+	defaultBackoff   = 2 * time.Second
+	defaultQueueSize = 100
+)
+
+// Notification is what a channel's template is rendered with.
+type Notification struct {
+	RuleID    int64
+	Rule      string
+	Status    db.AlertStatus
+	MonitorID int64
+	TenantID  int64
+	URL       string
+	Time      time.Time
+	// Test is set for the notifications sent by the test endpoint.
+	Test bool
+}
+
+// Firing reports whether the alert fired, rather than resolved.
+func (n Notification) Firing() bool {
+	return n.Status == db.AlertFiring
+}
+
+// Sender delivers notifications to one channel.
+type Sender interface {
+	Send(ctx context.Context, notification Notification) error
+}
+
+// RequiredSettings lists the settings each kind of channel needs. Kinds missing from it are unsupported.
+var RequiredSettings = map[db.ChannelKind][]string{
+	db.ChannelEmail:    {"host", "from", "to"},
+	db.ChannelSlack:    {"url"},
+	db.ChannelTelegram: {"token", "chat_id"},
+	db.ChannelWebhook:  {"url"},
+}
+
+// SecretSettings lists the settings of each kind of channel that are masked when the API returns it. A
+// Slack webhook URL is a credential on its own.
+var SecretSettings = map[db.ChannelKind][]string{
+	db.ChannelEmail:    {"password"},
+	db.ChannelSlack:    {"url"},
+	db.ChannelTelegram: {"token"},
+}
+
+const defaultTemplate = `{{if .Firing}}FIRING{{else}}RESOLVED{{end}}: {{.Rule}} for monitor {{.MonitorID}}{{with .URL}} ({{.}}){{end}}`
+
+const defaultWebhookTemplate = `{"rule_id": {{.RuleID}}, "rule": {{json .Rule}}, "status": {{json .Status}}, ` +
+	`"monitor_id": {{.MonitorID}}, "url": {{json .URL}}, "time": {{json .Time}}, "test": {{.Test}}}`
+
+var templateFuncs = template.FuncMap{
+	"json": func(value any) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// ParseTemplate parses a channel's message template. An empty text gives the kind's default.
+func ParseTemplate(kind db.ChannelKind, text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultTemplate
+		if kind == db.ChannelWebhook {
+			text = defaultWebhookTemplate
+		}
+	}
+	return template.New(string(kind)).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+}
+
+func render(tmpl *template.Template, notification Notification) (string, error) {
+	var message strings.Builder
+	if err := tmpl.Execute(&message, notification); err != nil {
+		return "", fmt.Errorf("failed to render template: %v", err)
+	}
+	return message.String(), nil
+}
+
+// New returns the Sender of channel. HTTP channels post through client, and email dials through its
+// transport, so the egress policy applies to notifications too.
+func New(channel db.Channel, client *http.Client) (Sender, error) {
+	required, ok := RequiredSettings[channel.Kind]
+	if !ok {
+		return nil, fmt.Errorf("unsupported channel kind %q", channel.Kind)
+	}
+	for _, name := range required {
+		if channel.Settings[name] == "" {
+			return nil, fmt.Errorf("%s channels need the %s setting", channel.Kind, name)
+		}
+	}
+	tmpl, err := ParseTemplate(channel.Kind, channel.Template)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	settings := channel.Settings
+	switch channel.Kind {
+	case db.ChannelEmail:
+		return &emailSender{settings: settings, template: tmpl, client: client}, nil
+	case db.ChannelSlack:
+		return &slackSender{url: settings["url"], template: tmpl, client: client}, nil
+	case db.ChannelTelegram:
+		return &telegramSender{settings: settings, template: tmpl, client: client}, nil
+	default:
+		return &webhookSender{settings: settings, template: tmpl, client: client}, nil
+	}
+}
+
+// Notifier delivers alert events to every channel of the alert's tenant.
+type Notifier struct {
+	store  db.ChannelStore
+	events *services.EventBus
+	client *http.Client
+	// Attempts is how many times a notification is sent to a channel before it is given up on. Backoff
+	// is the wait before the first retry, doubled for each one after it.
+	Attempts int
+	Backoff  time.Duration
+	// QueueSize bounds the notifications waiting for a channel. Notifications for a channel whose queue
+	// is full are dropped.
+	QueueSize int
+
+	mu     sync.Mutex
+	queues map[int64]chan delivery
+}
+
+// delivery is a notification waiting in a channel's queue.
+type delivery struct {
+	channel      db.Channel
+	notification Notification
+}
+
+func NewNotifier(store db.ChannelStore, events *services.EventBus, client *http.Client) *Notifier {
+	return &Notifier{store: store, events: events, client: client, Attempts: defaultAttempts, Backoff: defaultBackoff,
+		QueueSize: defaultQueueSize, queues: make(map[int64]chan delivery)}
+}
+
+// Run delivers alert events until ctx is cancelled. When the bus drops the subscription for falling
+// behind, Run subscribes again and replays the events it missed.
+func (n *Notifier) Run(ctx context.Context) {
+	var lastID uint64
+	for {
+		sub, replay := n.events.Subscribe(lastID, func(event services.Event) bool {
+			return event.Type == services.EventAlert
+		})
+		for _, event := range replay {
+			n.Notify(ctx, event)
+			lastID = event.ID
+		}
+	receive:
+		for {
+			select {
+			case event, ok := <-sub.C:
+				if !ok {
+					break receive
+				}
+				n.Notify(ctx, event)
+				lastID = event.ID
+			case <-ctx.Done():
+				n.events.Unsubscribe(sub)
+				return
+			}
+		}
+	}
+}
+
+// Notify queues an alert event for the channels of its tenant and returns without waiting for it to be
+// sent. Every channel has its own queue and worker, so a channel that hangs or keeps failing only delays
+// its own notifications. Deliveries that never succeed are logged.
+func (n *Notifier) Notify(ctx context.Context, event services.Event) {
+	channels, err := n.Channels(event.TenantID)
+	if err != nil {
+		log.Printf("Failed to list notification channels: %v\n", err)
+		return
+	}
+	notification := Notification{RuleID: event.RuleID, Rule: event.Data, Status: event.Alert, MonitorID: event.MonitorID,
+		TenantID: event.TenantID, URL: event.URL, Time: event.Time}
+	for _, channel := range channels {
+		n.enqueue(ctx, delivery{channel: channel, notification: notification})
+	}
+}
+
+// enqueue adds job to its channel's queue, starting the channel's worker when it has none.
+func (n *Notifier) enqueue(ctx context.Context, job delivery) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	queue, ok := n.queues[job.channel.ID]
+	if !ok {
+		queue = make(chan delivery, n.QueueSize)
+		n.queues[job.channel.ID] = queue
+		go n.work(ctx, job.channel.ID, queue)
+	}
+	select {
+	case queue <- job:
+	default:
+		log.Printf("Dropped %s alert %q on monitor %d for channel %d (%s): its queue is full\n",
+			job.notification.Status, job.notification.Rule, job.notification.MonitorID, job.channel.ID, job.channel.Name)
+	}
+}
+
+// work delivers the notifications of a channel's queue in order and exits once the queue is empty.
+func (n *Notifier) work(ctx context.Context, channelID int64, queue chan delivery) {
+	for {
+		n.mu.Lock()
+		var job delivery
+		select {
+		case job = <-queue:
+		default:
+			delete(n.queues, channelID)
+			n.mu.Unlock()
+			return
+		}
+		n.mu.Unlock()
+		if attempts, err := n.deliver(ctx, job.channel, job.notification); err != nil {
+			log.Printf("Gave up notifying channel %d (%s) of %s alert %q on monitor %d after %d attempts: %v\n",
+				job.channel.ID, job.channel.Name, job.notification.Status, job.notification.Rule, job.notification.MonitorID, attempts, err)
+		}
+	}
+}
+
+// deliver sends notification to channel, retrying with exponential backoff until it succeeds, Attempts
+// run out or ctx is cancelled. It returns the number of attempts made and the last error.
+func (n *Notifier) deliver(ctx context.Context, channel db.Channel, notification Notification) (int, error) {
+	sender, err := New(channel, n.client)
+	if err != nil {
+		// A channel that cannot be built will not be fixed by retrying.
+		return 1, err
+	}
+	backoff := n.Backoff
+	attempt := 1
+	for ; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err = sender.Send(attemptCtx, notification)
+		cancel()
+		if err == nil || attempt >= n.Attempts {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return attempt, err
+		}
+		backoff *= 2
+	}
+	return attempt, err
+}
+
+// Send delivers notification to channel once, without retrying.
+func (n *Notifier) Send(ctx context.Context, channel db.Channel, notification Notification) error {
+	sender, err := New(channel, n.client)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return sender.Send(ctx, notification)
+}
+
+// Channels returns the channels of tenantID. Tenant 0 sees every channel.
+func (n *Notifier) Channels(tenantID int64) ([]db.Channel, error) {
+	all, err := n.store.ListChannels()
+	if err != nil {
+		return nil, err
+	}
+	channels := []db.Channel{}
+	for _, channel := range all {
+		if tenantID == 0 || channel.TenantID == tenantID {
+			channels = append(channels, channel)
+		}
+	}
+	return channels, nil
+}
+
+// Channel returns the channel. Channels of other tenants are reported as db.ErrNotFound.
+func (n *Notifier) Channel(tenantID, id int64) (db.Channel, error) {
+	channel, err := n.store.GetChannel(id)
+	if err == nil && tenantID != 0 && channel.TenantID != tenantID {
+		return db.Channel{}, db.ErrNotFound
+	}
+	return channel, err
+}
+
+// CreateChannel stores channel. A channel without a tenant belongs to the default tenant.
+func (n *Notifier) CreateChannel(channel db.Channel) (db.Channel, error) {
+	if channel.TenantID == 0 {
+		channel.TenantID = db.DefaultTenantID
+	}
+	return n.store.CreateChannel(channel)
+}
+
+// DeleteChannel deletes the channel. Channels of other tenants are reported as db.ErrNotFound.
+func (n *Notifier) DeleteChannel(tenantID, id int64) error {
+	if _, err := n.Channel(tenantID, id); err != nil {
+		return err
+	}
+	return n.store.DeleteChannel(id)
+}
@@ -0,0 +1,398 @@
+package notifier_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"snapp-task/db"
+	. "snapp-task/notifier"
+	"snapp-task/services"
+	"strings"
+	"time"
+)
+
+// smtpMessage is a mail received by serveSMTP.
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// serveSMTP speaks just enough SMTP to accept one mail per connection.
+func serveSMTP(listener net.Listener, received chan<- smtpMessage) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+			reply("220 localhost ESMTP")
+			var message smtpMessage
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				command := strings.TrimSpace(line)
+				switch {
+				case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+					reply("250 localhost")
+				case strings.HasPrefix(command, "MAIL FROM:"):
+					message.From = strings.Trim(strings.TrimPrefix(command, "MAIL FROM:"), "<>")
+					reply("250 OK")
+				case strings.HasPrefix(command, "RCPT TO:"):
+					message.To = append(message.To, strings.Trim(strings.TrimPrefix(command, "RCPT TO:"), "<>"))
+					reply("250 OK")
+				case command == "DATA":
+					reply("354 Go ahead")
+					var data strings.Builder
+					for {
+						line, err = reader.ReadString('\n')
+						if err != nil || line == ".\r\n" {
+							break
+						}
+						data.WriteString(line)
+					}
+					message.Data = data.String()
+					received <- message
+					reply("250 OK")
+				case command == "QUIT":
+					reply("221 Bye")
+					return
+				default:
+					reply("250 OK")
+				}
+			}
+		}()
+	}
+}
+
+var _ = Describe("Senders", func() {
+	var (
+		server   *httptest.Server
+		requests chan *http.Request
+		bodies   chan string
+		status   int
+		channel  db.Channel
+		sendErr  error
+	)
+
+	notification := Notification{RuleID: 3, Rule: "api down", Status: db.AlertFiring, MonitorID: 7, TenantID: 1,
+		URL: "https://api.example.com", Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	BeforeEach(func() {
+		requests = make(chan *http.Request, 1)
+		bodies = make(chan string, 1)
+		status = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			requests <- r
+			bodies <- string(body)
+			w.WriteHeader(status)
+			if strings.Contains(r.URL.Path, "sendMessage") {
+				io.WriteString(w, `{"ok": false, "description": "Bad Request: chat not found"}`)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		sender, err := New(channel, server.Client())
+		Expect(err).NotTo(HaveOccurred())
+		sendErr = sender.Send(context.Background(), notification)
+	})
+
+	Context("for a Slack webhook", func() {
+		BeforeEach(func() {
+			channel = db.Channel{Kind: db.ChannelSlack, Settings: db.ChannelSettings{"url": server.URL + "/hooks/x"}}
+		})
+
+		It("should post the rendered default template as text", func() {
+			Expect(sendErr).NotTo(HaveOccurred())
+			Expect((<-requests).URL.Path).To(Equal("/hooks/x"))
+			Expect(<-bodies).To(MatchJSON(`{"text": "FIRING: api down for monitor 7 (https://api.example.com)"}`))
+		})
+
+		Context("that rejects the message", func() {
+			BeforeEach(func() {
+				status = http.StatusNotFound
+			})
+			It("should return the status", func() {
+				Expect(sendErr).To(MatchError(ContainSubstring("unexpected status 404")))
+			})
+		})
+	})
+
+	Context("for a Telegram bot", func() {
+		BeforeEach(func() {
+			channel = db.Channel{Kind: db.ChannelTelegram, Template: "{{.Rule}} is {{.Status}}",
+				Settings: db.ChannelSettings{"token": "123:abc", "chat_id": "-100", "api_url": server.URL}}
+		})
+
+		It("should send the message to the chat", func() {
+			Expect((<-requests).URL.Path).To(Equal("/bot123:abc/sendMessage"))
+			Expect(<-bodies).To(MatchJSON(`{"chat_id": "-100", "text": "api down is firing"}`))
+		})
+
+		Context("that fails", func() {
+			BeforeEach(func() {
+				status = http.StatusBadRequest
+			})
+			It("should return the bot API's description", func() {
+				Expect(sendErr).To(MatchError("telegram: Bad Request: chat not found"))
+			})
+		})
+	})
+
+	Context("for a generic webhook", func() {
+		BeforeEach(func() {
+			channel = db.Channel{Kind: db.ChannelWebhook, Settings: db.ChannelSettings{"url": server.URL, "method": http.MethodPut}}
+		})
+
+		It("should send the default JSON document", func() {
+			Expect(sendErr).NotTo(HaveOccurred())
+			request := <-requests
+			Expect(request.Method).To(Equal(http.MethodPut))
+			Expect(request.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(<-bodies).To(MatchJSON(`{"rule_id": 3, "rule": "api down", "status": "firing", "monitor_id": 7,
+				"url": "https://api.example.com", "time": "2024-01-01T00:00:00Z", "test": false}`))
+		})
+
+		Context("with its own template", func() {
+			BeforeEach(func() {
+				channel.Settings["content_type"] = "text/plain"
+				channel.Template = `{{if .Firing}}down{{else}}up{{end}} {{.MonitorID}}`
+			})
+			It("should send the rendered template", func() {
+				Expect((<-requests).Header.Get("Content-Type")).To(Equal("text/plain"))
+				Expect(<-bodies).To(Equal("down 7"))
+			})
+		})
+	})
+
+	Context("for email", func() {
+		var (
+			listener net.Listener
+			received chan smtpMessage
+		)
+
+		BeforeEach(func() {
+			var err error
+			listener, err = net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			received = make(chan smtpMessage, 1)
+			go serveSMTP(listener, received)
+			host, port, _ := net.SplitHostPort(listener.Addr().String())
+			channel = db.Channel{Kind: db.ChannelEmail, Template: "{{.Rule}}\nMonitor {{.MonitorID}} is {{.Status}}.",
+				Settings: db.ChannelSettings{"host": host, "port": port, "from": "alerts@example.com", "to": "ops@example.com, oncall@example.com"}}
+		})
+
+		AfterEach(func() {
+			listener.Close()
+		})
+
+		It("should send the first line as the subject", func() {
+			Expect(sendErr).NotTo(HaveOccurred())
+			var message smtpMessage
+			Expect(received).To(Receive(&message))
+			Expect(message.From).To(Equal("alerts@example.com"))
+			Expect(message.To).To(Equal([]string{"ops@example.com", "oncall@example.com"}))
+			Expect(message.Data).To(ContainSubstring("Subject: api down\r\n"))
+			Expect(message.Data).To(MatchRegexp(`Date: .+\r\nMessage-ID: <[0-9a-f]+@example\.com>\r\n`))
+			Expect(message.Data).To(HaveSuffix("\r\n\r\napi down\r\nMonitor 7 is firing.\r\n"))
+		})
+
+		Context("with a template using CRLF line endings", func() {
+			BeforeEach(func() {
+				channel.Template = "{{.Rule}}\r\nMonitor {{.MonitorID}}\r\n"
+			})
+
+			It("should not double the carriage returns", func() {
+				var message smtpMessage
+				Expect(received).To(Receive(&message))
+				Expect(message.Data).To(ContainSubstring("Subject: api down\r\n"))
+				Expect(message.Data).To(HaveSuffix("\r\n\r\napi down\r\nMonitor 7\r\n\r\n"))
+			})
+		})
+
+		Context("with a line break in a header", func() {
+			BeforeEach(func() {
+				channel.Template = "{{.Rule}}\rBcc: attacker@example.com\nbody"
+			})
+
+			It("should refuse to send", func() {
+				Expect(sendErr).To(MatchError("subject must not contain line breaks"))
+				Expect(received).NotTo(Receive())
+			})
+		})
+
+		It("should connect over TLS from the start when asked to", func() {
+			<-received
+			server := httptest.NewTLSServer(http.NotFoundHandler())
+			defer server.Close()
+			tlsListener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer tlsListener.Close()
+			go serveSMTP(tls.NewListener(tlsListener, &tls.Config{Certificates: server.TLS.Certificates}), received)
+			_, channel.Settings["port"], _ = net.SplitHostPort(tlsListener.Addr().String())
+			channel.Settings["tls"] = "implicit"
+			sender, err := New(channel, server.Client())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sender.Send(context.Background(), notification)).To(Succeed())
+			Expect(received).To(Receive(HaveField("From", "alerts@example.com")))
+
+			channel.Settings["from"] = "alerts@example.com\r\nBcc: attacker@example.com"
+			sender, err = New(channel, server.Client())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sender.Send(context.Background(), notification)).To(MatchError("from must not contain line breaks"))
+		})
+	})
+})
+
+var _ = Describe("New", func() {
+	It("should reject channels it cannot send to", func() {
+		_, err := New(db.Channel{Kind: "pager"}, nil)
+		Expect(err).To(MatchError(`unsupported channel kind "pager"`))
+		_, err = New(db.Channel{Kind: db.ChannelTelegram, Settings: db.ChannelSettings{"token": "t"}}, nil)
+		Expect(err).To(MatchError("telegram channels need the chat_id setting"))
+		_, err = New(db.Channel{Kind: db.ChannelSlack, Settings: db.ChannelSettings{"url": "x"}, Template: "{{.Rule"}, nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Notifier", func() {
+	var (
+		server   *httptest.Server
+		payloads chan map[string]string
+		bus      *services.EventBus
+		notifier *Notifier
+	)
+
+	BeforeEach(func() {
+		payloads = make(chan map[string]string, 10)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var payload map[string]string
+			json.NewDecoder(r.Body).Decode(&payload)
+			payloads <- payload
+		}))
+		store := &db.ChannelStoreMock{ListChannelsFunc: func() ([]db.Channel, error) {
+			return []db.Channel{
+				{ID: 1, TenantID: 1, Kind: db.ChannelSlack, Settings: db.ChannelSettings{"url": server.URL}},
+				{ID: 2, TenantID: 2, Kind: db.ChannelSlack, Settings: db.ChannelSettings{"url": server.URL}},
+			}, nil
+		}}
+		bus = services.NewEventBus(10)
+		notifier = NewNotifier(store, bus, server.Client())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should deliver alert events to the channels of the alert's tenant", func() {
+		notifier.Notify(context.Background(), services.Event{Type: services.EventAlert, MonitorID: 4, TenantID: 1, Alert: db.AlertOK, Data: "slow"})
+		Eventually(payloads).Should(Receive(Equal(map[string]string{"text": "RESOLVED: slow for monitor 4"})))
+		Consistently(payloads, 50*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("should deliver the alert events published on the bus", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go notifier.Run(ctx)
+		Eventually(func() bool {
+			bus.Publish(services.Event{Type: services.EventAlert, MonitorID: 4, TenantID: 2, Alert: db.AlertFiring, Data: "down"})
+			select {
+			case payload := <-payloads:
+				return payload["text"] == "FIRING: down for monitor 4"
+			case <-time.After(10 * time.Millisecond):
+				return false
+			}
+		}).Should(BeTrue())
+	})
+
+	Context("when deliveries fail", func() {
+		var (
+			failures int
+			requests chan string
+		)
+
+		BeforeEach(func() {
+			server.Close()
+			failures = 2
+			requests = make(chan string, 20)
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests <- r.URL.Path
+				if failures > 0 {
+					failures--
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+			notifier = NewNotifier(&db.ChannelStoreMock{ListChannelsFunc: func() ([]db.Channel, error) {
+				return []db.Channel{{ID: 1, TenantID: 1, Kind: db.ChannelSlack, Settings: db.ChannelSettings{"url": server.URL + "/ops"}}}, nil
+			}}, bus, server.Client())
+			notifier.Backoff = time.Millisecond
+		})
+
+		It("should retry with backoff until the channel accepts it", func() {
+			notifier.Notify(context.Background(), services.Event{Type: services.EventAlert, MonitorID: 4, TenantID: 1, Alert: db.AlertFiring, Data: "down"})
+			Eventually(requests).Should(HaveLen(3))
+		})
+
+		It("should give up after the configured attempts", func() {
+			failures = 10
+			notifier.Attempts = 2
+			notifier.Notify(context.Background(), services.Event{Type: services.EventAlert, MonitorID: 4, TenantID: 1, Alert: db.AlertFiring, Data: "down"})
+			Eventually(requests).Should(HaveLen(2))
+			Consistently(requests, 50*time.Millisecond).Should(HaveLen(2))
+		})
+	})
+
+	It("should keep notifying healthy channels while another one hangs", func() {
+		release := make(chan struct{})
+		defer close(release)
+		server.Close()
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/hang" {
+				<-release
+				return
+			}
+			var payload map[string]string
+			json.NewDecoder(r.Body).Decode(&payload)
+			payloads <- payload
+		}))
+		notifier = NewNotifier(&db.ChannelStoreMock{ListChannelsFunc: func() ([]db.Channel, error) {
+			return []db.Channel{
+				{ID: 1, TenantID: 1, Kind: db.ChannelSlack, Settings: db.ChannelSettings{"url": server.URL + "/hang"}},
+				{ID: 2, TenantID: 1, Kind: db.ChannelSlack, Settings: db.ChannelSettings{"url": server.URL + "/fast"}},
+			}, nil
+		}}, bus, server.Client())
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go notifier.Run(ctx)
+		Eventually(func() bool {
+			bus.Publish(services.Event{Type: services.EventAlert, MonitorID: 4, TenantID: 1, Alert: db.AlertFiring, Data: "down"})
+			select {
+			case payload := <-payloads:
+				return payload["text"] == "FIRING: down for monitor 4"
+			case <-time.After(10 * time.Millisecond):
+				return false
+			}
+		}).Should(BeTrue())
+		// The first alert is still being sent to the hanging channel.
+		bus.Publish(services.Event{Type: services.EventAlert, MonitorID: 5, TenantID: 1, Alert: db.AlertFiring, Data: "slow"})
+		Eventually(payloads).Should(Receive(HaveKeyWithValue("text", "FIRING: slow for monitor 5")))
+	})
+})
@@ -0,0 +1,440 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"snapp-task/db"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Alert is a rule's state for one monitor, as reported by the API.
+type Alert struct {
+	db.AlertState
+	RuleName string
+	// Silenced is set while a silence mutes the monitor's alerts.
+	Silenced bool
+}
+
+type alertKey struct {
+	ruleID    int64
+	monitorID int64
+}
+
+// silenceSweepInterval is how often Run looks for alerts whose silence has ended.
+const silenceSweepInterval = 10 * time.Second
+
+// Alerter turns check events into alerts. It keeps the rules, silences and alert states in memory and
+// writes their changes through to the store. Alerts that fire or resolve are published as alert events;
+// while a silence mutes the monitor the event is held back, and published once the silence ends unless
+// the alert changed back in the meantime.
+type Alerter struct {
+	store    db.AlertStore
+	events   *EventBus
+	mu       sync.Mutex
+	rules    []db.AlertRule
+	states   map[alertKey]*db.AlertState
+	silences []db.Silence
+	// unsent holds the alert events muted by a silence.
+	unsent map[alertKey]Event
+	// dropped holds the deleted monitors, so that a check still running when its monitor was deleted
+	// does not bring its alerts back. Monitor IDs are never reused.
+	dropped map[int64]bool
+	// saveMu orders the state writes, which run outside mu. It is taken while holding mu.
+	saveMu sync.Mutex
+}
+
+func NewAlerter(store db.AlertStore, events *EventBus) *Alerter {
+	return &Alerter{store: store, events: events, states: make(map[alertKey]*db.AlertState), unsent: make(map[alertKey]Event),
+		dropped: make(map[int64]bool)}
+}
+
+// Start loads the rules, alert states and silences from the store.
+func (a *Alerter) Start() error {
+	rules, err := a.store.ListAlertRules()
+	if err != nil {
+		return err
+	}
+	states, err := a.store.ListAlertStates()
+	if err != nil {
+		return err
+	}
+	silences, err := a.store.ListSilences()
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rules = rules
+	a.silences = silences
+	for _, state := range states {
+		a.states[alertKey{state.RuleID, state.MonitorID}] = &state
+	}
+	return nil
+}
+
+// Run evaluates the rules against every check event until ctx is cancelled, and publishes the alerts
+// held back by silences that have ended. When the bus drops the subscription for falling behind, Run
+// subscribes again and replays the events it missed.
+func (a *Alerter) Run(ctx context.Context) {
+	ticker := time.NewTicker(silenceSweepInterval)
+	defer ticker.Stop()
+	var lastID uint64
+	for {
+		sub, replay := a.events.Subscribe(lastID, func(event Event) bool {
+			return event.Type == EventCheck
+		})
+		for _, event := range replay {
+			a.Observe(event)
+			lastID = event.ID
+		}
+	receive:
+		for {
+			select {
+			case event, ok := <-sub.C:
+				if !ok {
+					break receive
+				}
+				a.Observe(event)
+				lastID = event.ID
+			case now := <-ticker.C:
+				a.publishUnsilenced(now)
+			case <-ctx.Done():
+				a.events.Unsubscribe(sub)
+				return
+			}
+		}
+	}
+}
+
+// Observe records the outcome of a check event for every rule that applies to its monitor.
+func (a *Alerter) Observe(event Event) {
+	if event.Type != EventCheck {
+		return
+	}
+	tenantID := eventTenant(event)
+	a.mu.Lock()
+	if a.dropped[event.MonitorID] {
+		a.mu.Unlock()
+		return
+	}
+	var published []Event
+	var changedStates []db.AlertState
+	for _, rule := range a.rules {
+		if rule.TenantID != tenantID || (rule.MonitorID != 0 && rule.MonitorID != event.MonitorID) {
+			continue
+		}
+		key := alertKey{rule.ID, event.MonitorID}
+		state, ok := a.states[key]
+		if !ok {
+			state = &db.AlertState{RuleID: rule.ID, MonitorID: event.MonitorID, TenantID: tenantID, Status: db.AlertOK, Since: event.Time}
+			a.states[key] = state
+		}
+		bad := event.Error != ""
+		if rule.On == db.AlertOnMatch {
+			bad = event.Matched
+		}
+		previous := *state
+		changed := advanceAlert(rule, state, bad, event.Time)
+		if !ok || state.History != previous.History || state.Status != previous.Status || state.Flapping != previous.Flapping {
+			changedStates = append(changedStates, *state)
+		}
+		unsent, pending := a.unsent[key]
+		silenced := a.silenced(tenantID, event.MonitorID, event.Time)
+		alert := Event{Type: EventAlert, MonitorID: event.MonitorID, TenantID: tenantID, URL: event.URL,
+			Time: event.Time, RuleID: rule.ID, Alert: state.Status, Data: rule.Name}
+		switch {
+		case changed && pending:
+			// The alert changed back before its last change was published, so neither needs to be.
+			delete(a.unsent, key)
+		case changed && silenced:
+			a.unsent[key] = alert
+		case changed:
+			published = append(published, alert)
+		case pending && !silenced:
+			published = append(published, unsent)
+			delete(a.unsent, key)
+		}
+	}
+	a.saveMu.Lock()
+	a.mu.Unlock()
+	for _, state := range changedStates {
+		if err := a.store.SaveAlertState(state); err != nil {
+			log.Printf("Failed to save alert state: %v\n", err)
+		}
+	}
+	a.saveMu.Unlock()
+	for _, event := range published {
+		a.events.Publish(event)
+	}
+}
+
+// publishUnsilenced publishes the alert events held back by silences that are no longer active at now.
+func (a *Alerter) publishUnsilenced(now time.Time) {
+	a.mu.Lock()
+	var published []Event
+	for key, event := range a.unsent {
+		if !a.silenced(event.TenantID, event.MonitorID, now) {
+			published = append(published, event)
+			delete(a.unsent, key)
+		}
+	}
+	a.mu.Unlock()
+	sort.Slice(published, func(i, j int) bool {
+		return published[i].Time.Before(published[j].Time)
+	})
+	for _, event := range published {
+		a.events.Publish(event)
+	}
+}
+
+// eventTenant returns the event's tenant, counting events without one as the default tenant's.
+func eventTenant(event Event) int64 {
+	if event.TenantID == 0 {
+		return db.DefaultTenantID
+	}
+	return event.TenantID
+}
+
+// advanceAlert records a check outcome and reports whether it fired or resolved the alert. An alert
+// fires once rule.Threshold of the last rule.Window checks were bad and resolves after
+// rule.ResolveAfter good checks in a row. While the outcomes keep changing, the monitor is flapping and
+// the alert keeps its status; it stops flapping once the changes drop to half the threshold, so a
+// monitor hovering around it does not toggle in and out.
+func advanceAlert(rule db.AlertRule, state *db.AlertState, bad bool, now time.Time) bool {
+	outcome := "0"
+	if bad {
+		outcome = "1"
+	}
+	state.History += outcome
+	if len(state.History) > rule.Window {
+		state.History = state.History[len(state.History)-rule.Window:]
+	}
+	if bad {
+		state.GoodStreak = 0
+	} else {
+		state.GoodStreak++
+	}
+	state.UpdatedAt = now
+	if rule.FlapThreshold > 0 {
+		changes := 0
+		for i := 1; i < len(state.History); i++ {
+			if state.History[i] != state.History[i-1] {
+				changes++
+			}
+		}
+		if changes >= rule.FlapThreshold {
+			state.Flapping = true
+		} else if changes <= rule.FlapThreshold/2 {
+			state.Flapping = false
+		}
+		if state.Flapping {
+			return false
+		}
+	}
+	switch {
+	case state.Status == db.AlertOK && strings.Count(state.History, "1") >= rule.Threshold:
+		state.Status = db.AlertFiring
+	case state.Status == db.AlertFiring && state.GoodStreak >= rule.ResolveAfter:
+		state.Status = db.AlertOK
+	default:
+		return false
+	}
+	state.Since = now
+	return true
+}
+
+func (a *Alerter) silenced(tenantID, monitorID int64, now time.Time) bool {
+	for _, silence := range a.silences {
+		if silence.TenantID == tenantID && (silence.MonitorID == 0 || silence.MonitorID == monitorID) && silence.Active(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateRule stores and starts evaluating rule. A rule without a tenant belongs to the default tenant.
+func (a *Alerter) CreateRule(rule db.AlertRule) (db.AlertRule, error) {
+	if rule.TenantID == 0 {
+		rule.TenantID = db.DefaultTenantID
+	}
+	rule, err := a.store.CreateAlertRule(rule)
+	if err != nil {
+		return rule, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rules = append(a.rules, rule)
+	return rule, nil
+}
+
+// Rules returns the rules of tenantID. Tenant 0 sees every rule.
+func (a *Alerter) Rules(tenantID int64) []db.AlertRule {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	rules := []db.AlertRule{}
+	for _, rule := range a.rules {
+		if tenantID == 0 || rule.TenantID == tenantID {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// DeleteRule deletes the rule and its alerts. Rules of other tenants are reported as db.ErrNotFound.
+func (a *Alerter) DeleteRule(tenantID, id int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, rule := range a.rules {
+		if rule.ID != id || (tenantID != 0 && rule.TenantID != tenantID) {
+			continue
+		}
+		// Wait for the state writes in flight, so none of them brings a deleted state back.
+		a.saveMu.Lock()
+		err := a.store.DeleteAlertRule(id)
+		a.saveMu.Unlock()
+		if err != nil {
+			return err
+		}
+		a.rules = append(a.rules[:i], a.rules[i+1:]...)
+		for key := range a.states {
+			if key.ruleID == id {
+				delete(a.states, key)
+				delete(a.unsent, key)
+			}
+		}
+		return nil
+	}
+	return db.ErrNotFound
+}
+
+// DropMonitor deletes the alerts of a deleted monitor, along with the events held back for them. Checks
+// of the monitor observed afterwards are ignored.
+func (a *Alerter) DropMonitor(monitorID int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.saveMu.Lock()
+	err := a.store.DeleteAlertStates(monitorID)
+	a.saveMu.Unlock()
+	if err != nil {
+		return err
+	}
+	a.dropped[monitorID] = true
+	for key := range a.states {
+		if key.monitorID == monitorID {
+			delete(a.states, key)
+			delete(a.unsent, key)
+		}
+	}
+	return nil
+}
+
+// Alerts returns the alerts of tenantID's rules, firing or not, ordered by rule and monitor.
+func (a *Alerter) Alerts(tenantID int64) []Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	alerts := []Alert{}
+	for _, rule := range a.rules {
+		if tenantID != 0 && rule.TenantID != tenantID {
+			continue
+		}
+		var ruleAlerts []Alert
+		for key, state := range a.states {
+			if key.ruleID == rule.ID {
+				ruleAlerts = append(ruleAlerts, Alert{AlertState: *state, RuleName: rule.Name, Silenced: a.silenced(state.TenantID, state.MonitorID, now)})
+			}
+		}
+		sort.Slice(ruleAlerts, func(i, j int) bool {
+			return ruleAlerts[i].MonitorID < ruleAlerts[j].MonitorID
+		})
+		alerts = append(alerts, ruleAlerts...)
+	}
+	return alerts
+}
+
+// CreateSilence stores silence. A silence without a tenant belongs to the default tenant.
+func (a *Alerter) CreateSilence(silence db.Silence) (db.Silence, error) {
+	if silence.TenantID == 0 {
+		silence.TenantID = db.DefaultTenantID
+	}
+	silence, err := a.store.CreateSilence(silence)
+	if err != nil {
+		return silence, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.silences = append(a.silences, silence)
+	return silence, nil
+}
+
+// Silences returns the silences of tenantID, including expired ones until PruneSilences deletes them.
+// Tenant 0 sees every silence.
+func (a *Alerter) Silences(tenantID int64) []db.Silence {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	silences := []db.Silence{}
+	for _, silence := range a.silences {
+		if tenantID == 0 || silence.TenantID == tenantID {
+			silences = append(silences, silence)
+		}
+	}
+	return silences
+}
+
+// DeleteSilence ends a silence early by deleting it, and publishes the alerts it held back. Silences of
+// other tenants are reported as db.ErrNotFound.
+func (a *Alerter) DeleteSilence(tenantID, id int64) error {
+	if err := a.deleteSilence(tenantID, id); err != nil {
+		return err
+	}
+	a.publishUnsilenced(time.Now())
+	return nil
+}
+
+// PruneSilences deletes the silences that ended before now, publishes the alerts they held back and
+// returns how many were deleted.
+func (a *Alerter) PruneSilences(now time.Time) (int, error) {
+	pruned, err := a.pruneSilences(now)
+	a.publishUnsilenced(now)
+	return pruned, err
+}
+
+func (a *Alerter) pruneSilences(now time.Time) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	kept := a.silences[:0]
+	pruned := 0
+	var err error
+	for _, silence := range a.silences {
+		if err == nil && silence.EndsAt.Before(now) {
+			if err = a.store.DeleteSilence(silence.ID); err == nil || errors.Is(err, db.ErrNotFound) {
+				err = nil
+				pruned++
+				continue
+			}
+		}
+		kept = append(kept, silence)
+	}
+	a.silences = kept
+	return pruned, err
+}
+
+func (a *Alerter) deleteSilence(tenantID, id int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, silence := range a.silences {
+		if silence.ID != id || (tenantID != 0 && silence.TenantID != tenantID) {
+			continue
+		}
+		if err := a.store.DeleteSilence(id); err != nil {
+			return err
+		}
+		a.silences = append(a.silences[:i], a.silences[i+1:]...)
+		return nil
+	}
+	return db.ErrNotFound
+}
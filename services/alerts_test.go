@@ -0,0 +1,224 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"snapp-task/db"
+	. "snapp-task/services"
+	"time"
+)
+
+var _ = Describe("Alerter", func() {
+	var (
+		store   *db.AlertStoreMock
+		bus     *EventBus
+		alerter *Alerter
+		alerts  *Subscription
+		rule    db.AlertRule
+		now     time.Time
+	)
+
+	check := func(failed bool) {
+		now = now.Add(time.Minute)
+		event := Event{Type: EventCheck, MonitorID: 1, TenantID: db.DefaultTenantID, Time: now}
+		if failed {
+			event.Error = "down"
+		}
+		alerter.Observe(event)
+	}
+
+	checks := func(outcomes string) {
+		for _, outcome := range outcomes {
+			check(outcome == '1')
+		}
+	}
+
+	BeforeEach(func() {
+		var rules []db.AlertRule
+		var silences []db.Silence
+		store = &db.AlertStoreMock{
+			CreateAlertRuleFunc: func(rule db.AlertRule) (db.AlertRule, error) {
+				rule.ID = int64(len(rules) + 1)
+				rules = append(rules, rule)
+				return rule, nil
+			},
+			ListAlertRulesFunc:  func() ([]db.AlertRule, error) { return rules, nil },
+			ListAlertStatesFunc: func() ([]db.AlertState, error) { return nil, nil },
+			ListSilencesFunc:    func() ([]db.Silence, error) { return silences, nil },
+			SaveAlertStateFunc:  func(state db.AlertState) error { return nil },
+			DeleteAlertRuleFunc: func(id int64) error { return nil },
+			CreateSilenceFunc: func(silence db.Silence) (db.Silence, error) {
+				silence.ID = int64(len(silences) + 1)
+				silences = append(silences, silence)
+				return silence, nil
+			},
+		}
+		bus = NewEventBus(100)
+		alerts, _ = bus.Subscribe(0, func(event Event) bool { return event.Type == EventAlert })
+		alerter = NewAlerter(store, bus)
+		Expect(alerter.Start()).To(Succeed())
+		now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		rule = db.AlertRule{Name: "down", On: db.AlertOnFailure, Threshold: 2, Window: 3, ResolveAfter: 2}
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		rule, err = alerter.CreateRule(rule)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should fire once threshold of the window's checks failed", func() {
+		checks("101")
+		Expect(alerts.C).To(Receive(And(HaveField("Alert", db.AlertFiring), HaveField("RuleID", rule.ID), HaveField("Data", "down"))))
+		Expect(alerter.Alerts(0)).To(ConsistOf(HaveField("Status", db.AlertFiring)))
+		Expect(store.SaveAlertStateCalls()).To(HaveLen(3))
+	})
+
+	It("should not fire when the failures fall out of the window", func() {
+		checks("1001")
+		Expect(alerts.C).NotTo(Receive())
+		Expect(alerter.Alerts(db.DefaultTenantID)[0].History).To(Equal("001"))
+	})
+
+	It("should resolve after resolve_after good checks in a row", func() {
+		checks("11")
+		Expect(alerts.C).To(Receive(HaveField("Alert", db.AlertFiring)))
+		checks("010")
+		Expect(alerts.C).NotTo(Receive())
+		check(false)
+		Expect(alerts.C).To(Receive(HaveField("Alert", db.AlertOK)))
+		check(true)
+		Expect(alerts.C).NotTo(Receive())
+	})
+
+	It("should only count matches for match rules", func() {
+		rule.On = db.AlertOnMatch
+		rule, _ = alerter.CreateRule(rule)
+		alerter.Observe(Event{Type: EventCheck, MonitorID: 1, Matched: true, Time: now})
+		alerter.Observe(Event{Type: EventCheck, MonitorID: 1, Matched: true, Time: now})
+		Expect(alerts.C).To(Receive(HaveField("RuleID", rule.ID)))
+		Expect(alerts.C).NotTo(Receive())
+	})
+
+	Context("with flap detection", func() {
+		BeforeEach(func() {
+			rule = db.AlertRule{Name: "flaky", On: db.AlertOnFailure, Threshold: 2, Window: 6, ResolveAfter: 2, FlapThreshold: 4}
+		})
+
+		It("should hold the status while the monitor flaps", func() {
+			checks("11")
+			Expect(alerts.C).To(Receive(HaveField("Alert", db.AlertFiring)))
+			checks("00")
+			Expect(alerts.C).To(Receive(HaveField("Alert", db.AlertOK)))
+			checks("1")
+			Expect(alerts.C).To(Receive(HaveField("Alert", db.AlertFiring)))
+			checks("0100")
+			Expect(alerts.C).NotTo(Receive())
+			Expect(alerter.Alerts(0)[0].Flapping).To(BeTrue())
+			Expect(alerter.Alerts(0)[0].Status).To(Equal(db.AlertFiring))
+			checks("00")
+			Expect(alerts.C).To(Receive(HaveField("Alert", db.AlertOK)))
+			Expect(alerter.Alerts(0)[0].Flapping).To(BeFalse())
+		})
+	})
+
+	Context("with an active silence", func() {
+		var silence db.Silence
+
+		JustBeforeEach(func() {
+			var err error
+			silence, err = alerter.CreateSilence(db.Silence{StartsAt: now, EndsAt: now.Add(time.Hour)})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should track the alert without publishing it", func() {
+			checks("11")
+			Expect(alerts.C).NotTo(Receive())
+			Expect(alerter.Alerts(0)).To(ConsistOf(HaveField("Status", db.AlertFiring)))
+		})
+
+		It("should publish the alert once the silence has ended", func() {
+			checks("11")
+			now = now.Add(time.Hour)
+			check(true)
+			Expect(alerts.C).To(Receive(And(HaveField("Alert", db.AlertFiring), HaveField("Time", now.Add(-time.Hour-time.Minute)))))
+		})
+
+		It("should publish the alert when the silence is deleted", func() {
+			store.DeleteSilenceFunc = func(id int64) error { return nil }
+			checks("11")
+			Expect(alerter.DeleteSilence(0, silence.ID)).To(Succeed())
+			Expect(alerts.C).To(Receive(HaveField("Alert", db.AlertFiring)))
+		})
+
+		It("should not publish an alert that resolved during the silence", func() {
+			store.DeleteSilenceFunc = func(id int64) error { return nil }
+			checks("1100")
+			Expect(alerter.DeleteSilence(0, silence.ID)).To(Succeed())
+			check(false)
+			Expect(alerts.C).NotTo(Receive())
+		})
+		It("should delete the silence once it has ended and publish what it held back", func() {
+			store.DeleteSilenceFunc = func(id int64) error { return nil }
+			checks("11")
+			Expect(alerter.PruneSilences(now)).To(Equal(0))
+			Expect(alerter.PruneSilences(now.Add(time.Hour))).To(Equal(1))
+			Expect(store.DeleteSilenceCalls()).To(ConsistOf(HaveField("ID", silence.ID)))
+			Expect(alerter.Silences(0)).To(BeEmpty())
+			Expect(alerts.C).To(Receive(HaveField("Alert", db.AlertFiring)))
+		})
+	})
+
+	It("should drop the alerts of a deleted monitor", func() {
+		store.DeleteAlertStatesFunc = func(monitorID int64) error { return nil }
+		checks("11")
+		Expect(alerter.Alerts(0)).To(HaveLen(1))
+		Expect(alerter.DropMonitor(1)).To(Succeed())
+		Expect(store.DeleteAlertStatesCalls()).To(ConsistOf(HaveField("MonitorID", int64(1))))
+		Expect(alerter.Alerts(0)).To(BeEmpty())
+	})
+
+	It("should ignore the checks of a monitor that finish after it was deleted", func() {
+		store.DeleteAlertStatesFunc = func(monitorID int64) error { return nil }
+		Expect(alerter.DropMonitor(1)).To(Succeed())
+		checks("111")
+		Expect(alerter.Alerts(0)).To(BeEmpty())
+		Expect(store.SaveAlertStateCalls()).To(BeEmpty())
+		Expect(alerts.C).NotTo(Receive())
+	})
+
+	It("should only save alert states that changed", func() {
+		checks("0000")
+		Expect(store.SaveAlertStateCalls()).To(HaveLen(3))
+		check(true)
+		Expect(store.SaveAlertStateCalls()).To(HaveLen(4))
+	})
+
+	It("should scope rules to their tenant", func() {
+		Expect(alerter.Rules(2)).To(BeEmpty())
+		Expect(alerter.DeleteRule(2, rule.ID)).To(MatchError(db.ErrNotFound))
+		alerter.Observe(Event{Type: EventCheck, MonitorID: 9, TenantID: 2, Error: "down", Time: now})
+		Expect(alerter.Alerts(0)).To(BeEmpty())
+		Expect(alerter.DeleteRule(db.DefaultTenantID, rule.ID)).To(Succeed())
+		Expect(alerter.Rules(0)).To(BeEmpty())
+	})
+
+	It("should restore its rules from the store", func() {
+		restarted := NewAlerter(store, bus)
+		Expect(restarted.Start()).To(Succeed())
+		Expect(restarted.Rules(0)).To(Equal([]db.AlertRule{rule}))
+	})
+
+	It("should evaluate the check events published on the bus", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go alerter.Run(ctx)
+		Eventually(func() int {
+			bus.PublishCheck(db.Monitor{ID: 1}, false, 0, errors.New("down"))
+			return len(alerter.Alerts(0))
+		}).ShouldNot(BeZero())
+		Eventually(alerts.C).Should(Receive(HaveField("Alert", db.AlertFiring)))
+	})
+})
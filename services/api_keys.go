@@ -0,0 +1,89 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"snapp-task/db"
+	"strings"
+)
+
+const apiKeyPrefix = "snk_"
+
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// HashAPIKey returns the digest stored in place of a key. Keys are 256 random bits, so a plain
+// sha256 is enough to make a leaked table useless.
+func HashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+type APIKeys struct {
+	store db.KeyStore
+}
+
+func NewAPIKeys(store db.KeyStore) *APIKeys {
+	return &APIKeys{store: store}
+}
+
+// Create issues a new key for tenantID and returns it along with its secret, which is only available here.
+// Tenant 0 issues a global key.
+func (k *APIKeys) Create(name string, scope db.Scope, tenantID int64) (db.APIKey, string, error) {
+	if !scope.Valid() {
+		return db.APIKey{}, "", fmt.Errorf("invalid scope %q", scope)
+	}
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return db.APIKey{}, "", err
+	}
+	secret := apiKeyPrefix + hex.EncodeToString(random)
+	key, err := k.store.CreateAPIKey(db.APIKey{Name: name, Prefix: secret[:len(apiKeyPrefix)+8], Scope: scope, TenantID: tenantID}, HashAPIKey(secret))
+	if err != nil {
+		return key, "", err
+	}
+	return key, secret, nil
+}
+
+// Authenticate returns the key for secret, or ErrInvalidAPIKey if it is unknown or revoked.
+func (k *APIKeys) Authenticate(secret string) (db.APIKey, error) {
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		return db.APIKey{}, ErrInvalidAPIKey
+	}
+	key, err := k.store.GetAPIKeyByHash(HashAPIKey(secret))
+	if errors.Is(err, db.ErrNotFound) || (err == nil && key.RevokedAt != nil) {
+		return db.APIKey{}, ErrInvalidAPIKey
+	}
+	return key, err
+}
+
+// List returns the keys of tenantID. Tenant 0 sees every key.
+func (k *APIKeys) List(tenantID int64) ([]db.APIKey, error) {
+	keys, err := k.store.ListAPIKeys()
+	if err != nil || tenantID == 0 {
+		return keys, err
+	}
+	var owned []db.APIKey
+	for _, key := range keys {
+		if key.TenantID == tenantID {
+			owned = append(owned, key)
+		}
+	}
+	return owned, nil
+}
+
+func (k *APIKeys) Revoke(tenantID int64, id int64) error {
+	if tenantID != 0 {
+		keys, err := k.List(tenantID)
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(keys, func(key db.APIKey) bool { return key.ID == id }) {
+			return db.ErrNotFound
+		}
+	}
+	return k.store.RevokeAPIKey(id)
+}
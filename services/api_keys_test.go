@@ -0,0 +1,79 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"snapp-task/db"
+	. "snapp-task/services"
+	"strings"
+	"time"
+)
+
+var _ = Describe("APIKeys", func() {
+	var (
+		stored map[string]db.APIKey
+		keys   *APIKeys
+	)
+
+	BeforeEach(func() {
+		stored = make(map[string]db.APIKey)
+		keys = NewAPIKeys(&db.KeyStoreMock{
+			CreateAPIKeyFunc: func(key db.APIKey, hash string) (db.APIKey, error) {
+				key.ID = int64(len(stored) + 1)
+				stored[hash] = key
+				return key, nil
+			},
+			GetAPIKeyByHashFunc: func(hash string) (db.APIKey, error) {
+				key, ok := stored[hash]
+				if !ok {
+					return key, db.ErrNotFound
+				}
+				return key, nil
+			},
+		})
+	})
+
+	It("should store only the hash of a new key", func() {
+		key, secret, err := keys.Create("ci", db.ScopeWrite, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stored).To(HaveKey(HashAPIKey(secret)))
+		Expect(stored).NotTo(HaveKey(secret))
+		Expect(strings.HasPrefix(secret, key.Prefix)).To(BeTrue())
+	})
+
+	It("should authenticate a valid secret", func() {
+		created, secret, err := keys.Create("ci", db.ScopeRead, 0)
+		Expect(err).NotTo(HaveOccurred())
+		key, err := keys.Authenticate(secret)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key).To(Equal(created))
+	})
+
+	It("should reject unknown and revoked secrets", func() {
+		_, secret, err := keys.Create("ci", db.ScopeRead, 0)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = keys.Authenticate(secret + "0")
+		Expect(err).To(MatchError(ErrInvalidAPIKey))
+		_, err = keys.Authenticate("")
+		Expect(err).To(MatchError(ErrInvalidAPIKey))
+
+		revoked := stored[HashAPIKey(secret)]
+		now := time.Now()
+		revoked.RevokedAt = &now
+		stored[HashAPIKey(secret)] = revoked
+		_, err = keys.Authenticate(secret)
+		Expect(err).To(MatchError(ErrInvalidAPIKey))
+	})
+
+	It("should reject unknown scopes", func() {
+		_, _, err := keys.Create("ci", db.Scope("root"), 0)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should let each scope include the ones below it", func() {
+		Expect(db.ScopeAdmin.Allows(db.ScopeWrite)).To(BeTrue())
+		Expect(db.ScopeWrite.Allows(db.ScopeRead)).To(BeTrue())
+		Expect(db.ScopeRead.Allows(db.ScopeWrite)).To(BeFalse())
+		Expect(db.ScopeWrite.Allows(db.ScopeAdmin)).To(BeFalse())
+	})
+})
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/net/html/charset"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var xmlEncoding = regexp.MustCompile(`^<\?xml[^>]*\sencoding=["']([A-Za-z0-9._:-]+)["']`)
+
+var byteOrderMarks = []struct {
+	bom     []byte
+	charset string
+}{
+	{[]byte{0xEF, 0xBB, 0xBF}, "utf-8"},
+	{[]byte{0xFE, 0xFF}, "utf-16be"},
+	{[]byte{0xFF, 0xFE}, "utf-16le"},
+}
+
+// responseCharset picks the charset of a response from its byte order mark, the Content-Type charset
+// parameter, or the document's own declaration for HTML and XML. It returns "" when there is nothing
+// to go by, in which case the content is taken to be UTF-8.
+func responseCharset(content []byte, contentType string) (string, []byte) {
+	for _, mark := range byteOrderMarks {
+		if bytes.HasPrefix(content, mark.bom) {
+			return mark.charset, content[len(mark.bom):]
+		}
+	}
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	if label := params["charset"]; label != "" {
+		return label, content
+	}
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		// Without a meta tag DetermineEncoding guesses windows-1252, which valid UTF-8 is better read as.
+		if _, name, _ := charset.DetermineEncoding(content, ""); name != "utf-8" && !(name == "windows-1252" && utf8.Valid(content)) {
+			return name, content
+		}
+	case strings.HasSuffix(mediaType, "xml"):
+		if match := xmlEncoding.FindSubmatch(content); match != nil {
+			return string(match[1]), content
+		}
+	}
+	return "", content
+}
+
+// DecodeCharset converts content to UTF-8 from the charset the response declares.
+func DecodeCharset(content []byte, contentType string) ([]byte, error) {
+	label, content := responseCharset(content, contentType)
+	if label == "" {
+		return content, nil
+	}
+	encoding, name := charset.Lookup(label)
+	if encoding == nil {
+		return nil, fmt.Errorf("unsupported charset %q", label)
+	}
+	if name == "utf-8" {
+		return content, nil
+	}
+	decoded, err := encoding.NewDecoder().Bytes(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s response: %v", name, err)
+	}
+	return decoded, nil
+}
+
+// isNDJSON reports whether every non-empty line of content is a JSON value.
+func isNDJSON(content []byte) bool {
+	lines := 0
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return false
+		}
+		lines++
+	}
+	return lines > 0
+}
+
+// SniffContentType guesses the media type of a response that came without a Content-Type header.
+// On top of http.DetectContentType it recognizes JSON and NDJSON.
+func SniffContentType(content []byte) string {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(content, byteOrderMarks[0].bom))
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		if json.Valid(trimmed) {
+			return "application/json"
+		}
+		if isNDJSON(trimmed) {
+			return "application/x-ndjson"
+		}
+	}
+	// The sniffed charset is a guess, so only the media type is kept.
+	return MediaType(http.DetectContentType(content))
+}
@@ -0,0 +1,43 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "snapp-task/services"
+)
+
+var _ = Describe("Charsets", func() {
+	DescribeTable("should decode responses to UTF-8",
+		func(contentType string, content []byte, expected string) {
+			decoded, err := DecodeCharset(content, contentType)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(decoded)).To(Equal(expected))
+		},
+		Entry("windows-1256 from the header", "text/plain; charset=windows-1256", []byte{0xD3, 0xE1, 0xC7, 0xE3}, "سلام"),
+		Entry("ISO-8859-1 from the header", "text/plain; charset=ISO-8859-1", []byte("caf\xe9"), "café"),
+		Entry("UTF-8 without a declaration", "application/json", []byte(`"café"`), `"café"`),
+		Entry("a UTF-16 byte order mark", "text/plain", []byte{0xFF, 0xFE, 'o', 0, 'k', 0}, "ok"),
+		Entry("a UTF-8 byte order mark", "text/plain", []byte("\xEF\xBB\xBFok"), "ok"),
+		Entry("an XML declaration", "application/xml", []byte(`<?xml version="1.0" encoding="ISO-8859-1"?><a>caf`+"\xe9</a>"),
+			`<?xml version="1.0" encoding="ISO-8859-1"?><a>café</a>`),
+		Entry("an HTML meta tag", "text/html", []byte(`<meta charset="windows-1256"><p>`+"\xD3\xE1\xC7\xE3"),
+			`<meta charset="windows-1256"><p>سلام`),
+		Entry("HTML without a declaration", "text/html", []byte("<p>café</p>"), "<p>café</p>"),
+	)
+
+	It("should reject unknown charsets", func() {
+		_, err := DecodeCharset([]byte("ok"), "text/plain; charset=x-unknown")
+		Expect(err).To(MatchError(`unsupported charset "x-unknown"`))
+	})
+
+	DescribeTable("should sniff the media type of responses without a Content-Type",
+		func(content, expected string) {
+			Expect(SniffContentType([]byte(content))).To(Equal(expected))
+		},
+		Entry("JSON", ` {"status": "ok"}`, "application/json"),
+		Entry("NDJSON", "{\"a\": 1}\n{\"a\": 2}\n", "application/x-ndjson"),
+		Entry("HTML", "<!DOCTYPE html><p>ok</p>", "text/html"),
+		Entry("XML", `<?xml version="1.0"?><a/>`, "text/xml"),
+		Entry("text", "status: ok", "text/plain"),
+	)
+})
@@ -1,12 +1,12 @@
 package services
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"regexp"
 	"snapp-task/db"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -14,130 +14,293 @@ import (
 //go:generate moq -out=mocked_checker.go . UrlChecker
 type UrlChecker interface {
 	CheckData() error
+	// Check fetches the URL and reports everything it found. With dryRun nothing is saved or published.
+	Check(dryRun bool) CheckResult
 }
 
-type UrlCheckerFactory func(url, pattern string, db db.DB) UrlChecker
+type CheckResult struct {
+	StatusCode  int
+	ContentType string
+	// ContentEncoding lists the content codings that were undone, like "gzip"; "" for plain bodies.
+	ContentEncoding string
+	RawBytes        int64
+	DecodedBytes    int64
+	Matched         bool
+	// Data is what was matched, as it would be stored.
+	Data string
+	// Value is the number extracted by the monitor's ValuePath, if it has one.
+	Value *float64
+	// Steps reports the steps of a multi-step monitor that ran, in order.
+	Steps    []StepResult
+	Duration time.Duration
+	Err      error
+}
+
+// checkTimeout bounds a check, or each step of a multi-step check.
+const checkTimeout = 1 * time.Second
+
+type UrlCheckerFactory func(monitor db.Monitor, db db.DB) UrlChecker
 
 type UrlCheckerImpl struct {
-	Url     string
-	Pattern string
+	Monitor db.Monitor
 	Db      db.DB
+	// Client fetches the monitored URL; http.DefaultClient is used when nil.
+	Client *http.Client
+	// Events receives the check results, matches and state changes; nothing is published when nil.
+	Events *EventBus
+	// Handlers picks how responses are matched by media type; DefaultContentHandlers is used when nil.
+	Handlers *ContentHandlers
+	// MaxBodyBytes caps the response body before and after decompression; DefaultMaxBodyBytes is used
+	// when 0.
+	MaxBodyBytes int64
 }
 
-func NewUrlCheckerImpl(url, pattern string, db db.DB) UrlChecker {
-	return &UrlCheckerImpl{Url: url, Pattern: pattern, Db: db}
+func NewUrlCheckerImpl(monitor db.Monitor, db db.DB) UrlChecker {
+	return NewChecker(UrlCheckerImpl{Monitor: monitor, Db: db})
+}
+
+// NewChecker returns the checker for the monitor's kind: a StepChecker for monitors with steps, a
+// ProbeChecker for tcp, dns and tls URLs, and checker itself for HTTP monitors.
+func NewChecker(checker UrlCheckerImpl) UrlChecker {
+	if len(checker.Monitor.Steps) > 0 {
+		return &StepChecker{UrlCheckerImpl: checker}
+	}
+	if IsProbeURL(checker.Monitor.URL) {
+		return &ProbeChecker{UrlCheckerImpl: checker}
+	}
+	return &checker
 }
 
 func (uc *UrlCheckerImpl) CheckData() error {
-	resultChan := make(chan error)
-	timeout := 1 * time.Second
+	return uc.Check(false).Err
+}
+
+func (uc *UrlCheckerImpl) Check(dryRun bool) CheckResult {
+	return uc.runCheck(dryRun, checkTimeout, uc.checkData)
+}
+
+// runCheck runs check, giving up after timeout, and publishes the result unless it is a dry run.
+func (uc *UrlCheckerImpl) runCheck(dryRun bool, timeout time.Duration, check func(dryRun bool) CheckResult) CheckResult {
+	resultChan := make(chan CheckResult, 1)
+	startedAt := time.Now()
 
 	go func() {
-		resultChan <- uc.checkData()
+		resultChan <- check(dryRun)
 	}()
 
+	var result CheckResult
 	select {
-	case err := <-resultChan:
-		return err
+	case result = <-resultChan:
 	case <-time.After(timeout):
-		return fmt.Errorf("request timed out after %v", timeout)
+		result.Err = fmt.Errorf("request timed out after %v", timeout)
 	}
+	result.Duration = time.Since(startedAt)
+	if uc.Events != nil && !dryRun {
+		uc.Events.PublishCheck(uc.Monitor, result.Matched, result.Duration, result.Err)
+	}
+	return result
 }
 
-func (uc *UrlCheckerImpl) checkData() error {
-	resp, err := http.Get(uc.Url)
+func (uc *UrlCheckerImpl) checkData(dryRun bool) CheckResult {
+	var result CheckResult
+	request, err := http.NewRequest(http.MethodGet, uc.Monitor.URL, nil)
 	if err != nil {
-		return fmt.Errorf("failed to fetch data from URL: %v", err)
+		result.Err = fmt.Errorf("failed to fetch data from URL: %v", err)
+		return result
 	}
-	defer resp.Body.Close()
+	response, err := uc.fetch(uc.client(), request, &result)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	uc.match(&result, response, dryRun)
+	return result
+}
+
+func (uc *UrlCheckerImpl) client() *http.Client {
+	if uc.Client == nil {
+		return http.DefaultClient
+	}
+	return uc.Client
+}
 
-	body, err := io.ReadAll(resp.Body)
+// fetchedResponse is a response whose body has been read and decoded to UTF-8.
+type fetchedResponse struct {
+	status      int
+	header      http.Header
+	contentType string
+	body        []byte
+	elapsed     time.Duration
+}
+
+// fetch sends request and reads the response, filling in the result's status, content type and sizes.
+func (uc *UrlCheckerImpl) fetch(client *http.Client, request *http.Request, result *CheckResult) (*fetchedResponse, error) {
+	startedAt := time.Now()
+	request.Header.Set("Accept-Encoding", acceptEncoding)
+	resp, err := client.Do(request)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %v", err)
+		return nil, fmt.Errorf("failed to fetch data from URL: %v", err)
 	}
+	defer resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+	result.ContentType = resp.Header.Get("Content-Type")
 
-	contentType := resp.Header.Get("Content-Type")
-	matchedData, err := uc.findMatch(body, contentType)
+	limit := uc.MaxBodyBytes
+	if limit <= 0 {
+		limit = DefaultMaxBodyBytes
+	}
+	body, err := readLimited(resp.Body, limit)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	result.RawBytes = int64(len(body))
+	if body, result.ContentEncoding, err = DecodeBody(body, resp.Header.Get("Content-Encoding"), limit); err != nil {
+		return nil, err
 	}
-	if matchedData == "" {
-		return nil
+	result.DecodedBytes = int64(len(body))
+	if result.ContentType == "" {
+		result.ContentType = SniffContentType(body)
 	}
-	return uc.Db.SaveData(uc.Url, uc.Pattern, matchedData)
+	if body, err = DecodeCharset(body, result.ContentType); err != nil {
+		return nil, err
+	}
+	return &fetchedResponse{status: resp.StatusCode, header: resp.Header, contentType: result.ContentType, body: body,
+		elapsed: time.Since(startedAt)}, nil
 }
 
-func (uc *UrlCheckerImpl) findMatch(content []byte, contentType string) (string, error) {
-	isRegex := uc.isRegexPattern(uc.Pattern)
-	var regex *regexp.Regexp
-	var err error
-
-	if isRegex {
-		regex, err = regexp.Compile(uc.Pattern)
-		if err != nil {
-			return "", fmt.Errorf("invalid regex pattern: %v", err)
+// match checks the response against the monitor's rule, value path and pattern, and records a match.
+func (uc *UrlCheckerImpl) match(result *CheckResult, response *fetchedResponse, dryRun bool) {
+	ruleHolds := true
+	if uc.Monitor.Rule != "" {
+		input := RuleInput{Status: response.status, Headers: response.header, ContentType: response.contentType, Body: response.body,
+			Duration: response.elapsed}
+		var err error
+		if ruleHolds, err = uc.ruleHolds(input); err != nil {
+			result.Err = err
+			return
 		}
 	}
+	if uc.Monitor.ValuePath != "" {
+		uc.checkValue(result, response.body, ruleHolds, dryRun)
+		return
+	}
+	if !ruleHolds {
+		return
+	}
+	if uc.Monitor.Pattern == "" {
+		// A rule on its own, or the last step of a multi-step monitor, stores the whole body.
+		uc.recordMatch(result, string(response.body), dryRun)
+		return
+	}
+	matchedData, err := uc.findMatch(response.body, response.contentType)
+	if err != nil {
+		result.Err = err
+		return
+	}
+	if matchedData != "" {
+		uc.recordMatch(result, matchedData, dryRun)
+	}
+}
 
-	matched := false
-	var matchedData string
+func (uc *UrlCheckerImpl) ruleHolds(input RuleInput) (bool, error) {
+	rule, err := CompileRule(uc.Monitor.Rule)
+	if err != nil {
+		return false, fmt.Errorf("invalid rule: %v", err)
+	}
+	return rule.Evaluate(input)
+}
 
-	if strings.Contains(contentType, "application/json") {
-		var jsonData interface{}
-		err = json.Unmarshal(content, &jsonData)
-		if err != nil {
-			return "", fmt.Errorf("failed to parse JSON response: %v", err)
+// checkValue records the number at the monitor's ValuePath and matches when the monitor's condition and
+// rule, whichever it has, hold. The condition is evaluated before the value is saved, so change
+// conditions compare against the value of the previous check.
+func (uc *UrlCheckerImpl) checkValue(result *CheckResult, content []byte, ruleHolds bool, dryRun bool) {
+	value, err := ExtractValue(content, MediaType(result.ContentType), uc.Monitor.ValuePath)
+	if err != nil {
+		result.Err = err
+		return
+	}
+	result.Value = &value
+	matched := ruleHolds && (uc.Monitor.Condition != "" || uc.Monitor.Rule != "")
+	if matched && uc.Monitor.Condition != "" {
+		if matched, err = uc.conditionHolds(value); err != nil {
+			result.Err = err
+			return
 		}
-
-		matched, matchedData = uc.matchFoundInJSON(jsonData, uc.Pattern, regex)
-	} else {
-		responseString := string(content)
-		if isRegex {
-			if regex.MatchString(responseString) {
-				matched = true
-				matchedData = responseString
-			}
-		} else {
-			if strings.Contains(responseString, uc.Pattern) {
-				matched = true
-				matchedData = responseString
-			}
+	}
+	if !dryRun {
+		saveErr := uc.Db.SaveValue(db.MonitorValue{MonitorID: uc.Monitor.ID, TenantID: uc.Monitor.TenantID, Value: value, RecordedAt: time.Now()})
+		if saveErr != nil {
+			result.Err = fmt.Errorf("failed to record value: %v", saveErr)
+			return
 		}
 	}
 	if matched {
-		return matchedData, nil
+		uc.recordMatch(result, strconv.FormatFloat(value, 'f', -1, 64), dryRun)
 	}
-	return "", nil
 }
 
-func (uc *UrlCheckerImpl) isRegexPattern(pattern string) bool {
-	return strings.HasPrefix(pattern, "^") || strings.HasSuffix(pattern, "$") || strings.Contains(pattern, ".*")
+func (uc *UrlCheckerImpl) conditionHolds(value float64) (bool, error) {
+	condition, err := ParseCondition(uc.Monitor.Condition)
+	if err != nil {
+		return false, fmt.Errorf("invalid condition: %v", err)
+	}
+	var previous *float64
+	if condition.NeedsPrevious() {
+		last, err := uc.Db.LastValue(uc.Monitor.ID)
+		if err == nil {
+			previous = &last.Value
+		} else if !errors.Is(err, db.ErrNotFound) {
+			return false, fmt.Errorf("failed to read the previous value: %v", err)
+		}
+	}
+	return condition.Holds(value, previous), nil
 }
 
-func (uc *UrlCheckerImpl) matchFoundInJSON(data interface{}, pattern string, regex *regexp.Regexp) (bool, string) {
-	switch v := data.(type) {
-	case map[string]interface{}:
-		for _, value := range v {
-			if matched, matchedData := uc.matchFoundInJSON(value, pattern, regex); matched {
-				return true, matchedData
-			}
-		}
-	case []interface{}:
-		for _, item := range v {
-			if matched, matchedData := uc.matchFoundInJSON(item, pattern, regex); matched {
-				return true, matchedData
-			}
-		}
-	case string:
-		if regex != nil {
-			if regex.MatchString(v) {
-				return true, v
-			}
-		} else {
-			if strings.Contains(v, pattern) {
-				return true, v
-			}
-		}
+// recordMatch marks the result as matched and, unless it is a dry run, saves and publishes the match.
+func (uc *UrlCheckerImpl) recordMatch(result *CheckResult, matchedData string, dryRun bool) {
+	result.Matched = true
+	result.Data = matchedData
+	if dryRun {
+		return
+	}
+	if result.Err = uc.Db.SaveData(db.Match{MonitorID: uc.Monitor.ID, TenantID: uc.Monitor.TenantID, URL: uc.Monitor.URL, Pattern: uc.Monitor.Pattern, Data: matchedData, Dedup: uc.Monitor.Dedup}); result.Err != nil {
+		return
+	}
+	if uc.Events != nil {
+		uc.Events.Publish(Event{Type: EventMatch, MonitorID: uc.Monitor.ID, TenantID: uc.Monitor.TenantID, URL: uc.Monitor.URL, Data: matchedData})
 	}
-	return false, ""
+}
+
+func (uc *UrlCheckerImpl) findMatch(content []byte, contentType string) (string, error) {
+	match, err := uc.textMatcher()
+	if err != nil {
+		return "", err
+	}
+	mediaType := MediaType(contentType)
+	if uc.Monitor.Selector != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return "", fmt.Errorf("selector requires an HTML response, got %q", contentType)
+	}
+	handlers := uc.Handlers
+	if handlers == nil {
+		handlers = DefaultContentHandlers
+	}
+	return handlers.Lookup(mediaType).Match(content, uc.Monitor, match)
+}
+
+func (uc *UrlCheckerImpl) textMatcher() (TextMatcher, error) {
+	pattern := uc.Monitor.Pattern
+	if !uc.isRegexPattern(pattern) {
+		return func(text string) bool {
+			return strings.Contains(text, pattern)
+		}, nil
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex pattern: %v", err)
+	}
+	return regex.MatchString, nil
+}
+
+func (uc *UrlCheckerImpl) isRegexPattern(pattern string) bool {
+	return strings.HasPrefix(pattern, "^") || strings.HasSuffix(pattern, "$") || strings.Contains(pattern, ".*")
 }
@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"snapp-task/db"
 	. "snapp-task/services"
+	"strings"
 	"time"
 )
 
@@ -22,25 +23,56 @@ var _ = Describe("UrlCheckerImpl", func() {
 		statusCode  int
 		testServer  *httptest.Server
 		timeOut     time.Duration
+		dedup       bool
+		events      *EventBus
+		contentType string
+		selector    string
+		encoding    string
+		maxBody     int64
+		valuePath   string
+		condition   string
+		rule        string
 	)
 
 	BeforeEach(func() {
-		mockDB = &db.DBMock{SaveDataFunc: func(url string, pattern string, data string) error {
+		dedup = false
+		events = nil
+		contentType = ""
+		selector = ""
+		encoding = ""
+		maxBody = 0
+		valuePath = ""
+		condition = ""
+		rule = ""
+		mockDB = &db.DBMock{SaveDataFunc: func(match db.Match) error {
 			return nil
 		}}
 		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			time.Sleep(timeOut)
+			switch contentType {
+			case "":
+			case "none":
+				// Keeps the server from sniffing a Content-Type of its own.
+				w.Header()["Content-Type"] = nil
+			default:
+				w.Header().Set("Content-Type", contentType)
+			}
+			if encoding != "" {
+				w.Header().Set("Content-Encoding", encoding)
+			}
 			w.WriteHeader(statusCode)
 			if isJson {
 				w.Header().Set("Content-Type", "application/json")
 			}
+
 			w.Write([]byte(testData))
 		})
 		testServer = httptest.NewServer(handler)
 	})
 
 	JustBeforeEach(func() {
-		urlChecker = NewUrlCheckerImpl(testServer.URL, testPattern, mockDB)
+		urlChecker = &UrlCheckerImpl{Monitor: db.Monitor{ID: 1, URL: testServer.URL, Pattern: testPattern, Dedup: dedup, Selector: selector,
+			ValuePath: valuePath, Condition: condition, Rule: rule}, Db: mockDB, Events: events, MaxBodyBytes: maxBody}
 		err = urlChecker.CheckData()
 	})
 
@@ -54,13 +86,264 @@ var _ = Describe("UrlCheckerImpl", func() {
 		It("should save data to DB and not return an error", func() {
 			Expect(err).To(BeNil())
 			Expect(mockDB.SaveDataCalls()).To(HaveLen(1))
-			call := mockDB.SaveDataCalls()[0]
+			call := mockDB.SaveDataCalls()[0].Match
 			Expect(call.URL).To(Equal(testServer.URL))
 			Expect(call.Pattern).To(Equal(testPattern))
 			Expect(call.Data).To(Equal(testData))
 		})
 	})
 
+	Context("when the monitor has dedup enabled", func() {
+		BeforeEach(func() {
+			testPattern = "test_pattern"
+			testData = "this_is_data_containing_test_pattern!"
+			statusCode = http.StatusOK
+			timeOut = time.Millisecond * 10
+			dedup = true
+		})
+		It("should ask the DB to deduplicate the match", func() {
+			Expect(err).To(BeNil())
+			Expect(mockDB.SaveDataCalls()).To(HaveLen(1))
+			Expect(mockDB.SaveDataCalls()[0].Match.Dedup).To(BeTrue())
+		})
+	})
+
+	Context("when the monitor has a selector", func() {
+		BeforeEach(func() {
+			testPattern = "^In stock$"
+			testData = `<div class="stock"><a href="/buy" class="btn">Out of stock</a></div>
+				<div class="stock"><a class="btn" href="/buy">
+					In   stock</a></div>`
+			statusCode = http.StatusOK
+			timeOut = time.Millisecond * 10
+			contentType = "text/html; charset=utf-8"
+			selector = ".stock a"
+		})
+		It("should match the selected element's text and store the element", func() {
+			Expect(err).To(BeNil())
+			Expect(mockDB.SaveDataCalls()).To(HaveLen(1))
+			Expect(mockDB.SaveDataCalls()[0].Match.Data).To(MatchJSON(`{"tag": "a", "text": "In stock", "attributes": {"class": "btn", "href": "/buy"}}`))
+		})
+	})
+
+	Context("when the monitor has a selector but the response is not HTML", func() {
+		BeforeEach(func() {
+			testPattern = "stock"
+			testData = "In stock"
+			statusCode = http.StatusOK
+			timeOut = time.Millisecond * 10
+			contentType = "text/plain"
+			selector = "a"
+		})
+		It("should return an error", func() {
+			Expect(err).To(MatchError(`selector requires an HTML response, got "text/plain"`))
+		})
+	})
+
+	Context("when the monitor extracts a value", func() {
+		BeforeEach(func() {
+			testData = `{"queue": {"depth": 120}}`
+			statusCode = http.StatusOK
+			timeOut = time.Millisecond * 10
+			contentType = "application/json"
+			valuePath = "queue.depth"
+			condition = "change > 10%"
+			mockDB.SaveValueFunc = func(value db.MonitorValue) error { return nil }
+			mockDB.LastValueFunc = func(monitorID int64) (db.MonitorValue, error) {
+				return db.MonitorValue{MonitorID: monitorID, Value: 100}, nil
+			}
+		})
+		It("should record the value and match when the condition holds", func() {
+			Expect(err).To(BeNil())
+			Expect(mockDB.SaveValueCalls()).To(HaveLen(1))
+			Expect(mockDB.SaveValueCalls()[0].Value.Value).To(Equal(120.0))
+			Expect(mockDB.SaveDataCalls()).To(HaveLen(1))
+			Expect(mockDB.SaveDataCalls()[0].Match.Data).To(Equal("120"))
+		})
+
+		Context("and the condition does not hold", func() {
+			BeforeEach(func() {
+				condition = "< 100"
+			})
+			It("should only record the value", func() {
+				Expect(err).To(BeNil())
+				Expect(mockDB.SaveValueCalls()).To(HaveLen(1))
+				Expect(mockDB.LastValueCalls()).To(BeEmpty())
+				Expect(mockDB.SaveDataCalls()).To(BeEmpty())
+			})
+		})
+
+		Context("and the value is missing", func() {
+			BeforeEach(func() {
+				valuePath = "queue.size"
+			})
+			It("should return an error without recording anything", func() {
+				Expect(err).To(MatchError("no value at queue.size"))
+				Expect(mockDB.SaveValueCalls()).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("when the monitor has a rule", func() {
+		BeforeEach(func() {
+			testPattern = ""
+			testData = `{"items": [{"id": 1}]}`
+			statusCode = http.StatusOK
+			timeOut = time.Millisecond * 10
+			contentType = "application/json"
+			rule = "status == 200 && body.items.size() > 0"
+		})
+		It("should store the body when the rule holds", func() {
+			Expect(err).To(BeNil())
+			Expect(mockDB.SaveDataCalls()).To(HaveLen(1))
+			Expect(mockDB.SaveDataCalls()[0].Match.Data).To(Equal(testData))
+		})
+
+		Context("and the rule does not hold", func() {
+			BeforeEach(func() {
+				testData = `{"items": []}`
+			})
+			It("should not save anything", func() {
+				Expect(err).To(BeNil())
+				Expect(mockDB.SaveDataCalls()).To(BeEmpty())
+			})
+		})
+
+		Context("and a pattern", func() {
+			BeforeEach(func() {
+				testPattern = "missing"
+			})
+			It("should require the pattern to match too", func() {
+				Expect(err).To(BeNil())
+				Expect(mockDB.SaveDataCalls()).To(BeEmpty())
+			})
+		})
+
+		Context("and the rule fails to evaluate", func() {
+			BeforeEach(func() {
+				rule = "body.missing == 1"
+			})
+			It("should return an error", func() {
+				Expect(err).To(MatchError(ContainSubstring("rule evaluation failed")))
+				Expect(mockDB.SaveDataCalls()).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("when the response is compressed", func() {
+		var result CheckResult
+
+		BeforeEach(func() {
+			testPattern = "test_pattern"
+			testData = string(compress("br", []byte("this_is_data_containing_test_pattern!")))
+			statusCode = http.StatusOK
+			timeOut = time.Millisecond * 10
+			contentType = "text/plain"
+			encoding = "br"
+		})
+		JustBeforeEach(func() {
+			result = urlChecker.Check(true)
+		})
+		It("should match the decompressed body and record the encoding and sizes", func() {
+			Expect(result.Err).To(BeNil())
+			Expect(result.Data).To(Equal("this_is_data_containing_test_pattern!"))
+			Expect(result.ContentEncoding).To(Equal("br"))
+			Expect(result.RawBytes).To(BeEquivalentTo(len(testData)))
+			Expect(result.DecodedBytes).To(BeEquivalentTo(len("this_is_data_containing_test_pattern!")))
+		})
+	})
+
+	Context("when the response decompresses past the body limit", func() {
+		BeforeEach(func() {
+			testPattern = "test_pattern"
+			testData = string(compress("gzip", []byte(strings.Repeat("test_pattern", 1000))))
+			statusCode = http.StatusOK
+			timeOut = time.Millisecond * 10
+			contentType = "text/plain"
+			encoding = "gzip"
+			maxBody = 1000
+		})
+		It("should fail without matching", func() {
+			Expect(err).To(MatchError(ErrBodyTooLarge))
+			Expect(mockDB.SaveDataCalls()).To(BeEmpty())
+		})
+	})
+
+	Context("when the response is in a legacy charset", func() {
+		BeforeEach(func() {
+			testPattern = "سلام"
+			testData = "\xD3\xE1\xC7\xE3 world"
+			statusCode = http.StatusOK
+			timeOut = time.Millisecond * 10
+			contentType = "text/plain; charset=windows-1256"
+		})
+		It("should match and store the decoded text", func() {
+			Expect(err).To(BeNil())
+			Expect(mockDB.SaveDataCalls()).To(HaveLen(1))
+			Expect(mockDB.SaveDataCalls()[0].Match.Data).To(Equal("سلام world"))
+		})
+	})
+
+	Context("when the response has no Content-Type", func() {
+		var result CheckResult
+
+		BeforeEach(func() {
+			testPattern = "down"
+			testData = `{"service": "db", "state": "down"}`
+			statusCode = http.StatusOK
+			timeOut = time.Millisecond * 10
+			contentType = "none"
+		})
+		JustBeforeEach(func() {
+			result = urlChecker.Check(true)
+		})
+		It("should sniff the media type and use its handler", func() {
+			Expect(result.Err).To(BeNil())
+			Expect(result.ContentType).To(Equal("application/json"))
+			Expect(result.Data).To(Equal("down"))
+		})
+	})
+
+	Context("when checking in dry-run mode", func() {
+		var result CheckResult
+
+		BeforeEach(func() {
+			testPattern = "test_pattern"
+			testData = "this_is_data_containing_test_pattern!"
+			statusCode = http.StatusAccepted
+			timeOut = time.Millisecond * 10
+		})
+		JustBeforeEach(func() {
+			result = urlChecker.Check(true)
+		})
+		It("should report the match without saving it", func() {
+			Expect(result.Err).NotTo(HaveOccurred())
+			Expect(result.Matched).To(BeTrue())
+			Expect(result.Data).To(Equal(testData))
+			Expect(result.StatusCode).To(Equal(http.StatusAccepted))
+			Expect(mockDB.SaveDataCalls()).To(HaveLen(1), "only the CheckData call saves")
+		})
+	})
+
+	Context("when the checker publishes events", func() {
+		var sub *Subscription
+
+		BeforeEach(func() {
+			testPattern = "test_pattern"
+			testData = "this_is_data_containing_test_pattern!"
+			statusCode = http.StatusOK
+			timeOut = time.Millisecond * 10
+			events = NewEventBus(10)
+			sub, _ = events.Subscribe(0, func(Event) bool { return true })
+		})
+		It("should publish the match, the check result and the new state", func() {
+			Expect(err).To(BeNil())
+			Expect(sub.C).To(Receive(And(HaveField("Type", EventMatch), HaveField("Data", testData))))
+			Expect(sub.C).To(Receive(And(HaveField("Type", EventCheck), HaveField("Matched", true), HaveField("MonitorID", int64(1)))))
+			Expect(sub.C).To(Receive(And(HaveField("Type", EventState), HaveField("State", StateUp))))
+		})
+	})
+
 	Context("when CheckData fails due to timeout", func() {
 		BeforeEach(func() {
 			testPattern = "test_pattern"
@@ -80,7 +363,7 @@ var _ = Describe("UrlCheckerImpl", func() {
 			statusCode = http.StatusOK
 			timeOut = time.Millisecond * 10
 
-			mockDB.SaveDataFunc = func(url string, pattern string, data string) error {
+			mockDB.SaveDataFunc = func(match db.Match) error {
 				return fmt.Errorf("insertion error")
 			}
 		})
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Condition is a parsed threshold on an extracted value. It is one of
+//
+//	OP N                 with OP one of >, >=, <, <=, == and !=
+//	between A and B      A <= value <= B
+//	change OP N%         the percent change since the previous value, compared with OP
+type Condition struct {
+	op      string
+	operand float64
+	upper   float64
+	change  bool
+}
+
+var comparisonOps = []string{">=", "<=", "==", "!=", ">", "<"}
+
+func ParseCondition(input string) (*Condition, error) {
+	fields := strings.Fields(strings.ToLower(input))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("expected a condition")
+	}
+	var condition Condition
+	if fields[0] == "between" {
+		if len(fields) != 4 || fields[2] != "and" {
+			return nil, fmt.Errorf("expected between A and B")
+		}
+		lower, err := parseOperand(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		upper, err := parseOperand(fields[3])
+		if err != nil {
+			return nil, err
+		}
+		if lower > upper {
+			return nil, fmt.Errorf("between bounds are reversed: %v > %v", lower, upper)
+		}
+		return &Condition{op: "between", operand: lower, upper: upper}, nil
+	}
+	expression := strings.Join(fields, "")
+	if rest, ok := strings.CutPrefix(expression, "change"); ok {
+		percent, ok := strings.CutSuffix(rest, "%")
+		if !ok {
+			return nil, fmt.Errorf("change must be compared with a percentage, like change > 5%%")
+		}
+		condition.change = true
+		expression = percent
+	}
+	for _, op := range comparisonOps {
+		if operand, ok := strings.CutPrefix(expression, op); ok {
+			condition.op = op
+			expression = operand
+			break
+		}
+	}
+	if condition.op == "" {
+		return nil, fmt.Errorf("expected a comparison like > 100, between 10 and 20 or change > 5%%")
+	}
+	var err error
+	if condition.operand, err = parseOperand(expression); err != nil {
+		return nil, err
+	}
+	return &condition, nil
+}
+
+func parseOperand(input string) (float64, error) {
+	value, err := strconv.ParseFloat(input, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("%q is not a number", input)
+	}
+	return value, nil
+}
+
+// NeedsPrevious reports whether the condition compares against the previous value.
+func (c *Condition) NeedsPrevious() bool {
+	return c.change
+}
+
+// Holds evaluates the condition. previous is only used by change conditions, which never hold without
+// a previous value or when it is zero.
+func (c *Condition) Holds(value float64, previous *float64) bool {
+	if c.change {
+		if previous == nil || *previous == 0 {
+			return false
+		}
+		value = (value - *previous) / math.Abs(*previous) * 100
+	}
+	switch c.op {
+	case "between":
+		return c.operand <= value && value <= c.upper
+	case ">":
+		return value > c.operand
+	case ">=":
+		return value >= c.operand
+	case "<":
+		return value < c.operand
+	case "<=":
+		return value <= c.operand
+	case "==":
+		return value == c.operand
+	default:
+		return value != c.operand
+	}
+}
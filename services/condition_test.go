@@ -0,0 +1,56 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "snapp-task/services"
+)
+
+var _ = Describe("Condition", func() {
+	previous := func(value float64) *float64 { return &value }
+
+	DescribeTable("should evaluate conditions",
+		func(input string, value float64, last *float64, expected bool) {
+			condition, err := ParseCondition(input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(condition.Holds(value, last)).To(Equal(expected))
+		},
+		Entry("greater than", "> 100", 101.0, nil, true),
+		Entry("not greater than", ">100", 100.0, nil, false),
+		Entry("at least", ">= 100", 100.0, nil, true),
+		Entry("less than", "< 0.5", 0.25, nil, true),
+		Entry("at most", "<= -1", 0.0, nil, false),
+		Entry("equal", "== 3", 3.0, nil, true),
+		Entry("not equal", "!= 3", 3.0, nil, false),
+		Entry("between, inclusive", "between 10 and 20", 20.0, nil, true),
+		Entry("outside between", "BETWEEN 10 AND 20", 20.5, nil, false),
+		Entry("rise over a percentage", "change > 5%", 106.0, previous(100), true),
+		Entry("rise under a percentage", "change > 5%", 104.0, previous(100), false),
+		Entry("drop", "change <= -10%", 45.0, previous(50), true),
+		Entry("drop from a negative value", "change < 0%", -60.0, previous(-50), true),
+		Entry("change without a previous value", "change > 5%", 106.0, nil, false),
+		Entry("change from zero", "change != 0%", 1.0, previous(0), false),
+	)
+
+	It("should report whether the previous value is needed", func() {
+		condition, err := ParseCondition("change > 5%")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(condition.NeedsPrevious()).To(BeTrue())
+		condition, err = ParseCondition("> 5")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(condition.NeedsPrevious()).To(BeFalse())
+	})
+
+	DescribeTable("should reject malformed conditions",
+		func(input, message string) {
+			_, err := ParseCondition(input)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("empty", " ", "expected a condition"),
+		Entry("no operator", "100", "expected a comparison"),
+		Entry("not a number", "> many", `"many" is not a number`),
+		Entry("incomplete between", "between 10", "expected between A and B"),
+		Entry("reversed between", "between 20 and 10", "reversed"),
+		Entry("change without a percentage", "change > 5", "percentage"),
+	)
+})
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/antchfx/htmlquery"
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v3"
+	"io"
+	"mime"
+	"snapp-task/db"
+	"strings"
+	"sync"
+)
+
+// TextMatcher reports whether text matches a monitor's pattern.
+type TextMatcher func(text string) bool
+
+// ContentHandler finds what a response matched. It returns the data to store as the match, or "" when
+// nothing matched.
+type ContentHandler interface {
+	Match(content []byte, monitor db.Monitor, match TextMatcher) (string, error)
+}
+
+type ContentHandlerFunc func(content []byte, monitor db.Monitor, match TextMatcher) (string, error)
+
+func (f ContentHandlerFunc) Match(content []byte, monitor db.Monitor, match TextMatcher) (string, error) {
+	return f(content, monitor, match)
+}
+
+// ContentHandlers maps media types to the handlers matching them. Responses of unregistered media types
+// go to Fallback.
+type ContentHandlers struct {
+	mu       sync.RWMutex
+	handlers map[string]ContentHandler
+	Fallback ContentHandler
+}
+
+func NewContentHandlers() *ContentHandlers {
+	return &ContentHandlers{handlers: make(map[string]ContentHandler), Fallback: ContentHandlerFunc(matchText)}
+}
+
+// Register sets the handler for a media type such as "text/csv", replacing any earlier one.
+func (h *ContentHandlers) Register(mediaType string, handler ContentHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handlers[strings.ToLower(mediaType)] = handler
+}
+
+// Lookup returns the handler for mediaType. Types with a structured syntax suffix that have no handler
+// of their own, like application/problem+json, use the handler of the suffix's base type.
+func (h *ContentHandlers) Lookup(mediaType string) ContentHandler {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if handler, ok := h.handlers[mediaType]; ok {
+		return handler
+	}
+	if plus := strings.LastIndexByte(mediaType, '+'); plus >= 0 {
+		if handler, ok := h.handlers["application/"+mediaType[plus+1:]]; ok {
+			return handler
+		}
+	}
+	return h.Fallback
+}
+
+// DefaultContentHandlers is used by checkers that have no handlers of their own.
+var DefaultContentHandlers = newDefaultContentHandlers()
+
+// RegisterContentHandler registers handler for mediaType in DefaultContentHandlers.
+func RegisterContentHandler(mediaType string, handler ContentHandler) {
+	DefaultContentHandlers.Register(mediaType, handler)
+}
+
+func newDefaultContentHandlers() *ContentHandlers {
+	handlers := NewContentHandlers()
+	register := func(handler ContentHandlerFunc, mediaTypes ...string) {
+		for _, mediaType := range mediaTypes {
+			handlers.Register(mediaType, handler)
+		}
+	}
+	register(matchText, "text/plain")
+	register(matchJSON, "application/json")
+	register(matchNDJSON, "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines")
+	register(matchHTML, "text/html", "application/xhtml+xml")
+	register(matchXML, "application/xml", "text/xml")
+	register(matchFeed, "application/rss+xml", "application/atom+xml")
+	register(matchYAML, "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml")
+	register(matchCSV, "text/csv", "application/csv")
+	return handlers
+}
+
+// MediaType returns the lower-cased media type of a Content-Type header without its parameters.
+func MediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, _, _ = strings.Cut(contentType, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	}
+	return mediaType
+}
+
+// matchText matches the whole body and stores it.
+func matchText(content []byte, monitor db.Monitor, match TextMatcher) (string, error) {
+	if text := string(content); match(text) {
+		return text, nil
+	}
+	return "", nil
+}
+
+// matchStrings walks decoded JSON or YAML and returns the first string value that matches.
+func matchStrings(data any, match TextMatcher) (bool, string) {
+	switch v := data.(type) {
+	case map[string]any:
+		for _, value := range v {
+			if matched, matchedData := matchStrings(value, match); matched {
+				return true, matchedData
+			}
+		}
+	case map[any]any:
+		for _, value := range v {
+			if matched, matchedData := matchStrings(value, match); matched {
+				return true, matchedData
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if matched, matchedData := matchStrings(item, match); matched {
+				return true, matchedData
+			}
+		}
+	case string:
+		if match(v) {
+			return true, v
+		}
+	}
+	return false, ""
+}
+
+func matchJSON(content []byte, monitor db.Monitor, match TextMatcher) (string, error) {
+	var jsonData any
+	if err := json.Unmarshal(content, &jsonData); err != nil {
+		return "", fmt.Errorf("failed to parse JSON response: %v", err)
+	}
+	_, matchedData := matchStrings(jsonData, match)
+	return matchedData, nil
+}
+
+// matchNDJSON matches newline-delimited JSON one record at a time and stores the first matching line.
+func matchNDJSON(content []byte, monitor db.Monitor, match TextMatcher) (string, error) {
+	for number, line := range bytes.Split(content, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+		var record any
+		if err := json.Unmarshal(line, &record); err != nil {
+			return "", fmt.Errorf("failed to parse NDJSON response at line %d: %v", number+1, err)
+		}
+		if matched, _ := matchStrings(record, match); matched {
+			return string(line), nil
+		}
+	}
+	return "", nil
+}
+
+// matchYAML matches string values like matchJSON. Every document of a multi-document stream is searched.
+func matchYAML(content []byte, monitor db.Monitor, match TextMatcher) (string, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var yamlData any
+		if err := decoder.Decode(&yamlData); err != nil {
+			if err == io.EOF {
+				return "", nil
+			}
+			return "", fmt.Errorf("failed to parse YAML response: %v", err)
+		}
+		if matched, matchedData := matchStrings(yamlData, match); matched {
+			return matchedData, nil
+		}
+	}
+}
+
+// selectedElement is stored as the match when a selector picked the element.
+type selectedElement struct {
+	Tag        string            `json:"tag"`
+	Text       string            `json:"text"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// matchHTML applies the pattern to the text of each element the monitor's selector picks and stores the
+// first matching element. With an XPath instead, the first matching value is stored like for XML.
+// Without either the raw markup is matched.
+func matchHTML(content []byte, monitor db.Monitor, match TextMatcher) (string, error) {
+	if monitor.Selector == "" && monitor.XPath == "" {
+		return matchText(content, monitor, match)
+	}
+	document, err := html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML response: %v", err)
+	}
+	if monitor.Selector == "" {
+		path, err := ParseXPath(monitor.XPath)
+		if err != nil {
+			return "", fmt.Errorf("invalid xpath: %v", err)
+		}
+		for _, value := range path.Values(htmlquery.CreateXPathNavigator(document)) {
+			if match(value) {
+				return value, nil
+			}
+		}
+		return "", nil
+	}
+	selector, err := ParseSelector(monitor.Selector)
+	if err != nil {
+		return "", fmt.Errorf("invalid selector: %v", err)
+	}
+	for _, node := range selector.Select(document) {
+		text := NodeText(node)
+		if !match(text) {
+			continue
+		}
+		element := selectedElement{Tag: node.Data, Text: text}
+		if len(node.Attr) > 0 {
+			element.Attributes = make(map[string]string, len(node.Attr))
+			for _, attr := range node.Attr {
+				element.Attributes[attr.Key] = attr.Val
+			}
+		}
+		data, err := json.Marshal(element)
+		return string(data), err
+	}
+	return "", nil
+}
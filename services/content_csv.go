@@ -0,0 +1,76 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"snapp-task/db"
+	"strconv"
+	"strings"
+)
+
+// csvColumn resolves the monitor's CSVColumn against the header row, by name first and then by
+// 1-based index.
+func csvColumn(header []string, column string) (int, error) {
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), column) {
+			return i, nil
+		}
+	}
+	if index, err := strconv.Atoi(column); err == nil && index >= 1 && index <= len(header) {
+		return index - 1, nil
+	}
+	return 0, fmt.Errorf("column %q is not in the CSV header", column)
+}
+
+// matchCSV matches the cells of every row after the header, or only those in the monitor's CSVColumn,
+// and stores the first matching row as an object keyed by the header.
+func matchCSV(content []byte, monitor db.Monitor, match TextMatcher) (string, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to parse CSV response: %v", err)
+	}
+	column := -1
+	if monitor.CSVColumn != "" {
+		if column, err = csvColumn(header, monitor.CSVColumn); err != nil {
+			return "", err
+		}
+	}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse CSV response: %v", err)
+		}
+		matched := false
+		for i, cell := range row {
+			if (column < 0 || i == column) && match(cell) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		record := make(map[string]string, len(row))
+		for i, cell := range row {
+			key := strconv.Itoa(i + 1)
+			if i < len(header) && header[i] != "" {
+				key = header[i]
+			}
+			record[key] = cell
+		}
+		data, err := json.Marshal(record)
+		return string(data), err
+	}
+}
@@ -0,0 +1,193 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"snapp-task/db"
+	. "snapp-task/services"
+	"strings"
+)
+
+var _ = Describe("Content handlers", func() {
+	contains := func(pattern string) TextMatcher {
+		return func(text string) bool { return strings.Contains(text, pattern) }
+	}
+
+	match := func(mediaType, content string, monitor db.Monitor, pattern string) (string, error) {
+		return DefaultContentHandlers.Lookup(mediaType).Match([]byte(content), monitor, contains(pattern))
+	}
+
+	It("should parse media types out of Content-Type headers", func() {
+		Expect(MediaType("Application/JSON; charset=utf-8")).To(Equal("application/json"))
+		Expect(MediaType("text/csv;;")).To(Equal("text/csv"))
+		Expect(MediaType("")).To(Equal(""))
+	})
+
+	It("should fall back to matching the whole body", func() {
+		data, err := match("application/octet-stream", "status: ok", db.Monitor{}, "ok")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal("status: ok"))
+	})
+
+	It("should use handlers registered for new media types", func() {
+		handlers := NewContentHandlers()
+		handlers.Register("Application/X-Custom", ContentHandlerFunc(func(content []byte, monitor db.Monitor, match TextMatcher) (string, error) {
+			return "custom", nil
+		}))
+		data, err := handlers.Lookup("application/x-custom").Match(nil, db.Monitor{}, contains("x"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal("custom"))
+	})
+
+	DescribeTable("should use the handler of a structured syntax suffix",
+		func(mediaType, content, expected string) {
+			data, err := match(mediaType, content, db.Monitor{}, "down")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(Equal(expected))
+		},
+		Entry("problem+json", "application/problem+json", `{"title": "service down", "status": 503}`, "service down"),
+		Entry("vnd.api+json", "application/vnd.api+json", `{"data": [{"attributes": {"state": "down"}}]}`, "down"),
+		Entry("custom +xml", "application/vnd.status+xml", `<status><db>down</db></status>`, "down"),
+	)
+
+	Describe("NDJSON", func() {
+		const records = "{\"service\": \"api\", \"state\": \"up\"}\n\n{\"service\": \"db\", \"state\": \"down\"}\n"
+
+		It("should match records one line at a time and store the matching line", func() {
+			data, err := match("application/x-ndjson", records, db.Monitor{}, "down")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(Equal(`{"service": "db", "state": "down"}`))
+		})
+
+		It("should report the line of a malformed record", func() {
+			_, err := match("application/jsonl", records+"{oops\n", db.Monitor{}, "missing")
+			Expect(err).To(MatchError(ContainSubstring("at line 4")))
+		})
+	})
+
+	Describe("XML", func() {
+		const document = `<?xml version="1.0"?>
+			<catalog xmlns:x="urn:x">
+				<book id="1" lang="en"><title>Go in Action</title><x:price>30</x:price></book>
+				<book id="2" lang="fa"><title>Persian Poetry</title><x:price>25</x:price></book>
+			</catalog>`
+
+		It("should match any text or attribute value without an XPath", func() {
+			data, err := match("application/xml", document, db.Monitor{}, "Poetry")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(Equal("Persian Poetry"))
+		})
+
+		DescribeTable("should match only the nodes an XPath selects",
+			func(xpath, pattern, expected string) {
+				data, err := match("text/xml", document, db.Monitor{XPath: xpath}, pattern)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(data).To(Equal(expected))
+			},
+			Entry("descendant elements", "//title", "Go", "Go in Action"),
+			Entry("absolute path and prefixed names", "/catalog/book/x:price", "25", "25"),
+			Entry("attribute values", "//book/@lang", "fa", "fa"),
+			Entry("attribute predicates", "//book[@lang='fa']/title", "Go", ""),
+			Entry("child value predicates", "//book[title='Go in Action']/x:price", "3", "30"),
+			Entry("functions and boolean operators", "//book[contains(title, 'Go') or @lang='fa']/@id", "2", "2"),
+			Entry("number results", "count(//book)", "2", "2"),
+			Entry("positions", "//book[last()]/@id", "", "2"),
+			Entry("parent steps", "//title/../@id", "1", "1"),
+		)
+
+		It("should report malformed documents", func() {
+			_, err := match("application/xml", "<a><b></a", db.Monitor{}, "a")
+			Expect(err).To(MatchError(ContainSubstring("failed to parse XML response")))
+		})
+	})
+
+	Describe("RSS and Atom", func() {
+		const rss = `<rss version="2.0"><channel><title>News</title>
+			<item><title>Weather</title><link>https://n.com/1</link><guid>1</guid><description>Sunny</description></item>
+			<item><title>Markets</title><link>https://n.com/2</link><guid>2</guid><description>Stocks fall</description></item>
+		</channel></rss>`
+		const atom = `<feed xmlns="http://www.w3.org/2005/Atom"><title>Blog</title>
+			<entry><id>urn:1</id><title>Release 1.0</title><link rel="alternate" href="https://b.com/1"/><updated>2024-01-01</updated><summary>First release</summary></entry>
+		</feed>`
+
+		It("should match RSS items one at a time and store the item", func() {
+			data, err := match("application/rss+xml", rss, db.Monitor{}, "fall")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(MatchJSON(`{"id": "2", "title": "Markets", "link": "https://n.com/2", "summary": "Stocks fall"}`))
+		})
+
+		It("should not match text that only appears outside of entries", func() {
+			data, err := match("application/rss+xml", rss, db.Monitor{}, "News")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(BeEmpty())
+		})
+
+		It("should match Atom entries, also when served as plain XML", func() {
+			data, err := match("application/xml", atom, db.Monitor{}, "Release")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(MatchJSON(`{"id": "urn:1", "title": "Release 1.0", "link": "https://b.com/1", "published": "2024-01-01", "summary": "First release"}`))
+		})
+	})
+
+	Describe("HTML", func() {
+		const page = `<html><body><ul>
+			<li class="item" data-sku="A-1"><b>Lamp</b> <span class="price">10</span></li>
+			<li class="item sale" data-sku="B-2"><b>Desk</b> <span class="price">20</span></li>
+		</ul></body></html>`
+
+		It("should store the first element the selector picks whose text matches", func() {
+			data, err := match("text/html", page, db.Monitor{Selector: "li.item"}, "Desk")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(MatchJSON(`{"tag": "li", "text": "Desk 20", "attributes": {"class": "item sale", "data-sku": "B-2"}}`))
+		})
+
+		It("should match the values an XPath selects", func() {
+			data, err := match("text/html", page, db.Monitor{XPath: "//li[contains(@class, 'sale')]/span"}, "2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(Equal("20"))
+
+			data, err = match("text/html", page, db.Monitor{XPath: "//li[b='Lamp']/@data-sku"}, "A")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(Equal("A-1"))
+		})
+	})
+
+	Describe("YAML", func() {
+		It("should match string values across documents", func() {
+			data, err := match("application/yaml", "status: ok\n---\nservices:\n  - name: db\n    state: degraded\n", db.Monitor{}, "degraded")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(Equal("degraded"))
+		})
+
+		It("should not match keys", func() {
+			data, err := match("text/yaml", "degraded: false\n", db.Monitor{}, "degraded")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(BeEmpty())
+		})
+	})
+
+	Describe("CSV", func() {
+		const table = "service,status,note\napi,up,\ndb,down,investigating up time\n"
+
+		It("should match any cell and store the row keyed by the header", func() {
+			data, err := match("text/csv", table, db.Monitor{}, "down")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(MatchJSON(`{"service": "db", "status": "down", "note": "investigating up time"}`))
+		})
+
+		It("should only match the monitor's column, by name or index", func() {
+			data, err := match("text/csv", table, db.Monitor{CSVColumn: "note"}, "up")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(ContainSubstring(`"service":"db"`))
+
+			data, err = match("text/csv", table, db.Monitor{CSVColumn: "2"}, "up")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(ContainSubstring(`"service":"api"`))
+		})
+
+		It("should report unknown columns", func() {
+			_, err := match("text/csv", table, db.Monitor{CSVColumn: "owner"}, "up")
+			Expect(err).To(MatchError(`column "owner" is not in the CSV header`))
+		})
+	})
+})
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/antchfx/xmlquery"
+	"snapp-task/db"
+	"strings"
+)
+
+func parseXMLResponse(content []byte) (*xmlquery.Node, error) {
+	document, err := ParseXML(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse XML response: %v", err)
+	}
+	return document, nil
+}
+
+// matchXMLPath returns the first value of the monitor's XPath that matches.
+func matchXMLPath(document *xmlquery.Node, monitor db.Monitor, match TextMatcher) (string, error) {
+	path, err := ParseXPath(monitor.XPath)
+	if err != nil {
+		return "", fmt.Errorf("invalid xpath: %v", err)
+	}
+	for _, value := range path.Values(xmlquery.CreateXPathNavigator(document)) {
+		if match(value) {
+			return value, nil
+		}
+	}
+	return "", nil
+}
+
+// matchXML matches the nodes selected by the monitor's XPath. Without one, RSS and Atom documents are
+// matched per entry and other documents by every text and attribute value.
+func matchXML(content []byte, monitor db.Monitor, match TextMatcher) (string, error) {
+	document, err := parseXMLResponse(content)
+	if err != nil {
+		return "", err
+	}
+	if monitor.XPath != "" {
+		return matchXMLPath(document, monitor, match)
+	}
+	if isFeed(document) {
+		return matchFeedEntries(document, match)
+	}
+	var matched string
+	walkXML(document, func(node *xmlquery.Node) bool {
+		for _, attr := range node.Attr {
+			if value := strings.TrimSpace(attr.Value); match(value) {
+				matched = value
+				return false
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != xmlquery.TextNode && child.Type != xmlquery.CharDataNode {
+				continue
+			}
+			if value := strings.TrimSpace(child.Data); value != "" && match(value) {
+				matched = value
+				return false
+			}
+		}
+		return true
+	})
+	return matched, nil
+}
+
+// walkXML calls visit with node and its descendant elements in document order, until visit returns false.
+func walkXML(node *xmlquery.Node, visit func(node *xmlquery.Node) bool) bool {
+	if !visit(node) {
+		return false
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == xmlquery.ElementNode && !walkXML(child, visit) {
+			return false
+		}
+	}
+	return true
+}
+
+// feedEntry is stored as the match of an RSS item or Atom entry.
+type feedEntry struct {
+	ID        string `json:"id,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Link      string `json:"link,omitempty"`
+	Published string `json:"published,omitempty"`
+	Summary   string `json:"summary,omitempty"`
+}
+
+func isFeed(document *xmlquery.Node) bool {
+	root := xmlRoot(document)
+	return root.Data == "rss" || root.Data == "feed" || root.Data == "RDF"
+}
+
+func childValue(node *xmlquery.Node, names ...string) string {
+	for _, name := range names {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == xmlquery.ElementNode && child.Data == name {
+				return xmlText(child)
+			}
+		}
+	}
+	return ""
+}
+
+// entryLink returns the RSS link, or the href of the Atom link that points to the entry itself.
+func entryLink(node *xmlquery.Node) string {
+	if link := childValue(node, "link"); link != "" {
+		return link
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != xmlquery.ElementNode || child.Data != "link" {
+			continue
+		}
+		if rel := child.SelectAttr("rel"); rel == "" || rel == "alternate" {
+			return child.SelectAttr("href")
+		}
+	}
+	return ""
+}
+
+func toFeedEntry(node *xmlquery.Node) feedEntry {
+	return feedEntry{
+		ID:        childValue(node, "guid", "id"),
+		Title:     childValue(node, "title"),
+		Link:      entryLink(node),
+		Published: childValue(node, "pubDate", "published", "updated", "date"),
+		Summary:   childValue(node, "description", "summary", "content"),
+	}
+}
+
+// matchFeedEntries applies the pattern to the title and summary of each entry and stores the first
+// entry that matches.
+func matchFeedEntries(document *xmlquery.Node, match TextMatcher) (string, error) {
+	var matched *feedEntry
+	walkXML(document, func(node *xmlquery.Node) bool {
+		if node.Data != "item" && node.Data != "entry" {
+			return true
+		}
+		entry := toFeedEntry(node)
+		if !match(strings.TrimSpace(entry.Title + "\n" + entry.Summary)) {
+			return true
+		}
+		matched = &entry
+		return false
+	})
+	if matched == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(matched)
+	return string(data), err
+}
+
+// matchFeed matches RSS and Atom feeds per entry, or by the monitor's XPath when it has one.
+func matchFeed(content []byte, monitor db.Monitor, match TextMatcher) (string, error) {
+	document, err := parseXMLResponse(content)
+	if err != nil {
+		return "", err
+	}
+	if monitor.XPath != "" {
+		return matchXMLPath(document, monitor, match)
+	}
+	return matchFeedEntries(document, match)
+}
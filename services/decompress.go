@@ -0,0 +1,126 @@
+package services
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"strings"
+)
+
+// DefaultMaxBodyBytes is the largest response body a check reads, before and after decompression.
+const DefaultMaxBodyBytes = 10 << 20
+
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// acceptEncoding is sent with every check. Setting it ourselves turns off the transport's transparent
+// gzip handling, so the checker sees the Content-Encoding and the raw size.
+const acceptEncoding = "gzip, deflate, br, zstd"
+
+var contentDecoders = map[string]func(content []byte, limit int64) (io.ReadCloser, error){
+	"gzip": func(content []byte, limit int64) (io.ReadCloser, error) {
+		return gzip.NewReader(bytes.NewReader(content))
+	},
+	// deflate is meant to be zlib-wrapped, but plenty of servers send raw deflate streams.
+	"deflate": func(content []byte, limit int64) (io.ReadCloser, error) {
+		if reader, err := zlib.NewReader(bytes.NewReader(content)); err == nil {
+			return reader, nil
+		}
+		return flate.NewReader(bytes.NewReader(content)), nil
+	},
+	"br": func(content []byte, limit int64) (io.ReadCloser, error) {
+		return io.NopCloser(brotli.NewReader(bytes.NewReader(content))), nil
+	},
+	"zstd": func(content []byte, limit int64) (io.ReadCloser, error) {
+		decoder, err := zstd.NewReader(bytes.NewReader(content), zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(uint64(limit)))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	},
+}
+
+// sniffEncoding recognizes gzip and zstd streams by their magic numbers.
+func sniffEncoding(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, []byte{0x1f, 0x8b}):
+		return "gzip"
+	case bytes.HasPrefix(content, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return "zstd"
+	}
+	return ""
+}
+
+// readLimited reads at most limit bytes from reader and fails with ErrBodyTooLarge if there is more.
+func readLimited(reader io.Reader, limit int64) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > limit {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrBodyTooLarge, limit)
+	}
+	return content, nil
+}
+
+// DecodeBody undoes the content codings listed in contentEncoding, last applied first, and returns the
+// decoded body with the codings it actually undid. Servers are not trusted to label their bodies: a
+// gzip or zstd stream is decoded whatever the header says, and a coding whose decoder rejects a body
+// that does not carry its magic number is taken to be mislabeled and skipped. No step may produce more
+// than limit bytes.
+func DecodeBody(content []byte, contentEncoding string, limit int64) ([]byte, string, error) {
+	var codings []string
+	for _, coding := range strings.Split(contentEncoding, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "x-gzip" {
+			coding = "gzip"
+		}
+		if coding != "" && coding != "identity" {
+			codings = append(codings, coding)
+		}
+	}
+	if len(codings) == 0 {
+		if sniffed := sniffEncoding(content); sniffed != "" {
+			codings = []string{sniffed}
+		}
+	}
+	var applied []string
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := codings[i]
+		sniffed := sniffEncoding(content)
+		if sniffed != "" {
+			coding = sniffed
+		}
+		newReader, ok := contentDecoders[coding]
+		if !ok {
+			return nil, "", fmt.Errorf("unsupported content encoding %q", coding)
+		}
+		decoded, err := decompress(content, newReader, limit)
+		if errors.Is(err, ErrBodyTooLarge) {
+			return nil, "", fmt.Errorf("%w after decoding %s", err, coding)
+		}
+		if err != nil {
+			if sniffed != "" {
+				return nil, "", fmt.Errorf("failed to decode %s response: %v", coding, err)
+			}
+			continue
+		}
+		content = decoded
+		applied = append([]string{coding}, applied...)
+	}
+	return content, strings.Join(applied, ", "), nil
+}
+
+func decompress(content []byte, newReader func(content []byte, limit int64) (io.ReadCloser, error), limit int64) ([]byte, error) {
+	reader, err := newReader(content, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return readLimited(reader, limit)
+}
@@ -0,0 +1,87 @@
+package services_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"io"
+	. "snapp-task/services"
+	"strings"
+)
+
+func compress(coding string, content []byte) []byte {
+	var buffer bytes.Buffer
+	var writer io.WriteCloser
+	switch coding {
+	case "gzip":
+		writer = gzip.NewWriter(&buffer)
+	case "deflate":
+		writer = zlib.NewWriter(&buffer)
+	case "raw-deflate":
+		writer, _ = flate.NewWriter(&buffer, flate.DefaultCompression)
+	case "br":
+		writer = brotli.NewWriter(&buffer)
+	case "zstd":
+		writer, _ = zstd.NewWriter(&buffer)
+	}
+	writer.Write(content)
+	writer.Close()
+	return buffer.Bytes()
+}
+
+var _ = Describe("DecodeBody", func() {
+	const text = "status: degraded, status: degraded, status: degraded"
+
+	DescribeTable("should decode every supported coding",
+		func(header, coding, applied string) {
+			decoded, encoding, err := DecodeBody(compress(coding, []byte(text)), header, DefaultMaxBodyBytes)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(decoded)).To(Equal(text))
+			Expect(encoding).To(Equal(applied))
+		},
+		Entry("gzip", "gzip", "gzip", "gzip"),
+		Entry("x-gzip", "X-Gzip", "gzip", "gzip"),
+		Entry("zlib deflate", "deflate", "deflate", "deflate"),
+		Entry("raw deflate", "deflate", "raw-deflate", "deflate"),
+		Entry("brotli", "br", "br", "br"),
+		Entry("zstd", "zstd", "zstd", "zstd"),
+		Entry("unlabeled gzip", "", "gzip", "gzip"),
+		Entry("gzip labeled as brotli", "br", "gzip", "gzip"),
+		Entry("zstd labeled as gzip", "gzip", "zstd", "zstd"),
+	)
+
+	It("should leave plain bodies with a wrong Content-Encoding alone", func() {
+		decoded, encoding, err := DecodeBody([]byte(text), "br", DefaultMaxBodyBytes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(decoded)).To(Equal(text))
+		Expect(encoding).To(BeEmpty())
+	})
+
+	It("should undo stacked codings in reverse order", func() {
+		decoded, encoding, err := DecodeBody(compress("br", compress("deflate", []byte(text))), "deflate, br", DefaultMaxBodyBytes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(decoded)).To(Equal(text))
+		Expect(encoding).To(Equal("deflate, br"))
+	})
+
+	It("should stop decompressing at the limit", func() {
+		bomb := compress("gzip", []byte(strings.Repeat("0", 1<<20)))
+		Expect(len(bomb)).To(BeNumerically("<", 4096))
+		_, _, err := DecodeBody(bomb, "gzip", 64<<10)
+		Expect(err).To(MatchError(ErrBodyTooLarge))
+	})
+
+	It("should report corrupt streams and unknown codings", func() {
+		corrupt := compress("gzip", []byte(text))
+		_, _, err := DecodeBody(corrupt[:len(corrupt)/2], "gzip", DefaultMaxBodyBytes)
+		Expect(err).To(MatchError(ContainSubstring("failed to decode gzip response")))
+
+		_, _, err = DecodeBody([]byte(text), "compress", DefaultMaxBodyBytes)
+		Expect(err).To(MatchError(`unsupported content encoding "compress"`))
+	})
+})
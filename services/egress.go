@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+var ErrEgressDenied = errors.New("egress denied")
+
+// internalPrefixes are denied unless the policy allows private destinations.
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// EgressPolicy decides which destinations checks may connect to. Deny lists win over allow lists, and
+// allowed hosts and CIDRs are exempt from the private range check.
+type EgressPolicy struct {
+	AllowPrivate bool
+	AllowCIDRs   []netip.Prefix
+	DenyCIDRs    []netip.Prefix
+	// AllowHosts and DenyHosts hold hostnames; "*.example.com" matches every subdomain of example.com.
+	AllowHosts []string
+	DenyHosts  []string
+}
+
+type EgressDeniedError struct {
+	Host   string
+	Reason string
+}
+
+func (e *EgressDeniedError) Error() string {
+	return fmt.Sprintf("egress to %s denied: %s", e.Host, e.Reason)
+}
+
+func (e *EgressDeniedError) Unwrap() error {
+	return ErrEgressDenied
+}
+
+func matchHost(patterns []string, host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHost applies the hostname lists and reports whether host is explicitly allowed.
+func (p EgressPolicy) checkHost(host string) (bool, error) {
+	if matchHost(p.DenyHosts, host) {
+		return false, &EgressDeniedError{Host: host, Reason: "host is on the deny list"}
+	}
+	return matchHost(p.AllowHosts, host), nil
+}
+
+func (p EgressPolicy) checkAddr(host string, addr netip.Addr, hostAllowed bool) error {
+	addr = addr.Unmap()
+	if containsAddr(p.DenyCIDRs, addr) {
+		return &EgressDeniedError{Host: host, Reason: addr.String() + " is on the deny list"}
+	}
+	if hostAllowed || p.AllowPrivate || containsAddr(p.AllowCIDRs, addr) {
+		return nil
+	}
+	if containsAddr(internalPrefixes, addr) {
+		return &EgressDeniedError{Host: host, Reason: addr.String() + " is a private address"}
+	}
+	return nil
+}
+
+// CheckURL rejects URLs whose hostname or literal IP is denied without resolving DNS; the dialer
+// still checks every address that is actually connected to.
+func (p EgressPolicy) CheckURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := parsed.Hostname()
+	hostAllowed, err := p.checkHost(host)
+	if err != nil {
+		return err
+	}
+	if addr, parseErr := netip.ParseAddr(host); parseErr == nil {
+		return p.checkAddr(host, addr, hostAllowed)
+	}
+	return nil
+}
+
+// DialContext connects like net.Dialer but checks the policy against the resolved address right
+// before each connection, so DNS rebinding cannot slip a private address past an earlier lookup.
+func (p EgressPolicy) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	hostAllowed, err := p.checkHost(host)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, parseErr := netip.ParseAddrPort(address)
+			if parseErr != nil {
+				return parseErr
+			}
+			return p.checkAddr(host, addrPort.Addr(), hostAllowed)
+		},
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+// HTTPClient returns a client that enforces the policy on every connection, including redirects.
+// Proxies are ignored since they would dial on the client's behalf.
+func (p EgressPolicy) HTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = p.DialContext
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if request.URL.Scheme != "http" && request.URL.Scheme != "https" {
+				return &EgressDeniedError{Host: request.URL.Host, Reason: "redirect to unsupported scheme " + request.URL.Scheme}
+			}
+			return p.CheckURL(request.URL.String())
+		},
+	}
+}
+
+// ParseCIDRs parses a comma-separated list of CIDRs or single addresses.
+func ParseCIDRs(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range splitList(list) {
+		if addr, err := netip.ParseAddr(item); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ParseHosts parses a comma-separated list of hostnames.
+func ParseHosts(list string) []string {
+	return splitList(list)
+}
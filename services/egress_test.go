@@ -0,0 +1,104 @@
+package services_test
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	. "snapp-task/services"
+)
+
+var _ = Describe("EgressPolicy", func() {
+	DescribeTable("CheckURL with the default policy",
+		func(rawURL string, allowed bool) {
+			err := EgressPolicy{}.CheckURL(rawURL)
+			if allowed {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(errors.Is(err, ErrEgressDenied)).To(BeTrue())
+			}
+		},
+		Entry("public host", "https://example.com/", true),
+		Entry("public address", "http://93.184.216.34/", true),
+		Entry("metadata service", "http://169.254.169.254/latest/meta-data", false),
+		Entry("loopback", "http://127.0.0.1:8080/", false),
+		Entry("private range", "http://10.1.2.3/", false),
+		Entry("IPv6 loopback", "http://[::1]/", false),
+		Entry("IPv4-mapped loopback", "http://[::ffff:127.0.0.1]/", false),
+	)
+
+	It("should let allow and deny lists override the private range check", func() {
+		policy := EgressPolicy{
+			AllowCIDRs: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/24")},
+			DenyCIDRs:  []netip.Prefix{netip.MustParsePrefix("10.0.0.5/32"), netip.MustParsePrefix("93.184.216.0/24")},
+			DenyHosts:  []string{"*.internal.example.com"},
+		}
+		Expect(policy.CheckURL("http://10.0.0.1/")).To(Succeed())
+		Expect(policy.CheckURL("http://10.0.0.5/")).To(MatchError(ErrEgressDenied))
+		Expect(policy.CheckURL("http://93.184.216.34/")).To(MatchError(ErrEgressDenied))
+		Expect(policy.CheckURL("http://admin.internal.example.com/")).To(MatchError(ErrEgressDenied))
+		Expect(policy.CheckURL("http://internal.example.com/")).To(Succeed())
+	})
+
+	Describe("HTTPClient", func() {
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				if request.URL.Path == "/redirect" {
+					target, _ := url.Parse(server.URL)
+					target.Host = "localhost:" + target.Port()
+					http.Redirect(writer, request, target.String(), http.StatusFound)
+					return
+				}
+				writer.Write([]byte("ok"))
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should refuse to connect to a private address", func() {
+			_, err := EgressPolicy{}.HTTPClient().Get(server.URL)
+			Expect(errors.Is(err, ErrEgressDenied)).To(BeTrue())
+		})
+
+		It("should check the resolved address, not just the hostname", func() {
+			target, _ := url.Parse(server.URL)
+			target.Host = "localhost:" + target.Port()
+			Expect(EgressPolicy{}.CheckURL(target.String())).To(Succeed())
+			_, err := EgressPolicy{}.HTTPClient().Get(target.String())
+			Expect(errors.Is(err, ErrEgressDenied)).To(BeTrue())
+		})
+
+		It("should connect to allowed destinations", func() {
+			policy := EgressPolicy{AllowCIDRs: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}}
+			resp, err := policy.HTTPClient().Get(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("should apply the policy to redirects", func() {
+			policy := EgressPolicy{AllowPrivate: true, DenyHosts: []string{"localhost"}}
+			_, err := policy.HTTPClient().Get(server.URL + "/redirect")
+			Expect(errors.Is(err, ErrEgressDenied)).To(BeTrue())
+		})
+	})
+
+	It("should parse CIDR and address lists", func() {
+		prefixes, err := ParseCIDRs("10.0.0.0/8, 192.168.1.7,fd00::/8")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(prefixes).To(Equal([]netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("192.168.1.7/32"),
+			netip.MustParsePrefix("fd00::/8"),
+		}))
+		_, err = ParseCIDRs("10.0.0.0/33")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"snapp-task/db"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventCheck EventType = "check"
+	EventMatch EventType = "match"
+	EventState EventType = "state"
+	EventAlert EventType = "alert"
+)
+
+type MonitorState string
+
+const (
+	StateUp   MonitorState = "up"
+	StateDown MonitorState = "down"
+)
+
+// Event is something that happened to a monitor. Fields beyond the common ones depend on Type: check
+// events carry Matched, Error and Duration, match events carry Data, state events carry State and
+// alert events carry RuleID, Alert and the rule's name as Data.
+type Event struct {
+	ID        uint64
+	Type      EventType
+	MonitorID int64
+	TenantID  int64
+	URL       string
+	Time      time.Time
+	Matched   bool
+	Error     string
+	Duration  time.Duration
+	Data      string
+	State     MonitorState
+	RuleID    int64
+	Alert     db.AlertStatus
+}
+
+const subscriberBuffer = 64
+
+// Subscription receives the events published after it was created. It is closed when the bus drops it
+// for falling behind, after which the subscriber can resume from the last event it saw.
+type Subscription struct {
+	C      <-chan Event
+	events chan Event
+	filter func(Event) bool
+	closed bool
+}
+
+// EventBus fans events out from checkers to subscribers and keeps the most recent ones so that
+// subscribers can resume after a disconnect.
+type EventBus struct {
+	mu          sync.Mutex
+	nextID      uint64
+	recent      []Event
+	capacity    int
+	subscribers map[*Subscription]struct{}
+	states      map[int64]MonitorState
+	// forgotten holds the deleted monitors, whose checks still in flight must not track their state again.
+	forgotten map[int64]bool
+}
+
+func NewEventBus(capacity int) *EventBus {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &EventBus{
+		nextID:      1,
+		capacity:    capacity,
+		subscribers: make(map[*Subscription]struct{}),
+		states:      make(map[int64]MonitorState),
+		forgotten:   make(map[int64]bool),
+	}
+}
+
+// Publish assigns event the next ID and delivers it to every matching subscriber without blocking.
+// Subscribers whose buffer is full are closed.
+func (b *EventBus) Publish(event Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	event.ID = b.nextID
+	b.nextID++
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if len(b.recent) == b.capacity {
+		b.recent = append(b.recent[:0], b.recent[1:]...)
+	}
+	b.recent = append(b.recent, event)
+	for sub := range b.subscribers {
+		if !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			b.remove(sub)
+		}
+	}
+	return event
+}
+
+// PublishCheck publishes the outcome of a check, followed by a state event when it flips the monitor
+// between up and down.
+func (b *EventBus) PublishCheck(monitor db.Monitor, matched bool, duration time.Duration, checkErr error) {
+	event := Event{Type: EventCheck, MonitorID: monitor.ID, TenantID: monitor.TenantID, URL: monitor.URL, Matched: matched, Duration: duration}
+	state := StateUp
+	if checkErr != nil {
+		event.Error = checkErr.Error()
+		state = StateDown
+	}
+	b.Publish(event)
+	b.mu.Lock()
+	if b.forgotten[monitor.ID] {
+		b.mu.Unlock()
+		return
+	}
+	previous, known := b.states[monitor.ID]
+	b.states[monitor.ID] = state
+	b.mu.Unlock()
+	if !known || previous != state {
+		b.Publish(Event{Type: EventState, MonitorID: monitor.ID, TenantID: monitor.TenantID, URL: monitor.URL, State: state})
+	}
+}
+
+// Forget drops the state of a deleted monitor. Its checks published afterwards no longer publish state
+// events.
+func (b *EventBus) Forget(monitorID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.states, monitorID)
+	b.forgotten[monitorID] = true
+}
+
+// Subscribe registers a subscriber for the events accepted by filter. When lastID is non-zero the
+// retained events after it are returned for replay. If lastID is ahead of the bus, e.g. after a
+// restart, every retained event is replayed.
+func (b *EventBus) Subscribe(lastID uint64, filter func(Event) bool) (*Subscription, []Event) {
+	events := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: events, events: events, filter: filter}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var replay []Event
+	if lastID != 0 {
+		if lastID >= b.nextID {
+			lastID = 0
+		}
+		for _, event := range b.recent {
+			if event.ID > lastID && filter(event) {
+				replay = append(replay, event)
+			}
+		}
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub, replay
+}
+
+func (b *EventBus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(sub)
+}
+
+func (b *EventBus) remove(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(b.subscribers, sub)
+	close(sub.events)
+}
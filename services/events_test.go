@@ -0,0 +1,73 @@
+package services_test
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"snapp-task/db"
+	. "snapp-task/services"
+)
+
+var _ = Describe("EventBus", func() {
+	var bus *EventBus
+	all := func(Event) bool { return true }
+
+	BeforeEach(func() {
+		bus = NewEventBus(3)
+	})
+
+	It("should deliver published events to matching subscribers", func() {
+		sub, replay := bus.Subscribe(0, func(event Event) bool { return event.MonitorID == 1 })
+		Expect(replay).To(BeEmpty())
+		bus.Publish(Event{Type: EventMatch, MonitorID: 2})
+		published := bus.Publish(Event{Type: EventMatch, MonitorID: 1, Data: "a"})
+		Expect(published.ID).To(Equal(uint64(2)))
+		Expect(sub.C).To(Receive(HaveField("Data", "a")))
+		Expect(sub.C).NotTo(Receive())
+	})
+
+	It("should replay the retained events after the last seen ID", func() {
+		for i := 1; i <= 4; i++ {
+			bus.Publish(Event{Type: EventCheck, MonitorID: int64(i)})
+		}
+		_, replay := bus.Subscribe(2, all)
+		Expect(replay).To(HaveLen(2))
+		Expect(replay[0].ID).To(Equal(uint64(3)))
+
+		_, replay = bus.Subscribe(1, all)
+		Expect(replay).To(HaveLen(3), "event 1 fell out of the buffer")
+
+		_, replay = bus.Subscribe(100, all)
+		Expect(replay).To(HaveLen(3), "an ID from before a restart replays everything")
+	})
+
+	It("should publish state events only when a monitor goes up or down", func() {
+		sub, _ := bus.Subscribe(0, func(event Event) bool { return event.Type == EventState })
+		monitor := db.Monitor{ID: 1}
+		bus.PublishCheck(monitor, true, 0, nil)
+		bus.PublishCheck(monitor, false, 0, nil)
+		bus.PublishCheck(monitor, false, 0, errors.New("down"))
+		Expect(sub.C).To(Receive(HaveField("State", StateUp)))
+		Expect(sub.C).To(Receive(HaveField("State", StateDown)))
+		Expect(sub.C).NotTo(Receive())
+	})
+
+	It("should stop tracking the state of a forgotten monitor", func() {
+		sub, _ := bus.Subscribe(0, func(event Event) bool { return event.Type == EventState })
+		monitor := db.Monitor{ID: 1}
+		bus.PublishCheck(monitor, true, 0, nil)
+		Expect(sub.C).To(Receive(HaveField("State", StateUp)))
+		bus.Forget(monitor.ID)
+		bus.PublishCheck(monitor, false, 0, errors.New("down"))
+		Expect(sub.C).NotTo(Receive())
+	})
+
+	It("should close subscribers that fall behind", func() {
+		sub, _ := bus.Subscribe(0, all)
+		for i := 0; i < 100; i++ {
+			bus.Publish(Event{Type: EventCheck})
+		}
+		Eventually(sub.C).Should(BeClosed())
+		bus.Unsubscribe(sub)
+	})
+})
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"log"
+	"snapp-task/db"
+	"time"
+)
+
+type Janitor struct {
+	Pruner      db.Pruner
+	Policy      db.RetentionPolicy
+	Interval    time.Duration
+	VacuumEvery int
+	// Silences, when set, has the silences that have ended deleted on every run.
+	Silences *Alerter
+	runs     int
+}
+
+func NewJanitor(pruner db.Pruner, policy db.RetentionPolicy, interval time.Duration, vacuumEvery int) *Janitor {
+	return &Janitor{Pruner: pruner, Policy: policy, Interval: interval, VacuumEvery: vacuumEvery}
+}
+
+// Run enforces the retention policy every Interval until ctx is cancelled.
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := j.RunOnce(now); err != nil {
+				log.Printf("Retention run failed: %v\n", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (j *Janitor) RunOnce(now time.Time) error {
+	result, err := j.Pruner.Prune(j.Policy, now)
+	if err != nil {
+		return err
+	}
+	if result.MatchesDeleted+result.CheckRunsDeleted+result.CheckRunsRolledUp+result.AggregatesDeleted+result.ValuesDeleted > 0 {
+		log.Printf("Pruned %d matches, %d check runs, %d values, rolled up %d check runs, dropped %d aggregates\n",
+			result.MatchesDeleted, result.CheckRunsDeleted, result.ValuesDeleted, result.CheckRunsRolledUp, result.AggregatesDeleted)
+	}
+	if j.Silences != nil {
+		pruned, err := j.Silences.PruneSilences(now)
+		if err != nil {
+			return err
+		}
+		if pruned > 0 {
+			log.Printf("Pruned %d expired silences\n", pruned)
+		}
+	}
+	j.runs++
+	if j.VacuumEvery > 0 && j.runs%j.VacuumEvery == 0 {
+		return j.Pruner.Vacuum()
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"snapp-task/db"
+	. "snapp-task/services"
+	"time"
+)
+
+var _ = Describe("Janitor", func() {
+	var (
+		mockPruner *db.PrunerMock
+		janitor    *Janitor
+		policy     db.RetentionPolicy
+	)
+
+	BeforeEach(func() {
+		policy = db.RetentionPolicy{MaxAge: time.Hour, MaxRowsPerMonitor: 10}
+		mockPruner = &db.PrunerMock{
+			PruneFunc: func(policy db.RetentionPolicy, now time.Time) (db.PruneResult, error) {
+				return db.PruneResult{MatchesDeleted: 1}, nil
+			},
+			VacuumFunc: func() error {
+				return nil
+			},
+		}
+		janitor = NewJanitor(mockPruner, policy, 50*time.Millisecond, 2)
+	})
+
+	Describe("RunOnce", func() {
+		It("should prune with the configured policy", func() {
+			now := time.Now()
+			Expect(janitor.RunOnce(now)).To(Succeed())
+			Expect(mockPruner.PruneCalls()).To(HaveLen(1))
+			Expect(mockPruner.PruneCalls()[0].Policy).To(Equal(policy))
+			Expect(mockPruner.PruneCalls()[0].Now).To(Equal(now))
+		})
+
+		It("should vacuum every VacuumEvery runs", func() {
+			for i := 0; i < 4; i++ {
+				Expect(janitor.RunOnce(time.Now())).To(Succeed())
+			}
+			Expect(mockPruner.VacuumCalls()).To(HaveLen(2))
+		})
+
+		It("should return prune errors without vacuuming", func() {
+			mockPruner.PruneFunc = func(policy db.RetentionPolicy, now time.Time) (db.PruneResult, error) {
+				return db.PruneResult{}, fmt.Errorf("prune error")
+			}
+			Expect(janitor.RunOnce(time.Now())).To(MatchError("prune error"))
+			Expect(janitor.RunOnce(time.Now())).To(MatchError("prune error"))
+			Expect(mockPruner.VacuumCalls()).To(BeEmpty())
+		})
+
+		It("should delete the silences that have ended", func() {
+			now := time.Now()
+			store := &db.AlertStoreMock{
+				ListAlertRulesFunc:  func() ([]db.AlertRule, error) { return nil, nil },
+				ListAlertStatesFunc: func() ([]db.AlertState, error) { return nil, nil },
+				ListSilencesFunc: func() ([]db.Silence, error) {
+					return []db.Silence{{ID: 1, EndsAt: now.Add(-time.Minute)}, {ID: 2, EndsAt: now.Add(time.Minute)}}, nil
+				},
+				DeleteSilenceFunc: func(id int64) error { return nil },
+			}
+			janitor.Silences = NewAlerter(store, NewEventBus(10))
+			Expect(janitor.Silences.Start()).To(Succeed())
+			Expect(janitor.RunOnce(now)).To(Succeed())
+			Expect(store.DeleteSilenceCalls()).To(ConsistOf(HaveField("ID", int64(1))))
+			Expect(janitor.Silences.Silences(0)).To(ConsistOf(HaveField("ID", int64(2))))
+		})
+	})
+
+	Describe("Run", func() {
+		It("should prune periodically until cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				janitor.Run(ctx)
+				close(done)
+			}()
+
+			Eventually(func() int {
+				return len(mockPruner.PruneCalls())
+			}, time.Second, 10*time.Millisecond).Should(BeNumerically(">=", 2))
+			cancel()
+			Eventually(done).Should(BeClosed())
+		})
+	})
+})
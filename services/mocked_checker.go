@@ -17,6 +17,9 @@ var _ UrlChecker = &UrlCheckerMock{}
 //
 //		// make and configure a mocked UrlChecker
 //		mockedUrlChecker := &UrlCheckerMock{
+//			CheckFunc: func(dryRun bool) CheckResult {
+//				panic("mock out the Check method")
+//			},
 //			CheckDataFunc: func() error {
 //				panic("mock out the CheckData method")
 //			},
@@ -27,18 +30,59 @@ var _ UrlChecker = &UrlCheckerMock{}
 //
 //	}
 type UrlCheckerMock struct {
+	// CheckFunc mocks the Check method.
+	CheckFunc func(dryRun bool) CheckResult
+
 	// CheckDataFunc mocks the CheckData method.
 	CheckDataFunc func() error
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// Check holds details about calls to the Check method.
+		Check []struct {
+			// DryRun is the dryRun argument value.
+			DryRun bool
+		}
 		// CheckData holds details about calls to the CheckData method.
 		CheckData []struct {
 		}
 	}
+	lockCheck     sync.RWMutex
 	lockCheckData sync.RWMutex
 }
 
+// Check calls CheckFunc.
+func (mock *UrlCheckerMock) Check(dryRun bool) CheckResult {
+	if mock.CheckFunc == nil {
+		panic("UrlCheckerMock.CheckFunc: method is nil but UrlChecker.Check was just called")
+	}
+	callInfo := struct {
+		DryRun bool
+	}{
+		DryRun: dryRun,
+	}
+	mock.lockCheck.Lock()
+	mock.calls.Check = append(mock.calls.Check, callInfo)
+	mock.lockCheck.Unlock()
+	return mock.CheckFunc(dryRun)
+}
+
+// CheckCalls gets all the calls that were made to Check.
+// Check the length with:
+//
+//	len(mockedUrlChecker.CheckCalls())
+func (mock *UrlCheckerMock) CheckCalls() []struct {
+	DryRun bool
+} {
+	var calls []struct {
+		DryRun bool
+	}
+	mock.lockCheck.RLock()
+	calls = mock.calls.Check
+	mock.lockCheck.RUnlock()
+	return calls
+}
+
 // CheckData calls CheckDataFunc.
 func (mock *UrlCheckerMock) CheckData() error {
 	if mock.CheckDataFunc == nil {
@@ -4,6 +4,8 @@
 package services
 
 import (
+	"context"
+	"snapp-task/db"
 	"sync"
 )
 
@@ -17,9 +19,18 @@ var _ CheckScheduler = &CheckSchedulerMock{}
 //
 //		// make and configure a mocked CheckScheduler
 //		mockedCheckScheduler := &CheckSchedulerMock{
-//			ScheduleCheckFunc: func()  {
+//			RunNowFunc: func()  {
+//				panic("mock out the RunNow method")
+//			},
+//			ScheduleCheckFunc: func(ctx context.Context)  {
 //				panic("mock out the ScheduleCheck method")
 //			},
+//			SetPausedFunc: func(paused bool)  {
+//				panic("mock out the SetPaused method")
+//			},
+//			UpdateMonitorFunc: func(monitor db.Monitor)  {
+//				panic("mock out the UpdateMonitor method")
+//			},
 //		}
 //
 //		// use mockedCheckScheduler in code that requires CheckScheduler
@@ -27,29 +38,86 @@ var _ CheckScheduler = &CheckSchedulerMock{}
 //
 //	}
 type CheckSchedulerMock struct {
+	// RunNowFunc mocks the RunNow method.
+	RunNowFunc func()
+
 	// ScheduleCheckFunc mocks the ScheduleCheck method.
-	ScheduleCheckFunc func()
+	ScheduleCheckFunc func(ctx context.Context)
+
+	// SetPausedFunc mocks the SetPaused method.
+	SetPausedFunc func(paused bool)
+
+	// UpdateMonitorFunc mocks the UpdateMonitor method.
+	UpdateMonitorFunc func(monitor db.Monitor)
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// RunNow holds details about calls to the RunNow method.
+		RunNow []struct {
+		}
 		// ScheduleCheck holds details about calls to the ScheduleCheck method.
 		ScheduleCheck []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// SetPaused holds details about calls to the SetPaused method.
+		SetPaused []struct {
+			// Paused is the paused argument value.
+			Paused bool
+		}
+		// UpdateMonitor holds details about calls to the UpdateMonitor method.
+		UpdateMonitor []struct {
+			// Monitor is the monitor argument value.
+			Monitor db.Monitor
 		}
 	}
+	lockRunNow        sync.RWMutex
 	lockScheduleCheck sync.RWMutex
+	lockSetPaused     sync.RWMutex
+	lockUpdateMonitor sync.RWMutex
+}
+
+// RunNow calls RunNowFunc.
+func (mock *CheckSchedulerMock) RunNow() {
+	if mock.RunNowFunc == nil {
+		panic("CheckSchedulerMock.RunNowFunc: method is nil but CheckScheduler.RunNow was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockRunNow.Lock()
+	mock.calls.RunNow = append(mock.calls.RunNow, callInfo)
+	mock.lockRunNow.Unlock()
+	mock.RunNowFunc()
+}
+
+// RunNowCalls gets all the calls that were made to RunNow.
+// Check the length with:
+//
+//	len(mockedCheckScheduler.RunNowCalls())
+func (mock *CheckSchedulerMock) RunNowCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockRunNow.RLock()
+	calls = mock.calls.RunNow
+	mock.lockRunNow.RUnlock()
+	return calls
 }
 
 // ScheduleCheck calls ScheduleCheckFunc.
-func (mock *CheckSchedulerMock) ScheduleCheck() {
+func (mock *CheckSchedulerMock) ScheduleCheck(ctx context.Context) {
 	if mock.ScheduleCheckFunc == nil {
 		panic("CheckSchedulerMock.ScheduleCheckFunc: method is nil but CheckScheduler.ScheduleCheck was just called")
 	}
 	callInfo := struct {
-	}{}
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
 	mock.lockScheduleCheck.Lock()
 	mock.calls.ScheduleCheck = append(mock.calls.ScheduleCheck, callInfo)
 	mock.lockScheduleCheck.Unlock()
-	mock.ScheduleCheckFunc()
+	mock.ScheduleCheckFunc(ctx)
 }
 
 // ScheduleCheckCalls gets all the calls that were made to ScheduleCheck.
@@ -57,11 +125,77 @@ func (mock *CheckSchedulerMock) ScheduleCheck() {
 //
 //	len(mockedCheckScheduler.ScheduleCheckCalls())
 func (mock *CheckSchedulerMock) ScheduleCheckCalls() []struct {
+	Ctx context.Context
 } {
 	var calls []struct {
+		Ctx context.Context
 	}
 	mock.lockScheduleCheck.RLock()
 	calls = mock.calls.ScheduleCheck
 	mock.lockScheduleCheck.RUnlock()
 	return calls
 }
+
+// SetPaused calls SetPausedFunc.
+func (mock *CheckSchedulerMock) SetPaused(paused bool) {
+	if mock.SetPausedFunc == nil {
+		panic("CheckSchedulerMock.SetPausedFunc: method is nil but CheckScheduler.SetPaused was just called")
+	}
+	callInfo := struct {
+		Paused bool
+	}{
+		Paused: paused,
+	}
+	mock.lockSetPaused.Lock()
+	mock.calls.SetPaused = append(mock.calls.SetPaused, callInfo)
+	mock.lockSetPaused.Unlock()
+	mock.SetPausedFunc(paused)
+}
+
+// SetPausedCalls gets all the calls that were made to SetPaused.
+// Check the length with:
+//
+//	len(mockedCheckScheduler.SetPausedCalls())
+func (mock *CheckSchedulerMock) SetPausedCalls() []struct {
+	Paused bool
+} {
+	var calls []struct {
+		Paused bool
+	}
+	mock.lockSetPaused.RLock()
+	calls = mock.calls.SetPaused
+	mock.lockSetPaused.RUnlock()
+	return calls
+}
+
+// UpdateMonitor calls UpdateMonitorFunc.
+func (mock *CheckSchedulerMock) UpdateMonitor(monitor db.Monitor) {
+	if mock.UpdateMonitorFunc == nil {
+		panic("CheckSchedulerMock.UpdateMonitorFunc: method is nil but CheckScheduler.UpdateMonitor was just called")
+	}
+	callInfo := struct {
+		Monitor db.Monitor
+	}{
+		Monitor: monitor,
+	}
+	mock.lockUpdateMonitor.Lock()
+	mock.calls.UpdateMonitor = append(mock.calls.UpdateMonitor, callInfo)
+	mock.lockUpdateMonitor.Unlock()
+	mock.UpdateMonitorFunc(monitor)
+}
+
+// UpdateMonitorCalls gets all the calls that were made to UpdateMonitor.
+// Check the length with:
+//
+//	len(mockedCheckScheduler.UpdateMonitorCalls())
+func (mock *CheckSchedulerMock) UpdateMonitorCalls() []struct {
+	Monitor db.Monitor
+} {
+	var calls []struct {
+		Monitor db.Monitor
+	}
+	mock.lockUpdateMonitor.RLock()
+	calls = mock.calls.UpdateMonitor
+	mock.lockUpdateMonitor.RUnlock()
+	return calls
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"snapp-task/db"
+	"strings"
+)
+
+// CanonicalURL normalizes the parts of a URL that don't change what is fetched: scheme and host case,
+// default ports, an empty path, query parameter order and the fragment.
+func CanonicalURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	host := strings.ToLower(parsed.Hostname())
+	port := parsed.Port()
+	if (parsed.Scheme == "http" && port == "80") || (parsed.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	parsed.Host = host
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+	parsed.RawQuery = parsed.Query().Encode()
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	return parsed.String()
+}
+
+// MonitorKey identifies monitors that would run the same check.
+func MonitorKey(monitor db.Monitor) string {
+	steps, _ := json.Marshal(monitor.Steps)
+	return fmt.Sprintf("%s\n%s\n%s\n%t\n%s\n%s\n%s\n%s\n%s\n%s\n%s", CanonicalURL(monitor.URL), monitor.Pattern, monitor.Interval, monitor.Dedup,
+		monitor.Selector, monitor.XPath, monitor.CSVColumn, monitor.ValuePath, monitor.Condition, monitor.Rule, steps)
+}
+
+type DuplicateMonitorError struct {
+	ExistingID int64
+}
+
+func (e *DuplicateMonitorError) Error() string {
+	return fmt.Sprintf("monitor already exists with id %d", e.ExistingID)
+}
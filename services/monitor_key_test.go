@@ -0,0 +1,39 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"snapp-task/db"
+	. "snapp-task/services"
+	"time"
+)
+
+var _ = Describe("MonitorKey", func() {
+	DescribeTable("CanonicalURL",
+		func(raw, expected string) {
+			Expect(CanonicalURL(raw)).To(Equal(expected))
+		},
+		Entry("lowercases scheme and host", "HTTPS://Example.COM/Path", "https://example.com/Path"),
+		Entry("drops default ports", "http://example.com:80/a", "http://example.com/a"),
+		Entry("keeps other ports", "https://example.com:8443/a", "https://example.com:8443/a"),
+		Entry("adds the root path", "https://example.com", "https://example.com/"),
+		Entry("sorts the query", "https://example.com/?b=2&a=1", "https://example.com/?a=1&b=2"),
+		Entry("drops the fragment", "https://example.com/#top", "https://example.com/"),
+	)
+
+	It("should treat equivalent monitors as the same key", func() {
+		monitor := db.Monitor{URL: "https://example.com/?a=1&b=2", Pattern: "p", Interval: time.Second}
+		other := db.Monitor{ID: 7, URL: "HTTPS://example.com:443/?b=2&a=1#x", Pattern: "p", Interval: time.Second, Revision: 3}
+		Expect(MonitorKey(monitor)).To(Equal(MonitorKey(other)))
+	})
+
+	It("should tell apart monitors with different options", func() {
+		monitor := db.Monitor{URL: "https://example.com", Pattern: "p", Interval: time.Second}
+		other := monitor
+		other.Dedup = true
+		Expect(MonitorKey(monitor)).NotTo(Equal(MonitorKey(other)))
+		other = monitor
+		other.Interval = time.Minute
+		Expect(MonitorKey(monitor)).NotTo(Equal(MonitorKey(other)))
+	})
+})
@@ -0,0 +1,13 @@
+package services
+
+import "fmt"
+
+// ParseError reports where a value path or rule failed to parse.
+type ParseError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at offset %d", e.Msg, e.Offset)
+}
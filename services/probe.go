@@ -0,0 +1,319 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultExpiryDays is how many days before its certificate expires a tls monitor matches, unless the URL
+// sets days.
+const DefaultExpiryDays = 14
+
+// maxBannerBytes caps how much of a TCP banner is read, and bannerTimeout how long it is waited for.
+const (
+	maxBannerBytes = 4096
+	bannerTimeout  = 500 * time.Millisecond
+)
+
+// DNSRecordTypes are the record types dns monitors can look up.
+var DNSRecordTypes = []string{"A", "AAAA", "CNAME", "MX", "NS", "TXT"}
+
+// Probe is a parsed non-HTTP monitor URL:
+//
+//	tcp://host:port                      connects, and matches the pattern against the banner the server sends
+//	dns://[server[:port]]/name[?type=MX]  resolves name, and matches the pattern against each record
+//	tls://host[:port][?days=N]           matches when the certificate expires in fewer than N days
+type Probe struct {
+	// Kind is "tcp", "dns" or "tls".
+	Kind string
+	// Address is the host:port to connect to; for dns it is the server, or "" for the system resolver.
+	Address string
+	// Host is the name to resolve for dns and the name the certificate is verified for with tls.
+	Host       string
+	RecordType string
+	ExpiryDays int
+}
+
+// IsProbeURL reports whether rawURL is a tcp, dns or tls monitor URL rather than an HTTP one.
+func IsProbeURL(rawURL string) bool {
+	scheme, _, _ := strings.Cut(rawURL, "://")
+	switch strings.ToLower(scheme) {
+	case "tcp", "dns", "tls":
+		return true
+	}
+	return false
+}
+
+func ParseProbe(rawURL string) (*Probe, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	probe := &Probe{Kind: strings.ToLower(parsed.Scheme)}
+	query := parsed.Query()
+	switch probe.Kind {
+	case "tcp", "tls":
+		if parsed.Hostname() == "" {
+			return nil, errors.New("url has no host")
+		}
+		if parsed.Path != "" && parsed.Path != "/" {
+			return nil, fmt.Errorf("%s urls have no path", probe.Kind)
+		}
+		port := parsed.Port()
+		if port == "" && probe.Kind == "tcp" {
+			return nil, errors.New("tcp urls need a port")
+		}
+		if port == "" {
+			port = "443"
+		}
+		probe.Host = parsed.Hostname()
+		probe.Address = net.JoinHostPort(probe.Host, port)
+	case "dns":
+		probe.Host = strings.Trim(parsed.Path, "/")
+		if probe.Host == "" || strings.Contains(probe.Host, "/") {
+			return nil, errors.New("dns urls name what to resolve as their path, like dns:///example.com")
+		}
+		if parsed.Host != "" {
+			port := parsed.Port()
+			if port == "" {
+				port = "53"
+			}
+			probe.Address = net.JoinHostPort(parsed.Hostname(), port)
+		}
+		probe.RecordType = strings.ToUpper(query.Get("type"))
+		if probe.RecordType == "" {
+			probe.RecordType = "A"
+		}
+		if !isDNSRecordType(probe.RecordType) {
+			return nil, fmt.Errorf("unsupported record type %q, expected one of %s", probe.RecordType, strings.Join(DNSRecordTypes, ", "))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported probe scheme %q", parsed.Scheme)
+	}
+	if probe.Kind == "tls" {
+		probe.ExpiryDays = DefaultExpiryDays
+		if days := query.Get("days"); days != "" {
+			if probe.ExpiryDays, err = strconv.Atoi(days); err != nil || probe.ExpiryDays < 1 {
+				return nil, fmt.Errorf("days must be a positive number of days, got %q", days)
+			}
+		}
+	}
+	return probe, nil
+}
+
+func isDNSRecordType(recordType string) bool {
+	for _, supported := range DNSRecordTypes {
+		if recordType == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// ProbeChecker is the UrlChecker of tcp, dns and tls monitors. It connects through the same dialer as
+// the HTTP client, so the egress policy applies to probes too.
+type ProbeChecker struct {
+	UrlCheckerImpl
+}
+
+func (pc *ProbeChecker) CheckData() error {
+	return pc.Check(false).Err
+}
+
+func (pc *ProbeChecker) Check(dryRun bool) CheckResult {
+	return pc.runCheck(dryRun, checkTimeout, pc.checkProbe)
+}
+
+func (pc *ProbeChecker) checkProbe(dryRun bool) CheckResult {
+	var result CheckResult
+	probe, err := ParseProbe(pc.Monitor.URL)
+	if err != nil {
+		result.Err = fmt.Errorf("invalid probe url: %v", err)
+		return result
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	switch probe.Kind {
+	case "tcp":
+		pc.probeTCP(ctx, probe, &result, dryRun)
+	case "dns":
+		pc.probeDNS(ctx, probe, &result, dryRun)
+	default:
+		pc.probeTLS(ctx, probe, &result, dryRun)
+	}
+	return result
+}
+
+func (pc *ProbeChecker) dialContext() func(ctx context.Context, network, address string) (net.Conn, error) {
+	if transport, ok := pc.client().Transport.(*http.Transport); ok && transport.DialContext != nil {
+		return transport.DialContext
+	}
+	return (&net.Dialer{}).DialContext
+}
+
+// rootCAs returns the roots the HTTP client trusts, or nil for the system's.
+func (pc *ProbeChecker) rootCAs() *x509.CertPool {
+	if transport, ok := pc.client().Transport.(*http.Transport); ok && transport.TLSClientConfig != nil {
+		return transport.TLSClientConfig.RootCAs
+	}
+	return nil
+}
+
+// probeTCP connects to the address. With a pattern, it reads the server's banner until the pattern
+// matches, the server stops sending or bannerTimeout passes.
+func (pc *ProbeChecker) probeTCP(ctx context.Context, probe *Probe, result *CheckResult, dryRun bool) {
+	conn, err := pc.dialContext()(ctx, "tcp", probe.Address)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to connect: %v", err)
+		return
+	}
+	defer conn.Close()
+	if pc.Monitor.Pattern == "" {
+		return
+	}
+	match, err := pc.textMatcher()
+	if err != nil {
+		result.Err = err
+		return
+	}
+	conn.SetReadDeadline(time.Now().Add(bannerTimeout))
+	var banner []byte
+	buffer := make([]byte, maxBannerBytes)
+	for len(banner) < maxBannerBytes {
+		n, err := conn.Read(buffer[:maxBannerBytes-len(banner)])
+		banner = append(banner, buffer[:n]...)
+		if match(string(banner)) {
+			pc.recordMatch(result, string(banner), dryRun)
+			return
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, os.ErrDeadlineExceeded) {
+			return
+		}
+		if err != nil {
+			result.Err = fmt.Errorf("failed to read banner: %v", err)
+			return
+		}
+	}
+}
+
+// probeDNS looks up the records. Finding none is an error; with a pattern, the first matching record
+// is the match.
+func (pc *ProbeChecker) probeDNS(ctx context.Context, probe *Probe, result *CheckResult, dryRun bool) {
+	resolver := net.DefaultResolver
+	if probe.Address != "" {
+		dial := pc.dialContext()
+		resolver = &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dial(ctx, network, probe.Address)
+		}}
+	}
+	records, err := lookupRecords(ctx, resolver, probe.Host, probe.RecordType)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to resolve %s: %v", probe.Host, err)
+		return
+	}
+	if len(records) == 0 {
+		result.Err = fmt.Errorf("no %s records for %s", probe.RecordType, probe.Host)
+		return
+	}
+	if pc.Monitor.Pattern == "" {
+		return
+	}
+	match, err := pc.textMatcher()
+	if err != nil {
+		result.Err = err
+		return
+	}
+	for _, record := range records {
+		if match(record) {
+			pc.recordMatch(result, record, dryRun)
+			return
+		}
+	}
+}
+
+func lookupRecords(ctx context.Context, resolver *net.Resolver, host, recordType string) ([]string, error) {
+	var records []string
+	switch recordType {
+	case "A", "AAAA":
+		network := "ip4"
+		if recordType == "AAAA" {
+			network = "ip6"
+		}
+		ips, err := resolver.LookupIP(ctx, network, host)
+		for _, ip := range ips {
+			records = append(records, ip.String())
+		}
+		return records, err
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		return []string{cname}, nil
+	case "MX":
+		mxs, err := resolver.LookupMX(ctx, host)
+		for _, mx := range mxs {
+			records = append(records, fmt.Sprintf("%d %s", mx.Pref, mx.Host))
+		}
+		return records, err
+	case "NS":
+		nss, err := resolver.LookupNS(ctx, host)
+		for _, ns := range nss {
+			records = append(records, ns.Host)
+		}
+		return records, err
+	default:
+		return resolver.LookupTXT(ctx, host)
+	}
+}
+
+// probeTLS reads the server's certificate and matches when it expires in fewer than the probe's days.
+// The days left are the result's value. Expiry aside, the certificate has to verify for the host.
+func (pc *ProbeChecker) probeTLS(ctx context.Context, probe *Probe, result *CheckResult, dryRun bool) {
+	rawConn, err := pc.dialContext()(ctx, "tcp", probe.Address)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to connect: %v", err)
+		return
+	}
+	defer rawConn.Close()
+	// Verification is done below, so an expired certificate is reported as expiring instead of failing
+	// the handshake.
+	conn := tls.Client(rawConn, &tls.Config{ServerName: probe.Host, InsecureSkipVerify: true})
+	if err = conn.HandshakeContext(ctx); err != nil {
+		result.Err = fmt.Errorf("TLS handshake failed: %v", err)
+		return
+	}
+	certificates := conn.ConnectionState().PeerCertificates
+	leaf := certificates[0]
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+	now := time.Now()
+	verifyAt := now
+	if verifyAt.After(leaf.NotAfter) {
+		verifyAt = leaf.NotAfter
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: probe.Host, Roots: pc.rootCAs(), Intermediates: intermediates, CurrentTime: verifyAt})
+	if err != nil {
+		result.Err = fmt.Errorf("certificate does not verify: %v", err)
+		return
+	}
+	daysLeft := math.Floor(leaf.NotAfter.Sub(now).Hours() / 24)
+	result.Value = &daysLeft
+	if daysLeft < float64(probe.ExpiryDays) {
+		pc.recordMatch(result, fmt.Sprintf("certificate for %s expires at %s", probe.Host, leaf.NotAfter.UTC().Format(time.RFC3339)), dryRun)
+	}
+}
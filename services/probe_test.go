@@ -0,0 +1,211 @@
+package services_test
+
+import (
+	"crypto/tls"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/dns/dnsmessage"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"snapp-task/db"
+	. "snapp-task/services"
+	"strings"
+)
+
+// serveDNS answers every A query with 192.0.2.1 and every MX query with two exchanges.
+func serveDNS(conn net.PacketConn) {
+	buffer := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		var request dnsmessage.Message
+		if request.Unpack(buffer[:n]) != nil || len(request.Questions) == 0 {
+			continue
+		}
+		question := request.Questions[0]
+		builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: request.ID, Response: true, Authoritative: true})
+		builder.StartQuestions()
+		builder.Question(question)
+		builder.StartAnswers()
+		header := dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 60}
+		switch question.Type {
+		case dnsmessage.TypeA:
+			builder.AResource(header, dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}})
+		case dnsmessage.TypeMX:
+			builder.MXResource(header, dnsmessage.MXResource{Pref: 10, MX: dnsmessage.MustNewName("mx1.example.com.")})
+			builder.MXResource(header, dnsmessage.MXResource{Pref: 20, MX: dnsmessage.MustNewName("mx2.example.com.")})
+		}
+		response, _ := builder.Finish()
+		conn.WriteTo(response, addr)
+	}
+}
+
+var _ = Describe("ProbeChecker", func() {
+	var (
+		mockDB  *db.DBMock
+		client  *http.Client
+		url     string
+		pattern string
+		result  CheckResult
+	)
+
+	BeforeEach(func() {
+		mockDB = &db.DBMock{SaveDataFunc: func(match db.Match) error {
+			return nil
+		}}
+		client = nil
+	})
+
+	JustBeforeEach(func() {
+		checker := NewChecker(UrlCheckerImpl{Monitor: db.Monitor{ID: 1, URL: url, Pattern: pattern}, Db: mockDB, Client: client})
+		Expect(checker).To(BeAssignableToTypeOf(&ProbeChecker{}))
+		result = checker.Check(false)
+	})
+
+	Context("when probing a TCP port", func() {
+		var listener net.Listener
+
+		BeforeEach(func() {
+			var err error
+			listener, err = net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			go func() {
+				for {
+					conn, err := listener.Accept()
+					if err != nil {
+						return
+					}
+					conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+					conn.Close()
+				}
+			}()
+			url = "tcp://" + listener.Addr().String()
+			pattern = "^SSH-2.0"
+		})
+
+		AfterEach(func() {
+			listener.Close()
+		})
+
+		It("should match the banner", func() {
+			Expect(result.Err).To(BeNil())
+			Expect(result.Matched).To(BeTrue())
+			Expect(mockDB.SaveDataCalls()).To(HaveLen(1))
+			Expect(mockDB.SaveDataCalls()[0].Match.Data).To(Equal("SSH-2.0-OpenSSH_9.6\r\n"))
+		})
+
+		Context("and the banner does not match", func() {
+			BeforeEach(func() {
+				pattern = "SMTP"
+			})
+			It("should connect without matching", func() {
+				Expect(result.Err).To(BeNil())
+				Expect(result.Matched).To(BeFalse())
+			})
+		})
+
+		Context("and nothing listens", func() {
+			BeforeEach(func() {
+				listener.Close()
+			})
+			It("should return an error", func() {
+				Expect(result.Err).To(MatchError(ContainSubstring("failed to connect")))
+			})
+		})
+	})
+
+	Context("when resolving DNS records", func() {
+		var server net.PacketConn
+
+		BeforeEach(func() {
+			var err error
+			server, err = net.ListenPacket("udp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			go serveDNS(server)
+			url = "dns://" + server.LocalAddr().String() + "/example.com?type=MX"
+			pattern = "mx2"
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should match the pattern against each record", func() {
+			Expect(result.Err).To(BeNil())
+			Expect(result.Matched).To(BeTrue())
+			Expect(mockDB.SaveDataCalls()[0].Match.Data).To(Equal("20 mx2.example.com."))
+		})
+
+		Context("of type A", func() {
+			BeforeEach(func() {
+				url = strings.TrimSuffix(url, "?type=MX")
+				pattern = "192.0.2.1"
+			})
+			It("should resolve addresses", func() {
+				Expect(result.Err).To(BeNil())
+				Expect(result.Matched).To(BeTrue())
+			})
+		})
+	})
+
+	Context("when checking a TLS certificate", func() {
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			client = server.Client()
+			url = "tls://" + server.Listener.Addr().String()
+			pattern = ""
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should report the days left without matching a certificate far from expiry", func() {
+			Expect(result.Err).To(BeNil())
+			Expect(result.Value).NotTo(BeNil())
+			Expect(*result.Value).To(BeNumerically(">", 365))
+			Expect(result.Matched).To(BeFalse())
+		})
+
+		Context("and the certificate expires within the days given", func() {
+			BeforeEach(func() {
+				url += "?days=1000000"
+			})
+			It("should match", func() {
+				Expect(result.Err).To(BeNil())
+				Expect(result.Matched).To(BeTrue())
+				Expect(mockDB.SaveDataCalls()[0].Match.Data).To(HavePrefix("certificate for 127.0.0.1 expires at "))
+			})
+		})
+
+		Context("and the client does not trust the certificate", func() {
+			BeforeEach(func() {
+				client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{}}}
+			})
+			It("should return an error", func() {
+				Expect(result.Err).To(MatchError(ContainSubstring("certificate does not verify")))
+			})
+		})
+	})
+})
+
+var _ = Describe("ParseProbe", func() {
+	It("should parse each probe kind", func() {
+		Expect(ParseProbe("tcp://db.internal:5432")).To(Equal(&Probe{Kind: "tcp", Address: "db.internal:5432", Host: "db.internal"}))
+		Expect(ParseProbe("dns:///example.com")).To(Equal(&Probe{Kind: "dns", Host: "example.com", RecordType: "A"}))
+		Expect(ParseProbe("dns://1.1.1.1/example.com?type=txt")).To(Equal(&Probe{Kind: "dns", Address: "1.1.1.1:53", Host: "example.com", RecordType: "TXT"}))
+		Expect(ParseProbe("tls://example.com?days=30")).To(Equal(&Probe{Kind: "tls", Address: "example.com:443", Host: "example.com", ExpiryDays: 30}))
+	})
+
+	It("should reject incomplete probes", func() {
+		for _, input := range []string{"tcp://example.com", "dns://1.1.1.1", "dns:///example.com?type=SRV", "tls://example.com?days=0", "tls:///path"} {
+			_, err := ParseProbe(input)
+			Expect(err).To(HaveOccurred(), input)
+		}
+	})
+})
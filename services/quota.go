@@ -0,0 +1,53 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"snapp-task/db"
+	"time"
+)
+
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+var ErrCapacity = errors.New("monitor capacity reached")
+
+type QuotaError struct {
+	Quota string
+	Limit int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("tenant quota exceeded: %s is %d", e.Quota, e.Limit)
+}
+
+// checkQuota returns a QuotaError if storing monitor would exceed its tenant's quotas. adding is how many
+// of the tenant's monitors are being created along with it, itself included, and 0 for updates.
+func (r *Registry) checkQuota(monitor db.Monitor, adding int) error {
+	if r.Tenants == nil {
+		return nil
+	}
+	tenant, err := r.Tenants.GetTenant(monitor.TenantID)
+	if errors.Is(err, db.ErrNotFound) {
+		return ErrUnknownTenant
+	}
+	if err != nil {
+		return err
+	}
+	if tenant.MinInterval > 0 && monitor.Interval < tenant.MinInterval {
+		return &QuotaError{Quota: "min_interval", Limit: int64(tenant.MinInterval / time.Second)}
+	}
+	if adding == 0 || (tenant.MaxMonitors == 0 && tenant.MaxStorageBytes == 0) {
+		return nil
+	}
+	usage, err := r.Tenants.GetTenantUsage(tenant.ID)
+	if err != nil {
+		return err
+	}
+	if tenant.MaxMonitors > 0 && usage.Monitors+adding > tenant.MaxMonitors {
+		return &QuotaError{Quota: "max_monitors", Limit: int64(tenant.MaxMonitors)}
+	}
+	if tenant.MaxStorageBytes > 0 && usage.StorageBytes >= tenant.MaxStorageBytes {
+		return &QuotaError{Quota: "max_storage_bytes", Limit: tenant.MaxStorageBytes}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package services_test
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"snapp-task/db"
+	. "snapp-task/services"
+	"time"
+)
+
+var _ = Describe("Tenants", func() {
+	var (
+		mockStore *db.MonitorStoreMock
+		registry  *Registry
+		tenant    db.Tenant
+		usage     db.TenantUsage
+		stored    []db.Monitor
+	)
+
+	BeforeEach(func() {
+		tenant = db.Tenant{ID: 2, Name: "acme", MaxMonitors: 2, MinInterval: time.Minute, MaxStorageBytes: 100}
+		usage = db.TenantUsage{}
+		stored = []db.Monitor{
+			{ID: 1, URL: "https://a.com", Pattern: "a", Interval: time.Minute, TenantID: 2},
+			{ID: 2, URL: "https://b.com", Pattern: "b", Interval: time.Minute, TenantID: db.DefaultTenantID},
+		}
+		mockStore = &db.MonitorStoreMock{
+			ListMonitorsFunc: func() ([]db.Monitor, error) {
+				return stored, nil
+			},
+			GetMonitorFunc: func(id int64) (db.Monitor, error) {
+				for _, monitor := range stored {
+					if monitor.ID == id {
+						return monitor, nil
+					}
+				}
+				return db.Monitor{}, db.ErrNotFound
+			},
+			CreateMonitorFunc: func(monitor db.Monitor) (db.Monitor, error) {
+				monitor.ID = 3
+				return monitor, nil
+			},
+			UpdateMonitorFunc: func(monitor db.Monitor) (db.Monitor, error) {
+				return monitor, nil
+			},
+		}
+		schedulerFactory := func(monitor db.Monitor, database db.DB) CheckScheduler {
+			return &CheckSchedulerMock{
+				ScheduleCheckFunc: func(ctx context.Context) {},
+				UpdateMonitorFunc: func(monitor db.Monitor) {},
+			}
+		}
+		registry = NewRegistry(mockStore, &db.DBMock{}, schedulerFactory)
+		registry.Tenants = &db.TenantStoreMock{
+			GetTenantFunc: func(id int64) (db.Tenant, error) {
+				if id != tenant.ID {
+					return db.Tenant{}, db.ErrNotFound
+				}
+				return tenant, nil
+			},
+			GetTenantUsageFunc: func(id int64) (db.TenantUsage, error) {
+				return usage, nil
+			},
+		}
+	})
+
+	AfterEach(func() {
+		registry.StopAll()
+	})
+
+	It("should only show a tenant its own monitors", func() {
+		monitors, err := registry.List(2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitors).To(Equal(stored[:1]))
+
+		_, err = registry.Get(2, 2)
+		Expect(err).To(MatchError(db.ErrNotFound))
+		_, err = registry.Update(2, db.Monitor{ID: 2, URL: "https://c.com", Pattern: "c", Interval: time.Minute})
+		Expect(err).To(MatchError(db.ErrNotFound))
+		Expect(registry.Delete(2, 2)).To(MatchError(db.ErrNotFound))
+
+		all, err := registry.List(0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(all).To(HaveLen(2))
+	})
+
+	It("should not treat monitors of other tenants as duplicates", func() {
+		created, err := registry.Create(db.Monitor{URL: "https://b.com", Pattern: "b", Interval: time.Minute, TenantID: 2})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created.ID).To(Equal(int64(3)))
+	})
+
+	It("should enforce the minimum interval", func() {
+		_, err := registry.Create(db.Monitor{URL: "https://c.com", Pattern: "c", Interval: time.Second, TenantID: 2})
+		Expect(err).To(MatchError(&QuotaError{Quota: "min_interval", Limit: 60}))
+		_, err = registry.Update(2, db.Monitor{ID: 1, URL: "https://a.com", Pattern: "a", Interval: time.Second})
+		Expect(err).To(MatchError(&QuotaError{Quota: "min_interval", Limit: 60}))
+		Expect(mockStore.UpdateMonitorCalls()).To(BeEmpty())
+	})
+
+	It("should enforce the monitor and storage quotas on create", func() {
+		monitor := db.Monitor{URL: "https://c.com", Pattern: "c", Interval: time.Minute, TenantID: 2}
+		usage = db.TenantUsage{Monitors: 2}
+		_, err := registry.Create(monitor)
+		Expect(err).To(MatchError(&QuotaError{Quota: "max_monitors", Limit: 2}))
+
+		usage = db.TenantUsage{Monitors: 1, StorageBytes: 100}
+		_, err = registry.Create(monitor)
+		Expect(err).To(MatchError(&QuotaError{Quota: "max_storage_bytes", Limit: 100}))
+		Expect(mockStore.CreateMonitorCalls()).To(BeEmpty())
+	})
+
+	It("should reject monitors of unknown tenants", func() {
+		_, err := registry.Create(db.Monitor{URL: "https://c.com", Pattern: "c", Interval: time.Minute, TenantID: 9})
+		Expect(err).To(MatchError(ErrUnknownTenant))
+	})
+})
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucket allows rate events per second on average, with bursts of up to burst events.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Take consumes a token if one is available. Otherwise it reports how long until one will be.
+func (b *TokenBucket) Take() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, b.wait()
+}
+
+// Peek reports whether Take would succeed, without consuming a token.
+func (b *TokenBucket) Peek() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	if b.tokens >= 1 {
+		return true, 0
+	}
+	return false, b.wait()
+}
+
+// wait is how long until the next token.
+func (b *TokenBucket) wait() time.Duration {
+	if b.rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *TokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// full reports whether the bucket has refilled completely, i.e. dropping it loses nothing.
+func (b *TokenBucket) full(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+const limiterSweepInterval = time.Minute
+
+// RateLimiter keeps a TokenBucket per key, such as an API key or a client address.
+type RateLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     int
+	buckets   map[string]*TokenBucket
+	lastSweep time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{rate: rate, burst: burst, buckets: make(map[string]*TokenBucket), lastSweep: time.Now()}
+}
+
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	now := time.Now()
+	if now.Sub(l.lastSweep) >= limiterSweepInterval {
+		for k, bucket := range l.buckets {
+			if bucket.full(now) {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = NewTokenBucket(l.rate, l.burst)
+		l.buckets[key] = bucket
+	}
+	l.mu.Unlock()
+	return bucket.Take()
+}
+
+// Peek reports whether Allow would let key through, without consuming a token.
+func (l *RateLimiter) Peek(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	bucket, ok := l.buckets[key]
+	l.mu.Unlock()
+	if !ok {
+		return true, 0
+	}
+	return bucket.Peek()
+}
@@ -0,0 +1,52 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "snapp-task/services"
+	"time"
+)
+
+var _ = Describe("Rate limiting", func() {
+	It("should allow a burst and then report when the next token is due", func() {
+		bucket := NewTokenBucket(2, 3)
+		for i := 0; i < 3; i++ {
+			ok, _ := bucket.Take()
+			Expect(ok).To(BeTrue())
+		}
+		ok, retryAfter := bucket.Take()
+		Expect(ok).To(BeFalse())
+		Expect(retryAfter).To(BeNumerically("~", 500*time.Millisecond, 50*time.Millisecond))
+	})
+
+	It("should refill tokens over time", func() {
+		bucket := NewTokenBucket(50, 1)
+		ok, _ := bucket.Take()
+		Expect(ok).To(BeTrue())
+		Eventually(func() bool {
+			ok, _ := bucket.Take()
+			return ok
+		}).WithTimeout(200 * time.Millisecond).Should(BeTrue())
+	})
+
+	It("should keep a separate bucket per key", func() {
+		limiter := NewRateLimiter(1, 1)
+		ok, _ := limiter.Allow("a")
+		Expect(ok).To(BeTrue())
+		ok, _ = limiter.Allow("a")
+		Expect(ok).To(BeFalse())
+		ok, _ = limiter.Allow("b")
+		Expect(ok).To(BeTrue())
+	})
+
+	It("should peek without consuming tokens", func() {
+		limiter := NewRateLimiter(1, 1)
+		ok, _ := limiter.Peek("a")
+		Expect(ok).To(BeTrue())
+		ok, _ = limiter.Allow("a")
+		Expect(ok).To(BeTrue())
+		ok, retryAfter := limiter.Peek("a")
+		Expect(ok).To(BeFalse())
+		Expect(retryAfter).To(BeNumerically(">", 0))
+	})
+})
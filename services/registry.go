@@ -0,0 +1,447 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"snapp-task/db"
+	"sync"
+	"time"
+)
+
+// Registry owns the persisted monitors and the scheduler goroutine running each of them.
+type Registry struct {
+	store            db.MonitorStore
+	db               db.DB
+	schedulerFactory SchedulerFactory
+	mu               sync.Mutex
+	running          map[int64]*runningMonitor
+
+	// Tenants supplies the per-tenant quotas checked on Create and Update. When nil no quotas are enforced.
+	Tenants db.TenantStore
+	// MaxMonitors caps the number of monitors across all tenants. Zero means unlimited.
+	MaxMonitors int
+	// CheckerFactory builds the checkers for manual and dry-run checks; NewUrlCheckerImpl is used when nil.
+	CheckerFactory UrlCheckerFactory
+	// OnUpdate, when set, is called with the new revision of every updated monitor once it is scheduled.
+	OnUpdate func(monitor db.Monitor)
+	// OnDelete, when set, is called with the ID of every deleted monitor once its checks have stopped.
+	OnDelete func(monitorID int64)
+}
+
+type runningMonitor struct {
+	scheduler CheckScheduler
+	cancel    context.CancelFunc
+}
+
+func NewRegistry(store db.MonitorStore, db db.DB, schedulerFactory SchedulerFactory) *Registry {
+	return &Registry{
+		store:            store,
+		db:               db,
+		schedulerFactory: schedulerFactory,
+		running:          make(map[int64]*runningMonitor),
+	}
+}
+
+// Start schedules every monitor already in the store.
+func (r *Registry) Start() error {
+	monitors, err := r.store.ListMonitors()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, monitor := range monitors {
+		r.start(monitor)
+	}
+	return nil
+}
+
+func (r *Registry) StopAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, running := range r.running {
+		running.cancel()
+		delete(r.running, id)
+	}
+}
+
+func (r *Registry) start(monitor db.Monitor) {
+	r.stop(monitor.ID)
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduler := r.schedulerFactory(monitor, r.db)
+	r.running[monitor.ID] = &runningMonitor{scheduler: scheduler, cancel: cancel}
+	go scheduler.ScheduleCheck(ctx)
+}
+
+func (r *Registry) stop(id int64) {
+	if running, ok := r.running[id]; ok {
+		running.cancel()
+		delete(r.running, id)
+	}
+}
+
+// tenantOf returns the monitor's tenant, counting monitors without one as the default tenant's.
+func tenantOf(monitor db.Monitor) int64 {
+	if monitor.TenantID == 0 {
+		return db.DefaultTenantID
+	}
+	return monitor.TenantID
+}
+
+// findDuplicate returns a DuplicateMonitorError if another monitor of the same tenant has the same MonitorKey.
+func (r *Registry) findDuplicate(monitor db.Monitor) error {
+	monitors, err := r.store.ListMonitors()
+	if err != nil {
+		return err
+	}
+	key := MonitorKey(monitor)
+	for _, existing := range monitors {
+		if existing.ID != monitor.ID && tenantOf(existing) == tenantOf(monitor) && MonitorKey(existing) == key {
+			return &DuplicateMonitorError{ExistingID: existing.ID}
+		}
+	}
+	return nil
+}
+
+// Create stores and schedules monitor. A monitor without a tenant belongs to the default tenant.
+func (r *Registry) Create(monitor db.Monitor) (db.Monitor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if monitor.TenantID == 0 {
+		monitor.TenantID = db.DefaultTenantID
+	}
+	if err := r.findDuplicate(monitor); err != nil {
+		return monitor, err
+	}
+	if err := r.checkQuota(monitor, 1); err != nil {
+		return monitor, err
+	}
+	if r.MaxMonitors > 0 {
+		monitors, err := r.store.ListMonitors()
+		if err != nil {
+			return monitor, err
+		}
+		if len(monitors) >= r.MaxMonitors {
+			return monitor, ErrCapacity
+		}
+	}
+	created, err := r.store.CreateMonitor(monitor)
+	if err != nil {
+		return created, err
+	}
+	r.start(created)
+	return created, nil
+}
+
+// Update stores a new revision of the monitor and hot-swaps it into the running scheduler.
+// The monitor keeps its tenant.
+func (r *Registry) Update(tenantID int64, monitor db.Monitor) (db.Monitor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, err := r.Get(tenantID, monitor.ID)
+	if err != nil {
+		return monitor, err
+	}
+	monitor.TenantID = current.TenantID
+	if err = r.findDuplicate(monitor); err != nil {
+		return monitor, err
+	}
+	if err = r.checkQuota(monitor, 0); err != nil {
+		return monitor, err
+	}
+	updated, err := r.store.UpdateMonitor(monitor)
+	if err != nil {
+		return updated, err
+	}
+	if running, ok := r.running[updated.ID]; ok {
+		running.scheduler.UpdateMonitor(updated)
+	} else {
+		r.start(updated)
+	}
+	if r.OnUpdate != nil {
+		r.OnUpdate(updated)
+	}
+	return updated, nil
+}
+
+func (r *Registry) Delete(tenantID int64, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.Get(tenantID, id); err != nil {
+		return err
+	}
+	if err := r.store.DeleteMonitor(id); err != nil {
+		return err
+	}
+	r.stop(id)
+	if r.OnDelete != nil {
+		r.OnDelete(id)
+	}
+	return nil
+}
+
+// Get returns the monitor if it belongs to tenantID. Tenant 0 sees every monitor.
+func (r *Registry) Get(tenantID int64, id int64) (db.Monitor, error) {
+	monitor, err := r.store.GetMonitor(id)
+	if err == nil && tenantID != 0 && tenantOf(monitor) != tenantID {
+		return db.Monitor{}, db.ErrNotFound
+	}
+	return monitor, err
+}
+
+// List returns the monitors of tenantID. Tenant 0 sees every monitor.
+func (r *Registry) List(tenantID int64) ([]db.Monitor, error) {
+	monitors, err := r.store.ListMonitors()
+	if err != nil || tenantID == 0 {
+		return monitors, err
+	}
+	var owned []db.Monitor
+	for _, monitor := range monitors {
+		if tenantOf(monitor) == tenantID {
+			owned = append(owned, monitor)
+		}
+	}
+	return owned, nil
+}
+
+func (r *Registry) Revisions(tenantID int64, id int64) ([]db.MonitorRevision, error) {
+	if _, err := r.Get(tenantID, id); err != nil {
+		return nil, err
+	}
+	return r.store.ListMonitorRevisions(id)
+}
+
+// Values returns up to limit of the monitor's recorded values in [from, to), in chronological order.
+func (r *Registry) Values(tenantID int64, id int64, from, to time.Time, limit int) ([]db.MonitorValue, error) {
+	if _, err := r.Get(tenantID, id); err != nil {
+		return nil, err
+	}
+	return r.store.ListMonitorValues(id, from, to, limit)
+}
+
+// scheduler returns the running scheduler of a monitor visible to tenantID.
+func (r *Registry) scheduler(tenantID int64, id int64) (CheckScheduler, error) {
+	if _, err := r.Get(tenantID, id); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	running, ok := r.running[id]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	return running.scheduler, nil
+}
+
+// RunNow starts a check of the monitor immediately; its result is recorded like a scheduled one.
+func (r *Registry) RunNow(tenantID int64, id int64) error {
+	scheduler, err := r.scheduler(tenantID, id)
+	if err != nil {
+		return err
+	}
+	scheduler.RunNow()
+	return nil
+}
+
+// SetPaused pauses or resumes the monitor's scheduled checks until the process restarts.
+func (r *Registry) SetPaused(tenantID int64, id int64, paused bool) error {
+	scheduler, err := r.scheduler(tenantID, id)
+	if err != nil {
+		return err
+	}
+	scheduler.SetPaused(paused)
+	return nil
+}
+
+func (r *Registry) checker(monitor db.Monitor) UrlChecker {
+	if r.CheckerFactory == nil {
+		return NewUrlCheckerImpl(monitor, r.db)
+	}
+	return r.CheckerFactory(monitor, r.db)
+}
+
+// Check runs the monitor's check synchronously and records it like a scheduled one.
+func (r *Registry) Check(tenantID int64, id int64) (CheckResult, error) {
+	monitor, err := r.Get(tenantID, id)
+	if err != nil {
+		return CheckResult{}, err
+	}
+	startedAt := time.Now()
+	result := r.checker(monitor).Check(false)
+	recordRun(r.db, monitor, startedAt, result.Err)
+	return result, nil
+}
+
+// DryRun checks an unsaved monitor without storing or publishing anything.
+func (r *Registry) DryRun(monitor db.Monitor) CheckResult {
+	return r.checker(monitor).Check(true)
+}
+
+type MonitorChange struct {
+	Before db.Monitor
+	After  db.Monitor
+}
+
+type SyncPlan struct {
+	Create    []db.Monitor
+	Update    []MonitorChange
+	Delete    []db.Monitor
+	Unchanged int
+}
+
+// Plan diffs the desired monitor set against tenantID's monitors. A desired monitor with the ID of one
+// of the tenant's monitors is compared against it; other desired monitors are matched by MonitorKey and
+// created when none has their configuration. Monitors missing from desired are only deleted when prune
+// is set.
+func (r *Registry) Plan(tenantID int64, desired []db.Monitor, prune bool) (SyncPlan, error) {
+	var plan SyncPlan
+	existing, err := r.List(tenantID)
+	if err != nil {
+		return plan, err
+	}
+	byID := make(map[int64]db.Monitor, len(existing))
+	byKey := make(map[string]db.Monitor, len(existing))
+	for _, monitor := range existing {
+		byID[monitor.ID] = monitor
+		byKey[MonitorKey(monitor)] = monitor
+	}
+	// Monitors are matched by ID first, so an entry matched by its configuration cannot take a monitor
+	// another entry names. Entries repeating a configuration already in the set are skipped.
+	matched := make(map[int64]bool, len(desired))
+	for _, monitor := range desired {
+		if _, ok := byID[monitor.ID]; ok {
+			matched[monitor.ID] = true
+		}
+	}
+	keys := make(map[string]bool, len(desired))
+	named := make(map[int64]bool, len(desired))
+	for _, monitor := range desired {
+		current, ok := byID[monitor.ID]
+		key := MonitorKey(monitor)
+		if keys[key] || (ok && named[monitor.ID]) {
+			continue
+		}
+		keys[key] = true
+		if ok {
+			named[monitor.ID] = true
+		} else if current, ok = byKey[key]; ok && !matched[current.ID] {
+			matched[current.ID] = true
+		} else {
+			monitor.ID = 0
+			plan.Create = append(plan.Create, monitor)
+			continue
+		}
+		monitor.ID = current.ID
+		monitor.Revision = current.Revision
+		monitor.CreatedBy = current.CreatedBy
+		if monitor.TenantID == 0 {
+			monitor.TenantID = current.TenantID
+		}
+		if reflect.DeepEqual(monitor, current) {
+			plan.Unchanged++
+			continue
+		}
+		plan.Update = append(plan.Update, MonitorChange{Before: current, After: monitor})
+	}
+	if prune {
+		for _, monitor := range existing {
+			if !matched[monitor.ID] {
+				plan.Delete = append(plan.Delete, monitor)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// Apply stores the plan's creates, updates and deletes in one transaction and only then starts, swaps
+// and stops the schedulers to match. When a change would exceed a quota or duplicate a monitor, or the
+// store fails, none of the plan is applied.
+func (r *Registry) Apply(plan SyncPlan) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, err := r.store.ListMonitors()
+	if err != nil {
+		return err
+	}
+	remaining := make(map[int64]db.Monitor, len(existing))
+	for _, monitor := range existing {
+		remaining[monitor.ID] = monitor
+	}
+	var changes db.MonitorChanges
+	for _, monitor := range plan.Delete {
+		changes.Delete = append(changes.Delete, monitor.ID)
+		delete(remaining, monitor.ID)
+	}
+	for _, change := range plan.Update {
+		monitor := change.After
+		current, ok := remaining[monitor.ID]
+		if !ok {
+			return db.ErrNotFound
+		}
+		monitor.TenantID = current.TenantID
+		if err = r.checkQuota(monitor, 0); err != nil {
+			return err
+		}
+		changes.Update = append(changes.Update, monitor)
+	}
+	adding := make(map[int64]int)
+	for _, monitor := range plan.Create {
+		if monitor.TenantID == 0 {
+			monitor.TenantID = db.DefaultTenantID
+		}
+		adding[monitor.TenantID]++
+		if err = r.checkQuota(monitor, adding[monitor.TenantID]); err != nil {
+			return err
+		}
+		changes.Create = append(changes.Create, monitor)
+	}
+	if r.MaxMonitors > 0 && len(changes.Create) > 0 && len(remaining)+len(changes.Create) > r.MaxMonitors {
+		return ErrCapacity
+	}
+	// The plan has to leave no two monitors of a tenant running the same check.
+	type tenantKey struct {
+		tenantID int64
+		key      string
+	}
+	ids := make(map[tenantKey]int64, len(remaining)+len(changes.Create))
+	updating := make(map[int64]bool, len(changes.Update))
+	for _, monitor := range changes.Update {
+		updating[monitor.ID] = true
+	}
+	for _, monitor := range remaining {
+		if !updating[monitor.ID] {
+			ids[tenantKey{tenantOf(monitor), MonitorKey(monitor)}] = monitor.ID
+		}
+	}
+	for _, monitor := range append(changes.Update, changes.Create...) {
+		key := tenantKey{tenantOf(monitor), MonitorKey(monitor)}
+		if id, taken := ids[key]; taken && id != monitor.ID {
+			return &DuplicateMonitorError{ExistingID: id}
+		}
+		ids[key] = monitor.ID
+	}
+	applied, err := r.store.ApplyMonitorChanges(changes)
+	if err != nil {
+		return err
+	}
+	for _, monitor := range applied.Create {
+		r.start(monitor)
+	}
+	for _, monitor := range applied.Update {
+		if running, ok := r.running[monitor.ID]; ok {
+			running.scheduler.UpdateMonitor(monitor)
+		} else {
+			r.start(monitor)
+		}
+		if r.OnUpdate != nil {
+			r.OnUpdate(monitor)
+		}
+	}
+	for _, id := range applied.Delete {
+		r.stop(id)
+		if r.OnDelete != nil {
+			r.OnDelete(id)
+		}
+	}
+	return nil
+}
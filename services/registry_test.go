@@ -0,0 +1,359 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"snapp-task/db"
+	. "snapp-task/services"
+	"sync"
+	"time"
+)
+
+var _ = Describe("Registry", func() {
+	var (
+		mockStore *db.MonitorStoreMock
+		registry  *Registry
+		stored    []db.Monitor
+		started   map[int64]int
+		stopped   map[int64]int
+		swapped   map[int64][]db.Monitor
+		ran       map[int64]int
+		paused    map[int64]bool
+		lock      sync.Mutex
+	)
+
+	BeforeEach(func() {
+		stored = []db.Monitor{
+			{ID: 1, URL: "https://a.com", Pattern: "a", Interval: time.Second},
+			{ID: 2, URL: "https://b.com", Pattern: "b", Interval: time.Second},
+		}
+		startedCounts, stoppedCounts, swappedMonitors := make(map[int64]int), make(map[int64]int), make(map[int64][]db.Monitor)
+		started, stopped, swapped = startedCounts, stoppedCounts, swappedMonitors
+		ranCounts, pausedMonitors := make(map[int64]int), make(map[int64]bool)
+		ran, paused = ranCounts, pausedMonitors
+		mockStore = &db.MonitorStoreMock{
+			ListMonitorsFunc: func() ([]db.Monitor, error) {
+				return stored, nil
+			},
+			CreateMonitorFunc: func(monitor db.Monitor) (db.Monitor, error) {
+				monitor.ID = int64(len(stored) + 1)
+				return monitor, nil
+			},
+			UpdateMonitorFunc: func(monitor db.Monitor) (db.Monitor, error) {
+				monitor.Revision++
+				return monitor, nil
+			},
+			DeleteMonitorFunc: func(id int64) error {
+				return nil
+			},
+			GetMonitorFunc: func(id int64) (db.Monitor, error) {
+				for _, monitor := range stored {
+					if monitor.ID == id {
+						return monitor, nil
+					}
+				}
+				return db.Monitor{}, db.ErrNotFound
+			},
+		}
+		schedulerFactory := func(monitor db.Monitor, database db.DB) CheckScheduler {
+			return &CheckSchedulerMock{
+				ScheduleCheckFunc: func(ctx context.Context) {
+					lock.Lock()
+					startedCounts[monitor.ID]++
+					lock.Unlock()
+					<-ctx.Done()
+					lock.Lock()
+					stoppedCounts[monitor.ID]++
+					lock.Unlock()
+				},
+				UpdateMonitorFunc: func(updated db.Monitor) {
+					lock.Lock()
+					swappedMonitors[updated.ID] = append(swappedMonitors[updated.ID], updated)
+					lock.Unlock()
+				},
+				RunNowFunc: func() {
+					lock.Lock()
+					ranCounts[monitor.ID]++
+					lock.Unlock()
+				},
+				SetPausedFunc: func(isPaused bool) {
+					lock.Lock()
+					pausedMonitors[monitor.ID] = isPaused
+					lock.Unlock()
+				},
+			}
+		}
+		registry = NewRegistry(mockStore, &db.DBMock{}, schedulerFactory)
+	})
+
+	AfterEach(func() {
+		registry.StopAll()
+		Eventually(func() bool {
+			lock.Lock()
+			defer lock.Unlock()
+			for id, n := range started {
+				if stopped[id] != n {
+					return false
+				}
+			}
+			return true
+		}).Should(BeTrue())
+	})
+
+	count := func(counts map[int64]int, id int64) func() int {
+		return func() int {
+			lock.Lock()
+			defer lock.Unlock()
+			return counts[id]
+		}
+	}
+
+	It("should schedule every stored monitor on Start", func() {
+		Expect(registry.Start()).To(Succeed())
+		Eventually(count(started, 1)).Should(Equal(1))
+		Eventually(count(started, 2)).Should(Equal(1))
+		registry.StopAll()
+		Eventually(count(stopped, 1)).Should(Equal(1))
+		Eventually(count(stopped, 2)).Should(Equal(1))
+	})
+
+	It("should store and schedule created monitors", func() {
+		created, err := registry.Create(db.Monitor{URL: "https://c.com", Pattern: "c", Interval: time.Second})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created.ID).To(Equal(int64(3)))
+		Eventually(count(started, 3)).Should(Equal(1))
+	})
+
+	It("should hot-swap the configuration of a running monitor", func() {
+		Expect(registry.Start()).To(Succeed())
+		Eventually(count(started, 1)).Should(Equal(1))
+		updated, err := registry.Update(0, db.Monitor{ID: 1, URL: "https://a.com", Pattern: "a", Interval: time.Minute, Revision: 1})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Revision).To(Equal(2))
+
+		lock.Lock()
+		Expect(swapped[1]).To(Equal([]db.Monitor{updated}))
+		lock.Unlock()
+		Consistently(count(stopped, 1), 50*time.Millisecond).Should(BeZero())
+		Expect(count(started, 1)()).To(Equal(1))
+	})
+
+	It("should start a scheduler when updating a monitor that is not running", func() {
+		_, err := registry.Update(0, db.Monitor{ID: 1, URL: "https://a.com", Pattern: "a", Interval: time.Minute})
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(count(started, 1)).Should(Equal(1))
+	})
+
+	It("should report the new revision of an updated monitor", func() {
+		var updates []db.Monitor
+		registry.OnUpdate = func(monitor db.Monitor) { updates = append(updates, monitor) }
+		updated, err := registry.Update(0, db.Monitor{ID: 1, URL: "https://a.com", Pattern: "a", Interval: time.Minute, Revision: 1})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updates).To(Equal([]db.Monitor{updated}))
+	})
+
+	It("should reject a monitor that duplicates a stored one", func() {
+		_, err := registry.Create(db.Monitor{URL: "HTTPS://A.com:443", Pattern: "a", Interval: time.Second})
+		var duplicate *DuplicateMonitorError
+		Expect(errors.As(err, &duplicate)).To(BeTrue())
+		Expect(duplicate.ExistingID).To(Equal(int64(1)))
+		Expect(mockStore.CreateMonitorCalls()).To(BeEmpty())
+	})
+
+	It("should reject an update that would duplicate another monitor", func() {
+		_, err := registry.Update(0, db.Monitor{ID: 2, URL: "https://a.com/", Pattern: "a", Interval: time.Second})
+		Expect(err).To(MatchError(&DuplicateMonitorError{ExistingID: 1}))
+		Expect(mockStore.UpdateMonitorCalls()).To(BeEmpty())
+	})
+
+	It("should refuse new monitors beyond MaxMonitors", func() {
+		registry.MaxMonitors = 2
+		_, err := registry.Create(db.Monitor{URL: "https://c.com", Pattern: "c", Interval: time.Second})
+		Expect(err).To(MatchError(ErrCapacity))
+		Expect(mockStore.CreateMonitorCalls()).To(BeEmpty())
+	})
+
+	It("should stop the scheduler of a deleted monitor", func() {
+		var deleted []int64
+		registry.OnDelete = func(monitorID int64) { deleted = append(deleted, monitorID) }
+		Expect(registry.Start()).To(Succeed())
+		Eventually(count(started, 2)).Should(Equal(1))
+		Expect(registry.Delete(0, 2)).To(Succeed())
+		Expect(deleted).To(Equal([]int64{2}))
+		Eventually(count(stopped, 2)).Should(Equal(1))
+		Consistently(count(stopped, 1), 50*time.Millisecond).Should(BeZero())
+	})
+
+	It("should forward run-now and pause to the monitor's scheduler", func() {
+		Expect(registry.Start()).To(Succeed())
+		Expect(registry.RunNow(0, 1)).To(Succeed())
+		Expect(registry.SetPaused(0, 2, true)).To(Succeed())
+		Expect(count(ran, 1)()).To(Equal(1))
+		lock.Lock()
+		Expect(paused).To(Equal(map[int64]bool{2: true}))
+		lock.Unlock()
+	})
+
+	It("should not run monitors of another tenant", func() {
+		Expect(registry.Start()).To(Succeed())
+		Expect(registry.RunNow(2, 1)).To(MatchError(db.ErrNotFound))
+		Expect(registry.RunNow(0, 9)).To(MatchError(db.ErrNotFound))
+	})
+
+	Describe("Check", func() {
+		var (
+			database *db.DBMock
+			checked  []bool
+		)
+
+		BeforeEach(func() {
+			checked = nil
+			database = &db.DBMock{SaveCheckRunFunc: func(run db.CheckRun) error { return nil }}
+			registry = NewRegistry(mockStore, database, nil)
+			registry.CheckerFactory = func(monitor db.Monitor, database db.DB) UrlChecker {
+				return &UrlCheckerMock{CheckFunc: func(dryRun bool) CheckResult {
+					checked = append(checked, dryRun)
+					return CheckResult{Matched: true, Data: monitor.Pattern, Err: errors.New("status 500")}
+				}}
+			}
+		})
+
+		It("should check a stored monitor and record the run", func() {
+			result, err := registry.Check(0, 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Data).To(Equal("a"))
+			Expect(checked).To(Equal([]bool{false}))
+			Expect(database.SaveCheckRunCalls()).To(HaveLen(1))
+			Expect(database.SaveCheckRunCalls()[0].Run.Error).To(Equal("status 500"))
+		})
+
+		It("should not check monitors of another tenant", func() {
+			_, err := registry.Check(2, 1)
+			Expect(err).To(MatchError(db.ErrNotFound))
+			Expect(checked).To(BeEmpty())
+		})
+
+		It("should dry-run an unsaved monitor without recording anything", func() {
+			result := registry.DryRun(db.Monitor{URL: "https://c.com", Pattern: "c"})
+			Expect(result.Data).To(Equal("c"))
+			Expect(checked).To(Equal([]bool{true}))
+			Expect(database.SaveCheckRunCalls()).To(BeEmpty())
+		})
+	})
+
+	Describe("Values", func() {
+		It("should list the values of a visible monitor", func() {
+			from, to := time.Unix(0, 0), time.Unix(3600, 0)
+			mockStore.ListMonitorValuesFunc = func(id int64, from, to time.Time, limit int) ([]db.MonitorValue, error) {
+				return []db.MonitorValue{{MonitorID: id, Value: 1}}, nil
+			}
+			values, err := registry.Values(0, 1, from, to, 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values).To(HaveLen(1))
+			Expect(mockStore.ListMonitorValuesCalls()).To(HaveLen(1))
+			Expect(mockStore.ListMonitorValuesCalls()[0].Limit).To(Equal(10))
+
+			_, err = registry.Values(2, 1, from, to, 10)
+			Expect(err).To(MatchError(db.ErrNotFound))
+			Expect(mockStore.ListMonitorValuesCalls()).To(HaveLen(1))
+		})
+	})
+
+	Describe("Plan", func() {
+		It("should diff the desired set against the store", func() {
+			desired := []db.Monitor{
+				{URL: "https://a.com", Pattern: "a", Interval: time.Second},
+				{ID: 2, URL: "https://b.com", Pattern: "b", Interval: time.Minute},
+				{URL: "https://c.com", Pattern: "c", Interval: time.Second},
+				{URL: "https://a.com", Pattern: "a", Interval: time.Second},
+			}
+			plan, err := registry.Plan(0, desired, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.Unchanged).To(Equal(1))
+			Expect(plan.Create).To(Equal([]db.Monitor{desired[2]}))
+			Expect(plan.Update).To(HaveLen(1))
+			Expect(plan.Update[0].Before).To(Equal(stored[1]))
+			Expect(plan.Update[0].After.ID).To(Equal(int64(2)))
+			Expect(plan.Update[0].After.Interval).To(Equal(time.Minute))
+			Expect(plan.Delete).To(BeEmpty())
+		})
+
+		It("should only delete missing monitors when pruning", func() {
+			plan, err := registry.Plan(0, []db.Monitor{{URL: "https://a.com", Pattern: "a", Interval: time.Second}}, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.Delete).To(Equal([]db.Monitor{stored[1]}))
+		})
+
+		It("should match monitors by ID before their configuration", func() {
+			desired := []db.Monitor{
+				{ID: 1, URL: "https://b.com", Pattern: "b", Interval: time.Second},
+				{ID: 9, URL: "https://a.com", Pattern: "a", Interval: time.Second},
+			}
+			plan, err := registry.Plan(0, desired, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.Update).To(ConsistOf(HaveField("After.ID", int64(1))))
+			Expect(plan.Create).To(ConsistOf(And(HaveField("ID", int64(0)), HaveField("URL", "https://a.com"))))
+			Expect(plan.Delete).To(Equal([]db.Monitor{stored[1]}))
+		})
+	})
+
+	Describe("Apply", func() {
+		var plan SyncPlan
+
+		BeforeEach(func() {
+			plan = SyncPlan{
+				Create: []db.Monitor{{URL: "https://c.com", Pattern: "c", Interval: time.Second}},
+				Update: []MonitorChange{{Before: stored[0], After: db.Monitor{ID: 1, URL: "https://a.com", Pattern: "a", Interval: time.Minute}}},
+				Delete: []db.Monitor{stored[1]},
+			}
+			mockStore.ApplyMonitorChangesFunc = func(changes db.MonitorChanges) (db.MonitorChanges, error) {
+				changes.Create[0].ID = 3
+				return changes, nil
+			}
+		})
+
+		It("should store the plan in one go and then swap the schedulers", func() {
+			Expect(registry.Start()).To(Succeed())
+			Eventually(count(started, 2)).Should(Equal(1))
+			Expect(registry.Apply(plan)).To(Succeed())
+			Expect(mockStore.ApplyMonitorChangesCalls()).To(HaveLen(1))
+			changes := mockStore.ApplyMonitorChangesCalls()[0].Changes
+			Expect(changes.Create).To(ConsistOf(HaveField("TenantID", db.DefaultTenantID)))
+			Expect(changes.Update).To(ConsistOf(HaveField("Interval", time.Minute)))
+			Expect(changes.Delete).To(Equal([]int64{2}))
+			Expect(mockStore.CreateMonitorCalls()).To(BeEmpty())
+			Eventually(count(started, 3)).Should(Equal(1))
+			Eventually(count(stopped, 2)).Should(Equal(1))
+			lock.Lock()
+			Expect(swapped[1]).To(ConsistOf(HaveField("Interval", time.Minute)))
+			lock.Unlock()
+		})
+
+		It("should leave the schedulers alone when the store fails", func() {
+			mockStore.ApplyMonitorChangesFunc = func(changes db.MonitorChanges) (db.MonitorChanges, error) {
+				return db.MonitorChanges{}, errors.New("disk full")
+			}
+			Expect(registry.Start()).To(Succeed())
+			Eventually(count(started, 2)).Should(Equal(1))
+			Expect(registry.Apply(plan)).To(MatchError("disk full"))
+			Consistently(count(stopped, 2), 50*time.Millisecond).Should(BeZero())
+			Expect(count(started, 3)()).To(BeZero())
+			lock.Lock()
+			Expect(swapped).To(BeEmpty())
+			lock.Unlock()
+		})
+
+		It("should store nothing when the plan would duplicate a monitor or exceed the capacity", func() {
+			plan.Update[0].After = db.Monitor{ID: 1, URL: "https://b.com", Pattern: "b", Interval: time.Second}
+			plan.Delete = nil
+			Expect(registry.Apply(plan)).To(MatchError(&DuplicateMonitorError{ExistingID: 2}))
+
+			registry.MaxMonitors = 2
+			plan.Update = nil
+			Expect(registry.Apply(plan)).To(MatchError(ErrCapacity))
+			Expect(mockStore.ApplyMonitorChangesCalls()).To(BeEmpty())
+		})
+	})
+})
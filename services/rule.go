@@ -0,0 +1,158 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/cel-go/cel"
+	"gopkg.in/yaml.v3"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ruleCostLimit bounds the work a single rule evaluation may do, so a rule looping over a huge body
+// cannot stall a checker.
+const ruleCostLimit = 1_000_000
+
+// maxCachedRules caps the compiled rules kept around; the cache is dropped when it fills up.
+const maxCachedRules = 1000
+
+// Rule is a compiled CEL expression deciding whether a response matches. It sees these variables:
+//
+//	status        int                  the HTTP status code
+//	headers       map(string, string)  the response headers, lower-cased, with repeated values joined by ", "
+//	body          dyn                  the decoded JSON or YAML body, or the body text for other media types
+//	text          string               the body text
+//	content_type  string               the media type, without parameters
+//	duration      google.protobuf.Duration  how long the response took to arrive
+type Rule struct {
+	program cel.Program
+}
+
+var (
+	ruleEnvOnce sync.Once
+	ruleEnv     *cel.Env
+	ruleEnvErr  error
+
+	ruleCacheMu sync.Mutex
+	ruleCache   = make(map[string]*Rule)
+)
+
+func newRuleEnv() (*cel.Env, error) {
+	ruleEnvOnce.Do(func() {
+		ruleEnv, ruleEnvErr = cel.NewEnv(
+			cel.Variable("status", cel.IntType),
+			cel.Variable("headers", cel.MapType(cel.StringType, cel.StringType)),
+			cel.Variable("body", cel.DynType),
+			cel.Variable("text", cel.StringType),
+			cel.Variable("content_type", cel.StringType),
+			cel.Variable("duration", cel.DurationType),
+			// JSON numbers are doubles; this lets rules compare them with int literals.
+			cel.CrossTypeNumericComparisons(true),
+		)
+	})
+	return ruleEnv, ruleEnvErr
+}
+
+// CompileRule parses and type-checks a rule expression, which has to evaluate to a bool. Syntax and type
+// errors are reported as a *ParseError with the offset of the first problem.
+func CompileRule(expression string) (*Rule, error) {
+	ruleCacheMu.Lock()
+	cached, ok := ruleCache[expression]
+	ruleCacheMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+	env, err := newRuleEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		first := issues.Errors()[0]
+		return nil, &ParseError{Offset: sourceOffset(expression, first.Location.Line(), first.Location.Column()), Msg: first.Message}
+	}
+	if !ast.OutputType().IsExactType(cel.BoolType) {
+		return nil, fmt.Errorf("rule must evaluate to a bool, not %s", ast.OutputType())
+	}
+	program, err := env.Program(ast, cel.CostLimit(ruleCostLimit))
+	if err != nil {
+		return nil, err
+	}
+	rule := &Rule{program: program}
+	ruleCacheMu.Lock()
+	defer ruleCacheMu.Unlock()
+	if len(ruleCache) >= maxCachedRules {
+		ruleCache = make(map[string]*Rule)
+	}
+	ruleCache[expression] = rule
+	return rule, nil
+}
+
+// sourceOffset converts a 1-based line and 0-based rune column into a byte offset.
+func sourceOffset(source string, line, column int) int {
+	offset := 0
+	for ; line > 1; line-- {
+		newline := strings.IndexByte(source[offset:], '\n')
+		if newline < 0 {
+			return offset
+		}
+		offset += newline + 1
+	}
+	for ; column > 0 && offset < len(source); column-- {
+		_, size := utf8.DecodeRuneInString(source[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// RuleInput is the response a rule is evaluated against.
+type RuleInput struct {
+	Status      int
+	Headers     http.Header
+	ContentType string
+	Body        []byte
+	Duration    time.Duration
+}
+
+// ruleBody decodes JSON and YAML bodies so rules can navigate them, and falls back to the text.
+func ruleBody(content []byte, mediaType string) any {
+	var data any
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if json.Unmarshal(content, &data) == nil {
+			return data
+		}
+	case strings.HasSuffix(mediaType, "yaml"):
+		if yaml.Unmarshal(content, &data) == nil {
+			return data
+		}
+	}
+	return string(content)
+}
+
+func (rule *Rule) Evaluate(input RuleInput) (bool, error) {
+	headers := make(map[string]string, len(input.Headers))
+	for name, values := range input.Headers {
+		headers[strings.ToLower(name)] = strings.Join(values, ", ")
+	}
+	mediaType := MediaType(input.ContentType)
+	out, _, err := rule.program.Eval(map[string]any{
+		"status":       input.Status,
+		"headers":      headers,
+		"body":         ruleBody(input.Body, mediaType),
+		"text":         string(input.Body),
+		"content_type": mediaType,
+		"duration":     input.Duration,
+	})
+	if err != nil {
+		return false, fmt.Errorf("rule evaluation failed: %v", err)
+	}
+	matched, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("rule evaluated to %v, not a bool", out.Value())
+	}
+	return matched, nil
+}
@@ -0,0 +1,64 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"net/http"
+	. "snapp-task/services"
+	"time"
+)
+
+var _ = Describe("Rule", func() {
+	input := RuleInput{
+		Status:      200,
+		Headers:     http.Header{"X-Version": {"v2"}, "Set-Cookie": {"a=1", "b=2"}},
+		ContentType: "application/problem+json; charset=utf-8",
+		Body:        []byte(`{"items": [{"price": 9.5}, {"price": 12}], "status": "ok"}`),
+		Duration:    300 * time.Millisecond,
+	}
+
+	DescribeTable("should evaluate rules over the response",
+		func(expression string, expected bool) {
+			rule, err := CompileRule(expression)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rule.Evaluate(input)).To(Equal(expected))
+		},
+		Entry("the example rule", `status == 200 && body.items.size() > 0 && headers['x-version'] != 'v1'`, true),
+		Entry("numbers against int literals", `body.items[1].price >= 12`, true),
+		Entry("a macro over the body", `body.items.exists(i, i.price < 5)`, false),
+		Entry("joined headers", `headers['set-cookie'] == 'a=1, b=2'`, true),
+		Entry("missing headers", `!('etag' in headers)`, true),
+		Entry("the media type", `content_type == 'application/problem+json'`, true),
+		Entry("timing", `duration < duration('1s')`, true),
+		Entry("the text", `text.contains('"status": "ok"')`, true),
+	)
+
+	It("should expose non-JSON bodies as text", func() {
+		rule, err := CompileRule(`body.startsWith('<p>') && status >= 500`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rule.Evaluate(RuleInput{Status: 503, ContentType: "text/html", Body: []byte("<p>down</p>")})).To(BeTrue())
+	})
+
+	It("should report the offset of compile errors", func() {
+		_, err := CompileRule("status == 200 &&\n  headers.size() > 'x'")
+		var ruleErr *ParseError
+		Expect(err).To(BeAssignableToTypeOf(ruleErr))
+		Expect(err.(*ParseError).Offset).To(Equal(34))
+		Expect(err).To(MatchError(ContainSubstring("no matching overload")))
+
+		_, err = CompileRule("status ==")
+		Expect(err).To(BeAssignableToTypeOf(ruleErr))
+	})
+
+	It("should reject rules that do not evaluate to a bool", func() {
+		_, err := CompileRule("status + 1")
+		Expect(err).To(MatchError("rule must evaluate to a bool, not int"))
+	})
+
+	It("should report evaluation errors", func() {
+		rule, err := CompileRule("body.missing > 1")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = rule.Evaluate(input)
+		Expect(err).To(MatchError(ContainSubstring("no such key: missing")))
+	})
+})
@@ -1,49 +1,157 @@
 package services
 
 import (
+	"context"
+	"errors"
 	_ "github.com/mattn/go-sqlite3"
 	"log"
 	"snapp-task/db"
+	"sync"
 	"time"
 )
 
 //go:generate moq -out=mocked_scheduler.go . CheckScheduler
 type CheckScheduler interface {
-	ScheduleCheck()
+	ScheduleCheck(ctx context.Context)
+	UpdateMonitor(monitor db.Monitor)
+	// RunNow starts a check without waiting for the next tick, even while paused.
+	RunNow()
+	SetPaused(paused bool)
 }
 
-type SchedulerFactory func(url, pattern string, interval time.Duration, db db.DB) CheckScheduler
+var ErrCheckRateLimited = errors.New("check skipped: global check rate limit reached")
+
+type SchedulerFactory func(monitor db.Monitor, db db.DB) CheckScheduler
 
 type CheckSchedulerImpl struct {
-	Url               string
-	Pattern           string
-	Interval          time.Duration
+	Monitor           db.Monitor
 	Db                db.DB
 	UrlCheckerFactory UrlCheckerFactory
+	// CheckLimiter caps the checks per second across all schedulers sharing it. Ticks over the cap are
+	// skipped and recorded as failed runs.
+	CheckLimiter *TokenBucket
+	mu           sync.Mutex
+	ticker       *time.Ticker
+	paused       bool
+	runNow       chan struct{}
 }
 
-func NewCheckSchedulerImpl(url, pattern string, interval time.Duration, db db.DB, urlCheckerFactory UrlCheckerFactory) CheckScheduler {
-	return &CheckSchedulerImpl{Url: url, Pattern: pattern, Interval: interval, Db: db, UrlCheckerFactory: urlCheckerFactory}
+func NewCheckSchedulerImpl(monitor db.Monitor, db db.DB, urlCheckerFactory UrlCheckerFactory) CheckScheduler {
+	return &CheckSchedulerImpl{Monitor: monitor, Db: db, UrlCheckerFactory: urlCheckerFactory}
 }
 
-func (cs CheckSchedulerImpl) ScheduleCheck() {
-	ticker := time.NewTicker(cs.Interval)
+func (cs *CheckSchedulerImpl) ScheduleCheck(ctx context.Context) {
+	cs.mu.Lock()
+	ticker := time.NewTicker(cs.Monitor.Interval)
+	cs.ticker = ticker
+	cs.mu.Unlock()
 	defer ticker.Stop()
 
 	errorChan := make(chan error)
 
+	check := func() {
+		monitor := cs.currentMonitor()
+		if cs.CheckLimiter != nil {
+			if ok, _ := cs.CheckLimiter.Take(); !ok {
+				cs.recordRun(monitor, time.Now(), ErrCheckRateLimited)
+				return
+			}
+		}
+		checker := cs.UrlCheckerFactory(monitor, cs.Db)
+		go func() {
+			startedAt := time.Now()
+			err := checker.CheckData()
+			cs.recordRun(monitor, startedAt, err)
+			if err != nil {
+				select {
+				case errorChan <- err:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+
 	for {
 		select {
 		case <-ticker.C:
-			checker := cs.UrlCheckerFactory(cs.Url, cs.Pattern, cs.Db)
-			go func() {
-				if err := checker.CheckData(); err != nil {
-					errorChan <- err
-				}
-			}()
+			if !cs.isPaused() {
+				check()
+			}
+
+		case <-cs.trigger():
+			check()
 
 		case err := <-errorChan:
 			log.Printf("Error encountered: %v\n", err)
+
+		case <-ctx.Done():
+			return
 		}
 	}
 }
+
+// UpdateMonitor swaps the configuration used by the following checks, resetting the ticker if the interval changed.
+func (cs *CheckSchedulerImpl) UpdateMonitor(monitor db.Monitor) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.ticker != nil && monitor.Interval != cs.Monitor.Interval {
+		cs.ticker.Reset(monitor.Interval)
+	}
+	cs.Monitor = monitor
+}
+
+func (cs *CheckSchedulerImpl) RunNow() {
+	select {
+	case cs.trigger() <- struct{}{}:
+	default:
+	}
+}
+
+// SetPaused stops or resumes the scheduled checks. Pausing is not persisted.
+func (cs *CheckSchedulerImpl) SetPaused(paused bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.paused = paused
+}
+
+func (cs *CheckSchedulerImpl) isPaused() bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.paused
+}
+
+// trigger returns the channel RunNow signals on. A pending signal coalesces further calls.
+func (cs *CheckSchedulerImpl) trigger() chan struct{} {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.runNow == nil {
+		cs.runNow = make(chan struct{}, 1)
+	}
+	return cs.runNow
+}
+
+func (cs *CheckSchedulerImpl) currentMonitor() db.Monitor {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.Monitor
+}
+
+func (cs *CheckSchedulerImpl) recordRun(monitor db.Monitor, startedAt time.Time, checkErr error) {
+	recordRun(cs.Db, monitor, startedAt, checkErr)
+}
+
+func recordRun(database db.DB, monitor db.Monitor, startedAt time.Time, checkErr error) {
+	run := db.CheckRun{
+		MonitorID: monitor.ID,
+		URL:       monitor.URL,
+		Pattern:   monitor.Pattern,
+		CheckedAt: startedAt,
+		Duration:  time.Since(startedAt),
+	}
+	if checkErr != nil {
+		run.Error = checkErr.Error()
+	}
+	if err := database.SaveCheckRun(run); err != nil {
+		log.Printf("Failed to record check run: %v\n", err)
+	}
+}
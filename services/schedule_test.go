@@ -1,10 +1,12 @@
 package services_test
 
 import (
+	"context"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"snapp-task/db"
 	. "snapp-task/services"
+	"sync"
 	"time"
 )
 
@@ -16,13 +18,21 @@ var _ = Describe("CheckSchedulerImpl", func() {
 		checkerFactory UrlCheckerFactory
 		testChan       chan struct{}
 		testInterval   time.Duration
+		ctx            context.Context
+		cancel         context.CancelFunc
 	)
 
 	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
 		testInterval = 250 * time.Millisecond
-		mockDB = &db.DBMock{SaveDataFunc: func(url string, pattern string, data string) error {
-			return nil
-		}}
+		mockDB = &db.DBMock{
+			SaveDataFunc: func(match db.Match) error {
+				return nil
+			},
+			SaveCheckRunFunc: func(run db.CheckRun) error {
+				return nil
+			},
+		}
 		testChan = make(chan struct{})
 		mockedChecker = &UrlCheckerMock{
 			CheckDataFunc: func() error {
@@ -35,22 +45,24 @@ var _ = Describe("CheckSchedulerImpl", func() {
 				return nil
 			},
 		}
-		checkerFactory = func(url, pattern string, db db.DB) UrlChecker {
+		checkerFactory = func(monitor db.Monitor, db db.DB) UrlChecker {
 			return mockedChecker
 		}
 	})
 
+	AfterEach(func() {
+		cancel()
+	})
+
 	Describe("ScheduleCheck", func() {
 		It("should call CheckData on the UrlChecker", func() {
 			scheduler = &CheckSchedulerImpl{
-				Url:               "https://example.com",
-				Pattern:           "test_pattern",
-				Interval:          testInterval,
+				Monitor:           db.Monitor{URL: "https://example.com", Pattern: "test_pattern", Interval: testInterval},
 				Db:                mockDB,
 				UrlCheckerFactory: checkerFactory,
 			}
 
-			go scheduler.ScheduleCheck()
+			go scheduler.ScheduleCheck(ctx)
 
 			select {
 			case <-testChan:
@@ -59,5 +71,104 @@ var _ = Describe("CheckSchedulerImpl", func() {
 				Fail("CheckData was not called on time")
 			}
 		})
+
+		It("should record a check run for every check", func() {
+			scheduler = &CheckSchedulerImpl{
+				Monitor:           db.Monitor{URL: "https://example.com", Pattern: "test_pattern", Interval: testInterval},
+				Db:                mockDB,
+				UrlCheckerFactory: checkerFactory,
+			}
+
+			go scheduler.ScheduleCheck(ctx)
+
+			Eventually(func() int {
+				return len(mockDB.SaveCheckRunCalls())
+			}, 2*testInterval, 10*time.Millisecond).Should(BeNumerically(">=", 1))
+			run := mockDB.SaveCheckRunCalls()[0].Run
+			Expect(run.URL).To(Equal("https://example.com"))
+			Expect(run.Pattern).To(Equal("test_pattern"))
+			Expect(run.Error).To(BeEmpty())
+		})
+
+		It("should use the swapped configuration and interval for later checks", func() {
+			var checkedMonitors []db.Monitor
+			var lock sync.Mutex
+			scheduler = &CheckSchedulerImpl{
+				Monitor: db.Monitor{URL: "https://example.com", Pattern: "test_pattern", Interval: time.Hour},
+				Db:      mockDB,
+				UrlCheckerFactory: func(monitor db.Monitor, db db.DB) UrlChecker {
+					lock.Lock()
+					checkedMonitors = append(checkedMonitors, monitor)
+					lock.Unlock()
+					return mockedChecker
+				},
+			}
+			go scheduler.ScheduleCheck(ctx)
+
+			updated := db.Monitor{URL: "https://example.com", Pattern: "new_pattern", Interval: 20 * time.Millisecond}
+			Eventually(func() bool {
+				scheduler.UpdateMonitor(updated)
+				lock.Lock()
+				defer lock.Unlock()
+				return len(checkedMonitors) > 0
+			}, time.Second, 50*time.Millisecond).Should(BeTrue())
+			lock.Lock()
+			defer lock.Unlock()
+			Expect(checkedMonitors[0]).To(Equal(updated))
+		})
+
+		It("should stop when the context is cancelled", func() {
+			scheduler = &CheckSchedulerImpl{
+				Monitor:           db.Monitor{URL: "https://example.com", Pattern: "test_pattern", Interval: testInterval},
+				Db:                mockDB,
+				UrlCheckerFactory: checkerFactory,
+			}
+			done := make(chan struct{})
+			go func() {
+				scheduler.ScheduleCheck(ctx)
+				close(done)
+			}()
+
+			cancel()
+			Eventually(done).Should(BeClosed())
+			Consistently(func() int {
+				return len(mockedChecker.CheckDataCalls())
+			}, 2*testInterval).Should(BeZero())
+		})
+
+		It("should skip ticks while paused but still run on demand", func() {
+			scheduler = &CheckSchedulerImpl{
+				Monitor:           db.Monitor{URL: "https://example.com", Pattern: "test_pattern", Interval: testInterval / 5},
+				Db:                mockDB,
+				UrlCheckerFactory: checkerFactory,
+			}
+			scheduler.SetPaused(true)
+			go scheduler.ScheduleCheck(ctx)
+
+			Consistently(func() int {
+				return len(mockedChecker.CheckDataCalls())
+			}, testInterval).Should(BeZero())
+			scheduler.RunNow()
+			Eventually(func() int {
+				return len(mockedChecker.CheckDataCalls())
+			}).Should(Equal(1))
+		})
+
+		It("should skip checks over the global check rate limit", func() {
+			limiter := NewTokenBucket(0, 1)
+			scheduler = &CheckSchedulerImpl{
+				Monitor:           db.Monitor{URL: "https://example.com", Pattern: "test_pattern", Interval: testInterval / 5},
+				Db:                mockDB,
+				UrlCheckerFactory: checkerFactory,
+				CheckLimiter:      limiter,
+			}
+			go scheduler.ScheduleCheck(ctx)
+
+			Eventually(func() int {
+				return len(mockDB.SaveCheckRunCalls())
+			}).Should(BeNumerically(">=", 3))
+			Expect(mockedChecker.CheckDataCalls()).To(HaveLen(1))
+			Expect(mockDB.SaveCheckRunCalls()).To(ContainElement(HaveField("Run.Error", ErrCheckRateLimited.Error())))
+		})
 	})
 })
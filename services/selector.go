@@ -0,0 +1,65 @@
+package services
+
+import (
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"strings"
+)
+
+// Selector is a parsed CSS selector list, matched by cascadia. It supports the CSS3 selectors and
+// combinators and the pseudo-classes that do not depend on user interaction.
+type Selector struct {
+	group cascadia.SelectorGroup
+}
+
+func ParseSelector(input string) (*Selector, error) {
+	group, err := cascadia.ParseGroup(strings.TrimSpace(input))
+	if err != nil {
+		return nil, err
+	}
+	return &Selector{group: group}, nil
+}
+
+func (s *Selector) Matches(node *html.Node) bool {
+	return s.group.Match(node)
+}
+
+// Select returns the elements under root that match, in document order.
+func (s *Selector) Select(root *html.Node) []*html.Node {
+	return cascadia.QueryAll(root, s.group)
+}
+
+// inlineElements do not separate words, so no whitespace is inserted around their text.
+var inlineElements = map[string]bool{
+	"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true, "cite": true, "code": true, "data": true,
+	"dfn": true, "em": true, "i": true, "kbd": true, "mark": true, "q": true, "s": true, "samp": true,
+	"small": true, "span": true, "strong": true, "sub": true, "sup": true, "time": true, "u": true, "var": true,
+}
+
+// NodeText returns the text content of node with runs of whitespace collapsed, leaving out scripts and
+// styles.
+func NodeText(node *html.Node) string {
+	var text strings.Builder
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.TextNode {
+			text.WriteString(node.Data)
+			return
+		}
+		if node.Type == html.ElementNode && (node.Data == "script" || node.Data == "style") {
+			return
+		}
+		block := node.Type == html.ElementNode && !inlineElements[node.Data]
+		if block {
+			text.WriteByte(' ')
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if block {
+			text.WriteByte(' ')
+		}
+	}
+	walk(node)
+	return strings.Join(strings.Fields(text.String()), " ")
+}
@@ -0,0 +1,70 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/html"
+	. "snapp-task/services"
+	"strings"
+)
+
+var _ = Describe("Selector", func() {
+	const page = `<html><body>
+		<ul id="items">
+			<li class="item sale" data-sku="A-1"><b>Lamp</b> <span class="price">10</span></li>
+			<li class="item"><b>Desk</b> <span class="price">20</span></li>
+			<li class="item"><b>Chair</b>
+				<span class="price">30</span></li>
+		</ul>
+		<p>Total: <span id="total">60</span><script>var x = 1;</script></p>
+	</body></html>`
+
+	var document *html.Node
+
+	BeforeEach(func() {
+		var err error
+		document, err = html.Parse(strings.NewReader(page))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	texts := func(selector string) []string {
+		parsed, err := ParseSelector(selector)
+		Expect(err).NotTo(HaveOccurred())
+		var result []string
+		for _, node := range parsed.Select(document) {
+			result = append(result, NodeText(node))
+		}
+		return result
+	}
+
+	DescribeTable("should select matching elements in document order",
+		func(selector string, expected ...string) {
+			Expect(texts(selector)).To(Equal(expected))
+		},
+		Entry("type", "b", "Lamp", "Desk", "Chair"),
+		Entry("id", "#total", "60"),
+		Entry("class and descendant", "ul .price", "10", "20", "30"),
+		Entry("child", "li > b", "Lamp", "Desk", "Chair"),
+		Entry("compound classes", "li.item.sale b", "Lamp"),
+		Entry("attribute prefix", `[data-sku^="A-"] .price`, "10"),
+		Entry("adjacent sibling", "li.sale + li b", "Desk"),
+		Entry("general sibling", "li.sale ~ li b", "Desk", "Chair"),
+		Entry("nth-child", "li:nth-child(2) .price", "20"),
+		Entry("last-child", "li:last-child", "Chair 30"),
+		Entry("selector list", "#total, li:first-child b", "Lamp", "60"),
+		Entry("text without scripts", "p", "Total: 60"),
+		Entry("negation", "li:not(.sale) b", "Desk", "Chair"),
+		Entry("has", "li:has(b) .price", "10", "20", "30"),
+	)
+
+	DescribeTable("should reject invalid selectors",
+		func(selector string) {
+			_, err := ParseSelector(selector)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("empty", ""),
+		Entry("dangling combinator", "ul >"),
+		Entry("unclosed attribute", "a[href"),
+		Entry("trailing comma", "a,"),
+	)
+})
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"regexp"
+	"snapp-task/db"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StepChecker is the UrlChecker of multi-step monitors. It runs the monitor's steps in order, sharing
+// cookies between them and templating the variables each step extracts into the steps after it, and
+// matches the last response like UrlCheckerImpl matches the response of the monitor's URL.
+type StepChecker struct {
+	UrlCheckerImpl
+}
+
+type StepResult struct {
+	Name       string
+	StatusCode int
+	Duration   time.Duration
+}
+
+// StepError reports the step a multi-step check failed at. Step is 1-based.
+type StepError struct {
+	Step int
+	Name string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("step %d failed: %v", e.Step, e.Err)
+	}
+	return fmt.Sprintf("step %d (%s) failed: %v", e.Step, e.Name, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+func (sc *StepChecker) CheckData() error {
+	return sc.Check(false).Err
+}
+
+// Check runs the steps, each with its own timeout. Every step's assertions have to pass for the
+// monitor's pattern, value path or rule to be checked against the last response.
+func (sc *StepChecker) Check(dryRun bool) CheckResult {
+	return sc.runCheck(dryRun, time.Duration(len(sc.Monitor.Steps))*checkTimeout, sc.checkSteps)
+}
+
+func (sc *StepChecker) checkSteps(dryRun bool) CheckResult {
+	var result CheckResult
+	client := *sc.client()
+	if client.Jar == nil {
+		// Lets a session cookie set by a login step reach the steps after it.
+		client.Jar, _ = cookiejar.New(nil)
+	}
+	variables := make(map[string]string)
+	var response *fetchedResponse
+	for i, step := range sc.Monitor.Steps {
+		// The result describes the last response fetched.
+		result = CheckResult{Steps: result.Steps}
+		startedAt := time.Now()
+		var err error
+		response, err = sc.runStep(&client, step, variables, &result)
+		result.Steps = append(result.Steps, StepResult{Name: step.Name, StatusCode: result.StatusCode, Duration: time.Since(startedAt)})
+		if err != nil {
+			result.Err = &StepError{Step: i + 1, Name: step.Name, Err: err}
+			return result
+		}
+	}
+	sc.match(&result, response, dryRun)
+	return result
+}
+
+func (sc *StepChecker) runStep(client *http.Client, step db.Step, variables map[string]string, result *CheckResult) (*fetchedResponse, error) {
+	request, err := newStepRequest(step, variables)
+	if err != nil {
+		return nil, err
+	}
+	response, err := sc.fetch(client, request, result)
+	if err != nil {
+		return nil, err
+	}
+	if err = sc.assertStep(step, response); err != nil {
+		return nil, err
+	}
+	return response, extractVariables(step, response, variables)
+}
+
+func newStepRequest(step db.Step, variables map[string]string) (*http.Request, error) {
+	url, err := ExpandVariables(step.URL, variables)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ExpandVariables(step.Body, variables)
+	if err != nil {
+		return nil, err
+	}
+	method := step.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch data from URL: %v", err)
+	}
+	for name, value := range step.Headers {
+		if value, err = ExpandVariables(value, variables); err != nil {
+			return nil, err
+		}
+		request.Header.Set(name, value)
+	}
+	return request, nil
+}
+
+func (sc *StepChecker) assertStep(step db.Step, response *fetchedResponse) error {
+	if step.Status != 0 && response.status != step.Status {
+		return fmt.Errorf("expected status %d, got %d", step.Status, response.status)
+	}
+	if step.Status == 0 && (response.status < 200 || response.status > 299) {
+		return fmt.Errorf("expected a 2xx status, got %d", response.status)
+	}
+	if step.Pattern != "" {
+		assertion := &UrlCheckerImpl{Monitor: db.Monitor{Pattern: step.Pattern}, Handlers: sc.Handlers}
+		matchedData, err := assertion.findMatch(response.body, response.contentType)
+		if err != nil {
+			return err
+		}
+		if matchedData == "" {
+			return fmt.Errorf("pattern %q did not match", step.Pattern)
+		}
+	}
+	if step.Rule != "" {
+		rule, err := CompileRule(step.Rule)
+		if err != nil {
+			return fmt.Errorf("invalid rule: %v", err)
+		}
+		holds, err := rule.Evaluate(RuleInput{Status: response.status, Headers: response.header, ContentType: response.contentType,
+			Body: response.body, Duration: response.elapsed})
+		if err != nil {
+			return err
+		}
+		if !holds {
+			return fmt.Errorf("rule %q did not hold", step.Rule)
+		}
+	}
+	return nil
+}
+
+// extractVariables sets the variables the step extracts from its JSON or YAML response.
+func extractVariables(step db.Step, response *fetchedResponse, variables map[string]string) error {
+	if len(step.Extract) == 0 {
+		return nil
+	}
+	data, err := decodeValueData(response.body, MediaType(response.contentType), "extract")
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(step.Extract))
+	for name := range step.Extract {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path, err := ParseValuePath(step.Extract[name])
+		if err != nil {
+			return fmt.Errorf("invalid path for %s: %v", name, err)
+		}
+		found, ok := path.Lookup(data)
+		if !ok {
+			return fmt.Errorf("no value at %s for %s", step.Extract[name], name)
+		}
+		if variables[name], err = variableValue(found, step.Extract[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func variableValue(value any, path string) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int, int64, uint64, bool:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("value at %s is not a string, number or bool", path)
+}
+
+var variableReference = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// IsVariableName reports whether name can be extracted into and referred to as {{name}}.
+func IsVariableName(name string) bool {
+	return variableName.MatchString(name)
+}
+
+// TemplateVariables returns the names of the variables input refers to, in order.
+func TemplateVariables(input string) []string {
+	var names []string
+	for _, reference := range variableReference.FindAllStringSubmatch(input, -1) {
+		names = append(names, reference[1])
+	}
+	return names
+}
+
+// ExpandVariables replaces every {{name}} in input with the variable's value.
+func ExpandVariables(input string, variables map[string]string) (string, error) {
+	var missing string
+	expanded := variableReference.ReplaceAllStringFunc(input, func(reference string) string {
+		name := variableReference.FindStringSubmatch(reference)[1]
+		value, ok := variables[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("unknown variable %q", missing)
+	}
+	return expanded, nil
+}
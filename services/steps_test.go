@@ -0,0 +1,127 @@
+package services_test
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"snapp-task/db"
+	. "snapp-task/services"
+)
+
+var _ = Describe("StepChecker", func() {
+	var (
+		mockDB     *db.DBMock
+		testServer *httptest.Server
+		monitor    db.Monitor
+		result     CheckResult
+	)
+
+	BeforeEach(func() {
+		mockDB = &db.DBMock{SaveDataFunc: func(match db.Match) error {
+			return nil
+		}}
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != `{"user": "alice"}` {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1"})
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"auth": {"token": "t0k3n", "user_id": 42}}`))
+		})
+		mux.HandleFunc("GET /users/{id}/orders", func(w http.ResponseWriter, r *http.Request) {
+			cookie, err := r.Cookie("session")
+			if r.Header.Get("Authorization") != "Bearer t0k3n" || err != nil || cookie.Value != "s1" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"user": "` + r.PathValue("id") + `", "orders": [{"status": "shipped"}]}`))
+		})
+		testServer = httptest.NewServer(mux)
+		monitor = db.Monitor{ID: 1, URL: testServer.URL + "/login", Pattern: "shipped", Steps: db.Steps{
+			{Name: "login", Method: http.MethodPost, URL: testServer.URL + "/login", Body: `{"user": "{{user}}"}`,
+				Extract: map[string]string{"token": "auth.token", "id": "auth.user_id"}},
+			{Name: "orders", URL: testServer.URL + "/users/{{id}}/orders", Headers: map[string]string{"Authorization": "Bearer {{ token }}"},
+				Status: http.StatusOK, Rule: "body.orders.size() > 0"},
+		}}
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	JustBeforeEach(func() {
+		checker := NewChecker(UrlCheckerImpl{Monitor: monitor, Db: mockDB})
+		Expect(checker).To(BeAssignableToTypeOf(&StepChecker{}))
+		result = checker.Check(false)
+	})
+
+	Context("when every step passes", func() {
+		BeforeEach(func() {
+			monitor.Steps[0].Body = `{"user": "alice"}`
+		})
+		It("should thread the extracted variables and cookies through the steps and match the last response", func() {
+			Expect(result.Err).To(BeNil())
+			Expect(result.Steps).To(HaveLen(2))
+			Expect(result.Steps[0].Name).To(Equal("login"))
+			Expect(result.Steps[1].StatusCode).To(Equal(http.StatusOK))
+			Expect(result.Matched).To(BeTrue())
+			Expect(mockDB.SaveDataCalls()).To(HaveLen(1))
+			Expect(mockDB.SaveDataCalls()[0].Match.URL).To(Equal(monitor.URL))
+			Expect(mockDB.SaveDataCalls()[0].Match.Data).To(ContainSubstring("shipped"))
+		})
+	})
+
+	Context("when a step refers to a variable no earlier step extracted", func() {
+		It("should fail that step before sending it", func() {
+			var stepErr *StepError
+			Expect(errors.As(result.Err, &stepErr)).To(BeTrue())
+			Expect(stepErr.Step).To(Equal(1))
+			Expect(result.Err).To(MatchError(`step 1 (login) failed: unknown variable "user"`))
+			Expect(result.Steps).To(HaveLen(1))
+			Expect(mockDB.SaveDataCalls()).To(BeEmpty())
+		})
+	})
+
+	Context("when a step's assertion fails", func() {
+		BeforeEach(func() {
+			monitor.Steps[0].Body = `{"user": "alice"}`
+			monitor.Steps[0].Extract["token"] = "auth.user_id"
+		})
+		It("should report the failing step and stop", func() {
+			Expect(result.Err).To(MatchError("step 2 (orders) failed: expected status 200, got 403"))
+			Expect(result.StatusCode).To(Equal(http.StatusForbidden))
+			Expect(result.Steps).To(HaveLen(2))
+			Expect(result.Matched).To(BeFalse())
+			Expect(mockDB.SaveDataCalls()).To(BeEmpty())
+		})
+	})
+
+	Context("when a step's response lacks an extracted value", func() {
+		BeforeEach(func() {
+			monitor.Steps[0].Body = `{"user": "alice"}`
+			monitor.Steps[0].Extract["token"] = "auth.session"
+		})
+		It("should fail the step", func() {
+			Expect(result.Err).To(MatchError("step 1 (login) failed: no value at auth.session for token"))
+		})
+	})
+})
+
+var _ = Describe("ExpandVariables", func() {
+	It("should replace references and report unknown variables", func() {
+		expanded, err := ExpandVariables("/users/{{id}}?token={{ token }}", map[string]string{"id": "1", "token": "a"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expanded).To(Equal("/users/1?token=a"))
+		Expect(TemplateVariables("{{a}} {{ b }} {{not a variable}}")).To(Equal([]string{"a", "b"}))
+
+		_, err = ExpandVariables("{{id}}", nil)
+		Expect(err).To(MatchError(`unknown variable "id"`))
+	})
+})
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"snapp-task/db"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValuePath addresses a value in decoded JSON or YAML, like "queue.depth", "items[0].price" or
+// "$.data['rate-limit'].remaining".
+type ValuePath struct {
+	steps []valueStep
+}
+
+type valueStep struct {
+	key   string
+	index int
+	isKey bool
+}
+
+type valuePathParser struct {
+	input string
+	pos   int
+}
+
+func (p *valuePathParser) errorf(format string, args ...any) error {
+	return &ParseError{Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func ParseValuePath(input string) (*ValuePath, error) {
+	p := &valuePathParser{input: strings.TrimSpace(input)}
+	if strings.HasPrefix(p.input, "$") {
+		p.pos++
+	}
+	if p.pos == len(p.input) {
+		return nil, p.errorf("expected a path")
+	}
+	var path ValuePath
+	// A leading key needs no dot.
+	leadingKey := p.input[p.pos] != '.' && p.input[p.pos] != '['
+	for p.pos < len(p.input) {
+		switch c := p.input[p.pos]; {
+		case c == '.' || leadingKey:
+			if !leadingKey {
+				p.pos++
+			}
+			leadingKey = false
+			start := p.pos
+			for p.pos < len(p.input) && p.input[p.pos] != '.' && p.input[p.pos] != '[' {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, p.errorf("expected a key")
+			}
+			path.steps = append(path.steps, valueStep{key: p.input[start:p.pos], isKey: true})
+		case c == '[':
+			step, err := p.parseIndex()
+			if err != nil {
+				return nil, err
+			}
+			path.steps = append(path.steps, step)
+		default:
+			return nil, p.errorf("unexpected %q", c)
+		}
+	}
+	return &path, nil
+}
+
+func (p *valuePathParser) parseIndex() (valueStep, error) {
+	p.pos++
+	end := strings.IndexByte(p.input[p.pos:], ']')
+	if end < 0 {
+		return valueStep{}, p.errorf("expected ]")
+	}
+	body := p.input[p.pos : p.pos+end]
+	if len(body) >= 2 && (body[0] == '\'' || body[0] == '"') && body[len(body)-1] == body[0] {
+		p.pos += end + 1
+		return valueStep{key: body[1 : len(body)-1], isKey: true}, nil
+	}
+	index, err := strconv.Atoi(body)
+	if err != nil || index < 0 {
+		return valueStep{}, p.errorf("expected an index or a quoted key")
+	}
+	p.pos += end + 1
+	return valueStep{index: index}, nil
+}
+
+// Lookup follows the path through decoded JSON or YAML.
+func (path *ValuePath) Lookup(data any) (any, bool) {
+	for _, step := range path.steps {
+		if step.isKey {
+			switch v := data.(type) {
+			case map[string]any:
+				var ok bool
+				if data, ok = v[step.key]; !ok {
+					return nil, false
+				}
+			case map[any]any:
+				var ok bool
+				if data, ok = v[step.key]; !ok {
+					return nil, false
+				}
+			default:
+				return nil, false
+			}
+			continue
+		}
+		list, ok := data.([]any)
+		if !ok || step.index >= len(list) {
+			return nil, false
+		}
+		data = list[step.index]
+	}
+	return data, true
+}
+
+// ExtractValue decodes a JSON or YAML response and returns the number at path. Numeric strings, like
+// "12.50", are accepted too.
+func ExtractValue(content []byte, mediaType string, path string) (float64, error) {
+	valuePath, err := ParseValuePath(path)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value path: %v", err)
+	}
+	data, err := decodeValueData(content, mediaType, "value_path")
+	if err != nil {
+		return 0, err
+	}
+	found, ok := valuePath.Lookup(data)
+	if !ok {
+		return 0, fmt.Errorf("no value at %s", path)
+	}
+	return toNumber(found, path)
+}
+
+// decodeValueData decodes a response for value paths. YAML media types are parsed as YAML and anything
+// else has to be JSON, whatever it was labeled. field names the option needing the data in errors.
+func decodeValueData(content []byte, mediaType string, field string) (any, error) {
+	var data any
+	if strings.HasSuffix(mediaType, "yaml") {
+		if err := yaml.Unmarshal(content, &data); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML response: %v", err)
+		}
+		return data, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, fmt.Errorf("%s requires a JSON or YAML response, got %q: %v", field, mediaType, err)
+	}
+	return data, nil
+}
+
+func toNumber(value any, path string) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case string:
+		if number, err := parseOperand(strings.TrimSpace(v)); err == nil {
+			return number, nil
+		}
+	}
+	return 0, fmt.Errorf("value at %s is not a number: %v", path, value)
+}
+
+// ValueBucket summarizes the values recorded in [Start, Start+step).
+type ValueBucket struct {
+	Start time.Time
+	Count int
+	Min   float64
+	Max   float64
+	Mean  float64
+}
+
+// BucketValues groups chronologically ordered values into buckets of step, aligned like time.Truncate.
+// Empty buckets are left out.
+func BucketValues(values []db.MonitorValue, step time.Duration) []ValueBucket {
+	var buckets []ValueBucket
+	for _, value := range values {
+		start := value.RecordedAt.Truncate(step)
+		if len(buckets) == 0 || !buckets[len(buckets)-1].Start.Equal(start) {
+			buckets = append(buckets, ValueBucket{Start: start, Min: value.Value, Max: value.Value})
+		}
+		bucket := &buckets[len(buckets)-1]
+		bucket.Min = min(bucket.Min, value.Value)
+		bucket.Max = max(bucket.Max, value.Value)
+		bucket.Mean += (value.Value - bucket.Mean) / float64(bucket.Count+1)
+		bucket.Count++
+	}
+	return buckets
+}
@@ -0,0 +1,68 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"snapp-task/db"
+	. "snapp-task/services"
+	"time"
+)
+
+var _ = Describe("Values", func() {
+	const document = `{"queue": {"depth": 42}, "items": [{"price": "12.50"}, {"price": 9.99}], "rate-limit": {"remaining": 1e3}, "name": "q"}`
+
+	DescribeTable("should extract numbers by path",
+		func(path string, expected float64) {
+			value, err := ExtractValue([]byte(document), "application/json", path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).To(Equal(expected))
+		},
+		Entry("nested keys", "queue.depth", 42.0),
+		Entry("a JSONPath-style root", "$.queue.depth", 42.0),
+		Entry("an index", "items[1].price", 9.99),
+		Entry("a numeric string", "items[0].price", 12.5),
+		Entry("a quoted key", "$['rate-limit'].remaining", 1000.0),
+	)
+
+	It("should extract numbers from YAML", func() {
+		value, err := ExtractValue([]byte("queue:\n  depth: 7\n"), "application/yaml", "queue.depth")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value).To(Equal(7.0))
+	})
+
+	DescribeTable("should report values that cannot be extracted",
+		func(content, mediaType, path, message string) {
+			_, err := ExtractValue([]byte(content), mediaType, path)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("a missing key", document, "application/json", "queue.size", "no value at queue.size"),
+		Entry("an index out of range", document, "application/json", "items[2]", "no value at items[2]"),
+		Entry("a string", document, "application/json", "name", "value at name is not a number"),
+		Entry("a non-JSON response", "<p>42</p>", "text/html", "queue.depth", `value_path requires a JSON or YAML response, got "text/html"`),
+	)
+
+	DescribeTable("should reject malformed paths",
+		func(path string, offset int) {
+			_, err := ParseValuePath(path)
+			var pathErr *ParseError
+			Expect(err).To(BeAssignableToTypeOf(pathErr))
+			Expect(err.(*ParseError).Offset).To(Equal(offset))
+		},
+		Entry("empty", "$", 1),
+		Entry("an empty key", "queue..depth", 6),
+		Entry("an unterminated index", "items[0", 6),
+		Entry("a negative index", "items[-1]", 6),
+	)
+
+	It("should summarize values per bucket", func() {
+		start := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+		var values []db.MonitorValue
+		for i, value := range []float64{1, 3, 5, 10} {
+			values = append(values, db.MonitorValue{Value: value, RecordedAt: start.Add(time.Duration(i*25) * time.Minute)})
+		}
+		Expect(BucketValues(values, time.Hour)).To(Equal([]ValueBucket{
+			{Start: start, Count: 3, Min: 1, Max: 5, Mean: 3},
+			{Start: start.Add(time.Hour), Count: 1, Min: 10, Max: 10, Mean: 10},
+		}))
+	})
+})
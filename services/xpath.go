@@ -0,0 +1,103 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseXML reads a UTF-8 XML document. Content is decoded to UTF-8 before parsing, so the encoding in the
+// declaration is ignored.
+func ParseXML(content []byte) (*xmlquery.Node, error) {
+	document, err := xmlquery.ParseWithOptions(bytes.NewReader(content), xmlquery.ParserOptions{Decoder: &xmlquery.DecoderOptions{
+		CharsetReader: func(label string, input io.Reader) (io.Reader, error) {
+			return input, nil
+		},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	if xmlRoot(document) == nil {
+		return nil, errors.New("document has no root element")
+	}
+	return document, nil
+}
+
+// xmlRoot returns the document's root element.
+func xmlRoot(document *xmlquery.Node) *xmlquery.Node {
+	for child := document.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == xmlquery.ElementNode {
+			return child
+		}
+	}
+	return nil
+}
+
+// xmlText returns the text of node and its descendants, with runs of whitespace collapsed and text
+// nodes separated by a space.
+func xmlText(node *xmlquery.Node) string {
+	var text strings.Builder
+	var walk func(node *xmlquery.Node)
+	walk = func(node *xmlquery.Node) {
+		if node.Type == xmlquery.TextNode || node.Type == xmlquery.CharDataNode {
+			text.WriteString(node.Data)
+			text.WriteByte(' ')
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(node)
+	return strings.Join(strings.Fields(text.String()), " ")
+}
+
+// XPath is a compiled XPath 1.0 expression, evaluated by antchfx/xpath.
+type XPath struct {
+	expr *xpath.Expr
+}
+
+func ParseXPath(input string) (*XPath, error) {
+	expr, err := xpath.Compile(strings.TrimSpace(input))
+	if err != nil {
+		return nil, err
+	}
+	return &XPath{expr: expr}, nil
+}
+
+// Values evaluates the expression against the XML or HTML document of navigator. A node-set gives the text of each
+// node in document order, and a string, number or boolean gives one value.
+func (path *XPath) Values(navigator xpath.NodeNavigator) []string {
+	switch result := path.expr.Evaluate(navigator).(type) {
+	case *xpath.NodeIterator:
+		var values []string
+		for result.MoveNext() {
+			values = append(values, nodeValue(result.Current()))
+		}
+		return values
+	case string:
+		return []string{result}
+	case float64:
+		return []string{strconv.FormatFloat(result, 'f', -1, 64)}
+	case bool:
+		return []string{strconv.FormatBool(result)}
+	}
+	return nil
+}
+
+// nodeValue returns the text of the node navigator is on.
+func nodeValue(navigator xpath.NodeNavigator) string {
+	if navigator.NodeType() != xpath.AttributeNode {
+		switch node := navigator.(type) {
+		case *xmlquery.NodeNavigator:
+			return xmlText(node.Current())
+		case *htmlquery.NodeNavigator:
+			return NodeText(node.Current())
+		}
+	}
+	return strings.Join(strings.Fields(navigator.Value()), " ")
+}